@@ -85,7 +85,7 @@ func (c *Client) callAPI(command string, callType apiType) (gjson.Result, error)
 			// If this is an auth failure and the client is using a credential script,
 			// expire the current credentials, call the script again, update the client's password,
 			// and try again
-			if errors.Is(he, errs.ErrAuthFailed) && pollerAuth.HasCredentialScript {
+			if errors.Is(he, errs.ErrAuthFailed) && pollerAuth.Refreshable {
 				c.auth.Expire()
 				return c.callWithAuthRetry(command, callType)
 			}
@@ -9,6 +9,7 @@ import (
 	"github.com/netapp/harvest/v2/cmd/poller/plugin"
 	"github.com/netapp/harvest/v2/cmd/tools/rest"
 	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/eventlog"
 	"github.com/netapp/harvest/v2/pkg/matrix"
 	"github.com/netapp/harvest/v2/pkg/set"
 	"github.com/netapp/harvest/v2/pkg/slice"
@@ -45,6 +46,9 @@ type Ems struct {
 	eventNames     []string                 // consist of all ems events supported
 	bookendEmsMap  map[string]*set.Set      // This is reverse bookend ems map, [Resolving ems]:[Set of Issuing ems]. Using Set here to ensure that it has slice of unique issuing ems
 	resolveAfter   map[string]time.Duration // This is resolve after map, [Issuing ems]:[Duration]. After this duration, ems got auto resolved.
+	receiver       *receiver                // optional listener for events pushed by ONTAP
+	logSink        eventlog.Sink            // optional sink that receives each event as a log record
+	logRecords     []eventlog.Record        // log records created during the current poll
 }
 
 type Metric struct {
@@ -117,6 +121,10 @@ func (e *Ems) Init(a *collector.AbstractCollector) error {
 		return err
 	}
 
+	if err := e.InitReceiver(); err != nil {
+		return err
+	}
+
 	return e.InitMatrix()
 }
 
@@ -136,6 +144,26 @@ func (e *Ems) InitMatrix() error {
 	return nil
 }
 
+// InitReceiver starts the optional listener for EMS events pushed by ONTAP
+func (e *Ems) InitReceiver() error {
+	var err error
+
+	r := e.Params.GetChildS("receiver")
+	if r == nil {
+		return nil
+	}
+	if e.receiver, err = newReceiver(r, e.Options.Poller, e.Logger); err != nil {
+		return err
+	}
+	for _, name := range []string{"pushReceived", "pushDropped", "pushRejected"} {
+		_, _ = e.Metadata.NewMetricUint64(name)
+	}
+	if e.Options.IsTest {
+		return nil
+	}
+	return e.receiver.start()
+}
+
 func (e *Ems) LoadPlugin(kind string, abc *plugin.AbstractPlugin) plugin.Plugin {
 	switch kind {
 	case "MetricTransformer":
@@ -203,6 +231,14 @@ func (e *Ems) InitCache() error {
 		}
 	}
 
+	if logSink := e.Params.GetChildS("log_sink"); logSink != nil {
+		sink, err := eventlog.New(logSink, e.Logger)
+		if err != nil {
+			return err
+		}
+		e.logSink = sink
+	}
+
 	// init plugins
 	if e.Plugins == nil {
 		e.Plugins = make(map[string][]plugin.Plugin)
@@ -337,6 +373,11 @@ func (e *Ems) PollInstance() (map[string]*matrix.Matrix, error) {
 	e.Logger.Debug("filtered ems events", slog.Any("skipped events", missingNames))
 	e.eventNames = filteredNames
 
+	if err := e.registerDestination(); err != nil {
+		// polling continues to work when the destination can not be registered, e.g. with a read-only user
+		e.Logger.Warn("Failed to register ems destination, events will be polled", slogx.Err(err))
+	}
+
 	// warning when total instance in cache > 1000 instance
 	for _, issuingEmsList := range e.bookendEmsMap {
 		for _, issuingEms := range issuingEmsList.Slice() {
@@ -381,7 +422,78 @@ func (e *Ems) PollData() (map[string]*matrix.Matrix, error) {
 	if err != nil {
 		return nil, err
 	}
-	toTime := clusterTime.Unix()
+
+	// When ONTAP is pushing events, skip polling and process the pushed events.
+	// Fall back to polling when no push has been received within fallback_after.
+	var (
+		pushed []gjson.Result
+		live   bool
+	)
+	var stats receiverStats
+	if e.receiver != nil {
+		pushed, live = e.receiver.drain(time.Now())
+		stats = e.receiver.stats()
+		e.Logger.Debug(
+			"ems receiver",
+			slog.Int("pushed", len(pushed)),
+			slog.Bool("live", live),
+			slog.Uint64("received", stats.received),
+			slog.Uint64("dropped", stats.dropped),
+			slog.Uint64("rejected", stats.rejected),
+		)
+	}
+
+	if live {
+		records = pushed
+	} else {
+		if records, err = e.pollEvents(clusterTime); err != nil {
+			return nil, err
+		}
+		records = mergeEvents(records, pushed)
+	}
+
+	apiD = time.Since(startTime)
+
+	startTime = time.Now()
+	_, count, instanceCount = e.HandleResults(records, e.emsProp)
+	e.logRecords = eventlog.Flush(e.logSink, e.logRecords, e.Logger)
+
+	parseD = time.Since(startTime)
+
+	dataInst := e.Metadata.MustGetInstance("data")
+	e.Metadata.MustSetValueInt64("api_time", dataInst, apiD.Microseconds())
+	e.Metadata.MustSetValueInt64("parse_time", dataInst, parseD.Microseconds())
+	e.Metadata.MustSetValueUint64("metrics", dataInst, count)
+	e.Metadata.MustSetValueUint64("instances", dataInst, instanceCount)
+
+	if e.receiver != nil {
+		e.Metadata.MustSetValueUint64("pushReceived", dataInst, stats.received)
+		e.Metadata.MustSetValueUint64("pushDropped", dataInst, stats.dropped)
+		e.Metadata.MustSetValueUint64("pushRejected", dataInst, stats.rejected)
+	}
+
+	e.AddCollectCount(count)
+
+	e.updateFilterTime(clusterTime, live, stats.lastPush, time.Now())
+	return e.Matrix, nil
+}
+
+// updateFilterTime moves the start of the next poll's time filter. After a poll, it is the current cluster time.
+// While pushes are live the cluster is not polled, and it only moves up to the cluster time of the last push,
+// so the first poll after pushes stop reads the events raised since the last push.
+func (e *Ems) updateFilterTime(clusterTime time.Time, live bool, lastPush time.Time, now time.Time) {
+	if !live {
+		e.lastFilterTime = clusterTime.Unix()
+		return
+	}
+	pushTime := clusterTime.Add(-now.Sub(lastPush)).Unix()
+	e.lastFilterTime = max(e.lastFilterTime, pushTime)
+}
+
+// pollEvents fetches the events raised since the last poll from the EMS events endpoint
+func (e *Ems) pollEvents(clusterTime time.Time) ([]gjson.Result, error) {
+	var records []gjson.Result
+
 	timeFilter := e.getTimeStampFilter(clusterTime)
 	filter := e.Filter
 	filter = append(filter, timeFilter)
@@ -413,25 +525,7 @@ func (e *Ems) PollData() (map[string]*matrix.Matrix, error) {
 		}
 		records = append(records, r...)
 	}
-
-	apiD = time.Since(startTime)
-
-	startTime = time.Now()
-	_, count, instanceCount = e.HandleResults(records, e.emsProp)
-
-	parseD = time.Since(startTime)
-
-	dataInst := e.Metadata.MustGetInstance("data")
-	e.Metadata.MustSetValueInt64("api_time", dataInst, apiD.Microseconds())
-	e.Metadata.MustSetValueInt64("parse_time", dataInst, parseD.Microseconds())
-	e.Metadata.MustSetValueUint64("metrics", dataInst, count)
-	e.Metadata.MustSetValueUint64("instances", dataInst, instanceCount)
-
-	e.AddCollectCount(count)
-
-	// update lastFilterTime to current cluster time
-	e.lastFilterTime = toTime
-	return e.Matrix, nil
+	return records, nil
 }
 
 func (e *Ems) getHref(names []string, filter []string) string {
@@ -550,6 +644,7 @@ func (e *Ems) HandleResults(result []gjson.Result, prop map[string][]*emsProp) (
 					slog.String("issuing ems", strings.Join(issuingEmsList.Slice(), ",")),
 				)
 			}
+			e.addLogRecord(instanceData, msgName)
 		} else {
 			existingEms := false
 			if _, ok := m[msgName]; !ok {
@@ -681,6 +776,7 @@ func (e *Ems) HandleResults(result []gjson.Result, prop map[string][]*emsProp) (
 				}
 				continue
 			}
+			e.addLogRecord(instanceData, msgName)
 			count += instanceLabelCount
 		}
 	}
@@ -696,6 +792,46 @@ func (e *Ems) HandleResults(result []gjson.Result, prop map[string][]*emsProp) (
 	return m, count, instanceCount
 }
 
+// addLogRecord creates a log record with the full event, including its message and all parameters
+func (e *Ems) addLogRecord(instanceData gjson.Result, msgName string) {
+	if e.logSink == nil {
+		return
+	}
+
+	eventTime, err := time.Parse(time.RFC3339, instanceData.Get("time").ClonedString())
+	if err != nil {
+		eventTime = time.Now()
+	}
+	severity := instanceData.Get("message.severity").ClonedString()
+
+	labels := map[string]string{
+		"cluster":  e.Remote.Name,
+		"message":  msgName,
+		"severity": severity,
+	}
+	if nodeName := instanceData.Get("node.name").ClonedString(); nodeName != "" {
+		labels["node"] = nodeName
+	}
+
+	params := make(map[string]string)
+	for _, p := range instanceData.Get("parameters").Array() {
+		params[p.Get("name").ClonedString()] = p.Get("value").ClonedString()
+	}
+	if index := instanceData.Get("index"); index.Exists() {
+		params["index"] = index.ClonedString()
+	}
+
+	e.logRecords = append(e.logRecords, eventlog.Record{
+		Time:       eventTime,
+		Severity:   severity,
+		Name:       msgName,
+		Source:     e.Remote.Name,
+		Message:    instanceData.Get("log_message").ClonedString(),
+		Labels:     labels,
+		Parameters: params,
+	})
+}
+
 func (e *Ems) getInstanceKeys(p *emsProp, instanceData gjson.Result) string {
 	var instanceKey strings.Builder
 	// extract instance key(s)
@@ -1,17 +1,21 @@
 package ems
 
 import (
+	"encoding/json"
 	"github.com/netapp/harvest/v2/assert"
 	"github.com/netapp/harvest/v2/cmd/collectors"
 	"github.com/netapp/harvest/v2/cmd/poller/collector"
 	"github.com/netapp/harvest/v2/cmd/poller/options"
 	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/eventlog"
 	"github.com/netapp/harvest/v2/pkg/slogx"
 	"github.com/netapp/harvest/v2/pkg/tree"
 	"github.com/netapp/harvest/v2/pkg/tree/node"
 	"log/slog"
 	"os"
+	"path/filepath"
 	"slices"
+	"strings"
 	"testing"
 	"time"
 )
@@ -216,3 +220,38 @@ func (e *Ems) testAutoResolvingEms(t *testing.T, path string) {
 	}
 	assert.False(t, slices.Contains(notAutoResolvedEmsNames, "monitor.fan.critical"))
 }
+
+func TestEmsLogSink(t *testing.T) {
+	e := NewEms()
+	path := filepath.Join(t.TempDir(), "ems.log")
+	sink := node.NewS("log_sink")
+	sink.NewChildS("type", eventlog.TypeFile)
+	sink.NewChildS("path", path)
+	logSink, err := eventlog.New(sink, e.Logger)
+	assert.Nil(t, err)
+	e.logSink = logSink
+
+	e.updateMatrix(time.Now())
+	results := collectors.JSONToGson("testdata/issuingEms.json", true)
+	e.HandleResults(results, e.emsProp)
+	e.logRecords = eventlog.Flush(e.logSink, e.logRecords, e.Logger)
+	assert.Nil(t, e.logSink.Close())
+	assert.Equal(t, len(e.logRecords), 0)
+
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Equal(t, len(lines), len(issuingEmsNames))
+
+	var records []eventlog.Record
+	for _, line := range lines {
+		var r eventlog.Record
+		assert.Nil(t, json.Unmarshal([]byte(line), &r))
+		records = append(records, r)
+	}
+	assert.Equal(t, records[0].Name, "hm.alert.raised")
+	assert.Equal(t, records[0].Severity, "alert")
+	assert.Equal(t, records[0].Parameters["alert_id"], "RaidLeftBehindAggrAlert")
+	assert.True(t, strings.HasPrefix(records[0].Message, "hm.alert.raised:"))
+	assert.Equal(t, records[0].Labels["node"], "umeng-aff300-01")
+}
@@ -0,0 +1,443 @@
+package ems
+
+import (
+	"cmp"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/netapp/harvest/v2/cmd/collectors"
+	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/slogx"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"github.com/netapp/harvest/v2/third_party/tidwall/gjson"
+)
+
+const (
+	defaultReceiverPath          = "/ems"
+	defaultReceiverFallbackAfter = 15 * time.Minute
+	maxPendingEvents             = 10_000
+	maxReceiverBodySize          = 8 << 20 // bytes
+	destinationsAPI              = "api/support/ems/destinations"
+	filtersAPI                   = "api/support/ems/filters"
+)
+
+// receiver is an HTTP(S) listener that accepts EMS events pushed by ONTAP to a rest_api destination.
+// Pushed events are buffered until the next data poll, where they are processed by HandleResults
+// exactly like events fetched from api/support/ems/events.
+type receiver struct {
+	addr          string
+	port          int
+	path          string
+	url           string // URL ONTAP posts events to
+	destination   string // name of the ONTAP EMS destination and filter
+	certFile      string
+	keyFile       string
+	clientCAFile  string // CA that signs the client certificate ONTAP presents, enables mTLS
+	token         string // shared secret ONTAP sends in the token query parameter of the destination URL
+	register      bool
+	fallbackAfter time.Duration
+	server        *http.Server
+	logger        *slog.Logger
+
+	mu         sync.Mutex
+	pending    []gjson.Result
+	lastPush   time.Time
+	received   uint64
+	dropped    uint64
+	rejected   uint64
+	registered bool
+}
+
+type receiverStats struct {
+	received uint64
+	dropped  uint64
+	rejected uint64
+	lastPush time.Time
+}
+
+// newReceiver parses the receiver section of the EMS template.
+//
+//	receiver:
+//	  addr: 0.0.0.0
+//	  port: 8443
+//	  url: https://harvest.example.com:8443/ems
+//	  cert_file: cert/harvest.crt
+//	  key_file: cert/harvest.key
+//	  token_file: cert/ems.token
+//	  destination: harvest-cluster1
+//	  register: true
+//	  fallback_after: 15m
+func newReceiver(n *node.Node, poller string, logger *slog.Logger) (*receiver, error) {
+	r := &receiver{
+		addr:          n.GetChildContentS("addr"),
+		path:          defaultReceiverPath,
+		url:           n.GetChildContentS("url"),
+		destination:   n.GetChildContentS("destination"),
+		certFile:      n.GetChildContentS("cert_file"),
+		keyFile:       n.GetChildContentS("key_file"),
+		clientCAFile:  n.GetChildContentS("client_ca_file"),
+		token:         n.GetChildContentS("token"),
+		register:      n.GetChildContentS("register") != "false",
+		fallbackAfter: defaultReceiverFallbackAfter,
+		logger:        logger.With(slog.String("ems", "receiver")),
+	}
+
+	port := n.GetChildContentS("port")
+	if port == "" {
+		return nil, errs.New(errs.ErrMissingParam, "receiver.port")
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 0 || p > 65535 {
+		return nil, errs.New(errs.ErrInvalidParam, "receiver.port ("+port+")")
+	}
+	r.port = p
+
+	if (r.certFile == "") != (r.keyFile == "") {
+		return nil, errs.New(errs.ErrInvalidParam, "receiver requires both cert_file and key_file for TLS")
+	}
+
+	if f := n.GetChildContentS("token_file"); f != "" {
+		if r.token != "" {
+			return nil, errs.New(errs.ErrInvalidParam, "receiver accepts token or token_file, not both")
+		}
+		b, err := os.ReadFile(f)
+		if err != nil {
+			return nil, errs.New(errs.ErrInvalidParam, "receiver.token_file: "+err.Error())
+		}
+		r.token = strings.TrimSpace(string(b))
+		if r.token == "" {
+			return nil, errs.New(errs.ErrInvalidParam, "receiver.token_file "+f+" is empty")
+		}
+	}
+
+	if r.clientCAFile != "" && r.keyFile == "" {
+		return nil, errs.New(errs.ErrInvalidParam, "receiver.client_ca_file requires cert_file and key_file")
+	}
+
+	// anyone who can reach the listener could push fake events
+	if r.token == "" && r.clientCAFile == "" {
+		return nil, errs.New(errs.ErrMissingParam, "receiver requires token, token_file, or client_ca_file to authenticate ONTAP")
+	}
+
+	if p := n.GetChildContentS("path"); p != "" {
+		r.path = "/" + strings.TrimPrefix(p, "/")
+	}
+
+	if r.destination == "" {
+		r.destination = "harvest-" + poller
+	}
+
+	if r.register && r.url == "" {
+		return nil, errs.New(errs.ErrMissingParam, "receiver.url is required when register is enabled")
+	}
+
+	if f := n.GetChildContentS("fallback_after"); f != "" {
+		d, err := time.ParseDuration(f)
+		if err != nil {
+			return nil, errs.New(errs.ErrInvalidParam, "receiver.fallback_after ("+f+"): "+err.Error())
+		}
+		r.fallbackAfter = d
+	}
+
+	return r, nil
+}
+
+// start begins listening for pushed events in the background.
+func (r *receiver) start() error {
+	mux := http.NewServeMux()
+	mux.HandleFunc(r.path, r.handlePush)
+
+	listener, err := net.Listen("tcp", net.JoinHostPort(r.addr, strconv.Itoa(r.port)))
+	if err != nil {
+		return fmt.Errorf("ems receiver failed to listen: %w", err)
+	}
+
+	r.server = &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 60 * time.Second,
+	}
+
+	if r.clientCAFile != "" {
+		pem, err := os.ReadFile(r.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("ems receiver failed to read client_ca_file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("ems receiver client_ca_file %s has no PEM certificates", r.clientCAFile)
+		}
+		r.server.TLSConfig = &tls.Config{
+			ClientCAs:  pool,
+			ClientAuth: tls.RequireAndVerifyClientCert,
+			MinVersion: tls.VersionTLS12,
+		}
+	}
+
+	r.logger.Info(
+		"server listen",
+		slog.String("addr", listener.Addr().String()),
+		slog.String("path", r.path),
+		slog.Bool("tls", r.keyFile != ""),
+		slog.Bool("mtls", r.clientCAFile != ""),
+	)
+
+	go func() {
+		var err error
+		if r.keyFile != "" {
+			err = r.server.ServeTLS(listener, r.certFile, r.keyFile)
+		} else {
+			err = r.server.Serve(listener)
+		}
+		if err != nil && !errors.Is(err, http.ErrServerClosed) {
+			r.logger.Error("receiver stopped", slogx.Err(err))
+		}
+	}()
+
+	return nil
+}
+
+func (r *receiver) handlePush(w http.ResponseWriter, req *http.Request) {
+	if req.Method != http.MethodPost {
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !r.authorized(req) {
+		r.mu.Lock()
+		r.rejected++
+		r.mu.Unlock()
+		r.logger.Warn("rejected unauthenticated push", slog.String("remote_addr", req.RemoteAddr))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(req.Body, maxReceiverBodySize))
+	if err != nil {
+		r.logger.Warn("failed to read pushed event", slogx.Err(err), slog.String("remote_addr", req.RemoteAddr))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	records, err := parsePushedEvents(body)
+	if err != nil {
+		r.logger.Warn("invalid pushed event", slogx.Err(err), slog.String("remote_addr", req.RemoteAddr))
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	r.add(records, time.Now())
+	w.WriteHeader(http.StatusOK)
+}
+
+// authorized checks the shared token, sent as the token query parameter or as a bearer token.
+// Client certificates are verified by the TLS handshake.
+func (r *receiver) authorized(req *http.Request) bool {
+	if r.token == "" {
+		return true
+	}
+	token := req.URL.Query().Get("token")
+	if bearer, ok := strings.CutPrefix(req.Header.Get("Authorization"), "Bearer "); ok {
+		token = bearer
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(r.token)) == 1
+}
+
+// destinationURL is the URL registered with ONTAP. ONTAP can not send headers, so the token is a query parameter.
+func (r *receiver) destinationURL() (string, error) {
+	if r.token == "" {
+		return r.url, nil
+	}
+	u, err := url.Parse(r.url)
+	if err != nil {
+		return "", errs.New(errs.ErrInvalidParam, "receiver.url ("+r.url+"): "+err.Error())
+	}
+	q := u.Query()
+	q.Set("token", r.token)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// parsePushedEvents accepts a single EMS event, an array of events, or a response shaped like
+// api/support/ems/events with a records array.
+func parsePushedEvents(body []byte) ([]gjson.Result, error) {
+	if !gjson.ValidBytes(body) {
+		return nil, errors.New("body is not valid JSON")
+	}
+	// Clone the body since gjson results reference the underlying bytes
+	result := gjson.Parse(string(body))
+	switch {
+	case result.IsArray():
+		return result.Array(), nil
+	case result.Get("records").IsArray():
+		return result.Get("records").Array(), nil
+	case result.IsObject():
+		return []gjson.Result{result}, nil
+	default:
+		return nil, errors.New("expected an EMS event object or array")
+	}
+}
+
+func (r *receiver) add(records []gjson.Result, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.lastPush = now
+	r.received += uint64(len(records))
+	r.pending = append(r.pending, records...)
+	if over := len(r.pending) - maxPendingEvents; over > 0 {
+		r.pending = r.pending[over:]
+		r.dropped += uint64(over) //nolint:gosec
+		r.logger.Warn("pending event buffer is full, dropping oldest events", slog.Int("dropped", over))
+	}
+}
+
+// drain returns the buffered events sorted by index and reports whether pushes are considered live.
+// When no push has been received within fallbackAfter, the caller should poll the cluster instead.
+func (r *receiver) drain(now time.Time) ([]gjson.Result, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	records := r.pending
+	r.pending = nil
+	live := !r.lastPush.IsZero() && now.Sub(r.lastPush) < r.fallbackAfter
+
+	// process events in the order ONTAP raised them so resolving events are handled after issuing ones
+	slices.SortStableFunc(records, func(a, b gjson.Result) int {
+		return cmp.Compare(a.Get("index").Int(), b.Get("index").Int())
+	})
+
+	return records, live
+}
+
+func (r *receiver) stats() receiverStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return receiverStats{received: r.received, dropped: r.dropped, rejected: r.rejected, lastPush: r.lastPush}
+}
+
+// mergeEvents combines polled and pushed events, removes duplicates by index, and sorts them by index.
+func mergeEvents(polled []gjson.Result, pushed []gjson.Result) []gjson.Result {
+	if len(pushed) == 0 {
+		return polled
+	}
+	seen := make(map[int64]struct{}, len(polled))
+	merged := make([]gjson.Result, 0, len(polled)+len(pushed))
+	for _, records := range [][]gjson.Result{polled, pushed} {
+		for _, record := range records {
+			index := record.Get("index")
+			if index.Exists() {
+				if _, ok := seen[index.Int()]; ok {
+					continue
+				}
+				seen[index.Int()] = struct{}{}
+			}
+			merged = append(merged, record)
+		}
+	}
+	slices.SortStableFunc(merged, func(a, b gjson.Result) int {
+		return cmp.Compare(a.Get("index").Int(), b.Get("index").Int())
+	})
+	return merged
+}
+
+// registerDestination creates the ONTAP EMS filter and rest_api destination that push the collected
+// events to this receiver. Existing filters and destinations with the same name are left untouched.
+func (e *Ems) registerDestination() error {
+	r := e.receiver
+	if r == nil || !r.register || r.registered || len(e.eventNames) == 0 {
+		return nil
+	}
+
+	exists, err := e.emsObjectExists(filtersAPI, r.destination)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		body, err := json.Marshal(e.filterBody())
+		if err != nil {
+			return err
+		}
+		if _, err := e.Client.PostRest(&e.RequestMetadata, filtersAPI, body); err != nil {
+			return fmt.Errorf("failed to create ems filter %s: %w", r.destination, err)
+		}
+		r.logger.Info("created ems filter", slog.String("name", r.destination), slog.Int("events", len(e.eventNames)))
+	}
+
+	exists, err = e.emsObjectExists(destinationsAPI, r.destination)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		destination, err := r.destinationURL()
+		if err != nil {
+			return err
+		}
+		body, err := json.Marshal(map[string]any{
+			"name":        r.destination,
+			"type":        "rest_api",
+			"destination": destination,
+			"filters":     []map[string]string{{"name": r.destination}},
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := e.Client.PostRest(&e.RequestMetadata, destinationsAPI, body); err != nil {
+			return fmt.Errorf("failed to create ems destination %s: %w", r.destination, err)
+		}
+		r.logger.Info("created ems destination", slog.String("name", r.destination), slog.String("url", r.url))
+	}
+
+	r.registered = true
+	return nil
+}
+
+func (e *Ems) emsObjectExists(api string, name string) (bool, error) {
+	href := rest.NewHrefBuilder().
+		APIPath(api).
+		Fields([]string{"name"}).
+		Filter([]string{"name=" + name}).
+		MaxRecords(collectors.DefaultBatchSize).
+		ReturnTimeout(e.ReturnTimeOut).
+		Build()
+	records, err := e.GetRestData(href)
+	if err != nil {
+		return false, err
+	}
+	return len(records) > 0, nil
+}
+
+// filterBody builds an EMS filter with one include rule per collected event and the configured severities
+func (e *Ems) filterBody() map[string]any {
+	severities := strings.ReplaceAll(strings.TrimPrefix(e.severityFilter, severityFilterPrefix), "|", ",")
+	rules := make([]map[string]any, 0, len(e.eventNames))
+	for i, name := range e.eventNames {
+		rules = append(rules, map[string]any{
+			"index": i + 1,
+			"type":  "include",
+			"message_criteria": map[string]string{
+				"name_pattern": name,
+				"severities":   severities,
+			},
+		})
+	}
+	return map[string]any{
+		"name":  e.receiver.destination,
+		"rules": rules,
+	}
+}
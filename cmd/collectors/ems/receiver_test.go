@@ -0,0 +1,200 @@
+package ems
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/netapp/harvest/v2/assert"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"github.com/netapp/harvest/v2/third_party/tidwall/gjson"
+)
+
+func newTestReceiver(t *testing.T) *receiver {
+	t.Helper()
+	n := node.NewS("receiver")
+	n.NewChildS("port", "0")
+	n.NewChildS("register", "false")
+	n.NewChildS("fallback_after", "1m")
+	n.NewChildS("token", "secret")
+	r, err := newReceiver(n, "testEms", slog.Default())
+	if err != nil {
+		t.Fatalf("failed to create receiver: %v", err)
+	}
+	return r
+}
+
+func TestNewReceiver(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  map[string]string
+		wantErr bool
+	}{
+		{name: "missing port", params: map[string]string{"register": "false"}, wantErr: true},
+		{name: "invalid port", params: map[string]string{"port": "abc", "register": "false"}, wantErr: true},
+		{name: "register without url", params: map[string]string{"port": "8443"}, wantErr: true},
+		{name: "cert without key", params: map[string]string{"port": "8443", "register": "false", "cert_file": "a.crt"}, wantErr: true},
+		{name: "invalid fallback", params: map[string]string{"port": "8443", "register": "false", "fallback_after": "soon"}, wantErr: true},
+		{name: "no authentication", params: map[string]string{"port": "8443", "register": "false"}, wantErr: true},
+		{name: "client ca without tls", params: map[string]string{"port": "8443", "register": "false", "client_ca_file": "ca.pem"}, wantErr: true},
+		{name: "token and token file", params: map[string]string{"port": "8443", "register": "false", "token": "a", "token_file": "a"}, wantErr: true},
+		{name: "missing token file", params: map[string]string{"port": "8443", "register": "false", "token_file": "testdata/missing.token"}, wantErr: true},
+		{name: "valid", params: map[string]string{"port": "8443", "url": "https://harvest:8443/ems", "token": "secret"}},
+		{name: "valid mtls", params: map[string]string{"port": "8443", "url": "https://harvest:8443/ems", "cert_file": "a.crt", "key_file": "a.key", "client_ca_file": "ca.pem"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := node.NewS("receiver")
+			for k, v := range tt.params {
+				n.NewChildS(k, v)
+			}
+			r, err := newReceiver(n, "testEms", slog.Default())
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Equal(t, r.destination, "harvest-testEms")
+			assert.Equal(t, r.path, defaultReceiverPath)
+			assert.Equal(t, r.fallbackAfter, defaultReceiverFallbackAfter)
+		})
+	}
+}
+
+func TestReceiverPush(t *testing.T) {
+	r := newTestReceiver(t)
+
+	body, err := os.ReadFile("testdata/issuingEms.json")
+	assert.Nil(t, err)
+
+	// pushes without the token are rejected
+	for _, path := range []string{defaultReceiverPath, defaultReceiverPath + "?token=wrong"} {
+		req := httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body))
+		w := httptest.NewRecorder()
+		r.handlePush(w, req)
+		assert.Equal(t, w.Code, http.StatusUnauthorized)
+	}
+	assert.Equal(t, r.stats().rejected, uint64(2))
+	assert.Equal(t, r.stats().received, uint64(0))
+
+	req := httptest.NewRequest(http.MethodPost, defaultReceiverPath+"?token=secret", bytes.NewReader(body))
+	w := httptest.NewRecorder()
+	r.handlePush(w, req)
+	assert.Equal(t, w.Code, http.StatusOK)
+
+	req = httptest.NewRequest(http.MethodPost, defaultReceiverPath, bytes.NewReader([]byte("not json")))
+	req.Header.Set("Authorization", "Bearer secret")
+	w = httptest.NewRecorder()
+	r.handlePush(w, req)
+	assert.Equal(t, w.Code, http.StatusBadRequest)
+
+	req = httptest.NewRequest(http.MethodGet, defaultReceiverPath, nil)
+	w = httptest.NewRecorder()
+	r.handlePush(w, req)
+	assert.Equal(t, w.Code, http.StatusMethodNotAllowed)
+
+	records, live := r.drain(time.Now())
+	assert.True(t, live)
+	assert.Equal(t, len(records), len(gjson.GetBytes(body, "records").Array()))
+	for i := 1; i < len(records); i++ {
+		assert.True(t, records[i-1].Get("index").Int() <= records[i].Get("index").Int())
+	}
+
+	// buffer is empty after drain
+	records, _ = r.drain(time.Now())
+	assert.Equal(t, len(records), 0)
+
+	// pushes are stale after fallback_after
+	_, live = r.drain(time.Now().Add(2 * time.Minute))
+	assert.False(t, live)
+}
+
+func TestReceiverHandleResults(t *testing.T) {
+	e := NewEms()
+	e.receiver = newTestReceiver(t)
+
+	body, err := os.ReadFile("testdata/issuingEms.json")
+	assert.Nil(t, err)
+	records, err := parsePushedEvents(body)
+	assert.Nil(t, err)
+	e.receiver.add(records, time.Now())
+
+	e.updateMatrix(time.Now())
+	pushed, live := e.receiver.drain(time.Now())
+	assert.True(t, live)
+
+	// pushed events are processed the same way as polled events
+	_, emsCount, _ := e.HandleResults(pushed, e.emsProp)
+	assert.Equal(t, emsCount, uint64(expectedInstanceLabelCount))
+}
+
+func TestReceiverFallbackFilter(t *testing.T) {
+	e := NewEms()
+	e.receiver = newTestReceiver(t)
+	clusterTime := time.Unix(10_000, 0)
+	now := time.Now()
+
+	// the first poll happens before any push
+	_, live := e.receiver.drain(now)
+	assert.False(t, live)
+	e.updateFilterTime(clusterTime, live, e.receiver.stats().lastPush, now)
+	assert.Equal(t, e.getTimeStampFilter(clusterTime), "time=>=10000")
+
+	// a push 30s later makes the receiver live, polls follow the last push
+	e.receiver.add(nil, now.Add(30*time.Second))
+	_, live = e.receiver.drain(now.Add(40 * time.Second))
+	assert.True(t, live)
+	e.updateFilterTime(clusterTime.Add(40*time.Second), live, e.receiver.stats().lastPush, now.Add(40*time.Second))
+	assert.Equal(t, e.lastFilterTime, int64(10_030))
+
+	// pushes stop, live polls do not move the filter past the last push
+	_, live = e.receiver.drain(now.Add(80 * time.Second))
+	assert.True(t, live)
+	e.updateFilterTime(clusterTime.Add(80*time.Second), live, e.receiver.stats().lastPush, now.Add(80*time.Second))
+	assert.Equal(t, e.lastFilterTime, int64(10_030))
+
+	// the fallback poll reads the events raised since the last push
+	fallback := now.Add(2 * time.Minute)
+	_, live = e.receiver.drain(fallback)
+	assert.False(t, live)
+	assert.Equal(t, e.getTimeStampFilter(clusterTime.Add(2*time.Minute)), "time=>=10030")
+	e.updateFilterTime(clusterTime.Add(2*time.Minute), live, e.receiver.stats().lastPush, fallback)
+	assert.Equal(t, e.lastFilterTime, int64(10_120))
+}
+
+func TestDestinationURL(t *testing.T) {
+	r := newTestReceiver(t)
+	r.url = "https://harvest.example.com:8443/ems"
+	u, err := r.destinationURL()
+	assert.Nil(t, err)
+	assert.Equal(t, u, "https://harvest.example.com:8443/ems?token=secret")
+}
+
+func TestMergeEvents(t *testing.T) {
+	polled := gjson.Parse(`[{"index": 3}, {"index": 1}]`).Array()
+	pushed := gjson.Parse(`[{"index": 2}, {"index": 3}]`).Array()
+
+	merged := mergeEvents(polled, pushed)
+	assert.Equal(t, len(merged), 3)
+	for i, want := range []int64{1, 2, 3} {
+		assert.Equal(t, merged[i].Get("index").Int(), want)
+	}
+}
+
+func TestFilterBody(t *testing.T) {
+	e := NewEms()
+	e.receiver = newTestReceiver(t)
+	e.eventNames = []string{"LUN.offline", "LUN.online"}
+
+	body := e.filterBody()
+	assert.Equal(t, body["name"].(string), "harvest-testEms")
+	rules := body["rules"].([]map[string]any)
+	assert.Equal(t, len(rules), 2)
+	criteria := rules[1]["message_criteria"].(map[string]string)
+	assert.Equal(t, criteria["name_pattern"], "LUN.online")
+	assert.Equal(t, criteria["severities"], "alert,emergency,error,informational,notice")
+}
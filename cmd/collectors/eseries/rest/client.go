@@ -296,7 +296,7 @@ func (c *Client) get(endpoint string, headers ...map[string]string) ([]gjson.Res
 				// If this is an auth failure and the client is using a credential script,
 				// expire the current credentials, call the script again, update the credentials,
 				// and try again
-				if pollerAuth.HasCredentialScript {
+				if pollerAuth.Refreshable {
 					c.Logger.Debug("Expiring cached credential script credentials after 401 response")
 					c.auth.Expire()
 					c.Logger.Debug("Retrying request with refreshed credentials from script")
@@ -402,7 +402,7 @@ func (c *Client) Post(endpoint string, body []byte, headers ...map[string]string
 				if err2 != nil {
 					return nil, err2
 				}
-				if pollerAuth.HasCredentialScript {
+				if pollerAuth.Refreshable {
 					c.Logger.Debug("Expiring cached credential script credentials after 401 response")
 					c.auth.Expire()
 					c.Logger.Debug("Retrying POST with refreshed credentials from script")
@@ -0,0 +1,354 @@
+// Package eseriesevents collects the E-Series Major Event Log (MEL).
+// Each poll reads the events logged since the last seen sequence number and turns the events that match
+// the template into metrics and, optionally, log records.
+package eseriesevents
+
+import (
+	"log/slog"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/netapp/harvest/v2/cmd/collectors"
+	"github.com/netapp/harvest/v2/cmd/collectors/eseries"
+	"github.com/netapp/harvest/v2/cmd/collectors/eseries/rest"
+	"github.com/netapp/harvest/v2/cmd/poller/collector"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/eventlog"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/slogx"
+	"github.com/netapp/harvest/v2/pkg/statefile"
+	"github.com/netapp/harvest/v2/third_party/tidwall/gjson"
+)
+
+const (
+	defaultDataPollDuration = 3 * time.Minute
+	defaultBatchSize        = 1000
+	defaultStatePath        = "eseriesevents"
+	sequenceNumberField     = "sequenceNumber"
+	timeStampField          = "timeStamp"
+)
+
+type EseriesEvents struct {
+	*eseries.ESeries
+	events       []*eventProp
+	batchSize    int
+	criticalOnly bool
+	lastSequence int64     // sequence number of the newest event seen, -1 before the first poll
+	cutoff       time.Time // events older than the cutoff are history and are not reported, set by the first poll
+	stateDir     string
+	statePath    string // file of the last seen sequence number, set once the array is discovered
+	logSink      eventlog.Sink
+	logRecords   []eventlog.Record
+}
+
+// state is what the collector keeps across poller restarts
+type state struct {
+	LastSequence int64 `json:"last_sequence"`
+}
+
+func init() {
+	plugin.RegisterModule(&EseriesEvents{})
+}
+
+func (e *EseriesEvents) HarvestModule() plugin.ModuleInfo {
+	return plugin.ModuleInfo{
+		ID:  "harvest.collector.eseriesevents",
+		New: func() plugin.Module { return new(EseriesEvents) },
+	}
+}
+
+func (e *EseriesEvents) Init(a *collector.AbstractCollector) error {
+	var err error
+
+	e.ESeries = &eseries.ESeries{AbstractCollector: a}
+	e.lastSequence = -1
+
+	e.InitProp()
+
+	if e.Prop.TemplatePath, err = e.LoadTemplate(); err != nil {
+		return err
+	}
+
+	if err := e.ParseTemplate(); err != nil {
+		return err
+	}
+
+	if err := e.InitClient(); err != nil {
+		return err
+	}
+
+	if e.Options.IsTest {
+		e.Matrix = make(map[string]*matrix.Matrix)
+		e.Matrix[e.Object] = matrix.New(e.Name, e.Object, e.Object)
+	} else {
+		if err := collector.Init(e); err != nil {
+			return err
+		}
+	}
+
+	e.InitMatrix()
+
+	e.Logger.Debug(
+		"initialized",
+		slog.Int("numEvents", len(e.events)),
+		slog.String("object", e.Prop.Object),
+		slog.Int("batchSize", e.batchSize),
+		slog.Bool("criticalOnly", e.criticalOnly),
+	)
+
+	return nil
+}
+
+func (e *EseriesEvents) InitMatrix() {
+	e.ESeries.InitMatrix()
+	// events are exported with all of their labels
+	e.Matrix[e.Object].SetExportOptions(nil)
+}
+
+// PollData fetches the MEL entries logged since the previous poll.
+// Without a saved sequence number, only events newer than one data interval before the first poll are reported.
+// Older entries, including the ones read by later polls while catching up on a log larger than batch_size, are skipped.
+func (e *EseriesEvents) PollData() (map[string]*matrix.Matrix, error) {
+	if e.GetArray() == "" {
+		return nil, errs.New(errs.ErrNoInstance, "array not discovered")
+	}
+
+	if e.statePath == "" {
+		e.loadState()
+	}
+
+	mat := e.Matrix[e.Object]
+	mat.PurgeInstances()
+
+	apiStart := time.Now()
+	results, err := e.Client.Fetch(e.Client.APIPath+"/"+e.query(), nil)
+	apiTime := time.Since(apiStart)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(results) >= e.batchSize {
+		e.Logger.Info(
+			"batch size reached, remaining events are collected next poll",
+			slog.Int("batchSize", e.batchSize),
+		)
+	}
+
+	parseStart := time.Now()
+	previous := e.lastSequence
+	count := e.HandleResults(results, apiStart)
+	parseTime := time.Since(parseStart)
+
+	if e.lastSequence != previous {
+		e.saveState()
+	}
+
+	e.logRecords = eventlog.Flush(e.logSink, e.logRecords, e.Logger)
+
+	dataInst := e.Metadata.MustGetInstance("data")
+	e.Metadata.MustSetValueInt64("api_time", dataInst, apiTime.Microseconds())
+	e.Metadata.MustSetValueInt64("parse_time", dataInst, parseTime.Microseconds())
+	e.Metadata.MustSetValueUint64("metrics", dataInst, count)
+	e.Metadata.MustSetValueUint64("instances", dataInst, uint64(len(mat.GetInstances())))
+	e.Metadata.MustSetValueUint64("bytesRx", dataInst, e.Client.Metadata.BytesRx.Load())
+	e.Metadata.MustSetValueUint64("numCalls", dataInst, e.Client.Metadata.NumCalls.Load())
+	e.AddCollectCount(count)
+
+	if len(mat.GetInstances()) == 0 {
+		return nil, errs.New(errs.ErrNoInstance, "no events found")
+	}
+
+	return e.Matrix, nil
+}
+
+// loadState restores the last seen sequence number of the array. Events logged while the poller was down are
+// reported by the next poll, so the cut-off of the first poll does not apply.
+func (e *EseriesEvents) loadState() {
+	e.statePath = statefile.Path(e.stateDir, e.Options.Poller, e.GetArray())
+
+	st := state{LastSequence: -1}
+	if err := statefile.Load(e.statePath, &st); err != nil {
+		e.Logger.Warn("Failed to load state, starting from the last data interval", slogx.Err(err), slog.String("path", e.statePath))
+		return
+	}
+	if st.LastSequence >= 0 {
+		e.lastSequence = st.LastSequence
+		e.cutoff = time.Unix(0, 0)
+	}
+}
+
+func (e *EseriesEvents) saveState() {
+	if err := statefile.Save(e.statePath, state{LastSequence: e.lastSequence}); err != nil {
+		e.Logger.Warn("Failed to save state", slogx.Err(err), slog.String("path", e.statePath))
+	}
+}
+
+func (e *EseriesEvents) query() string {
+	filter := []string{"count=" + strconv.Itoa(e.batchSize)}
+	if e.lastSequence >= 0 {
+		filter = append(filter, "startSequenceNumber="+strconv.FormatInt(e.lastSequence+1, 10))
+	}
+	if e.criticalOnly {
+		filter = append(filter, "critical=true")
+	}
+	return rest.NewURLBuilder().
+		APIPath(e.Prop.Query).
+		ArrayID(e.GetArray()).
+		Filter(filter).
+		Build()
+}
+
+// HandleResults adds the matching MEL entries and advances the last seen sequence number.
+// Events with the same labels are one instance, and the events metric is the number of these events in this poll.
+// It returns the number of metrics added.
+func (e *EseriesEvents) HandleResults(results []gjson.Result, now time.Time) uint64 {
+	var count uint64
+
+	mat := e.Matrix[e.Object]
+	newest := e.lastSequence
+	if e.cutoff.IsZero() {
+		e.cutoff = e.oldestEventTime(now)
+	}
+
+	metr := mat.GetMetric("events")
+	if metr == nil {
+		var err error
+		if metr, err = mat.NewMetricFloat64("events"); err != nil {
+			e.Logger.Error("NewMetricFloat64", slogx.Err(err), slog.String("name", "events"))
+			return 0
+		}
+	}
+
+	for _, event := range results {
+		if !event.IsObject() {
+			e.Logger.Warn("event is not object, skipping", slog.String("type", event.Type.String()))
+			continue
+		}
+
+		seq := event.Get(sequenceNumberField).Int()
+		if seq <= e.lastSequence {
+			continue
+		}
+		newest = max(newest, seq)
+		if eventTime(event).Before(e.cutoff) {
+			continue
+		}
+
+		prop := e.match(event)
+		if prop == nil {
+			continue
+		}
+
+		labels := map[string]string{"event": prop.Name}
+		for name, display := range prop.InstanceLabels {
+			if value := event.Get(name); value.Exists() {
+				labels[display] = value.ClonedString()
+			}
+		}
+		maps.Copy(labels, prop.Labels)
+
+		key := instanceKey(labels)
+		instance := mat.GetInstance(key)
+		if instance == nil {
+			var err error
+			if instance, err = mat.NewInstance(key); err != nil {
+				e.Logger.Warn("failed to create instance", slogx.Err(err), slog.String("key", key))
+				continue
+			}
+			instance.SetLabels(labels)
+			metr.SetValueFloat64(instance, 1)
+			count++
+		} else {
+			metr.AddValueFloat64(instance, 1)
+		}
+
+		e.addLogRecord(event, labels)
+	}
+
+	e.lastSequence = newest
+	return count
+}
+
+// instanceKey returns a key that is the same for events with the same labels
+func instanceKey(labels map[string]string) string {
+	keys := slices.Sorted(maps.Keys(labels))
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+labels[k])
+	}
+	return strings.Join(parts, ",")
+}
+
+// match returns the first event rule matching the MEL entry, or nil when none does
+func (e *EseriesEvents) match(event gjson.Result) *eventProp {
+	for _, p := range e.events {
+		if p.matches(event) {
+			return p
+		}
+	}
+	return nil
+}
+
+func (e *EseriesEvents) oldestEventTime(now time.Time) time.Time {
+	dataDuration, err := collectors.GetDataInterval(e.GetParams(), defaultDataPollDuration)
+	if err != nil {
+		e.Logger.Warn(
+			"Failed to parse duration. using default",
+			slogx.Err(err),
+			slog.String("defaultDataPollDuration", defaultDataPollDuration.String()),
+		)
+	}
+	return now.Add(-dataDuration)
+}
+
+// eventTime returns the time of a MEL entry. SANtricity reports it as seconds since the epoch.
+func eventTime(event gjson.Result) time.Time {
+	return time.Unix(event.Get(timeStampField).Int(), 0)
+}
+
+func (e *EseriesEvents) addLogRecord(event gjson.Result, labels map[string]string) {
+	if e.logSink == nil {
+		return
+	}
+
+	array := e.Params.GetChildContentS("array")
+	severity := labels["severity"]
+	if severity == "" {
+		severity = event.Get("priority").ClonedString()
+	}
+
+	recordLabels := map[string]string{
+		"array":    array,
+		"event":    labels["event"],
+		"severity": severity,
+	}
+	if component := labels["component"]; component != "" {
+		recordLabels["component"] = component
+	}
+
+	// the sequence number identifies the event, it is only part of the log record and not of the metric labels
+	params := map[string]string{"sequence_number": event.Get(sequenceNumberField).ClonedString()}
+	for k, v := range labels {
+		if _, ok := recordLabels[k]; !ok {
+			params[k] = v
+		}
+	}
+
+	e.logRecords = append(e.logRecords, eventlog.Record{
+		Time:       eventTime(event),
+		Severity:   severity,
+		Name:       labels["event"],
+		Source:     array,
+		Message:    strings.TrimSpace(event.Get("description").ClonedString()),
+		Labels:     recordLabels,
+		Parameters: params,
+	})
+}
+
+var (
+	_ collector.Collector = (*EseriesEvents)(nil)
+)
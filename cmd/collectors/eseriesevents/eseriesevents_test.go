@@ -0,0 +1,208 @@
+package eseriesevents
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/netapp/harvest/v2/assert"
+	"github.com/netapp/harvest/v2/cmd/poller/collector"
+	"github.com/netapp/harvest/v2/cmd/poller/options"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/eventlog"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"github.com/netapp/harvest/v2/third_party/tidwall/gjson"
+)
+
+const pollerName = "test"
+
+// time of the newest event in testdata/mel-events.json plus a few seconds
+var pollTime = time.Unix(1715287200, 0)
+
+func TestMain(m *testing.M) {
+	conf.TestLoadHarvestConfig("testdata/config.yml")
+	os.Exit(m.Run())
+}
+
+func params() *node.Node {
+	yml := `
+schedule:
+  - counter: 24h
+  - data: 3m
+objects:
+  MEL: mel.yaml
+`
+	root, err := tree.LoadYaml([]byte(yml))
+	if err != nil {
+		panic(err)
+	}
+	return root
+}
+
+func newEseriesEvents(t *testing.T) *EseriesEvents {
+	t.Helper()
+	opts := options.New(options.WithConfPath("../../../conf"))
+	opts.Poller = pollerName
+	opts.HomePath = "testdata"
+	opts.IsTest = true
+
+	ac := collector.New("EseriesEvents", "MEL", opts, params(), nil, conf.Remote{})
+	e := &EseriesEvents{}
+	if err := e.Init(ac); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func melEvents(t *testing.T) []gjson.Result {
+	t.Helper()
+	data, err := os.ReadFile("testdata/mel-events.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return gjson.ParseBytes(data).Array()
+}
+
+func TestHandleResults(t *testing.T) {
+	e := newEseriesEvents(t)
+	mat := e.Matrix[e.Object]
+	events := melEvents(t)
+
+	// first poll only reports events from the last data interval
+	count := e.HandleResults(events, pollTime)
+	assert.Equal(t, count, uint64(3))
+	assert.Equal(t, e.lastSequence, int64(40215))
+
+	want := map[string]string{
+		"drive_failure":      "drive",
+		"controller_failure": "controller",
+		"battery_failure":    "battery",
+	}
+	assert.Equal(t, len(mat.GetInstances()), len(want))
+	for event, component := range want {
+		instance := instanceOf(mat, event)
+		assert.NotNil(t, instance)
+		assert.Equal(t, instance.GetLabel("component"), component)
+		assert.Equal(t, instance.GetLabel("severity"), "critical")
+		assert.Equal(t, instance.GetLabel("sequence_number"), "")
+		assert.Equal(t, instance.GetLabel("description"), "")
+	}
+
+	drive := instanceOf(mat, "drive_failure")
+	assert.Equal(t, drive.GetLabel("location"), "Tray 0, Slot 12")
+	assert.Equal(t, drive.GetLabel("event_type"), "0x100a")
+	value, ok := mat.GetMetric("events").GetValueFloat64(drive)
+	assert.True(t, ok)
+	assert.Equal(t, value, 1.0)
+
+	// events that were already seen are skipped
+	mat.PurgeInstances()
+	assert.Equal(t, e.HandleResults(events, pollTime.Add(3*time.Minute)), uint64(0))
+
+	// later polls skip events older than the first poll's cut-off, and count events with the same labels
+	newEvents := gjson.Parse(`[
+		{"sequenceNumber":"40216","timeStamp":"1715280000","priority":"critical","componentType":"fan","description":"Fan failed"},
+		{"sequenceNumber":"40217","timeStamp":"1715287300","priority":"critical","componentType":"fan","description":"Fan failed"},
+		{"sequenceNumber":"40218","timeStamp":"1715287310","priority":"critical","componentType":"fan","description":"Fan failed again"}
+	]`).Array()
+	assert.Equal(t, e.HandleResults(newEvents, pollTime.Add(6*time.Minute)), uint64(1))
+	assert.Equal(t, len(mat.GetInstances()), 1)
+	fan := instanceOf(mat, "fan_failure")
+	assert.NotNil(t, fan)
+	value, ok = mat.GetMetric("events").GetValueFloat64(fan)
+	assert.True(t, ok)
+	assert.Equal(t, value, 2.0)
+	assert.Equal(t, e.lastSequence, int64(40218))
+}
+
+func TestStateIsPersisted(t *testing.T) {
+	dir := t.TempDir()
+	e := newEseriesEvents(t)
+	e.stateDir = dir
+	e.loadState()
+	assert.Equal(t, e.lastSequence, int64(-1))
+	e.HandleResults(melEvents(t), pollTime)
+	e.saveState()
+
+	restarted := newEseriesEvents(t)
+	restarted.stateDir = dir
+	restarted.loadState()
+	assert.Equal(t, restarted.lastSequence, int64(40215))
+
+	// events logged while the poller was down are reported, however old
+	mat := restarted.Matrix[restarted.Object]
+	newEvents := gjson.Parse(`[
+		{"sequenceNumber":"40215","timeStamp":"1715287100","priority":"critical","componentType":"battery"},
+		{"sequenceNumber":"40216","timeStamp":"1715280000","priority":"critical","componentType":"fan"}
+	]`).Array()
+	assert.Equal(t, restarted.HandleResults(newEvents, pollTime.Add(time.Hour)), uint64(1))
+	assert.Equal(t, len(mat.GetInstances()), 1)
+	assert.NotNil(t, instanceOf(mat, "fan_failure"))
+}
+
+// instanceOf returns the instance of the named event, or nil when there is none
+func instanceOf(mat *matrix.Matrix, event string) *matrix.Instance {
+	for _, instance := range mat.GetInstances() {
+		if instance.GetLabel("event") == event {
+			return instance
+		}
+	}
+	return nil
+}
+
+func TestQuery(t *testing.T) {
+	e := newEseriesEvents(t)
+	assert.Equal(t, e.query(), "storage-systems/{array_id}/mel-events?count=1000")
+
+	e.lastSequence = 40215
+	e.criticalOnly = true
+	assert.Equal(t, e.query(), "storage-systems/{array_id}/mel-events?count=1000&startSequenceNumber=40216&critical=true")
+}
+
+func TestLogRecords(t *testing.T) {
+	e := newEseriesEvents(t)
+	e.Params.NewChildS("array", "eseries1")
+
+	path := filepath.Join(t.TempDir(), "mel.log")
+	sinkParams := node.NewS("log_sink")
+	sinkParams.NewChildS("type", eventlog.TypeFile)
+	sinkParams.NewChildS("path", path)
+	sink, err := eventlog.New(sinkParams, e.Logger)
+	assert.Nil(t, err)
+	e.logSink = sink
+
+	e.HandleResults(melEvents(t), pollTime)
+	e.logRecords = eventlog.Flush(e.logSink, e.logRecords, e.Logger)
+	assert.Nil(t, sink.Close())
+
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Equal(t, len(lines), 3)
+
+	var r eventlog.Record
+	assert.Nil(t, json.Unmarshal([]byte(lines[0]), &r))
+	assert.Equal(t, r.Name, "drive_failure")
+	assert.Equal(t, r.Severity, "critical")
+	assert.Equal(t, r.Source, "eseries1")
+	assert.Equal(t, r.Message, "Drive failed by controller")
+	assert.Equal(t, r.Labels["component"], "drive")
+	assert.Equal(t, r.Parameters["location"], "Tray 0, Slot 12")
+	assert.Equal(t, r.Parameters["sequence_number"], "40212")
+	assert.True(t, r.Time.Equal(time.Unix(1715287180, 0)))
+}
+
+func TestParseTemplate(t *testing.T) {
+	e := newEseriesEvents(t)
+	assert.Equal(t, e.Prop.Object, "eseries_mel")
+	assert.Equal(t, e.batchSize, defaultBatchSize)
+	assert.False(t, e.criticalOnly)
+	assert.Equal(t, len(e.events), 6)
+	assert.Equal(t, e.events[0].Name, "drive_failure")
+	assert.Equal(t, e.events[0].InstanceLabels["componentType"], "component")
+}
@@ -0,0 +1,151 @@
+package eseriesevents
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/eventlog"
+	"github.com/netapp/harvest/v2/pkg/template"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"github.com/netapp/harvest/v2/third_party/tidwall/gjson"
+)
+
+// eventProp is one entry of the template's events section
+type eventProp struct {
+	Name           string
+	Matches        []*match
+	InstanceLabels map[string]string // MEL field => label
+	Labels         map[string]string // static labels
+}
+
+type match struct {
+	Name  string
+	value string
+}
+
+// matches returns true when every match of the event rule equals the MEL field, ignoring case.
+// A rule without matches matches every event.
+func (p *eventProp) matches(event gjson.Result) bool {
+	for _, m := range p.Matches {
+		if !strings.EqualFold(event.Get(m.Name).ClonedString(), m.value) {
+			return false
+		}
+	}
+	return true
+}
+
+// ParseTemplate parses the MEL template
+//
+//	exports:
+//	  - componentType => component
+//	events:
+//	  - name: drive_failure
+//	    matches:
+//	      - name: componentType
+//	        value: drive
+//	      - name: category
+//	        value: failure
+func (e *EseriesEvents) ParseTemplate() error {
+	e.Prop.Object = e.Params.GetChildContentS("object")
+	if e.Prop.Object == "" {
+		e.Prop.Object = strings.ToLower(e.Object)
+	}
+
+	if e.Prop.Query = e.Params.GetChildContentS("query"); e.Prop.Query == "" {
+		return errs.New(errs.ErrMissingParam, "query")
+	}
+
+	e.batchSize = defaultBatchSize
+	if b := e.Params.GetChildContentS("batch_size"); b != "" {
+		size, err := strconv.Atoi(b)
+		if err != nil || size <= 0 {
+			return errs.New(errs.ErrInvalidParam, "batch_size ("+b+")")
+		}
+		e.batchSize = size
+	}
+
+	e.criticalOnly = e.Params.GetChildContentS("critical_only") == "true"
+
+	if e.stateDir = e.Params.GetChildContentS("state_path"); e.stateDir == "" {
+		e.stateDir = defaultStatePath
+	}
+
+	var defaultLabels []string
+	if exports := e.Params.GetChildS("exports"); exports != nil {
+		defaultLabels = exports.GetAllChildContentS()
+	}
+
+	events := e.Params.GetChildS("events")
+	if events == nil || len(events.GetChildren()) == 0 {
+		return errs.New(errs.ErrMissingParam, "events")
+	}
+
+	for _, line := range events.GetChildren() {
+		prop := &eventProp{
+			Name:           line.GetChildContentS("name"),
+			InstanceLabels: make(map[string]string),
+			Labels:         make(map[string]string),
+		}
+		if prop.Name == "" {
+			e.Logger.Error("Missing event name")
+			continue
+		}
+
+		parseExports(defaultLabels, prop)
+		if exports := line.GetChildS("exports"); exports != nil {
+			parseExports(exports.GetAllChildContentS(), prop)
+		}
+		if matches := line.GetChildS("matches"); matches != nil {
+			e.parseMatches(matches, prop)
+		}
+		if labels := line.GetChildS("labels"); labels != nil {
+			for _, l := range labels.GetChildren() {
+				prop.Labels[l.GetNameS()] = l.GetContentS()
+			}
+		}
+
+		// populate prop counter for asup
+		e.Prop.Counters[prop.Name] = prop.Name
+		e.events = append(e.events, prop)
+	}
+
+	if logSink := e.Params.GetChildS("log_sink"); logSink != nil {
+		sink, err := eventlog.New(logSink, e.Logger)
+		if err != nil {
+			return err
+		}
+		e.logSink = sink
+	}
+
+	return nil
+}
+
+func parseExports(exports []string, prop *eventProp) {
+	for _, c := range exports {
+		if c == "" {
+			continue
+		}
+		// MEL events only support labels
+		name, display, _, _ := template.ParseMetric(c)
+		prop.InstanceLabels[name] = display
+	}
+}
+
+func (e *EseriesEvents) parseMatches(matches *node.Node, prop *eventProp) {
+	for _, v := range matches.GetChildren() {
+		name := v.GetChildContentS("name")
+		value := v.GetChildContentS("value")
+		if name == "" || value == "" {
+			e.Logger.Warn(
+				"Match name and value cannot be empty",
+				slog.String("event", prop.Name),
+				slog.String("name", name),
+				slog.String("value", value),
+			)
+			continue
+		}
+		prop.Matches = append(prop.Matches, &match{Name: name, value: value})
+	}
+}
@@ -0,0 +1,341 @@
+// Package exec implements the Exec collector. It runs a command on the collector's schedule, or keeps a
+// long-running command, and parses the command's output into a matrix.
+// The output can be Prometheus text, InfluxDB line protocol, or JSON.
+package exec
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"maps"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/netapp/harvest/v2/cmd/collectors/rest"
+	"github.com/netapp/harvest/v2/cmd/poller/collector"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/auth"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/slogx"
+	"github.com/netapp/harvest/v2/pkg/template"
+)
+
+const (
+	defaultTimeout   = "30s"
+	defaultMaxOutput = 32 * 1024 * 1024
+)
+
+type Exec struct {
+	*collector.AbstractCollector
+	Prop   *prop
+	runner runner
+}
+
+type prop struct {
+	Object       string
+	TemplatePath string
+	Command      []string
+	Format       string
+	Mode         string
+	Timeout      time.Duration
+	// Labels and Metrics rename the labels and metrics of the output. When the template has no counters,
+	// everything is collected as is.
+	Labels  map[string]string
+	Metrics map[string]string
+}
+
+func init() {
+	plugin.RegisterModule(&Exec{})
+}
+
+func (e *Exec) HarvestModule() plugin.ModuleInfo {
+	return plugin.ModuleInfo{
+		ID:  "harvest.collector.exec",
+		New: func() plugin.Module { return new(Exec) },
+	}
+}
+
+func (e *Exec) Init(a *collector.AbstractCollector) error {
+	var err error
+
+	e.AbstractCollector = a
+	e.Prop = &prop{}
+
+	if e.Prop.TemplatePath, err = e.LoadTemplate(); err != nil {
+		return err
+	}
+
+	if err := collector.Init(e); err != nil {
+		return err
+	}
+
+	if err := e.InitCache(); err != nil {
+		return err
+	}
+
+	env, err := e.environment()
+	if err != nil {
+		return err
+	}
+
+	maxOutput := defaultMaxOutput
+	if m := e.Params.GetChildContentS("max_output"); m != "" {
+		if maxOutput, err = strconv.Atoi(m); err != nil || maxOutput <= 0 {
+			return errs.New(errs.ErrInvalidParam, "max_output ("+m+")")
+		}
+	}
+
+	switch e.Prop.Mode {
+	case ModeOnce:
+		e.runner = &onceRunner{command: e.Prop.Command, env: env, maxOutput: maxOutput}
+	case ModeDaemon:
+		e.runner = &daemonRunner{command: e.Prop.Command, env: env, maxOutput: maxOutput, logger: e.Logger}
+	}
+
+	e.Logger.Debug(
+		"initialized",
+		slog.Any("command", e.Prop.Command),
+		slog.String("format", e.Prop.Format),
+		slog.String("mode", e.Prop.Mode),
+	)
+
+	return nil
+}
+
+func (e *Exec) LoadTemplate() (string, error) {
+	jitter := e.Params.GetChildContentS("jitter")
+	subTemplate, path, err := e.ImportSubTemplate([]string{""}, rest.TemplateFn(e.Params, e.Object), jitter, e.Remote.Version)
+	if err != nil {
+		return "", err
+	}
+
+	e.Params.Union(subTemplate)
+	return path, nil
+}
+
+func (e *Exec) InitCache() error {
+	var err error
+
+	mat := e.Matrix[e.Object]
+	if x := e.Params.GetChildContentS("object"); x != "" {
+		e.Prop.Object = x
+	} else {
+		e.Prop.Object = strings.ToLower(e.Object)
+	}
+	mat.Object = e.Prop.Object
+
+	if e.Params.HasChildS("labels") {
+		for _, l := range e.Params.GetChildS("labels").GetChildren() {
+			mat.SetGlobalLabel(l.GetNameS(), l.GetContentS())
+		}
+	}
+
+	command := e.Params.GetChildS("command")
+	if command != nil {
+		if len(command.GetChildren()) > 0 {
+			e.Prop.Command = command.GetAllChildContentS()
+		} else {
+			e.Prop.Command = strings.Fields(command.GetContentS())
+		}
+	}
+	if len(e.Prop.Command) == 0 {
+		return errs.New(errs.ErrMissingParam, "command")
+	}
+
+	e.Prop.Format = strings.ToLower(e.Params.GetChildContentS("format"))
+	switch e.Prop.Format {
+	case "":
+		e.Prop.Format = FormatPrometheus
+	case FormatPrometheus, FormatInflux, FormatJSON:
+	default:
+		return errs.New(errs.ErrInvalidParam, "format ("+e.Prop.Format+"), must be prometheus, influx, or json")
+	}
+
+	e.Prop.Mode = strings.ToLower(e.Params.GetChildContentS("mode"))
+	switch e.Prop.Mode {
+	case "":
+		e.Prop.Mode = ModeOnce
+	case ModeOnce, ModeDaemon:
+	default:
+		return errs.New(errs.ErrInvalidParam, "mode ("+e.Prop.Mode+"), must be once or daemon")
+	}
+
+	timeout := e.Params.GetChildContentS("timeout")
+	if timeout == "" {
+		timeout = defaultTimeout
+	}
+	if e.Prop.Timeout, err = time.ParseDuration(timeout); err != nil {
+		return errs.New(errs.ErrInvalidParam, "timeout ("+timeout+")")
+	}
+
+	if counters := e.Params.GetChildS("counters"); counters != nil {
+		e.Prop.Labels = make(map[string]string)
+		e.Prop.Metrics = make(map[string]string)
+		for _, c := range counters.GetAllChildContentS() {
+			if c == "" {
+				continue
+			}
+			name, display, kind, _ := template.ParseMetric(c)
+			switch kind {
+			case "key", "label":
+				e.Prop.Labels[name] = display
+			case "float":
+				e.Prop.Metrics[name] = display
+			}
+		}
+	}
+
+	return nil
+}
+
+// environment returns the environment of the command. It is Harvest's environment, the HARVEST_ variables,
+// the template's env, and the poller's credentials when pass_credentials is true.
+func (e *Exec) environment() ([]string, error) {
+	env := os.Environ()
+
+	var poller *conf.Poller
+	if e.Options != nil {
+		poller, _ = conf.PollerNamed(e.Options.Poller)
+		env = append(env, "HARVEST_POLLER="+e.Options.Poller)
+	}
+	if poller != nil {
+		env = append(env, "HARVEST_ADDR="+poller.Addr, "HARVEST_DATACENTER="+poller.Datacenter)
+	}
+	env = append(env, "HARVEST_OBJECT="+e.Prop.Object)
+
+	if vars := e.Params.GetChildS("env"); vars != nil {
+		for _, v := range vars.GetChildren() {
+			env = append(env, v.GetNameS()+"="+v.GetContentS())
+		}
+	}
+
+	if e.Params.GetChildContentS("pass_credentials") == "true" {
+		if poller == nil {
+			return nil, errs.New(errs.ErrMissingParam, "poller, required by pass_credentials")
+		}
+		credentials := e.Auth
+		if credentials == nil {
+			credentials = auth.NewCredentials(poller, e.Logger)
+		}
+		pollerAuth, err := credentials.GetPollerAuth()
+		if err != nil {
+			return nil, err
+		}
+		env = append(env, "HARVEST_USERNAME="+pollerAuth.Username, "HARVEST_PASSWORD="+pollerAuth.Password)
+	}
+
+	return env, nil
+}
+
+func (e *Exec) PollData() (map[string]*matrix.Matrix, error) {
+	apiStart := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), e.Prop.Timeout)
+	out, err := e.runner.run(ctx)
+	cancel()
+	apiTime := time.Since(apiStart)
+	if err != nil {
+		return nil, errs.New(errs.ErrConnection, err.Error())
+	}
+
+	parseStart := time.Now()
+	samples, err := parse(e.Prop.Format, e.Prop.Object, out)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s output: %w", e.Prop.Format, err)
+	}
+	count := e.pollData(samples)
+	parseTime := time.Since(parseStart)
+
+	mat := e.Matrix[e.Object]
+	numInstances := len(mat.GetInstances())
+
+	dataInst := e.Metadata.MustGetInstance("data")
+	e.Metadata.MustSetValueInt64("api_time", dataInst, apiTime.Microseconds())
+	e.Metadata.MustSetValueInt64("parse_time", dataInst, parseTime.Microseconds())
+	e.Metadata.MustSetValueUint64("metrics", dataInst, count)
+	e.Metadata.MustSetValueUint64("instances", dataInst, uint64(numInstances))
+	e.Metadata.MustSetValueUint64("bytesRx", dataInst, uint64(len(out)))
+	e.Metadata.MustSetValueUint64("numCalls", dataInst, 1)
+	e.AddCollectCount(count)
+
+	if numInstances == 0 {
+		return nil, errs.New(errs.ErrNoInstance, "no "+e.Object+" instances")
+	}
+
+	return e.Matrix, nil
+}
+
+func (e *Exec) pollData(samples []*sample) uint64 {
+	var count uint64
+
+	mat := e.Matrix[e.Object]
+	current := make(map[string]*sample, len(samples))
+	for _, s := range samples {
+		current[s.key] = s
+	}
+	for key := range mat.GetInstances() {
+		if _, ok := current[key]; !ok {
+			mat.RemoveInstance(key)
+		}
+	}
+	for _, s := range samples {
+		if mat.GetInstance(s.key) == nil {
+			if _, err := mat.NewInstance(s.key); err != nil {
+				e.Logger.Error("failed to create instance", slogx.Err(err), slog.String("key", s.key))
+			}
+		}
+	}
+	mat.Reset()
+
+	for _, s := range samples {
+		instance := mat.GetInstance(s.key)
+		if instance == nil {
+			continue
+		}
+		instance.SetExportable(true)
+		instance.ClearLabels()
+		for name, value := range s.labels {
+			if display, ok := e.display(e.Prop.Labels, name); ok {
+				instance.SetLabel(display, value)
+			}
+		}
+
+		for _, name := range slices.Sorted(maps.Keys(s.metrics)) {
+			display, ok := e.display(e.Prop.Metrics, name)
+			if !ok {
+				continue
+			}
+			metric := mat.GetMetric(name)
+			if metric == nil {
+				var err error
+				if metric, err = mat.NewMetricFloat64(name, display); err != nil {
+					e.Logger.Error("NewMetricFloat64", slogx.Err(err), slog.String("name", name))
+					continue
+				}
+			}
+			metric.SetValueFloat64(instance, s.metrics[name])
+			count++
+		}
+	}
+
+	return count
+}
+
+// display returns the name a label or metric is exported as. When the template has counters, only the listed
+// names are collected.
+func (e *Exec) display(names map[string]string, name string) (string, bool) {
+	if names == nil {
+		return name, true
+	}
+	display, ok := names[name]
+	return display, ok
+}
+
+// Interface guards
+var (
+	_ collector.Collector = (*Exec)(nil)
+)
@@ -0,0 +1,220 @@
+package exec
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/netapp/harvest/v2/assert"
+	"github.com/netapp/harvest/v2/cmd/collectors"
+	"github.com/netapp/harvest/v2/cmd/poller/collector"
+	"github.com/netapp/harvest/v2/cmd/poller/options"
+	"github.com/netapp/harvest/v2/pkg/conf"
+)
+
+const pollerName = "test"
+
+func TestMain(m *testing.M) {
+	conf.TestLoadHarvestConfig("testdata/config.yml")
+	os.Exit(m.Run())
+}
+
+func newExec(t *testing.T, object string, path string) *Exec {
+	t.Helper()
+	opts := options.New(options.WithConfPath("../../../conf"))
+	opts.Poller = pollerName
+	opts.HomePath = "testdata"
+	opts.IsTest = true
+
+	ac := collector.New("Exec", object, opts, collectors.Params(object, path), nil, conf.Remote{})
+	e := &Exec{}
+	if err := e.Init(ac); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+func bySample(samples []*sample) map[string]*sample {
+	m := make(map[string]*sample, len(samples))
+	for _, s := range samples {
+		m[s.key] = s
+	}
+	return m
+}
+
+func TestParsePrometheus(t *testing.T) {
+	out := `# HELP disk_busy Disk busy
+# TYPE disk_busy gauge
+disk_busy{disk="1.0.1",node="n1"} 12.5
+disk_busy{node="n1",disk="1.0.2"} 3 1700000000000
+disk_io_total{disk="1.0.1",node="n1"} 42
+disk_count 2
+`
+	samples, err := parse(FormatPrometheus, "disk", []byte(out))
+	assert.Nil(t, err)
+	assert.Equal(t, len(samples), 3)
+
+	s := bySample(samples)["disk=1.0.1,node=n1"]
+	assert.NotNil(t, s)
+	assert.Equal(t, s.labels["node"], "n1")
+	assert.Equal(t, s.metrics["busy"], 12.5)
+	assert.Equal(t, s.metrics["io_total"], 42.0)
+
+	assert.Equal(t, bySample(samples)["disk=1.0.2,node=n1"].metrics["busy"], 3.0)
+	assert.Equal(t, bySample(samples)[""].metrics["count"], 2.0)
+
+	_, err = parse(FormatPrometheus, "disk", []byte(`disk_busy{disk="1.0.1" 1`))
+	assert.NotNil(t, err)
+	_, err = parse(FormatPrometheus, "disk", []byte(`disk_busy abc`))
+	assert.NotNil(t, err)
+}
+
+func TestParseInflux(t *testing.T) {
+	out := `fan,node=n1,name=fan\ 1 rpm=3200i,ok=true,state="normal" 1700000000000000000
+fan,name=fan\ 1,node=n1 watts=12.5
+`
+	samples, err := parse(FormatInflux, "fan", []byte(out))
+	assert.Nil(t, err)
+	assert.Equal(t, len(samples), 1)
+
+	s := samples[0]
+	assert.Equal(t, s.key, "name=fan 1,node=n1")
+	assert.Equal(t, s.labels["name"], "fan 1")
+	assert.Equal(t, s.labels["state"], "normal")
+	assert.Equal(t, s.metrics["rpm"], 3200.0)
+	assert.Equal(t, s.metrics["ok"], 1.0)
+	assert.Equal(t, s.metrics["watts"], 12.5)
+
+	_, err = parse(FormatInflux, "fan", []byte(`fan,node rpm=1`))
+	assert.NotNil(t, err)
+}
+
+func TestParseJSON(t *testing.T) {
+	out := `{
+  "labels": {"site": "rtp"},
+  "instances": [
+    {"key": "vol1", "labels": {"volume": "vol1"}, "metrics": {"volume_size": 100, "online": true}},
+    {"labels": {"volume": "vol2", "site": "ral"}, "metrics": {"size": "200"}}
+  ]
+}`
+	samples, err := parse(FormatJSON, "volume", []byte(out))
+	assert.Nil(t, err)
+	assert.Equal(t, len(samples), 2)
+
+	s := bySample(samples)["vol1"]
+	assert.Equal(t, s.labels["site"], "rtp")
+	assert.Equal(t, s.metrics["size"], 100.0)
+	assert.Equal(t, s.metrics["online"], 1.0)
+
+	s = bySample(samples)["site=ral,volume=vol2"]
+	assert.NotNil(t, s)
+	assert.Equal(t, s.metrics["size"], 200.0)
+
+	samples, err = parse(FormatJSON, "volume", []byte(`[{"key": "vol1", "metrics": {"size": 1}}]`))
+	assert.Nil(t, err)
+	assert.Equal(t, len(samples), 1)
+
+	_, err = parse(FormatJSON, "volume", []byte(`{"instances": [{"metrics": {"size": "big"}}]}`))
+	assert.NotNil(t, err)
+	_, err = parse(FormatJSON, "volume", []byte(`{"instances":`))
+	assert.NotNil(t, err)
+}
+
+func TestOnceRunner(t *testing.T) {
+	r := &onceRunner{command: []string{"/bin/sh", "-c", "echo $GREETING"}, env: []string{"GREETING=hello"}, maxOutput: 1024}
+	out, err := r.run(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, string(out), "hello\n")
+
+	r = &onceRunner{command: []string{"/bin/sh", "-c", "echo oops >&2; exit 3"}, maxOutput: 1024}
+	_, err = r.run(context.Background())
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "oops"))
+
+	r = &onceRunner{command: []string{"/bin/sh", "-c", "echo 0123456789"}, maxOutput: 5}
+	_, err = r.run(context.Background())
+	assert.True(t, errors.Is(err, errOutputTooLarge))
+
+	r = &onceRunner{command: []string{"/bin/sh", "-c", "sleep 10"}, maxOutput: 1024}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = r.run(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.True(t, time.Since(start) < 5*time.Second)
+}
+
+func TestDaemonRunner(t *testing.T) {
+	// counts the polls, exits after the second one
+	script := `n=0
+while read -r line; do
+  n=$((n+1))
+  echo "polls $n"
+  echo "# EOF"
+  if [ $n -eq 2 ]; then exit 0; fi
+done`
+	r := &daemonRunner{command: []string{"/bin/sh", "-c", script}, maxOutput: 1024, logger: slog.Default()}
+	defer r.stop()
+
+	for _, want := range []string{"polls 1\n", "polls 2\n"} {
+		out, err := r.run(context.Background())
+		assert.Nil(t, err)
+		assert.Equal(t, string(out), want)
+	}
+
+	// the command exited, it is restarted by the next poll
+	_, err := r.run(context.Background())
+	assert.NotNil(t, err)
+	out, err := r.run(context.Background())
+	assert.Nil(t, err)
+	assert.Equal(t, string(out), "polls 1\n")
+
+	// a command that does not answer is restarted
+	r.stop()
+	r.command = []string{"/bin/sh", "-c", "while read -r line; do sleep 10; done"}
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err = r.run(ctx)
+	assert.True(t, errors.Is(err, context.DeadlineExceeded))
+	assert.Nil(t, r.cmd)
+}
+
+func TestFilesystem(t *testing.T) {
+	e := newExec(t, "Filesystem", "filesystem.yaml")
+	assert.Equal(t, e.Prop.Format, FormatInflux)
+	assert.Equal(t, e.Prop.Timeout, 10*time.Second)
+
+	e.runner = &onceRunner{
+		command:   []string{"/bin/sh", "-c", `echo 'filesystem,device=/dev/sda1,mount=/ size=1000i,used=400i,available=600i,inodes=5i'`},
+		maxOutput: 1024,
+	}
+	_, err := e.PollData()
+	assert.Nil(t, err)
+
+	mat := e.Matrix[e.Object]
+	assert.Equal(t, mat.Object, "filesystem")
+	instance := mat.GetInstance("device=/dev/sda1,mount=/")
+	assert.NotNil(t, instance)
+	assert.Equal(t, instance.GetLabel("mount"), "/")
+
+	used, ok := mat.GetMetric("used").GetValueFloat64(instance)
+	assert.True(t, ok)
+	assert.Equal(t, used, 400.0)
+	assert.Equal(t, mat.GetMetric("used").GetName(), "size_used")
+	// inodes is not in the template's counters
+	assert.Nil(t, mat.GetMetric("inodes"))
+
+	// instances missing from the output are removed
+	e.runner = &onceRunner{
+		command:   []string{"/bin/sh", "-c", `echo 'filesystem,device=/dev/sdb1,mount=/data size=1i'`},
+		maxOutput: 1024,
+	}
+	_, err = e.PollData()
+	assert.Nil(t, err)
+	assert.Equal(t, len(mat.GetInstances()), 1)
+	assert.Nil(t, mat.GetInstance("device=/dev/sda1,mount=/"))
+}
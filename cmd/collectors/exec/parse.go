@@ -0,0 +1,365 @@
+package exec
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"maps"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/netapp/harvest/v2/third_party/tidwall/gjson"
+)
+
+const (
+	FormatPrometheus = "prometheus"
+	FormatInflux     = "influx"
+	FormatJSON       = "json"
+)
+
+// sample is one instance parsed from the command's output
+type sample struct {
+	key     string
+	labels  map[string]string
+	metrics map[string]float64
+}
+
+// samples collects the samples of a parse, merging samples with the same key
+type samples struct {
+	object string
+	byKey  map[string]*sample
+	order  []*sample
+}
+
+func newSamples(object string) *samples {
+	return &samples{object: object, byKey: make(map[string]*sample)}
+}
+
+// get returns the sample of key, creating it with labels when it does not exist.
+// An empty key is replaced by the sorted labels.
+func (s *samples) get(key string, labels map[string]string) *sample {
+	if key == "" {
+		key = labelsKey(labels)
+	}
+	if sm, ok := s.byKey[key]; ok {
+		maps.Copy(sm.labels, labels)
+		return sm
+	}
+	sm := &sample{key: key, labels: labels, metrics: make(map[string]float64)}
+	if sm.labels == nil {
+		sm.labels = make(map[string]string)
+	}
+	s.byKey[key] = sm
+	s.order = append(s.order, sm)
+	return sm
+}
+
+// setMetric sets a metric of a sample. The object prefix is removed from the name, since exporters add it.
+func (s *samples) setMetric(sm *sample, name string, value float64) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return
+	}
+	if trimmed, ok := strings.CutPrefix(name, s.object+"_"); ok && trimmed != "" {
+		name = trimmed
+	}
+	sm.metrics[name] = value
+}
+
+func labelsKey(labels map[string]string) string {
+	var key strings.Builder
+	for i, k := range slices.Sorted(maps.Keys(labels)) {
+		if i > 0 {
+			key.WriteByte(',')
+		}
+		key.WriteString(k)
+		key.WriteByte('=')
+		key.WriteString(labels[k])
+	}
+	return key.String()
+}
+
+func parse(format string, object string, out []byte) ([]*sample, error) {
+	s := newSamples(object)
+	var err error
+	switch format {
+	case FormatPrometheus:
+		err = parsePrometheus(s, out)
+	case FormatInflux:
+		err = parseInflux(s, out)
+	case FormatJSON:
+		err = parseJSON(s, out)
+	default:
+		err = fmt.Errorf("unknown format %s", format)
+	}
+	return s.order, err
+}
+
+// parsePrometheus parses the Prometheus text exposition format. Each distinct set of labels is an instance.
+// Comments, HELP, TYPE, and timestamps are ignored.
+func parsePrometheus(s *samples, out []byte) error {
+	scanner := newScanner(out)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		var (
+			name   string
+			labels map[string]string
+			rest   string
+			err    error
+		)
+		if i := strings.IndexAny(line, "{ \t"); i < 0 {
+			return fmt.Errorf("line %d: missing value", lineNum)
+		} else if line[i] == '{' {
+			name = line[:i]
+			if labels, rest, err = parsePromLabels(line[i+1:]); err != nil {
+				return fmt.Errorf("line %d: %w", lineNum, err)
+			}
+		} else {
+			name, rest = line[:i], line[i:]
+		}
+
+		fields := strings.Fields(rest)
+		if len(fields) == 0 {
+			return fmt.Errorf("line %d: missing value", lineNum)
+		}
+		value, err := strconv.ParseFloat(fields[0], 64)
+		if err != nil {
+			return fmt.Errorf("line %d: invalid value %s", lineNum, fields[0])
+		}
+		s.setMetric(s.get("", labels), name, value)
+	}
+	return scanner.Err()
+}
+
+// parsePromLabels parses labels like a="1",b="2"} and returns the rest of the line after the closing brace
+func parsePromLabels(s string) (map[string]string, string, error) {
+	labels := make(map[string]string)
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return nil, "", fmt.Errorf("missing }")
+		}
+		if s[0] == '}' {
+			return labels, s[1:], nil
+		}
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 || eq+1 >= len(s) || s[eq+1] != '"' {
+			return nil, "", fmt.Errorf("invalid label %s", s)
+		}
+		name := strings.TrimSpace(s[:eq])
+		var value strings.Builder
+		i := eq + 2
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				if s[i] == 'n' {
+					value.WriteByte('\n')
+					continue
+				}
+			}
+			value.WriteByte(s[i])
+		}
+		if i >= len(s) {
+			return nil, "", fmt.Errorf("unterminated value of label %s", name)
+		}
+		labels[name] = value.String()
+		s = s[i+1:]
+	}
+}
+
+// parseInflux parses the InfluxDB line protocol. Tags are labels and each distinct set of tags is an instance.
+// Numeric and boolean fields are metrics named measurement_field. String fields are labels.
+func parseInflux(s *samples, out []byte) error {
+	scanner := newScanner(out)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		parts := splitUnescaped(line, ' ', true)
+		if len(parts) < 2 {
+			return fmt.Errorf("line %d: missing fields", lineNum)
+		}
+
+		keyParts := splitUnescaped(parts[0], ',', false)
+		measurement := unescapeInflux(keyParts[0])
+		tags := make(map[string]string, len(keyParts)-1)
+		for _, tag := range keyParts[1:] {
+			kv := splitUnescaped(tag, '=', false)
+			if len(kv) != 2 {
+				return fmt.Errorf("line %d: invalid tag %s", lineNum, tag)
+			}
+			tags[unescapeInflux(kv[0])] = unescapeInflux(kv[1])
+		}
+
+		type field struct {
+			name  string
+			value float64
+		}
+		var metrics []field
+		labels := make(map[string]string)
+		for _, f := range splitUnescaped(parts[1], ',', true) {
+			kv := splitUnescaped(f, '=', true)
+			if len(kv) != 2 {
+				return fmt.Errorf("line %d: invalid field %s", lineNum, f)
+			}
+			name, raw := unescapeInflux(kv[0]), kv[1]
+			if strings.HasPrefix(raw, `"`) {
+				labels[name] = strings.ReplaceAll(strings.Trim(raw, `"`), `\"`, `"`)
+				continue
+			}
+			value, ok := influxValue(raw)
+			if !ok {
+				return fmt.Errorf("line %d: invalid value of field %s", lineNum, name)
+			}
+			metrics = append(metrics, field{name: measurement + "_" + name, value: value})
+		}
+
+		sm := s.get(labelsKey(tags), tags)
+		maps.Copy(sm.labels, labels)
+		for _, m := range metrics {
+			s.setMetric(sm, m.name, m.value)
+		}
+	}
+	return scanner.Err()
+}
+
+func influxValue(raw string) (float64, bool) {
+	switch raw {
+	case "t", "T", "true", "True", "TRUE":
+		return 1, true
+	case "f", "F", "false", "False", "FALSE":
+		return 0, true
+	}
+	raw = strings.TrimRight(raw, "iu")
+	v, err := strconv.ParseFloat(raw, 64)
+	return v, err == nil
+}
+
+// splitUnescaped splits s on sep, ignoring separators escaped with a backslash and, when quotes is true,
+// separators inside double quotes
+func splitUnescaped(s string, sep byte, quotes bool) []string {
+	var parts []string
+	start := 0
+	inQuote := false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\':
+			i++
+		case quotes && s[i] == '"':
+			inQuote = !inQuote
+		case s[i] == sep && !inQuote:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func unescapeInflux(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// parseJSON parses a document like
+//
+//	{
+//	  "labels": {"site": "rtp"},
+//	  "instances": [
+//	    {"key": "vol1", "labels": {"volume": "vol1"}, "metrics": {"size": 100, "online": true}}
+//	  ]
+//	}
+//
+// labels are added to every instance. key is optional and defaults to the instance's labels.
+// The document can also be the list of instances.
+func parseJSON(s *samples, out []byte) error {
+	if !gjson.ValidBytes(out) {
+		return fmt.Errorf("invalid JSON")
+	}
+	doc := gjson.ParseBytes(out)
+	instances := doc
+	var global map[string]string
+	if doc.IsObject() {
+		instances = doc.Get("instances")
+		global = jsonLabels(doc.Get("labels"))
+	}
+	if !instances.IsArray() {
+		return fmt.Errorf("instances is not a list")
+	}
+
+	for i, instance := range instances.Array() {
+		if !instance.IsObject() {
+			return fmt.Errorf("instances[%d] is not an object", i)
+		}
+		labels := maps.Clone(global)
+		if labels == nil {
+			labels = make(map[string]string)
+		}
+		maps.Copy(labels, jsonLabels(instance.Get("labels")))
+
+		sm := s.get(instance.Get("key").ClonedString(), labels)
+		var err error
+		instance.Get("metrics").ForEach(func(name, value gjson.Result) bool {
+			v, ok := jsonValue(value)
+			if !ok {
+				err = fmt.Errorf("instances[%d]: invalid value of metric %s", i, name.String())
+				return false
+			}
+			s.setMetric(sm, name.ClonedString(), v)
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func jsonLabels(r gjson.Result) map[string]string {
+	if !r.IsObject() {
+		return nil
+	}
+	labels := make(map[string]string)
+	r.ForEach(func(name, value gjson.Result) bool {
+		labels[name.ClonedString()] = value.ClonedString()
+		return true
+	})
+	return labels
+}
+
+func jsonValue(value gjson.Result) (float64, bool) {
+	switch value.Type {
+	case gjson.Number:
+		return value.Float(), true
+	case gjson.True:
+		return 1, true
+	case gjson.False:
+		return 0, true
+	case gjson.String:
+		f, err := strconv.ParseFloat(value.String(), 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+func newScanner(out []byte) *bufio.Scanner {
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+	return scanner
+}
@@ -0,0 +1,187 @@
+package exec
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os/exec"
+
+	"github.com/netapp/harvest/v2/pkg/subprocess"
+)
+
+const (
+	ModeOnce   = "once"
+	ModeDaemon = "daemon"
+
+	// endOfOutput ends the output of each poll in daemon mode
+	endOfOutput = "# EOF"
+
+	maxLineSize = 1024 * 1024
+)
+
+var errOutputTooLarge = errors.New("output is larger than max_output")
+
+type runner interface {
+	run(ctx context.Context) ([]byte, error)
+	stop()
+}
+
+// onceRunner starts the command at each poll and reads its output until it exits
+type onceRunner struct {
+	command   []string
+	env       []string
+	maxOutput int
+}
+
+func (r *onceRunner) run(ctx context.Context) ([]byte, error) {
+	cmd := subprocess.Command(ctx, r.command, r.env)
+	stdout := &limitedBuffer{max: r.maxOutput}
+	stderr := &subprocess.TailBuffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	err := cmd.Run()
+	if stdout.overflow {
+		return nil, errOutputTooLarge
+	}
+	if err != nil {
+		if ctx.Err() != nil {
+			err = ctx.Err()
+		}
+		return nil, commandError(r.command, err, stderr)
+	}
+	return stdout.Bytes(), nil
+}
+
+func (r *onceRunner) stop() {}
+
+// daemonRunner keeps the command running. At each poll, it writes a newline to the command's stdin and reads
+// the command's output until a line with # EOF. The command is restarted at the next poll when it exits or
+// does not answer before the timeout. The command should exit when its stdin is closed.
+type daemonRunner struct {
+	command   []string
+	env       []string
+	maxOutput int
+	logger    *slog.Logger
+
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	lines  chan string
+	stderr *subprocess.TailBuffer
+	done   chan struct{}
+}
+
+func (r *daemonRunner) start() error {
+	cmd := subprocess.Command(context.Background(), r.command, r.env)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	r.stderr = &subprocess.TailBuffer{}
+	cmd.Stderr = r.stderr
+	if err := cmd.Start(); err != nil {
+		return commandError(r.command, err, r.stderr)
+	}
+
+	r.cmd = cmd
+	r.stdin = stdin
+	r.lines = make(chan string)
+	r.done = make(chan struct{})
+
+	go func(lines chan<- string, done chan struct{}) {
+		defer close(lines)
+		scanner := bufio.NewScanner(stdout)
+		scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+		for scanner.Scan() {
+			select {
+			case lines <- scanner.Text():
+			case <-done:
+				return
+			}
+		}
+	}(r.lines, r.done)
+
+	r.logger.Info("started command", slog.Int("pid", cmd.Process.Pid), slog.Any("command", r.command))
+	return nil
+}
+
+func (r *daemonRunner) run(ctx context.Context) ([]byte, error) {
+	if r.cmd == nil {
+		if err := r.start(); err != nil {
+			return nil, err
+		}
+	}
+
+	if _, err := io.WriteString(r.stdin, "\n"); err != nil {
+		return nil, r.fail(fmt.Errorf("write to stdin: %w", err))
+	}
+
+	var out bytes.Buffer
+	for {
+		select {
+		case line, ok := <-r.lines:
+			if !ok {
+				return nil, r.fail(errors.New("command exited"))
+			}
+			if line == endOfOutput {
+				return out.Bytes(), nil
+			}
+			if out.Len()+len(line)+1 > r.maxOutput {
+				return nil, r.fail(errOutputTooLarge)
+			}
+			out.WriteString(line)
+			out.WriteByte('\n')
+		case <-ctx.Done():
+			return nil, r.fail(ctx.Err())
+		}
+	}
+}
+
+// fail stops the command so that it is restarted by the next poll
+func (r *daemonRunner) fail(err error) error {
+	stderr := r.stderr
+	r.stop()
+	return commandError(r.command, err, stderr)
+}
+
+func (r *daemonRunner) stop() {
+	if r.cmd == nil {
+		return
+	}
+	close(r.done)
+	_ = r.stdin.Close()
+	_ = subprocess.Kill(r.cmd)
+	r.cmd = nil
+}
+
+func commandError(command []string, err error, stderr *subprocess.TailBuffer) error {
+	return fmt.Errorf("%s: %w", command[0], subprocess.Error(err, stderr))
+}
+
+// limitedBuffer is a buffer that discards writes after max bytes. It does not embed bytes.Buffer, so io.Copy
+// can not bypass Write with ReadFrom.
+type limitedBuffer struct {
+	buf      bytes.Buffer
+	max      int
+	overflow bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if b.buf.Len()+len(p) > b.max {
+		b.overflow = true
+		return len(p), nil
+	}
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) Bytes() []byte {
+	return b.buf.Bytes()
+}
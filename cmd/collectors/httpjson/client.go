@@ -0,0 +1,167 @@
+package httpjson
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/netapp/harvest/v2/pkg/auth"
+	"github.com/netapp/harvest/v2/pkg/collector"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/requests"
+)
+
+const (
+	DefaultTimeout = "30s"
+
+	AuthBasic  = "basic"
+	AuthBearer = "bearer"
+	AuthNone   = "none"
+)
+
+// Client sends GET requests to a JSON API using basic auth, a bearer token, or no auth
+type Client struct {
+	client   *http.Client
+	Logger   *slog.Logger
+	authType string
+	auth     *auth.Credentials
+	headers  map[string]string
+	Timeout  time.Duration
+	Metadata *collector.Metadata
+}
+
+// NewClient creates a client for the poller.
+// With bearer auth, the token is the authToken returned by the poller's credentials_script, or the poller's password.
+func NewClient(poller *conf.Poller, timeout time.Duration, credentials *auth.Credentials, authType string) (*Client, error) {
+	var (
+		transport http.RoundTripper
+		err       error
+	)
+
+	if authType == "" {
+		authType = AuthBasic
+	}
+
+	client := &Client{
+		Logger:   slog.Default().With(slog.String("HTTPJSON", "Client")),
+		authType: authType,
+		auth:     credentials,
+		headers:  make(map[string]string),
+		Timeout:  timeout,
+		Metadata: &collector.Metadata{},
+	}
+
+	switch authType {
+	case AuthBasic, AuthBearer:
+		if transport, err = credentials.Transport(nil, poller); err != nil {
+			return nil, err
+		}
+	case AuthNone:
+		if transport, err = credentials.TLSTransport(poller); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errs.New(errs.ErrInvalidParam, "auth ("+authType+")")
+	}
+
+	client.client = &http.Client{Transport: transport, Timeout: timeout}
+	return client, nil
+}
+
+// SetHeader adds a header to every request
+func (c *Client) SetHeader(name, value string) {
+	c.headers[name] = value
+}
+
+// Get returns the body and headers of the response for url.
+// When the API rejects the credentials and the poller uses a credentials_script, the script is called again and the
+// request retried once.
+func (c *Client) Get(url string) ([]byte, http.Header, error) {
+	body, header, err := c.get(url)
+	if err == nil || !errors.Is(err, errs.ErrAuthFailed) || c.authType == AuthNone {
+		return body, header, err
+	}
+
+	pollerAuth, err2 := c.auth.GetPollerAuth()
+	if err2 != nil || !pollerAuth.Refreshable {
+		return body, header, err
+	}
+	c.auth.Expire()
+	return c.get(url)
+}
+
+func (c *Client) get(url string) ([]byte, http.Header, error) {
+	req, err := requests.New(http.MethodGet, url, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	for k, v := range c.headers {
+		req.Header.Set(k, v)
+	}
+	if err := c.setAuth(req); err != nil {
+		return nil, nil, err
+	}
+
+	response, err := c.client.Do(req)
+	if err != nil {
+		return nil, nil, errs.NewRest().
+			StatusCode(0).
+			Error(err).
+			API(url).
+			Build()
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	c.Metadata.BytesRx.Add(uint64(len(body)))
+	c.Metadata.NumCalls.Add(1)
+
+	if response.StatusCode != http.StatusOK {
+		rest := errs.NewRest().
+			StatusCode(response.StatusCode).
+			API(url).
+			Message(strings.TrimSpace(string(body)))
+		if response.StatusCode == http.StatusUnauthorized || response.StatusCode == http.StatusForbidden {
+			rest.Error(errs.ErrAuthFailed)
+		}
+		return nil, nil, rest.Build()
+	}
+
+	return body, response.Header, nil
+}
+
+func (c *Client) setAuth(req *http.Request) error {
+	if c.authType == AuthNone {
+		return nil
+	}
+
+	pollerAuth, err := c.auth.GetPollerAuth()
+	if err != nil {
+		return err
+	}
+
+	switch c.authType {
+	case AuthBearer:
+		token := pollerAuth.AuthToken
+		if token == "" {
+			token = pollerAuth.Password
+		}
+		if token == "" {
+			return errs.New(errs.ErrMissingParam, "bearer token, set authToken in the credentials_script output or password")
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+	default:
+		req.SetBasicAuth(pollerAuth.Username, pollerAuth.Password)
+	}
+	return nil
+}
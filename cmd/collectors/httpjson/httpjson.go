@@ -0,0 +1,415 @@
+// Package httpjson implements a vendor-neutral collector for JSON APIs.
+// Templates declare the url, auth, pagination style, and the gjson paths of instance keys, labels, and metrics.
+package httpjson
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/netapp/harvest/v2/cmd/poller/collector"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/auth"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/set"
+	"github.com/netapp/harvest/v2/pkg/slogx"
+	"github.com/netapp/harvest/v2/third_party/tidwall/gjson"
+)
+
+type HTTPJSON struct {
+	*collector.AbstractCollector
+	Client  *Client
+	Prop    *prop
+	baseURL string
+}
+
+func init() {
+	plugin.RegisterModule(&HTTPJSON{})
+}
+
+func (h *HTTPJSON) HarvestModule() plugin.ModuleInfo {
+	return plugin.ModuleInfo{
+		ID:  "harvest.collector.httpjson",
+		New: func() plugin.Module { return new(HTTPJSON) },
+	}
+}
+
+func (h *HTTPJSON) Init(a *collector.AbstractCollector) error {
+	var err error
+
+	h.AbstractCollector = a
+	h.Prop = &prop{
+		InstanceLabels: make(map[string]string),
+		Metrics:        make(map[string]*Metric),
+		Counters:       make(map[string]string),
+	}
+
+	if h.Prop.TemplatePath, err = h.LoadTemplate(); err != nil {
+		return err
+	}
+
+	if err := h.InitClient(); err != nil {
+		return err
+	}
+
+	if err := collector.Init(h); err != nil {
+		return err
+	}
+
+	if err := h.InitCache(); err != nil {
+		return err
+	}
+
+	h.InitMatrix()
+
+	h.Logger.Debug(
+		"initialized",
+		slog.String("url", h.Prop.Query),
+		slog.Any("instanceKeys", h.Prop.InstanceKeys),
+		slog.Int("numMetrics", len(h.Prop.Metrics)),
+		slog.Int("numLabels", len(h.Prop.InstanceLabels)),
+	)
+
+	return nil
+}
+
+func (h *HTTPJSON) InitClient() error {
+	poller, err := conf.PollerNamed(h.Options.Poller)
+	if err != nil {
+		return err
+	}
+
+	clientTimeout := h.Params.GetChildContentS("client_timeout")
+	if clientTimeout == "" {
+		clientTimeout = DefaultTimeout
+	}
+	timeout, err := time.ParseDuration(clientTimeout)
+	if err != nil {
+		h.Logger.Info("Using default timeout", slog.String("timeout", DefaultTimeout))
+		timeout, _ = time.ParseDuration(DefaultTimeout)
+	}
+
+	credentials := h.Auth
+	if credentials == nil {
+		credentials = auth.NewCredentials(poller, h.Logger)
+	}
+
+	if h.Client, err = NewClient(poller, timeout, credentials, strings.ToLower(h.Params.GetChildContentS("auth"))); err != nil {
+		return err
+	}
+
+	if headers := h.Params.GetChildS("headers"); headers != nil {
+		for _, header := range headers.GetChildren() {
+			h.Client.SetHeader(header.GetNameS(), header.GetContentS())
+		}
+	}
+
+	if poller.Addr != "" {
+		h.baseURL = "https://" + poller.Addr + "/"
+	}
+
+	return nil
+}
+
+func (h *HTTPJSON) InitMatrix() {
+	mat := h.Matrix[h.Object]
+	// overwrite from abstract collector
+	mat.Object = h.Prop.Object
+
+	if h.Params.HasChildS("labels") {
+		for _, l := range h.Params.GetChildS("labels").GetChildren() {
+			mat.SetGlobalLabel(l.GetNameS(), l.GetContentS())
+		}
+	}
+}
+
+func (h *HTTPJSON) PollData() (map[string]*matrix.Matrix, error) {
+	h.Client.Metadata.Reset()
+
+	apiStart := time.Now()
+	records, err := h.fetch()
+	apiTime := time.Since(apiStart)
+	if err != nil {
+		return nil, err
+	}
+
+	parseStart := time.Now()
+	count := h.pollData(records)
+	parseTime := time.Since(parseStart)
+
+	mat := h.Matrix[h.Object]
+	numInstances := len(mat.GetInstances())
+
+	dataInst := h.Metadata.MustGetInstance("data")
+	h.Metadata.MustSetValueInt64("api_time", dataInst, apiTime.Microseconds())
+	h.Metadata.MustSetValueInt64("parse_time", dataInst, parseTime.Microseconds())
+	h.Metadata.MustSetValueUint64("metrics", dataInst, count)
+	h.Metadata.MustSetValueUint64("instances", dataInst, uint64(numInstances))
+	h.Metadata.MustSetValueUint64("bytesRx", dataInst, h.Client.Metadata.BytesRx.Load())
+	h.Metadata.MustSetValueUint64("numCalls", dataInst, h.Client.Metadata.NumCalls.Load())
+	h.AddCollectCount(count)
+
+	if numInstances == 0 {
+		return nil, errs.New(errs.ErrNoInstance, "no "+h.Object+" instances")
+	}
+
+	return h.Matrix, nil
+}
+
+// fetch returns the records of every page
+func (h *HTTPJSON) fetch() ([]gjson.Result, error) {
+	var records []gjson.Result
+
+	href, err := h.firstPage()
+	if err != nil {
+		return nil, err
+	}
+
+	p := h.Prop.Pagination
+	offset := 0
+	for page := 0; ; page++ {
+		body, header, err := h.Client.Get(href)
+		if err != nil {
+			return nil, err
+		}
+
+		pageRecords := h.records(body)
+		records = append(records, pageRecords...)
+
+		if p == nil {
+			break
+		}
+		if page+1 >= p.MaxPages {
+			h.Logger.Warn("max_pages reached, remaining pages are skipped", slog.Int("maxPages", p.MaxPages))
+			break
+		}
+
+		next := ""
+		switch p.Type {
+		case PageLink:
+			if next, err = nextLink(href, body, header, p.NextPath); err != nil {
+				return nil, err
+			}
+		case PageCursor:
+			if cursor := gjson.GetBytes(body, p.CursorPath).ClonedString(); cursor != "" {
+				next = withQuery(href, map[string]string{p.CursorParam: cursor})
+			}
+		case PageOffset:
+			if len(pageRecords) >= p.Limit {
+				offset += p.Limit
+				next = withQuery(href, map[string]string{
+					p.OffsetParam: strconv.Itoa(offset),
+					p.LimitParam:  strconv.Itoa(p.Limit),
+				})
+			}
+		}
+		if next == "" {
+			break
+		}
+		href = next
+	}
+
+	return records, nil
+}
+
+func (h *HTTPJSON) firstPage() (string, error) {
+	href := h.Prop.Query
+	if !strings.HasPrefix(href, "http://") && !strings.HasPrefix(href, "https://") {
+		if h.baseURL == "" {
+			return "", errs.New(errs.ErrMissingParam, "addr, required when url is relative")
+		}
+		href = h.baseURL + strings.TrimPrefix(href, "/")
+	}
+	if p := h.Prop.Pagination; p != nil && p.Type == PageOffset {
+		href = withQuery(href, map[string]string{
+			p.OffsetParam: "0",
+			p.LimitParam:  strconv.Itoa(p.Limit),
+		})
+	}
+	return href, nil
+}
+
+// records returns the records of a page. When the template does not define a records path, a page that is an
+// array is a list of records and any other page is a single record.
+func (h *HTTPJSON) records(body []byte) []gjson.Result {
+	result := gjson.ParseBytes(body)
+	if h.Prop.Records != "" {
+		result = result.Get(h.Prop.Records)
+	}
+	if !result.Exists() {
+		return nil
+	}
+	if result.IsArray() {
+		return result.Array()
+	}
+	return []gjson.Result{result}
+}
+
+func (h *HTTPJSON) pollData(records []gjson.Result) uint64 {
+	var count uint64
+
+	mat := h.Matrix[h.Object]
+	oldInstances := set.New()
+	for key := range mat.GetInstances() {
+		oldInstances.Add(key)
+	}
+
+	for _, record := range records {
+		if !record.IsObject() {
+			h.Logger.Warn("record is not object, skipping", slog.String("type", record.Type.String()))
+			continue
+		}
+
+		var instanceKey strings.Builder
+		for _, k := range h.Prop.InstanceKeys {
+			value := record.Get(k)
+			if !value.Exists() {
+				h.Logger.Warn("skip record, missing key", slog.String("key", k))
+				instanceKey.Reset()
+				break
+			}
+			instanceKey.WriteString(value.ClonedString())
+		}
+		key := instanceKey.String()
+		if key == "" {
+			continue
+		}
+
+		instance := mat.GetInstance(key)
+		if instance == nil {
+			var err error
+			if instance, err = mat.NewInstance(key); err != nil {
+				h.Logger.Error("failed to create instance", slogx.Err(err), slog.String("key", key))
+				continue
+			}
+		}
+		oldInstances.Remove(key)
+		instance.SetExportable(true)
+		instance.ClearLabels()
+
+		for label, display := range h.Prop.InstanceLabels {
+			if value := record.Get(label); value.Exists() {
+				instance.SetLabel(display, value.ClonedString())
+			}
+		}
+
+		for _, metric := range h.Prop.Metrics {
+			value, ok := metricValue(record.Get(metric.Name))
+			if !ok {
+				continue
+			}
+			metr := mat.GetMetric(metric.Name)
+			if metr == nil {
+				var err error
+				if metr, err = mat.NewMetricFloat64(metric.Name, metric.Label); err != nil {
+					h.Logger.Error("NewMetricFloat64", slogx.Err(err), slog.String("name", metric.Name))
+					continue
+				}
+				metr.SetExportable(metric.Exportable)
+			}
+			metr.SetValueFloat64(instance, value)
+			count++
+		}
+	}
+
+	for key := range oldInstances.Iter() {
+		mat.RemoveInstance(key)
+	}
+
+	return count
+}
+
+// metricValue converts numbers, numeric strings, and booleans to a float
+func metricValue(value gjson.Result) (float64, bool) {
+	switch value.Type {
+	case gjson.Number:
+		return value.Float(), true
+	case gjson.True:
+		return 1, true
+	case gjson.False:
+		return 0, true
+	case gjson.String:
+		f, err := strconv.ParseFloat(value.String(), 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// nextLink returns the next page from the page body at nextPath, or from the rel="next" Link header.
+// Relative links are resolved against the current page. Links to a different scheme or host are rejected,
+// since the client sends its credentials with every request.
+func nextLink(current string, body []byte, header http.Header, nextPath string) (string, error) {
+	var next string
+	if nextPath != "" {
+		next = gjson.GetBytes(body, nextPath).ClonedString()
+	} else {
+		next = linkHeaderNext(header)
+	}
+	if next == "" {
+		return "", nil
+	}
+
+	base, err := url.Parse(current)
+	if err != nil {
+		return "", err
+	}
+	ref, err := url.Parse(next)
+	if err != nil {
+		return "", fmt.Errorf("invalid next link %q: %w", next, err)
+	}
+	resolved := base.ResolveReference(ref)
+	if resolved.Scheme != base.Scheme || resolved.Host != base.Host {
+		return "", fmt.Errorf("next link %s is not on %s://%s", resolved.Redacted(), base.Scheme, base.Host)
+	}
+	return resolved.String(), nil
+}
+
+// linkHeaderNext parses an RFC 8288 Link header like <https://api/items?page=2>; rel="next"
+func linkHeaderNext(header http.Header) string {
+	for _, values := range header.Values("Link") {
+		for link := range strings.SplitSeq(values, ",") {
+			parts := strings.Split(link, ";")
+			target := strings.TrimSpace(parts[0])
+			if !strings.HasPrefix(target, "<") || !strings.HasSuffix(target, ">") {
+				continue
+			}
+			for _, param := range parts[1:] {
+				name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+				if !ok || !strings.EqualFold(name, "rel") {
+					continue
+				}
+				for rel := range strings.FieldsSeq(strings.Trim(value, `"`)) {
+					if strings.EqualFold(rel, "next") {
+						return target[1 : len(target)-1]
+					}
+				}
+			}
+		}
+	}
+	return ""
+}
+
+func withQuery(href string, params map[string]string) string {
+	u, err := url.Parse(href)
+	if err != nil {
+		return href
+	}
+	q := u.Query()
+	for k, v := range params {
+		q.Set(k, v)
+	}
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// Interface guards
+var (
+	_ collector.Collector = (*HTTPJSON)(nil)
+)
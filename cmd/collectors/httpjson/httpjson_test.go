@@ -0,0 +1,253 @@
+package httpjson
+
+import (
+	"encoding/pem"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/netapp/harvest/v2/assert"
+	"github.com/netapp/harvest/v2/cmd/collectors"
+	"github.com/netapp/harvest/v2/cmd/poller/collector"
+	"github.com/netapp/harvest/v2/cmd/poller/options"
+	"github.com/netapp/harvest/v2/pkg/auth"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+)
+
+const pollerName = "test"
+
+func TestMain(m *testing.M) {
+	conf.TestLoadHarvestConfig("testdata/config.yml")
+	os.Exit(m.Run())
+}
+
+func newHTTPJSON(t *testing.T, object string, path string, serverURL string) *HTTPJSON {
+	t.Helper()
+	opts := options.New(options.WithConfPath("../../../conf"))
+	opts.Poller = pollerName
+	opts.HomePath = "testdata"
+	opts.IsTest = true
+
+	ac := collector.New("HTTPJSON", object, opts, collectors.Params(object, path), nil, conf.Remote{})
+	h := &HTTPJSON{}
+	if err := h.Init(ac); err != nil {
+		t.Fatal(err)
+	}
+	h.baseURL = serverURL + "/"
+	return h
+}
+
+func TestAIQUMClusters(t *testing.T) {
+	var authHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader = r.Header.Get("Authorization")
+		file := "testdata/aiqum_clusters_1.json"
+		if r.URL.Query().Get("offset") == "1" {
+			file = "testdata/aiqum_clusters_2.json"
+		}
+		http.ServeFile(w, r, file)
+	}))
+	defer server.Close()
+
+	h := newHTTPJSON(t, "Cluster", "aiqum_cluster.yaml", server.URL)
+	assert.Equal(t, h.Prop.Object, "aiqum_cluster")
+
+	_, err := h.PollData()
+	assert.Nil(t, err)
+	assert.Equal(t, authHeader[:6], "Basic ")
+
+	mat := h.Matrix[h.Object]
+	assert.Equal(t, len(mat.GetInstances()), 2)
+
+	instance := mat.GetInstance("9d2b3a54-7f1e-11ed-b1c3-00a098d3f5a2:type=object,uuid=9d2b3a54-7f1e-11ed-b1c3-00a098d3f5a2")
+	assert.NotNil(t, instance)
+	assert.Equal(t, instance.GetLabel("cluster"), "sti-a900-01")
+	assert.Equal(t, instance.GetLabel("location"), "Sunnyvale")
+
+	nodes, ok := mat.GetMetric("nodes.#").GetValueFloat64(instance)
+	assert.True(t, ok)
+	assert.Equal(t, nodes, 4.0)
+}
+
+func TestPagination(t *testing.T) {
+	tests := []struct {
+		name       string
+		pagination map[string]string
+		handler    func(w http.ResponseWriter, r *http.Request)
+	}{
+		{
+			name:       "link header",
+			pagination: map[string]string{"type": PageLink},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+				if page < 2 {
+					w.Header().Set("Link", `</items?page=`+strconv.Itoa(page+1)+`>; rel="next", </items?page=0>; rel="first"`)
+				}
+				_, _ = w.Write([]byte(`{"items":[{"id":"` + strconv.Itoa(page) + `","size":"10"}]}`))
+			},
+		},
+		{
+			name:       "cursor",
+			pagination: map[string]string{"type": PageCursor, "cursor_path": "meta.next", "cursor_param": "after"},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				after := r.URL.Query().Get("after")
+				next := map[string]string{"": "b", "b": "c", "c": ""}[after]
+				id := map[string]string{"": "0", "b": "1", "c": "2"}[after]
+				_, _ = w.Write([]byte(`{"meta":{"next":"` + next + `"},"items":[{"id":"` + id + `","size":10}]}`))
+			},
+		},
+		{
+			name:       "offset",
+			pagination: map[string]string{"type": PageOffset, "limit": "2"},
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("limit") != "2" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+				switch r.URL.Query().Get("offset") {
+				case "0":
+					_, _ = w.Write([]byte(`{"items":[{"id":"0","size":10},{"id":"1","size":10}]}`))
+				default:
+					_, _ = w.Write([]byte(`{"items":[{"id":"2","size":10}]}`))
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(tt.handler))
+			defer server.Close()
+
+			h := newTestCollector(t, server.URL+"/items", tt.pagination)
+			records, err := h.fetch()
+			assert.Nil(t, err)
+			assert.Equal(t, len(records), 3)
+
+			assert.Equal(t, h.pollData(records), uint64(3))
+			assert.Equal(t, len(h.Matrix[h.Object].GetInstances()), 3)
+		})
+	}
+}
+
+func TestBearer(t *testing.T) {
+	var authHeader string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authHeader = r.Header.Get("Authorization")
+		if r.Header.Get("X-Api-Version") != "2" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`[{"id":"a","size":1}]`))
+	}))
+	defer server.Close()
+
+	h := newTestCollector(t, server.URL+"/items", nil)
+	poller, err := conf.PollerNamed(pollerName)
+	assert.Nil(t, err)
+	h.Client, err = NewClient(poller, h.Client.Timeout, h.Client.auth, AuthBearer)
+	assert.Nil(t, err)
+	h.Client.SetHeader("X-Api-Version", "2")
+	h.Prop.Records = ""
+
+	records, err := h.fetch()
+	assert.Nil(t, err)
+	assert.Equal(t, len(records), 1)
+	assert.Equal(t, authHeader, "Bearer secret")
+}
+
+func TestNoAuthTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		_, _ = w.Write([]byte(`[]`))
+	}))
+	defer server.Close()
+
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	assert.Nil(t, os.WriteFile(caCert, certPEM, 0o600))
+
+	// the server's certificate is only trusted with the poller's ca_cert
+	poller := &conf.Poller{Name: "noauth"}
+	client, err := NewClient(poller, time.Second, auth.NewCredentials(poller, slog.Default()), AuthNone)
+	assert.Nil(t, err)
+	_, _, err = client.Get(server.URL)
+	assert.NotNil(t, err)
+
+	poller = &conf.Poller{Name: "noauth", CaCertPath: caCert}
+	client, err = NewClient(poller, time.Second, auth.NewCredentials(poller, slog.Default()), AuthNone)
+	assert.Nil(t, err)
+	_, _, err = client.Get(server.URL)
+	assert.Nil(t, err)
+}
+
+func TestLinkHeaderNext(t *testing.T) {
+	header := http.Header{}
+	header.Add("Link", `<https://api.example.com/items?page=1>; rel="prev", <https://api.example.com/items?page=3>; rel="next last"`)
+	assert.Equal(t, linkHeaderNext(header), "https://api.example.com/items?page=3")
+	assert.Equal(t, linkHeaderNext(http.Header{}), "")
+
+	next, err := nextLink("https://um.example.com/api/volumes", []byte(`{"_links":{"next":{"href":"/api/volumes?offset=2"}}}`), nil, "_links.next.href")
+	assert.Nil(t, err)
+	assert.Equal(t, next, "https://um.example.com/api/volumes?offset=2")
+
+	// links to another scheme or host would leak the credentials
+	for _, href := range []string{"https://evil.example.com/api/volumes?offset=2", "http://um.example.com/api/volumes?offset=2", "//um.example.com:8443/api"} {
+		next, err = nextLink("https://um.example.com/api/volumes", []byte(`{"next":"`+href+`"}`), nil, "next")
+		assert.NotNil(t, err)
+		assert.Equal(t, next, "")
+	}
+}
+
+func TestParsePagination(t *testing.T) {
+	_, err := parsePagination(paginationParams(map[string]string{"type": "pages"}))
+	assert.NotNil(t, err)
+	_, err = parsePagination(paginationParams(map[string]string{"type": PageCursor}))
+	assert.NotNil(t, err)
+	_, err = parsePagination(paginationParams(map[string]string{"type": PageOffset, "limit": "0"}))
+	assert.NotNil(t, err)
+
+	p, err := parsePagination(paginationParams(map[string]string{"type": PageOffset}))
+	assert.Nil(t, err)
+	assert.Equal(t, p.OffsetParam, "offset")
+	assert.Equal(t, p.LimitParam, "limit")
+	assert.Equal(t, p.Limit, defaultPageLimit)
+}
+
+// newTestCollector returns a collector for the Volume template with its url and pagination replaced
+func newTestCollector(t *testing.T, url string, params map[string]string) *HTTPJSON {
+	t.Helper()
+	h := newHTTPJSON(t, "Volume", "aiqum_volume.yaml", "")
+	h.Prop = &prop{
+		Object:         "item",
+		Query:          url,
+		Records:        "items",
+		InstanceKeys:   []string{"id"},
+		InstanceLabels: map[string]string{"id": "id"},
+		Metrics:        map[string]*Metric{"size": {Label: "size", Name: "size", Exportable: true}},
+		Counters:       map[string]string{"id": "id", "size": "size"},
+	}
+	if params != nil {
+		p, err := parsePagination(paginationParams(params))
+		assert.Nil(t, err)
+		h.Prop.Pagination = p
+	}
+	return h
+}
+
+func paginationParams(params map[string]string) *node.Node {
+	n := node.NewS("pagination")
+	for k, v := range params {
+		n.NewChildS(k, v)
+	}
+	return n
+}
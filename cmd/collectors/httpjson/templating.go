@@ -0,0 +1,192 @@
+package httpjson
+
+import (
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/netapp/harvest/v2/cmd/collectors/rest"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/template"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+)
+
+const (
+	PageLink   = "link"
+	PageCursor = "cursor"
+	PageOffset = "offset"
+
+	defaultPageLimit = 100
+	defaultMaxPages  = 1000
+)
+
+type prop struct {
+	Object         string
+	Query          string // url of the first page, absolute or relative to the poller's addr
+	TemplatePath   string
+	Records        string // gjson path to the records in a page, empty when the page is the records
+	InstanceKeys   []string
+	InstanceLabels map[string]string
+	Metrics        map[string]*Metric
+	Counters       map[string]string
+	Pagination     *pagination
+}
+
+type Metric struct {
+	Label      string
+	Name       string
+	MetricType string
+	Exportable bool
+}
+
+// pagination describes how to request the next page
+//
+//	link:   follow the next link from the Link header, or from the page at next_path
+//	cursor: send the value at cursor_path as the cursor_param query parameter
+//	offset: increase the offset_param query parameter by limit until a page has less than limit records
+type pagination struct {
+	Type        string
+	NextPath    string
+	CursorPath  string
+	CursorParam string
+	OffsetParam string
+	LimitParam  string
+	Limit       int
+	MaxPages    int
+}
+
+func (h *HTTPJSON) LoadTemplate() (string, error) {
+	jitter := h.Params.GetChildContentS("jitter")
+	subTemplate, path, err := h.ImportSubTemplate([]string{""}, rest.TemplateFn(h.Params, h.Object), jitter, h.Remote.Version)
+	if err != nil {
+		return "", err
+	}
+
+	h.Params.Union(subTemplate)
+	return path, nil
+}
+
+func (h *HTTPJSON) InitCache() error {
+	var err error
+
+	if x := h.Params.GetChildContentS("object"); x != "" {
+		h.Prop.Object = x
+	} else {
+		h.Prop.Object = strings.ToLower(h.Object)
+	}
+
+	if e := h.Params.GetChildS("export_options"); e != nil {
+		h.Matrix[h.Object].SetExportOptions(e)
+	}
+
+	if h.Prop.Query = h.Params.GetChildContentS("url"); h.Prop.Query == "" {
+		return errs.New(errs.ErrMissingParam, "url")
+	}
+
+	h.Prop.Records = h.Params.GetChildContentS("records")
+
+	if h.Prop.Pagination, err = parsePagination(h.Params.GetChildS("pagination")); err != nil {
+		return err
+	}
+
+	counters := h.Params.GetChildS("counters")
+	if counters == nil {
+		return errs.New(errs.ErrMissingParam, "counters")
+	}
+	h.ParseCounters(counters)
+
+	if len(h.Prop.InstanceKeys) == 0 {
+		return errs.New(errs.ErrMissingParam, "instance key, mark at least one counter with ^^")
+	}
+
+	return nil
+}
+
+// ParseCounters parses counters the same way as the Rest collector. Names are gjson paths into each record.
+func (h *HTTPJSON) ParseCounters(counters *node.Node) {
+	instanceKeys := make(map[string]string)
+
+	for _, c := range counters.GetAllChildContentS() {
+		if c == "" {
+			continue
+		}
+		name, display, kind, metricType := template.ParseMetric(c)
+		h.Prop.Counters[name] = display
+		switch kind {
+		case "key":
+			h.Prop.InstanceLabels[name] = display
+			instanceKeys[display] = name
+		case "label":
+			h.Prop.InstanceLabels[name] = display
+		case "float":
+			h.Prop.Metrics[name] = &Metric{Label: display, Name: name, MetricType: metricType, Exportable: true}
+		}
+	}
+
+	// sort keys by display name so the instance key does not depend on the order of counters
+	for _, k := range slices.Sorted(maps.Keys(instanceKeys)) {
+		h.Prop.InstanceKeys = append(h.Prop.InstanceKeys, instanceKeys[k])
+	}
+}
+
+func parsePagination(n *node.Node) (*pagination, error) {
+	if n == nil {
+		return nil, nil
+	}
+
+	p := &pagination{
+		Type:        strings.ToLower(n.GetChildContentS("type")),
+		NextPath:    n.GetChildContentS("next_path"),
+		CursorPath:  n.GetChildContentS("cursor_path"),
+		CursorParam: n.GetChildContentS("cursor_param"),
+		OffsetParam: n.GetChildContentS("offset_param"),
+		LimitParam:  n.GetChildContentS("limit_param"),
+		Limit:       defaultPageLimit,
+		MaxPages:    defaultMaxPages,
+	}
+
+	var err error
+	if p.Limit, err = intParam(n, "limit", defaultPageLimit); err != nil {
+		return nil, err
+	}
+	if p.MaxPages, err = intParam(n, "max_pages", defaultMaxPages); err != nil {
+		return nil, err
+	}
+
+	switch p.Type {
+	case PageLink:
+	case PageCursor:
+		if p.CursorPath == "" {
+			return nil, errs.New(errs.ErrMissingParam, "pagination.cursor_path")
+		}
+		if p.CursorParam == "" {
+			p.CursorParam = "cursor"
+		}
+	case PageOffset:
+		if p.OffsetParam == "" {
+			p.OffsetParam = "offset"
+		}
+		if p.LimitParam == "" {
+			p.LimitParam = "limit"
+		}
+	case "":
+		return nil, errs.New(errs.ErrMissingParam, "pagination.type")
+	default:
+		return nil, errs.New(errs.ErrInvalidParam, "pagination.type ("+p.Type+")")
+	}
+
+	return p, nil
+}
+
+func intParam(n *node.Node, name string, defaultValue int) (int, error) {
+	v := n.GetChildContentS(name)
+	if v == "" {
+		return defaultValue, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil || i <= 0 {
+		return 0, errs.New(errs.ErrInvalidParam, "pagination."+name+" ("+v+")")
+	}
+	return i, nil
+}
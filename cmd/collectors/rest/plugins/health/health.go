@@ -14,29 +14,15 @@ import (
 	goversion "github.com/netapp/harvest/v2/third_party/go-version"
 	"github.com/netapp/harvest/v2/third_party/tidwall/gjson"
 	"log/slog"
-	"strconv"
+	"maps"
+	"slices"
 	"strings"
 	"time"
 )
 
-type AlertSeverity string
-
 const (
-	errr                            AlertSeverity = "error"
-	warning                         AlertSeverity = "warning"
-	diskHealthMatrix                              = "health_disk"
-	shelfHealthMatrix                             = "health_shelf"
-	supportHealthMatrix                           = "health_support"
-	nodeHealthMatrix                              = "health_node"
-	haHealthMatrix                                = "health_ha"
-	networkEthernetPortHealthMatrix               = "health_network_ethernet_port"
-	networkFCPortHealthMatrix                     = "health_network_fc_port"
-	lifHealthMatrix                               = "health_lif"
-	volumeRansomwareHealthMatrix                  = "health_volume_ransomware"
-	volumeMoveHealthMatrix                        = "health_volume_move"
-	licenseHealthMatrix                           = "health_license"
-	emsHealthMatrix                               = "health_ems"
-	severityLabel                                 = "severity"
+	emsHealthMatrix = "health_ems"
+	severityLabel   = "severity"
 )
 
 type Health struct {
@@ -46,6 +32,7 @@ type Health struct {
 	previousData   map[string]*matrix.Matrix
 	resolutionData map[string]*matrix.Matrix
 	emsSeverity    []string
+	rules          []*rule
 }
 
 func New(p *plugin.AbstractPlugin) plugin.Plugin {
@@ -64,6 +51,10 @@ func (h *Health) Init(remote conf.Remote) error {
 		return err
 	}
 
+	if h.rules, err = loadRules(h.Params.GetChildS("rules")); err != nil {
+		return err
+	}
+
 	if err := h.InitAllMatrix(); err != nil {
 		return err
 	}
@@ -94,10 +85,11 @@ func (h *Health) Init(remote conf.Remote) error {
 func (h *Health) InitAllMatrix() error {
 	h.data = make(map[string]*matrix.Matrix)
 	h.resolutionData = make(map[string]*matrix.Matrix)
-	mats := []string{diskHealthMatrix, shelfHealthMatrix, supportHealthMatrix, nodeHealthMatrix,
-		networkEthernetPortHealthMatrix, networkFCPortHealthMatrix, lifHealthMatrix,
-		volumeRansomwareHealthMatrix, volumeMoveHealthMatrix, licenseHealthMatrix, haHealthMatrix}
-	for _, m := range mats {
+	for _, r := range h.rules {
+		m := r.matrixName()
+		if _, ok := h.data[m]; ok {
+			continue
+		}
 		if err := h.initMatrix(m, "", h.data); err != nil {
 			return err
 		}
@@ -166,17 +158,16 @@ func (h *Health) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *coll
 		return nil, nil, err
 	}
 
-	diskAlertCount := h.collectDiskAlerts()
-	shelfAlertCount := h.collectShelfAlerts()
-	supportAlertCount := h.collectSupportAlerts()
-	nodeAlertCount := h.collectNodeAlerts()
-	HAAlertCount := h.collectHAAlerts()
-	networkEthernetPortAlertCount := h.collectNetworkEthernetPortAlerts()
-	networkFcpPortAlertCount := h.collectNetworkFCPortAlerts()
-	networkInterfaceAlertCount := h.collectNetworkInterfacesAlerts()
-	volumeRansomwareAlertCount := h.collectVolumeRansomwareAlerts()
-	volumeMoveAlertCount := h.collectVolumeMoveAlerts()
-	licenseAlertCount := h.collectLicenseAlerts()
+	ruleAlertCounts := make(map[string]int)
+	ruleAlertCount := 0
+	for _, r := range h.rules {
+		if !r.applies(ontapVersion, h.Remote) {
+			continue
+		}
+		count := h.collectRuleAlerts(r)
+		ruleAlertCounts[r.name] += count
+		ruleAlertCount += count
+	}
 	emsAlertCount := h.collectEmsAlerts(emsMat)
 
 	resolutionInstancesCount := h.generateResolutionMetrics()
@@ -192,484 +183,63 @@ func (h *Health) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *coll
 	}
 
 	result = append(result, emsMat)
+
+	alertAttrs := make([]any, 0, len(ruleAlertCounts))
+	for _, name := range slices.Sorted(maps.Keys(ruleAlertCounts)) {
+		alertAttrs = append(alertAttrs, slog.Int(name, ruleAlertCounts[name]))
+	}
 	h.SLogger.Info(
 		"Health plugin",
-		slog.Int("numLicenseAlerts", licenseAlertCount),
-		slog.Int("numVolumeMoveAlerts", volumeMoveAlertCount),
-		slog.Int("numVolumeRansomwareAlerts", volumeRansomwareAlertCount),
-		slog.Int("numNetworkInterfaceAlerts", networkInterfaceAlertCount),
-		slog.Int("numNetworkFcpPortAlerts", networkFcpPortAlertCount),
-		slog.Int("numNetworkEthernetPortAlerts", networkEthernetPortAlertCount),
-		slog.Int("numHAAlerts", HAAlertCount),
-		slog.Int("numNodeAlerts", nodeAlertCount),
-		slog.Int("numSupportAlerts", supportAlertCount),
-		slog.Int("numShelfAlerts", shelfAlertCount),
-		slog.Int("numDiskAlerts", diskAlertCount),
+		slog.Group("numRuleAlerts", alertAttrs...),
 		slog.Int("numEmsAlerts", emsAlertCount),
 		slog.Int("numResolutionInstanceCount", resolutionInstancesCount),
 	)
 
 	//nolint:gosec
-	h.RequestMetadata.PluginInstances.Store(uint64(diskAlertCount + shelfAlertCount + supportAlertCount + nodeAlertCount + HAAlertCount + networkEthernetPortAlertCount + networkFcpPortAlertCount +
-		networkInterfaceAlertCount + volumeRansomwareAlertCount + volumeMoveAlertCount + licenseAlertCount + emsAlertCount + resolutionInstancesCount))
+	h.RequestMetadata.PluginInstances.Store(uint64(ruleAlertCount + emsAlertCount + resolutionInstancesCount))
 
 	return result, &h.RequestMetadata, nil
 }
 
-func (h *Health) collectLicenseAlerts() int {
-	var (
-		instance *matrix.Instance
-	)
-	licenseAlertCount := 0
-	records, err := h.getNonCompliantLicense()
-	if err != nil {
-		if errs.IsRestErr(err, errs.APINotFound) {
-			h.SLogger.Debug("API not found", slogx.Err(err))
-		} else {
-			h.SLogger.Error("Failed to collect license data", slogx.Err(err))
-		}
-		return 0
-	}
-	mat := h.data[licenseHealthMatrix]
-	for _, record := range records {
-		name := record.Get("name").ClonedString()
-		scope := record.Get("scope").ClonedString()
-		state := record.Get("state").ClonedString()
-		instance, err = mat.NewInstance(name)
-		if err != nil {
-			h.SLogger.Warn("error while creating instance", slog.String("key", name))
-			continue
-		}
-		licenseAlertCount++
-		instance.SetLabel("name", name)
-		instance.SetLabel("scope", scope)
-		instance.SetLabel("state", state)
-		instance.SetLabel(severityLabel, string(errr))
-
-		h.setAlertMetric(mat, instance, 1)
-	}
-
-	return licenseAlertCount
-}
-
-func (h *Health) collectVolumeMoveAlerts() int {
-	var (
-		instance *matrix.Instance
-	)
-	// The volume move command is not available for these systems.
-	if h.Remote.IsAFX() || h.Remote.IsASAr2() {
-		return 0
-	}
-	volumeMoveAlertCount := 0
-	records, err := h.getMoveFailedVolumes()
-	if err != nil {
-		if errs.IsRestErr(err, errs.APINotFound) {
-			h.SLogger.Debug("API not found", slogx.Err(err))
-		} else {
-			h.SLogger.Error("Failed to collect volume move data", slogx.Err(err))
-		}
-		return 0
-	}
-	mat := h.data[volumeMoveHealthMatrix]
-	for _, record := range records {
-		uuid := record.Get("uuid").ClonedString()
-		volume := record.Get("name").ClonedString()
-		svm := record.Get("svm.name").ClonedString()
-		movementState := record.Get("movement.state").ClonedString()
-		instance, err = mat.NewInstance(uuid)
-		if err != nil {
-			h.SLogger.Warn("error while creating instance", slog.String("key", uuid))
-			continue
-		}
-		volumeMoveAlertCount++
-		instance.SetLabel("movement_state", movementState)
-		instance.SetLabel("svm", svm)
-		instance.SetLabel("volume", volume)
-		instance.SetLabel(severityLabel, string(warning))
-
-		h.setAlertMetric(mat, instance, 1)
-	}
-	return volumeMoveAlertCount
-}
-
-func (h *Health) collectVolumeRansomwareAlerts() int {
-	var (
-		instance *matrix.Instance
-	)
-	volumeRansomwareAlertCount := 0
-	clusterVersion := h.Remote.Version
-	ontapVersion, err := goversion.NewVersion(clusterVersion)
-	if err != nil {
-		h.SLogger.Error("Failed to parse version", slogx.Err(err), slog.String("version", clusterVersion))
-		return 0
-	}
-	version910 := "9.10"
-	version910After, err := goversion.NewVersion(version910)
-	if err != nil {
-		h.SLogger.Error("Failed to parse version", slogx.Err(err), slog.String("version", version910))
-		return 0
-	}
-
-	if ontapVersion.LessThan(version910After) {
-		return 0
-	}
-	records, err := h.getRansomwareVolumes()
-	if err != nil {
-		if errs.IsRestErr(err, errs.APINotFound) {
-			h.SLogger.Debug("API not found", slogx.Err(err))
-		} else {
-			h.SLogger.Error("Failed to collect ransomware data", slogx.Err(err))
-		}
-		return 0
-	}
-	mat := h.data[volumeRansomwareHealthMatrix]
-	for _, record := range records {
-		uuid := record.Get("uuid").ClonedString()
-		volume := record.Get("name").ClonedString()
-		antiRansomwareAttackProbability := record.Get("anti_ransomware.attack_probability").ClonedString()
-		instance, err = mat.NewInstance(uuid)
-		if err != nil {
-			h.SLogger.Warn("error while creating instance", slog.String("key", uuid))
-			continue
-		}
-		volumeRansomwareAlertCount++
-		instance.SetLabel("anti_ransomware_attack_probability", antiRansomwareAttackProbability)
-
-		instance.SetLabel("volume", volume)
-		instance.SetLabel(severityLabel, string(errr))
-
-		h.setAlertMetric(mat, instance, 1)
-	}
-	return volumeRansomwareAlertCount
-}
-
-func (h *Health) collectNetworkInterfacesAlerts() int {
-	var (
-		instance *matrix.Instance
-	)
-	networkInterfaceAlertCount := 0
-	records, err := h.getNonHomeLIFs()
-	if err != nil {
-		if errs.IsRestErr(err, errs.APINotFound) {
-			h.SLogger.Debug("API not found", slogx.Err(err))
-		} else {
-			h.SLogger.Error("Failed to collect network interfaces data", slogx.Err(err))
-		}
-		return 0
-	}
-	mat := h.data[lifHealthMatrix]
-	for _, record := range records {
-		uuid := record.Get("uuid").ClonedString()
-		lif := record.Get("name").ClonedString()
-		svm := record.Get("svm.name").ClonedString()
-		isHome := record.Get("location.is_home").ClonedString()
-		instance, err = mat.NewInstance(uuid)
-		if err != nil {
-			h.SLogger.Warn("error while creating instance", slog.String("key", uuid))
-			continue
-		}
-		networkInterfaceAlertCount++
-		instance.SetLabel("svm", svm)
-		instance.SetLabel("isHome", isHome)
-		instance.SetLabel("lif", lif)
-		instance.SetLabel(severityLabel, string(warning))
-
-		h.setAlertMetric(mat, instance, 1)
-	}
-	return networkInterfaceAlertCount
-}
-
-func (h *Health) collectNetworkFCPortAlerts() int {
-	var (
-		instance *matrix.Instance
-	)
-	networkFcpPortAlertCount := 0
-	records, err := h.getFCPorts()
-	if err != nil {
-		if errs.IsRestErr(err, errs.APINotFound) {
-			h.SLogger.Debug("API not found", slogx.Err(err))
-		} else {
-			h.SLogger.Error("Failed to collect fc port data", slogx.Err(err))
-		}
-		return 0
-	}
-	mat := h.data[networkFCPortHealthMatrix]
-	for _, record := range records {
-		uuid := record.Get("uuid").ClonedString()
-		nodeName := record.Get("node.name").ClonedString()
-		port := record.Get("name").ClonedString()
-		state := record.Get("state").ClonedString()
-		instance, err = mat.NewInstance(uuid)
-		if err != nil {
-			h.SLogger.Warn("error while creating instance", slog.String("key", uuid))
-			continue
-		}
-		networkFcpPortAlertCount++
-		instance.SetLabel("node", nodeName)
-		instance.SetLabel("state", state)
-		instance.SetLabel("port", port)
-		instance.SetLabel(severityLabel, string(errr))
-
-		h.setAlertMetric(mat, instance, 1)
-	}
-	return networkFcpPortAlertCount
-}
-
-func (h *Health) collectNetworkEthernetPortAlerts() int {
-	var (
-		instance *matrix.Instance
-	)
-	networkEthernetPortAlertCount := 0
-	records, err := h.getEthernetPorts()
-	if err != nil {
-		if errs.IsRestErr(err, errs.APINotFound) {
-			h.SLogger.Debug("API not found", slogx.Err(err))
-		} else {
-			h.SLogger.Error("Failed to collect ethernet port data", slogx.Err(err))
-		}
-		return 0
-	}
-	mat := h.data[networkEthernetPortHealthMatrix]
-	for _, record := range records {
-		uuid := record.Get("uuid").ClonedString()
-		port := record.Get("name").ClonedString()
-		nodeName := record.Get("node.name").ClonedString()
-		portType := record.Get("type").ClonedString()
-		state := record.Get("state").ClonedString()
-		instance, err = mat.NewInstance(uuid)
-		if err != nil {
-			h.SLogger.Warn("error while creating instance", slog.String("key", uuid))
-			continue
-		}
-		networkEthernetPortAlertCount++
-		instance.SetLabel("node", nodeName)
-		instance.SetLabel("state", state)
-		instance.SetLabel("port", port)
-		instance.SetLabel("type", portType)
-		instance.SetLabel(severityLabel, string(errr))
-
-		h.setAlertMetric(mat, instance, 1)
-	}
-	return networkEthernetPortAlertCount
-}
-
-func (h *Health) collectNodeAlerts() int {
-	var (
-		instance *matrix.Instance
-	)
-	nodeAlertCount := 0
-	records, err := h.getNodes()
-	if err != nil {
-		if errs.IsRestErr(err, errs.APINotFound) {
-			h.SLogger.Debug("API not found", slogx.Err(err))
-		} else {
-			h.SLogger.Error("Failed to collect node data", slogx.Err(err))
-		}
-		return 0
-	}
-	mat := h.data[nodeHealthMatrix]
-	for _, record := range records {
-		nodeName := record.Get("node").ClonedString()
-
-		instance, err = mat.NewInstance(nodeName)
-		if err != nil {
-			h.SLogger.Warn("error while creating instance", slog.String("key", nodeName))
-			continue
-		}
-		nodeAlertCount++
-		instance.SetLabel("node", nodeName)
-		instance.SetLabel("healthy", "false")
-		instance.SetLabel(severityLabel, string(errr))
-
-		h.setAlertMetric(mat, instance, 1)
-	}
-	return nodeAlertCount
-}
-
-func (h *Health) collectHAAlerts() int {
-	var (
-		instance *matrix.Instance
-	)
-	HAAlertCount := 0
-	possible := "possible"
-	if h.Remote.IsAFX() {
-		possible = "takeover_of_possible"
-	}
-	records, err := h.getHADown(possible)
-	if err != nil {
-		if errs.IsRestErr(err, errs.APINotFound) {
-			h.SLogger.Debug("API not found", slogx.Err(err))
-		} else {
-			h.SLogger.Error("Failed to collect HA data", slogx.Err(err))
-		}
-		return 0
-	}
-
-	// Build a map of partner names to check if a node with empty mode is someone's partner
-	partnerNodes := make(map[string]bool)
-	for _, record := range records {
-		partnerName := record.Get("partner_name").ClonedString()
-		if partnerName != "" {
-			partnerNodes[partnerName] = true
-		}
-	}
-
-	mat := h.data[haHealthMatrix]
-	for _, record := range records {
-		nodeName := record.Get("node").ClonedString()
-		takeoverPossible := record.Get(possible).ClonedString()
-		partnerName := record.Get("partner_name").ClonedString()
-		stateDescription := record.Get("state_description").ClonedString()
-		partnerState := record.Get("partner_state").ClonedString()
-		mode := record.Get("mode").ClonedString()
-		if takeoverPossible == "" {
-			takeoverPossible = "false"
-		}
-
-		// Only process if mode is "ha" OR if this node is a partner in some other record
-		if mode == "ha" || (mode == "" && partnerNodes[nodeName]) {
-			instance, err = mat.NewInstance(nodeName)
-			if err != nil {
-				h.SLogger.Warn("error while creating instance", slog.String("key", nodeName))
-				continue
-			}
-			HAAlertCount++
-			instance.SetLabel("node", nodeName)
-			instance.SetLabel("takeover_possible", takeoverPossible)
-			instance.SetLabel("partner", partnerName)
-			instance.SetLabel("state_description", stateDescription)
-			instance.SetLabel("partner_state", partnerState)
-			instance.SetLabel(severityLabel, string(errr))
-
-			h.setAlertMetric(mat, instance, 1)
-		}
-	}
-	return HAAlertCount
-}
+// collectRuleAlerts queries the cluster for the rule and adds an instance per alert to the rule's matrix.
+func (h *Health) collectRuleAlerts(r *rule) int {
+	href := rest.NewHrefBuilder().
+		APIPath(r.query).
+		Fields(r.fields).
+		MaxRecords(collectors.DefaultBatchSize).
+		Filter(r.filter).
+		Build()
 
-func (h *Health) collectShelfAlerts() int {
-	var (
-		instance *matrix.Instance
-	)
-	shelfAlertCount := 0
-	records, err := h.getShelves()
+	records, err := collectors.InvokeRestCall(h.client, href)
 	if err != nil {
 		if errs.IsRestErr(err, errs.APINotFound) {
-			h.SLogger.Debug("API not found", slogx.Err(err))
+			h.SLogger.Debug("API not found", slogx.Err(err), slog.String("rule", r.name))
 		} else {
-			h.SLogger.Error("Failed to collect shelf data", slogx.Err(err))
+			h.SLogger.Error("Failed to collect health data", slogx.Err(err), slog.String("rule", r.name))
 		}
 		return 0
 	}
-	mat := h.data[shelfHealthMatrix]
-	for _, record := range records {
-		shelf := record.Get("shelf").ClonedString()
-		errorType := record.Get("error_type").ClonedString()
-		errorSeverity := record.Get("error_severity").ClonedString()
-		errorText := record.Get("error_text").ClonedString()
-
-		// errorSeverity possible values are unknown|notice|warning|error|critical
-		if errorSeverity == "error" || errorSeverity == "critical" || errorSeverity == "warning" {
-			instance, err = mat.NewInstance(shelf)
-			if err != nil {
-				h.SLogger.Warn("error while creating instance", slog.String("key", shelf))
-				continue
-			}
-			shelfAlertCount++
-			instance.SetLabel("shelf", shelf)
-			instance.SetLabel("error_type", errorType)
-			instance.SetLabel("error_text", errorText)
-			if errorSeverity == "error" || errorSeverity == "critical" {
-				instance.SetLabel(severityLabel, string(errr))
-			} else {
-				instance.SetLabel(severityLabel, string(warning))
-			}
-
-			h.setAlertMetric(mat, instance, 1)
-		}
-	}
-	return shelfAlertCount
-}
 
-func (h *Health) collectSupportAlerts() int {
-	var (
-		instance *matrix.Instance
-	)
-	supportAlertCount := 0
-	filter := []string{"suppress=false"}
-
-	records, err := h.getSupportAlerts(filter)
+	alerts, err := r.evaluate(records)
 	if err != nil {
-		if errs.IsRestErr(err, errs.APINotFound) {
-			h.SLogger.Debug("API not found", slogx.Err(err))
-		} else {
-			h.SLogger.Error("Failed to collect support data", slogx.Err(err))
-		}
+		h.SLogger.Error("Failed to evaluate health rule", slogx.Err(err), slog.String("rule", r.name))
 		return 0
 	}
-	mat := h.data[supportHealthMatrix]
-	for index, record := range records {
-		nodeName := record.Get("node.name").ClonedString()
-		monitor := record.Get("monitor").ClonedString()
-		name := record.Get("name").ClonedString()
-		resource := record.Get("resource").ClonedString()
-		reason := record.Get("cause.message").ClonedString()
-		correctiveAction := record.Get("corrective_action.message").ClonedString()
-		instance, err = mat.NewInstance(strconv.Itoa(index))
-		if err != nil {
-			h.SLogger.Warn("error while creating instance", slog.Int("key", index))
-			continue
-		}
-		supportAlertCount++
-		instance.SetLabel("node", nodeName)
-		instance.SetLabel("monitor", monitor)
-		instance.SetLabel("name", name)
-		instance.SetLabel("resource", resource)
-		instance.SetLabel("reason", reason)
-		instance.SetLabel("correctiveAction", correctiveAction)
-		instance.SetLabel(severityLabel, string(warning))
-
-		h.setAlertMetric(mat, instance, 1)
-	}
-	return supportAlertCount
-}
 
-func (h *Health) collectDiskAlerts() int {
-	var (
-		instance *matrix.Instance
-	)
-	diskAlertCount := 0
-	records, err := h.getDisks()
-	if err != nil {
-		if errs.IsRestErr(err, errs.APINotFound) {
-			h.SLogger.Debug("API not found", slogx.Err(err))
-		} else {
-			h.SLogger.Error("Failed to collect disk data", slogx.Err(err))
-		}
-		return 0
-	}
-	mat := h.data[diskHealthMatrix]
-	for _, record := range records {
-		name := record.Get("name").ClonedString()
-		containerType := record.Get("container_type").ClonedString()
-		instance, err = mat.NewInstance(name)
+	alertCount := 0
+	mat := h.data[r.matrixName()]
+	for _, a := range alerts {
+		instance, err := mat.NewInstance(a.key)
 		if err != nil {
-			h.SLogger.Warn("error while creating instance", slog.String("key", name))
+			h.SLogger.Warn("error while creating instance", slog.String("key", a.key), slog.String("rule", r.name))
 			continue
 		}
-		diskAlertCount++
-		instance.SetLabel("disk", name)
-		instance.SetLabel("container_type", containerType)
-		switch containerType {
-		case "broken":
-			instance.SetLabel(severityLabel, string(errr))
-		case "unassigned":
-			instance.SetLabel(severityLabel, string(warning))
-		}
+		alertCount++
+		instance.SetLabels(a.labels)
 
 		h.setAlertMetric(mat, instance, 1)
 	}
-	return diskAlertCount
+	return alertCount
 }
 
 func (h *Health) collectEmsAlerts(emsMat *matrix.Matrix) int {
@@ -717,136 +287,6 @@ func (h *Health) collectEmsAlerts(emsMat *matrix.Matrix) int {
 	return emsAlertCount
 }
 
-func (h *Health) getDisks() ([]gjson.Result, error) {
-	fields := []string{"name", "container_type"}
-	query := "api/storage/disks"
-	href := rest.NewHrefBuilder().
-		APIPath(query).
-		Fields(fields).
-		MaxRecords(collectors.DefaultBatchSize).
-		Filter([]string{"container_type=broken|unassigned"}).
-		Build()
-
-	return collectors.InvokeRestCall(h.client, href)
-}
-
-func (h *Health) getShelves() ([]gjson.Result, error) {
-	fields := []string{"error_type", "error_severity", "error_text"}
-	query := "api/private/cli/storage/shelf"
-	href := rest.NewHrefBuilder().
-		APIPath(query).
-		Fields(fields).
-		MaxRecords(collectors.DefaultBatchSize).
-		Build()
-
-	return collectors.InvokeRestCall(h.client, href)
-}
-
-func (h *Health) getNodes() ([]gjson.Result, error) {
-	fields := []string{"health"}
-	query := "api/private/cli/node"
-	href := rest.NewHrefBuilder().
-		APIPath(query).
-		Fields(fields).
-		MaxRecords(collectors.DefaultBatchSize).
-		Filter([]string{"health=false"}).
-		Build()
-
-	return collectors.InvokeRestCall(h.client, href)
-}
-
-func (h *Health) getHADown(possible string) ([]gjson.Result, error) {
-	fields := []string{possible, "partner_name,state_description,partner_state"}
-	// mode field is not available in AFX. We reply on partner mapping for HA Alerts
-	if !h.Remote.IsAFX() {
-		fields = append(fields, "mode")
-	}
-	query := "api/private/cli/storage/failover"
-	href := rest.NewHrefBuilder().
-		APIPath(query).
-		Fields(fields).
-		MaxRecords(collectors.DefaultBatchSize).
-		Filter([]string{possible + "=!true"}).
-		Build()
-
-	return collectors.InvokeRestCall(h.client, href)
-}
-
-func (h *Health) getRansomwareVolumes() ([]gjson.Result, error) {
-	query := "api/storage/volumes"
-	href := rest.NewHrefBuilder().
-		APIPath(query).
-		MaxRecords(collectors.DefaultBatchSize).
-		Filter([]string{"anti_ransomware.state=enabled", "anti_ransomware.attack_probability=low|moderate|high"}).
-		Build()
-
-	return collectors.InvokeRestCall(h.client, href)
-}
-
-func (h *Health) getNonCompliantLicense() ([]gjson.Result, error) {
-	query := "api/cluster/licensing/licenses"
-	fields := []string{"name,scope,state"}
-	href := rest.NewHrefBuilder().
-		APIPath(query).
-		Fields(fields).
-		MaxRecords(collectors.DefaultBatchSize).
-		Filter([]string{"state=noncompliant"}).
-		Build()
-
-	return collectors.InvokeRestCall(h.client, href)
-}
-
-func (h *Health) getMoveFailedVolumes() ([]gjson.Result, error) {
-	query := "api/storage/volumes"
-	fields := []string{"uuid,name,movement.state,svm"}
-	href := rest.NewHrefBuilder().
-		APIPath(query).
-		Fields(fields).
-		MaxRecords(collectors.DefaultBatchSize).
-		Filter([]string{"movement.state=cutover_wait|failed|cutover_pending"}).
-		Build()
-
-	return collectors.InvokeRestCall(h.client, href)
-}
-
-func (h *Health) getNonHomeLIFs() ([]gjson.Result, error) {
-	query := "api/network/ip/interfaces"
-	href := rest.NewHrefBuilder().
-		APIPath(query).
-		Fields([]string{"svm", "location"}).
-		MaxRecords(collectors.DefaultBatchSize).
-		Filter([]string{"location.is_home=false"}).
-		Build()
-
-	return collectors.InvokeRestCall(h.client, href)
-}
-
-func (h *Health) getFCPorts() ([]gjson.Result, error) {
-	fields := []string{"name,node"}
-	query := "api/network/fc/ports"
-	href := rest.NewHrefBuilder().
-		APIPath(query).
-		Fields(fields).
-		MaxRecords(collectors.DefaultBatchSize).
-		Filter([]string{"enabled=true", "state=offlined_by_system"}).
-		Build()
-
-	return collectors.InvokeRestCall(h.client, href)
-}
-
-func (h *Health) getEthernetPorts() ([]gjson.Result, error) {
-	fields := []string{"name,node"}
-	query := "api/network/ethernet/ports"
-	href := rest.NewHrefBuilder().
-		APIPath(query).
-		Fields(fields).
-		MaxRecords(collectors.DefaultBatchSize).
-		Filter([]string{"enabled=true", "state=down"}).
-		Build()
-
-	return collectors.InvokeRestCall(h.client, href)
-}
-
 func (h *Health) getEmsAlerts() ([]gjson.Result, error) {
 	clusterTime, err := collectors.GetClusterTime(h.client, nil, h.SLogger)
 	if err != nil {
@@ -867,17 +307,6 @@ func (h *Health) getEmsAlerts() ([]gjson.Result, error) {
 	return collectors.InvokeRestCall(h.client, href)
 }
 
-func (h *Health) getSupportAlerts(filter []string) ([]gjson.Result, error) {
-	query := "api/private/support/alerts"
-	href := rest.NewHrefBuilder().
-		APIPath(query).
-		MaxRecords(collectors.DefaultBatchSize).
-		Filter(filter).
-		Build()
-
-	return collectors.InvokeRestCall(h.client, href)
-}
-
 func (h *Health) setAlertMetric(mat *matrix.Matrix, instance *matrix.Instance, value float64) {
 	m, err := mat.GetOrCreateMetric("alerts")
 	if err != nil {
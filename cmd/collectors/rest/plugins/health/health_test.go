@@ -2,11 +2,16 @@ package health
 
 import (
 	"log/slog"
+	"slices"
 	"testing"
 
 	"github.com/netapp/harvest/v2/assert"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/conf"
 	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	goversion "github.com/netapp/harvest/v2/third_party/go-version"
+	"github.com/netapp/harvest/v2/third_party/tidwall/gjson"
 )
 
 func TestEndPoll(t *testing.T) {
@@ -15,6 +20,7 @@ func TestEndPoll(t *testing.T) {
 	h.SLogger = slog.Default()
 	h.data = make(map[string]*matrix.Matrix)
 	h.previousData = make(map[string]*matrix.Matrix)
+	h.rules, _ = loadRules(nil)
 	_ = h.InitAllMatrix()
 
 	matName := "health_lif"
@@ -54,3 +60,140 @@ func TestEndPoll(t *testing.T) {
 		assert.Equal(t, actualLabel, expectedLabel)
 	}
 }
+
+func TestDefaultRules(t *testing.T) {
+	rules, err := loadRules(nil)
+	assert.Nil(t, err)
+
+	var names []string
+	for _, r := range rules {
+		if !slices.Contains(names, r.matrixName()) {
+			names = append(names, r.matrixName())
+		}
+	}
+	slices.Sort(names)
+	want := []string{"health_disk", "health_ha", "health_license", "health_lif", "health_network_ethernet_port",
+		"health_network_fc_port", "health_node", "health_shelf", "health_support", "health_volume_move",
+		"health_volume_ransomware"}
+	assert.Equal(t, names, want)
+}
+
+func TestCustomRules(t *testing.T) {
+	params, err := tree.LoadYaml([]byte(`
+rules:
+  - name: shelf
+    query: api/storage/shelves
+    key: name
+    labels:
+      - name => shelf
+    severity: warning
+  - name: aggregate_mirror
+    query: api/storage/aggregates
+    filter:
+      - block_storage.mirror.enabled=false
+    key: uuid
+    labels:
+      - name => aggr
+      - node.name => node
+    severity: error
+    message: "{{.aggr}} on {{.node}} is not mirrored"
+`))
+	assert.Nil(t, err)
+	rules, err := loadRules(params.GetChildS("rules"))
+	assert.Nil(t, err)
+
+	shelves := 0
+	var mirror *rule
+	for _, r := range rules {
+		if r.name == "shelf" {
+			shelves++
+			assert.Equal(t, r.query, "api/storage/shelves")
+		}
+		if r.name == "aggregate_mirror" {
+			mirror = r
+		}
+	}
+	assert.Equal(t, shelves, 1)
+	assert.NotNil(t, mirror)
+
+	records := gjson.Parse(`[{"uuid": "u1", "name": "aggr1", "node": {"name": "node1"}}]`).Array()
+	alerts, err := mirror.evaluate(records)
+	assert.Nil(t, err)
+	assert.Equal(t, len(alerts), 1)
+	assert.Equal(t, alerts[0].key, "u1")
+	assert.Equal(t, alerts[0].labels, map[string]string{
+		"aggr":     "aggr1",
+		"node":     "node1",
+		"message":  "aggr1 on node1 is not mirrored",
+		"severity": "error",
+	})
+}
+
+func TestInvalidRules(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+	}{
+		{name: "missing query", yaml: "rules:\n  - name: a\n    severity: error\n"},
+		{name: "missing severity", yaml: "rules:\n  - name: a\n    query: api/storage/disks\n"},
+		{name: "unknown select", yaml: "rules:\n  - name: a\n    query: api/storage/disks\n    severity: error\n    select: pairs\n"},
+		{name: "unknown platform", yaml: "rules:\n  - name: a\n    query: api/storage/disks\n    severity: error\n    platforms:\n      - fas\n"},
+		{name: "bad message", yaml: "rules:\n  - name: a\n    query: api/storage/disks\n    severity: error\n    message: \"{{.a\"\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := tree.LoadYaml([]byte(tt.yaml))
+			assert.Nil(t, err)
+			_, err = loadRules(params.GetChildS("rules"))
+			assert.NotNil(t, err)
+		})
+	}
+}
+
+func TestEvaluateDefaultRules(t *testing.T) {
+	rules, err := loadRules(nil)
+	assert.Nil(t, err)
+	byName := func(name string, remote conf.Remote) *rule {
+		version, _ := goversion.NewVersion("9.16.1")
+		for _, r := range rules {
+			if r.name == name && r.applies(version, remote) {
+				return r
+			}
+		}
+		return nil
+	}
+
+	// Severity is mapped from error_severity and other records are skipped
+	shelf := byName("shelf", conf.Remote{})
+	alerts, err := shelf.evaluate(gjson.Parse(`[
+		{"shelf": "1.1", "error_type": "fan", "error_severity": "critical", "error_text": "fan failed"},
+		{"shelf": "1.2", "error_type": "psu", "error_severity": "notice", "error_text": "psu"},
+		{"shelf": "1.3", "error_type": "temp", "error_severity": "warning", "error_text": "hot"}
+	]`).Array())
+	assert.Nil(t, err)
+	assert.Equal(t, len(alerts), 2)
+	assert.Equal(t, alerts[0].labels[severityLabel], "error")
+	assert.Equal(t, alerts[1].key, "1.3")
+	assert.Equal(t, alerts[1].labels[severityLabel], "warning")
+
+	// Nodes without a mode are only included when they are the partner of another node
+	ha := byName("ha", conf.Remote{})
+	alerts, err = ha.evaluate(gjson.Parse(`[
+		{"node": "n1", "mode": "ha", "partner_name": "n2", "possible": false},
+		{"node": "n2", "mode": "", "partner_name": "n1"},
+		{"node": "n3", "mode": "", "partner_name": ""},
+		{"node": "n4", "mode": "non_ha"}
+	]`).Array())
+	assert.Nil(t, err)
+	assert.Equal(t, len(alerts), 2)
+	assert.Equal(t, alerts[0].labels["takeover_possible"], "false")
+	assert.Equal(t, alerts[1].labels["partner"], "n1")
+	assert.Equal(t, alerts[1].labels["takeover_possible"], "false")
+
+	afx := conf.Remote{IsDisaggregated: true}
+	assert.Equal(t, byName("ha", afx).fields[0], "takeover_of_possible")
+	assert.Nil(t, byName("volume_move", afx))
+
+	version, _ := goversion.NewVersion("9.9.1")
+	assert.False(t, byName("volume_ransomware", conf.Remote{}).applies(version, conf.Remote{}))
+}
@@ -0,0 +1,288 @@
+package health
+
+import (
+	_ "embed"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	goversion "github.com/netapp/harvest/v2/third_party/go-version"
+	"github.com/netapp/harvest/v2/third_party/tidwall/gjson"
+)
+
+//go:embed default_rules.yaml
+var defaultRulesYaml []byte
+
+const (
+	messageLabel  = "message"
+	haPairsSelect = "ha_pairs"
+	platformAFX   = "afx"
+	platformASAr2 = "asar2"
+)
+
+// rule is one health check. Each record returned by its query becomes an alert instance in the
+// health_<name> matrix.
+type rule struct {
+	name          string
+	query         string
+	fields        []string
+	filter        []string
+	key           string
+	labels        []ruleLabel
+	labelDefaults map[string]string
+	severity      string
+	severityField string
+	severities    map[string]string
+	minVersion    *goversion.Version
+	platforms     []string
+	skipPlatforms []string
+	selector      string
+	message       *template.Template
+}
+
+type ruleLabel struct {
+	field string
+	label string
+}
+
+// loadRules returns the default rules with the rules of the plugin's template merged in.
+// A template rule replaces all default rules with the same name.
+func loadRules(custom *node.Node) ([]*rule, error) {
+	root, err := tree.LoadYaml(defaultRulesYaml)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load default health rules: %w", err)
+	}
+	defaults, err := parseRules(root.GetChildS("rules"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid default health rules: %w", err)
+	}
+	rules, err := parseRules(custom)
+	if err != nil {
+		return nil, err
+	}
+
+	overridden := make(map[string]bool, len(rules))
+	for _, r := range rules {
+		overridden[r.name] = true
+	}
+	merged := make([]*rule, 0, len(defaults)+len(rules))
+	for _, r := range defaults {
+		if !overridden[r.name] {
+			merged = append(merged, r)
+		}
+	}
+	return append(merged, rules...), nil
+}
+
+func parseRules(n *node.Node) ([]*rule, error) {
+	if n == nil {
+		return nil, nil
+	}
+	rules := make([]*rule, 0, len(n.GetChildren()))
+	for i, child := range n.GetChildren() {
+		r, err := parseRule(child)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d: %w", i, err)
+		}
+		rules = append(rules, r)
+	}
+	return rules, nil
+}
+
+func parseRule(n *node.Node) (*rule, error) {
+	r := &rule{
+		name:          n.GetChildContentS("name"),
+		query:         n.GetChildContentS("query"),
+		key:           n.GetChildContentS("key"),
+		selector:      n.GetChildContentS("select"),
+		labelDefaults: make(map[string]string),
+	}
+	if r.name == "" {
+		return nil, errs.New(errs.ErrMissingParam, "name")
+	}
+	if r.query == "" {
+		return nil, errs.New(errs.ErrMissingParam, r.name+": query")
+	}
+	if r.selector != "" && r.selector != haPairsSelect {
+		return nil, errs.New(errs.ErrInvalidParam, r.name+": unknown select "+r.selector)
+	}
+	if f := n.GetChildS("fields"); f != nil {
+		r.fields = f.GetAllChildContentS()
+	}
+	if f := n.GetChildS("filter"); f != nil {
+		r.filter = f.GetAllChildContentS()
+	}
+
+	if l := n.GetChildS("labels"); l != nil {
+		for _, label := range l.GetAllChildContentS() {
+			field, name, found := strings.Cut(label, "=>")
+			field = strings.TrimSpace(field)
+			name = strings.TrimSpace(name)
+			if !found {
+				name = strings.ReplaceAll(field, ".", "_")
+			}
+			if field == "" || name == "" {
+				return nil, errs.New(errs.ErrInvalidParam, r.name+": label "+label)
+			}
+			r.labels = append(r.labels, ruleLabel{field: field, label: name})
+		}
+	}
+	if d := n.GetChildS("label_defaults"); d != nil {
+		for _, c := range d.GetChildren() {
+			r.labelDefaults[c.GetNameS()] = c.GetContentS()
+		}
+	}
+
+	severity := n.GetChildS("severity")
+	switch {
+	case severity == nil:
+		return nil, errs.New(errs.ErrMissingParam, r.name+": severity")
+	case len(severity.GetChildren()) == 0:
+		r.severity = severity.GetContentS()
+	default:
+		r.severityField = severity.GetChildContentS("field")
+		r.severities = make(map[string]string)
+		if values := severity.GetChildS("values"); values != nil {
+			for _, c := range values.GetChildren() {
+				r.severities[c.GetNameS()] = c.GetContentS()
+			}
+		}
+		if r.severityField == "" || len(r.severities) == 0 {
+			return nil, errs.New(errs.ErrInvalidParam, r.name+": severity requires a field and values")
+		}
+	}
+	if r.severityField == "" && r.severity == "" {
+		return nil, errs.New(errs.ErrMissingParam, r.name+": severity")
+	}
+
+	if v := n.GetChildContentS("min_version"); v != "" {
+		minVersion, err := goversion.NewVersion(v)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid min_version %s: %w", r.name, v, err)
+		}
+		r.minVersion = minVersion
+	}
+	if p := n.GetChildS("platforms"); p != nil {
+		r.platforms = p.GetAllChildContentS()
+	}
+	if p := n.GetChildS("skip_platforms"); p != nil {
+		r.skipPlatforms = p.GetAllChildContentS()
+	}
+	for _, p := range slices.Concat(r.platforms, r.skipPlatforms) {
+		if p != platformAFX && p != platformASAr2 {
+			return nil, errs.New(errs.ErrInvalidParam, r.name+": unknown platform "+p)
+		}
+	}
+
+	if m := n.GetChildContentS("message"); m != "" {
+		message, err := template.New(r.name).Option("missingkey=zero").Parse(m)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid message: %w", r.name, err)
+		}
+		r.message = message
+	}
+
+	return r, nil
+}
+
+func (r *rule) matrixName() string {
+	return "health_" + r.name
+}
+
+// applies reports whether the rule should run against a cluster with this version and platform.
+func (r *rule) applies(version *goversion.Version, remote conf.Remote) bool {
+	if r.minVersion != nil && version.LessThan(r.minVersion) {
+		return false
+	}
+	platforms := make([]string, 0, 2)
+	if remote.IsAFX() {
+		platforms = append(platforms, platformAFX)
+	}
+	if remote.IsASAr2() {
+		platforms = append(platforms, platformASAr2)
+	}
+	for _, p := range r.skipPlatforms {
+		if slices.Contains(platforms, p) {
+			return false
+		}
+	}
+	for _, p := range r.platforms {
+		if slices.Contains(platforms, p) {
+			return true
+		}
+	}
+	return len(r.platforms) == 0
+}
+
+// alert is an instance of a rule built from one record.
+type alert struct {
+	key    string
+	labels map[string]string
+}
+
+// evaluate converts the records returned by the rule's query into alerts.
+// Records whose severity field has no mapping are skipped.
+func (r *rule) evaluate(records []gjson.Result) ([]alert, error) {
+	var partners map[string]bool
+	if r.selector == haPairsSelect {
+		// Build a map of partner names to check if a node with empty mode is someone's partner
+		partners = make(map[string]bool)
+		for _, record := range records {
+			if partnerName := record.Get("partner_name").ClonedString(); partnerName != "" {
+				partners[partnerName] = true
+			}
+		}
+	}
+
+	alerts := make([]alert, 0, len(records))
+	for index, record := range records {
+		if partners != nil {
+			// Only process if mode is "ha" OR if this node is a partner in some other record
+			mode := record.Get("mode").ClonedString()
+			nodeName := record.Get("node").ClonedString()
+			if mode != "ha" && (mode != "" || !partners[nodeName]) {
+				continue
+			}
+		}
+
+		severity := r.severity
+		if r.severityField != "" {
+			var ok bool
+			if severity, ok = r.severities[record.Get(r.severityField).ClonedString()]; !ok {
+				continue
+			}
+		}
+
+		key := strconv.Itoa(index)
+		if r.key != "" {
+			key = record.Get(r.key).ClonedString()
+		}
+
+		labels := make(map[string]string, len(r.labels)+2)
+		for _, l := range r.labels {
+			labels[l.label] = record.Get(l.field).ClonedString()
+		}
+		for label, value := range r.labelDefaults {
+			if labels[label] == "" {
+				labels[label] = value
+			}
+		}
+		if r.message != nil {
+			var b strings.Builder
+			if err := r.message.Execute(&b, labels); err != nil {
+				return nil, fmt.Errorf("%s: failed to render message: %w", r.name, err)
+			}
+			labels[messageLabel] = b.String()
+		}
+		labels[severityLabel] = severity
+
+		alerts = append(alerts, alert{key: key, labels: labels})
+	}
+	return alerts, nil
+}
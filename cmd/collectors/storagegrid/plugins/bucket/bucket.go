@@ -3,10 +3,13 @@ package bucket
 import (
 	"github.com/netapp/harvest/v2/cmd/collectors/storagegrid/rest"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/auth"
 	"github.com/netapp/harvest/v2/pkg/collector"
 	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
 	"github.com/netapp/harvest/v2/pkg/matrix"
 	"github.com/netapp/harvest/v2/pkg/slogx"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
 	"github.com/netapp/harvest/v2/third_party/tidwall/gjson"
 	"log/slog"
 )
@@ -20,8 +23,9 @@ var metricToJSON = map[string]string{
 
 type Bucket struct {
 	*plugin.AbstractPlugin
-	client *rest.Client
-	data   *matrix.Matrix
+	client  *rest.Client
+	tenants map[string]*rest.Client // tenant management API clients by account ID
+	data    *matrix.Matrix
 }
 
 func New(p *plugin.AbstractPlugin) plugin.Plugin {
@@ -37,7 +41,7 @@ func (b *Bucket) Init(remote conf.Remote) error {
 	}
 
 	clientTimeout := b.ParentParams.GetChildContentS("client_timeout")
-	if b.client, err = rest.NewClient(b.Options.Poller, clientTimeout, b.Auth); err != nil {
+	if b.client, err = rest.NewClientFunc(b.Options.Poller, clientTimeout, b.Auth); err != nil {
 		b.SLogger.Error("connecting", slogx.Err(err))
 		return err
 	}
@@ -46,6 +50,10 @@ func (b *Bucket) Init(remote conf.Remote) error {
 		return err
 	}
 
+	if err := b.initTenants(remote, clientTimeout); err != nil {
+		return err
+	}
+
 	b.data = matrix.New(b.Parent+".Bucket", "bucket", "bucket")
 	for k := range metricToJSON {
 		_, _ = b.data.NewMetricFloat64(k)
@@ -54,10 +62,6 @@ func (b *Bucket) Init(remote conf.Remote) error {
 }
 
 func (b *Bucket) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *collector.Metadata, error) {
-	var (
-		instanceKey string
-	)
-
 	data := dataMap[b.Object]
 	// Purge and reset data
 	b.data.PurgeInstances()
@@ -69,10 +73,9 @@ func (b *Bucket) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *coll
 
 	// request the buckets for each tenant
 	for instKey, inst := range data.GetInstances() {
-		href := "grid/accounts/" + instKey + "/usage?includeBucketDetail=true"
 		var records []gjson.Result
-		err := b.client.Fetch(href, &records)
 		tenantName := inst.GetLabel("tenant")
+		err := b.fetchUsage(instKey, &records)
 		if err != nil {
 			b.SLogger.Error("Unable to fetch bucket details",
 				slogx.Err(err),
@@ -81,65 +84,165 @@ func (b *Bucket) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *coll
 			)
 			continue
 		}
+		b.addBuckets(instKey, tenantName, records)
+	}
 
-		for _, record := range records {
-			if !record.IsObject() {
-				b.SLogger.Warn("Bucket is not object, skipping", slog.String("type", record.Type.String()))
-				continue
-			}
+	return []*matrix.Matrix{b.data}, b.client.Metadata, nil
+}
+
+// initTenants creates tenant management API clients for the tenants listed in the plugin parameters.
+// Buckets of these tenants are collected with the tenant's credentials.
+//
+//	plugins:
+//	  - Bucket:
+//	      tenants:
+//	        - account_id: 12345678901234567890
+//	          credentials_file: secrets/tenants.yml
+//
+// Tenant credentials are resolved like poller credentials, from a credentials_file, credentials_script, or
+// credentials_provider, with the tenant's account ID in place of the poller name. When none is set, the tenant is
+// looked up in the poller's credentials_file.
+func (b *Bucket) initTenants(remote conf.Remote, clientTimeout string) error {
+	b.tenants = make(map[string]*rest.Client)
+
+	tenants := b.Params.GetChildS("tenants")
+	if tenants == nil {
+		return nil
+	}
+
+	poller, err := conf.PollerNamed(b.Options.Poller)
+	if err != nil {
+		return err
+	}
+
+	for _, t := range tenants.GetChildren() {
+		tenantPoller, err := newTenantPoller(poller, t)
+		if err != nil {
+			return err
+		}
+		client, err := rest.NewClientFunc(b.Options.Poller, clientTimeout, b.Auth)
+		if err != nil {
+			return err
+		}
+		client.APIPath = b.client.APIPath
+		client.Remote = remote
+		client.Metadata = b.client.Metadata
+		client.SetTenant(&rest.TenantAuth{
+			AccountID: tenantPoller.Name,
+			Auth:      auth.NewCredentials(tenantPoller, b.SLogger),
+		})
+		b.tenants[tenantPoller.Name] = client
+	}
+	return nil
+}
 
-			bucketsJSON := record.Get("buckets")
-			for _, bucketJSON := range bucketsJSON.Array() {
-				bucket := bucketJSON.Get("name").ClonedString()
-				region := bucketJSON.Get("region").ClonedString()
-				versioningEnabled := bucketJSON.Get("versioningEnabled").ClonedString()
-
-				instanceKey = instKey + "#" + bucket
-				bucketInstance, err2 := b.data.NewInstance(instanceKey)
-				if err2 != nil {
-					b.SLogger.Error("Failed to add instance",
-						slog.Any("err", err2),
-						slog.String("instanceKey", instanceKey),
-					)
-					break
+// newTenantPoller returns a poller that holds the credential settings of the tenant. It is named after the
+// tenant's account ID, so the account ID is the key in credential files and the {poller} of credential providers.
+func newTenantPoller(poller *conf.Poller, t *node.Node) (*conf.Poller, error) {
+	accountID := t.GetChildContentS("account_id")
+	if accountID == "" {
+		return nil, errs.New(errs.ErrMissingParam, "tenants.account_id")
+	}
+	if t.GetChildS("password") != nil {
+		return nil, errs.New(errs.ErrInvalidParam,
+			"tenant "+accountID+": password is not supported, use credentials_file, credentials_script, or credentials_provider")
+	}
+
+	p := &conf.Poller{
+		Name:            accountID,
+		Addr:            poller.Addr,
+		Username:        t.GetChildContentS("username"),
+		CredentialsFile: t.GetChildContentS("credentials_file"),
+		UseInsecureTLS:  poller.UseInsecureTLS,
+		CaCertPath:      poller.CaCertPath,
+		TLSMinVersion:   poller.TLSMinVersion,
+	}
+	if script := t.GetChildS("credentials_script"); script != nil {
+		p.CredentialsScript = conf.CredentialsScript{
+			Path:     script.GetChildContentS("path"),
+			Schedule: script.GetChildContentS("schedule"),
+			Timeout:  script.GetChildContentS("timeout"),
+		}
+	}
+	if provider := t.GetChildS("credentials_provider"); provider != nil {
+		p.CredentialsProvider = conf.CredentialsProviderFromNode(provider)
+	}
+
+	if p.CredentialsFile == "" && p.CredentialsScript.Path == "" && p.CredentialsProvider.Type == "" {
+		if poller.CredentialsFile == "" {
+			return nil, errs.New(errs.ErrMissingParam,
+				"tenant "+accountID+": credentials_file, credentials_script, or credentials_provider")
+		}
+		p.CredentialsFile = poller.CredentialsFile
+	}
+	return p, nil
+}
+
+// fetchUsage requests the usage of a tenant, including bucket details.
+// Tenants with configured credentials use the tenant management API, other tenants use the grid management API.
+func (b *Bucket) fetchUsage(tenantID string, records *[]gjson.Result) error {
+	if client, ok := b.tenants[tenantID]; ok {
+		return client.Fetch("org/usage?includeBucketDetail=true", records)
+	}
+	return b.client.Fetch("grid/accounts/"+tenantID+"/usage?includeBucketDetail=true", records)
+}
+
+func (b *Bucket) addBuckets(tenantID string, tenantName string, records []gjson.Result) {
+	for _, record := range records {
+		if !record.IsObject() {
+			b.SLogger.Warn("Bucket is not object, skipping", slog.String("type", record.Type.String()))
+			continue
+		}
+
+		bucketsJSON := record.Get("buckets")
+		for _, bucketJSON := range bucketsJSON.Array() {
+			bucket := bucketJSON.Get("name").ClonedString()
+			region := bucketJSON.Get("region").ClonedString()
+			versioningEnabled := bucketJSON.Get("versioningEnabled").ClonedString()
+
+			instanceKey := tenantID + "#" + bucket
+			bucketInstance, err := b.data.NewInstance(instanceKey)
+			if err != nil {
+				b.SLogger.Error("Failed to add instance",
+					slog.Any("err", err),
+					slog.String("instanceKey", instanceKey),
+				)
+				break
+			}
+			b.SLogger.Debug("add instance", slog.String("instanceKey", instanceKey))
+			bucketInstance.SetLabel("bucket", bucket)
+			bucketInstance.SetLabel("tenant", tenantName)
+			bucketInstance.SetLabel("region", region)
+			for metricKey, m := range b.data.GetMetrics() {
+				if metricKey == "labels" {
+					bucketInstance.SetLabel("versioningEnabled", versioningEnabled)
+					bucketInstance.SetLabel("tenantID", tenantID)
+					m.SetValueFloat64(bucketInstance, 1.0)
+					continue
 				}
-				b.SLogger.Debug("add instance", slog.String("instanceKey", instanceKey))
-				bucketInstance.SetLabel("bucket", bucket)
-				bucketInstance.SetLabel("tenant", tenantName)
-				bucketInstance.SetLabel("region", region)
-				for metricKey, m := range b.data.GetMetrics() {
-					if metricKey == "labels" {
-						bucketInstance.SetLabel("versioningEnabled", versioningEnabled)
-						bucketInstance.SetLabel("tenantID", instKey)
-						m.SetValueFloat64(bucketInstance, 1.0)
+				jsonKey := metricToJSON[metricKey]
+				if value := bucketJSON.Get(jsonKey); value.Exists() {
+					valueStr := value.ClonedString()
+					if valueStr == "" {
 						continue
 					}
-					jsonKey := metricToJSON[metricKey]
-					if value := bucketJSON.Get(jsonKey); value.Exists() {
-						valueStr := value.ClonedString()
-						if valueStr == "" {
-							continue
-						}
-						if err = m.SetValueString(bucketInstance, valueStr); err != nil {
-							b.SLogger.Error(
-								"Unable to set float key on metric",
-								slogx.Err(err),
-								slog.String("key", metricKey),
-								slog.String("metric", m.GetName()),
-								slog.String("value", valueStr),
-							)
-						} else {
-							b.SLogger.Debug(
-								"added",
-								slog.String("metricKey", metricKey),
-								slog.String("value", valueStr),
-							)
-						}
+					if err = m.SetValueString(bucketInstance, valueStr); err != nil {
+						b.SLogger.Error(
+							"Unable to set float key on metric",
+							slogx.Err(err),
+							slog.String("key", metricKey),
+							slog.String("metric", m.GetName()),
+							slog.String("value", valueStr),
+						)
+					} else {
+						b.SLogger.Debug(
+							"added",
+							slog.String("metricKey", metricKey),
+							slog.String("value", valueStr),
+						)
 					}
 				}
 			}
 		}
 	}
-
-	return []*matrix.Matrix{b.data}, b.client.Metadata, nil
 }
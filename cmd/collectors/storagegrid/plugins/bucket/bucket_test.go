@@ -0,0 +1,123 @@
+package bucket
+
+import (
+	"log/slog"
+	"os"
+	"testing"
+
+	"github.com/netapp/harvest/v2/assert"
+	"github.com/netapp/harvest/v2/cmd/collectors/storagegrid/rest"
+	"github.com/netapp/harvest/v2/cmd/poller/options"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/auth"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"github.com/netapp/harvest/v2/third_party/tidwall/gjson"
+)
+
+func newBucket(t *testing.T, params *node.Node) *Bucket {
+	t.Helper()
+	conf.TestLoadHarvestConfig("../../testdata/config.yml")
+	rest.NewClientFunc = func(_ string, _ string, _ *auth.Credentials) (*rest.Client, error) {
+		return rest.NewDummyClient(), nil
+	}
+	opts := options.New()
+	opts.Poller = "test"
+	b := New(plugin.New("StorageGrid", opts, params, node.NewS("parent"), "tenant", nil)).(*Bucket)
+	remote := conf.Remote{Name: "TestCluster", UUID: "TestUUID", Version: "11.6.0"}
+	assert.Nil(t, b.Init(remote))
+	return b
+}
+
+func TestAddBuckets(t *testing.T) {
+	b := newBucket(t, node.NewS("Bucket"))
+
+	output, err := os.ReadFile("../../testdata/bucket_usage.json")
+	assert.Nil(t, err)
+	records := gjson.GetBytes(output, "data").Array()
+
+	b.addBuckets("93344766954338342034", "aron", records)
+
+	instances := b.data.GetInstances()
+	assert.Equal(t, len(instances), 2)
+
+	logs := b.data.GetInstance("93344766954338342034#logs")
+	assert.NotNil(t, logs)
+	assert.Equal(t, logs.GetLabel("tenant"), "aron")
+	assert.Equal(t, logs.GetLabel("region"), "us-east-1")
+	assert.Equal(t, logs.GetLabel("versioningEnabled"), "true")
+
+	objects, ok := b.data.GetMetric("objects").GetValueFloat64(logs)
+	assert.True(t, ok)
+	assert.Equal(t, objects, 1200.0)
+	bytes, ok := b.data.GetMetric("bytes").GetValueFloat64(logs)
+	assert.True(t, ok)
+	assert.Equal(t, bytes, 5390000.0)
+
+	backups := b.data.GetInstance("93344766954338342034#backups")
+	assert.NotNil(t, backups)
+	_, ok = b.data.GetMetric("quota_bytes").GetValueFloat64(backups)
+	assert.False(t, ok)
+}
+
+func TestTenantClients(t *testing.T) {
+	params := node.NewS("Bucket")
+	tenants := params.NewChildS("tenants", "")
+	t1 := tenants.NewChildS("", "")
+	t1.NewChildS("account_id", "93344766954338342034")
+	t1.NewChildS("credentials_file", "../../testdata/tenant_credentials.yml")
+
+	b := newBucket(t, params)
+
+	assert.Equal(t, len(b.tenants), 1)
+	client, ok := b.tenants["93344766954338342034"]
+	assert.True(t, ok)
+	assert.Equal(t, client.APIPath, b.client.APIPath)
+}
+
+func TestTenantPoller(t *testing.T) {
+	poller := &conf.Poller{Name: "sg", Addr: "10.0.0.1", CredentialsFile: "secrets.yml"}
+
+	tenant := func(params map[string]string) *node.Node {
+		n := node.NewS("")
+		for k, v := range params {
+			n.NewChildS(k, v)
+		}
+		return n
+	}
+
+	// tenant credentials are read from the credentials file with the account ID as key
+	p, err := newTenantPoller(poller, tenant(map[string]string{
+		"account_id":       "93344766954338342034",
+		"credentials_file": "../../testdata/tenant_credentials.yml",
+	}))
+	assert.Nil(t, err)
+	assert.Equal(t, p.Name, "93344766954338342034")
+	assert.Equal(t, p.Addr, "10.0.0.1")
+	pollerAuth, err := auth.NewCredentials(p, slog.Default()).GetPollerAuth()
+	assert.Nil(t, err)
+	assert.Equal(t, pollerAuth.Username, "monitor")
+	assert.Equal(t, pollerAuth.Password, "pass")
+
+	// without credential settings, the poller's credentials file is used
+	p, err = newTenantPoller(poller, tenant(map[string]string{"account_id": "1"}))
+	assert.Nil(t, err)
+	assert.Equal(t, p.CredentialsFile, "secrets.yml")
+
+	n := tenant(map[string]string{"account_id": "1"})
+	n.NewChildS("credentials_provider", "").NewChildS("type", "env")
+	p, err = newTenantPoller(poller, n)
+	assert.Nil(t, err)
+	assert.Equal(t, p.CredentialsProvider.Type, "env")
+	assert.Equal(t, p.CredentialsFile, "")
+
+	// passwords in templates are rejected
+	_, err = newTenantPoller(poller, tenant(map[string]string{"account_id": "1", "username": "a", "password": "secret"}))
+	assert.NotNil(t, err)
+
+	_, err = newTenantPoller(poller, tenant(map[string]string{"username": "a"}))
+	assert.NotNil(t, err)
+
+	_, err = newTenantPoller(&conf.Poller{Name: "sg"}, tenant(map[string]string{"account_id": "1"}))
+	assert.NotNil(t, err)
+}
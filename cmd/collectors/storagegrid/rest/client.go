@@ -42,9 +42,17 @@ type Client struct {
 	logRest  bool // used to log Rest request/response
 	APIPath  string
 	auth     *auth.Credentials
+	tenant   *TenantAuth // when set, the client authenticates to the tenant management API
 	Metadata *collector.Metadata
 }
 
+// TenantAuth holds the credentials of a tenant account user.
+// Tenant clients use the tenant management API (org/...) instead of the grid management API.
+type TenantAuth struct {
+	AccountID string
+	Auth      *auth.Credentials
+}
+
 func NewClient(pollerName string, clientTimeout string, c *auth.Credentials) (*Client, error) {
 	var (
 		poller  *conf.Poller
@@ -209,14 +217,15 @@ func (c *Client) invoke() ([]byte, error) {
 		if storageGridErr, ok := errors.AsType[errs.StorageGridError](err); ok {
 			if storageGridErr.IsAuthErr() {
 				// If using authToken from credential script, expire cache before retry
-				// so fetchTokenWithAuthRetry gets fresh token instead of cached expired one
-				pollerAuth, authErr := c.auth.GetPollerAuth()
+				// so fetchTokenWithAuthRetry gets fresh token instead of cached expired one.
+				// Tenant clients expire the tenant's credentials, not the poller's.
+				pollerAuth, authErr := c.credentials().GetPollerAuth()
 				if authErr != nil {
 					return nil, authErr
 				}
-				if pollerAuth.HasCredentialScript && pollerAuth.AuthToken != "" {
+				if pollerAuth.Refreshable && pollerAuth.AuthToken != "" {
 					c.Logger.Debug("Expiring cached credential script token after 401 response")
-					c.auth.Expire()
+					c.credentials().Expire()
 				}
 
 				err2 := c.fetchTokenWithAuthRetry()
@@ -352,8 +361,23 @@ func check(i int) int {
 }
 
 type authBody struct {
-	Username string `json:"username"`
-	Password string `json:"password"`
+	AccountID string `json:"accountId,omitempty"`
+	Username  string `json:"username"`
+	Password  string `json:"password"`
+}
+
+// SetTenant switches the client to authenticate as a tenant user
+func (c *Client) SetTenant(tenant *TenantAuth) {
+	c.tenant = tenant
+	c.token = ""
+}
+
+// credentials returns the tenant's credentials for tenant clients, and the poller's otherwise
+func (c *Client) credentials() *auth.Credentials {
+	if c.tenant != nil {
+		return c.tenant.Auth
+	}
+	return c.auth
 }
 
 func (c *Client) fetchTokenWithAuthRetry() error {
@@ -368,7 +392,7 @@ func (c *Client) fetchTokenWithAuthRetry() error {
 		if err != nil {
 			return fmt.Errorf("failed to create auth URL err: %w", err)
 		}
-		pollerAuth, err := c.auth.GetPollerAuth()
+		pollerAuth, err := c.credentials().GetPollerAuth()
 		if err != nil {
 			return err
 		}
@@ -383,6 +407,9 @@ func (c *Client) fetchTokenWithAuthRetry() error {
 			Username: pollerAuth.Username,
 			Password: pollerAuth.Password,
 		}
+		if c.tenant != nil {
+			authB.AccountID = c.tenant.AccountID
+		}
 		// #nosec G117 -- StorageGRID requires credentials in the JSON request body.
 		postBody, err := json.Marshal(authB)
 		if err != nil {
@@ -436,12 +463,12 @@ func (c *Client) fetchTokenWithAuthRetry() error {
 			// If this is an auth failure and the client is using a credential script,
 			// expire the current credentials, call the script again, and try again
 			if storageGridErr.IsAuthErr() {
-				pollerAuth, err2 := c.auth.GetPollerAuth()
+				pollerAuth, err2 := c.credentials().GetPollerAuth()
 				if err2 != nil {
 					return err2
 				}
-				if pollerAuth.HasCredentialScript {
-					c.auth.Expire()
+				if pollerAuth.Refreshable {
+					c.credentials().Expire()
 					return fetchToken()
 				}
 			}
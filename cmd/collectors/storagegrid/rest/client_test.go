@@ -0,0 +1,53 @@
+package rest
+
+import (
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/netapp/harvest/v2/assert"
+	"github.com/netapp/harvest/v2/pkg/auth"
+	"github.com/netapp/harvest/v2/pkg/conf"
+)
+
+func TestTenantAuthRetry(t *testing.T) {
+	var tokens []string
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := r.Header.Get("Authorization")
+		tokens = append(tokens, token)
+		if token != "Bearer tenant-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"code":401,"message":{"text":"token expired"}}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"data":[]}`))
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	assert.Nil(t, err)
+
+	grid := &conf.Poller{Name: "sg", Addr: u.Host, Username: "admin", Password: "grid", UseInsecureTLS: new(true)}
+	client, err := New(grid, time.Minute, auth.NewCredentials(grid, slog.Default()))
+	assert.Nil(t, err)
+	client.APIPath = "/api/v4"
+
+	// the tenant's token is cached until a request is rejected
+	t.Setenv("HARVEST_12345_AUTH_TOKEN", "tenant-1")
+	tenant := &conf.Poller{Name: "12345", Addr: u.Host, CredentialsProvider: conf.CredentialsProvider{Type: auth.ProviderEnv}}
+	tenantAuth := auth.NewCredentials(tenant, slog.Default())
+	client.SetTenant(&TenantAuth{AccountID: "12345", Auth: tenantAuth})
+	pollerAuth, err := tenantAuth.GetPollerAuth()
+	assert.Nil(t, err)
+	assert.Equal(t, pollerAuth.AuthToken, "tenant-1")
+
+	// a 401 expires the tenant's credentials, so the retry uses the new tenant token
+	t.Setenv("HARVEST_12345_AUTH_TOKEN", "tenant-2")
+	_, err = client.GetGridRest("org/usage")
+	assert.Nil(t, err)
+	assert.Equal(t, len(tokens), 2)
+	assert.Equal(t, tokens[0], "Bearer")
+	assert.Equal(t, tokens[1], "Bearer tenant-2")
+}
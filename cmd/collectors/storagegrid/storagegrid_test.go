@@ -55,3 +55,55 @@ func testFile(t *testing.T, sg *StorageGrid, filename string, expectedLen int) {
 
 	assert.Equal(t, len(sg.Matrix[sg.Object].GetInstances()), expectedLen)
 }
+
+func Test_SGNodeHealth(t *testing.T) {
+	conf.TestLoadHarvestConfig("testdata/config.yml")
+
+	sg, err := newStorageGrid("Node", "node.yaml")
+	assert.Nil(t, err)
+
+	testFile(t, sg, "testdata/node_health.json", 3)
+
+	mat := sg.Matrix[sg.Object]
+	sn2 := mat.GetInstance("8c6f8e3c-1f88-4a7d-9c9e-2a3d6e5c3c03")
+	assert.NotNil(t, sn2)
+	assert.Equal(t, sn2.GetLabel("node"), "DC1-SN2")
+	assert.Equal(t, sn2.GetLabel("site"), "Data Center 1")
+	assert.Equal(t, sn2.GetLabel("state"), "administratively-down")
+	assert.Equal(t, sn2.GetLabel("severity"), "critical")
+	assert.Equal(t, sn2.GetLabel("type"), "storageNode")
+}
+
+func Test_SGILMPrometheus(t *testing.T) {
+	conf.TestLoadHarvestConfig("testdata/config.yml")
+
+	sg, err := newStorageGrid("ILM", "ilm.yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, sg.Props.Query, "prometheus")
+	_, ok := sg.Props.Metrics["storagegrid_ilm_awaiting_client_evaluation_objects_per_second"]
+	assert.True(t, ok)
+
+	output, err := os.ReadFile("testdata/ilm_awaiting_client_objects.json")
+	assert.Nil(t, err)
+	records := []gjson.Result{gjson.GetBytes(output, "data")}
+
+	mat, err := sg.makePromMetrics("storagegrid_ilm_awaiting_client_objects", &records, nil)
+	assert.Nil(t, err)
+	assert.Equal(t, len(mat.GetInstances()), 2)
+
+	metric := mat.GetMetric("storagegrid_ilm_awaiting_client_objects")
+	assert.NotNil(t, metric)
+	for _, instance := range mat.GetInstances() {
+		value, ok := metric.GetValueFloat64(instance)
+		assert.True(t, ok)
+		switch instance.GetLabel("node") {
+		case "DC1-SN1":
+			assert.Equal(t, value, 1532.0)
+		case "DC1-SN2":
+			assert.Equal(t, value, 0.0)
+		default:
+			t.Errorf("unexpected node %s", instance.GetLabel("node"))
+		}
+		assert.Equal(t, instance.GetLabel("site_name"), "Data Center 1")
+	}
+}
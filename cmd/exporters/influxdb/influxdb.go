@@ -50,6 +50,14 @@ var protectedFieldNames = map[string]string{
 	"expiry_time":  "harvest_expiry_time",
 }
 
+// FieldName returns the name a metric is written as, renaming the protected field names
+func FieldName(name string) string {
+	if rename, has := protectedFieldNames[name]; has {
+		return rename
+	}
+	return name
+}
+
 type InfluxDB struct {
 	*exporter.AbstractExporter
 	client *http.Client
@@ -328,11 +336,7 @@ func (e *InfluxDB) Render(data *matrix.Matrix) ([][]byte, exporter.Stats, error)
 				fieldName = fnb.String()
 			}
 
-			if rename, has := protectedFieldNames[fieldName]; has {
-				fieldName = rename
-			}
-
-			m.AddField(fieldName, value)
+			m.AddField(FieldName(fieldName), value)
 			countTmp++
 		}
 
@@ -24,10 +24,12 @@ import (
 	"fmt"
 	"github.com/netapp/harvest/v2/cmd/admin"
 	"github.com/netapp/harvest/v2/cmd/harvest/version"
+	"github.com/netapp/harvest/v2/cmd/tools/cardinality"
 	"github.com/netapp/harvest/v2/cmd/tools/doctor"
 	"github.com/netapp/harvest/v2/cmd/tools/generate"
 	"github.com/netapp/harvest/v2/cmd/tools/grafana"
 	"github.com/netapp/harvest/v2/cmd/tools/rest"
+	"github.com/netapp/harvest/v2/cmd/tools/template"
 	"github.com/netapp/harvest/v2/cmd/tools/zapi"
 	"github.com/netapp/harvest/v2/pkg/conf"
 	"github.com/netapp/harvest/v2/pkg/ps"
@@ -539,7 +541,9 @@ func init() {
 	rootCmd.AddCommand(manageCmd("kill", true))
 	rootCmd.AddCommand(zapi.Cmd, rest.Cmd, grafana.Cmd)
 	rootCmd.AddCommand(generate.Cmd)
+	rootCmd.AddCommand(template.Cmd)
 	rootCmd.AddCommand(doctor.Cmd)
+	rootCmd.AddCommand(cardinality.Cmd)
 	rootCmd.AddCommand(version.Cmd())
 	rootCmd.AddCommand(admin.Cmd())
 
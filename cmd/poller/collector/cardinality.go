@@ -0,0 +1,254 @@
+package collector
+
+import (
+	"log/slog"
+	"maps"
+	"slices"
+
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+)
+
+const cardinalityObject = "metadata_cardinality"
+
+// cardinalityGuard enforces the poller's cardinality limits on the matrices a collector exports.
+// It reports the instances of each object, and the instances it dropped, in the metadata_cardinality matrix.
+// The matrix is exported even when the poller has no limits, so harvest cardinality can report every poller.
+//
+// Instances and label values that were exported by the previous poll are admitted first, so that a new
+// instance does not replace an existing one when an object is at its limit.
+type cardinalityGuard struct {
+	limits   conf.Cardinality
+	logger   *slog.Logger
+	uuid     string
+	object   string // the collector's object, it makes the metadata matrix unique among the collectors of an exporter
+	globals  map[string]string
+	states   map[string]*cardinalityState // keyed by matrix identifier
+	metadata *matrix.Matrix
+}
+
+type cardinalityState struct {
+	instances map[string]bool            // instance keys admitted by the previous poll
+	values    map[string]map[string]bool // label -> values admitted by the previous poll
+	dropped   int
+}
+
+func newCardinalityGuard(limits conf.Cardinality, uuid string, object string, globals map[string]string, logger *slog.Logger) *cardinalityGuard {
+	globals = maps.Clone(globals)
+	// each row has the object of its matrix
+	delete(globals, "object")
+	return &cardinalityGuard{
+		limits:  limits,
+		logger:  logger,
+		uuid:    uuid,
+		object:  object,
+		globals: globals,
+		states:  make(map[string]*cardinalityState),
+	}
+}
+
+// apply returns results with the instances over their object's limits removed.
+// Matrices with dropped instances are cloned, since the collector's matrices are reused by the next poll.
+func (g *cardinalityGuard) apply(results []*matrix.Matrix) []*matrix.Matrix {
+	md := matrix.New(g.uuid, cardinalityObject, cardinalityObject+"_"+g.object)
+	for k, v := range g.globals {
+		md.SetGlobalLabel(k, v)
+	}
+	for _, name := range []string{"instances", "metrics", "label_values", "dropped_instances", "limit"} {
+		_, _ = md.NewMetricUint64(name)
+	}
+	g.metadata = md
+
+	seen := make(map[string]bool, len(results))
+	guarded := make([]*matrix.Matrix, 0, len(results))
+	for _, data := range results {
+		if !data.IsExportable() {
+			guarded = append(guarded, data)
+			continue
+		}
+		seen[data.Identifier] = true
+		guarded = append(guarded, g.guard(data))
+	}
+
+	// forget the objects that are no longer collected
+	maps.DeleteFunc(g.states, func(id string, _ *cardinalityState) bool {
+		return !seen[id]
+	})
+	return guarded
+}
+
+func (g *cardinalityGuard) guard(data *matrix.Matrix) *matrix.Matrix {
+	var keys []string
+	for key, instance := range data.GetInstances() {
+		if instance.IsExportable() {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+
+	exportedMetrics := 0
+	for _, metric := range data.GetMetrics() {
+		if metric.IsExportable() {
+			exportedMetrics++
+		}
+	}
+
+	limits := g.limits.For(data.Object)
+	row := g.row(data, "")
+	g.set(row, "instances", len(keys))
+	g.set(row, "metrics", exportedMetrics)
+	g.set(row, "limit", limits.MaxInstances)
+	if !limits.IsEnabled() {
+		return data
+	}
+
+	state, ok := g.states[data.Identifier]
+	if !ok {
+		state = &cardinalityState{values: make(map[string]map[string]bool)}
+		g.states[data.Identifier] = state
+	}
+
+	// dropped maps the key of each dropped instance to the label that dropped it
+	dropped := make(map[string]string)
+	checked := g.labelsToCheck(data, keys, limits)
+	for _, label := range checked {
+		limit := limits.MaxLabelValues
+		if l, ok := limits.Labels[label]; ok {
+			limit = l
+		}
+		if limit <= 0 {
+			continue
+		}
+
+		byValue := make(map[string][]string)
+		for _, key := range keys {
+			if value := data.GetInstance(key).GetLabel(label); value != "" {
+				byValue[value] = append(byValue[value], key)
+			}
+		}
+		admitted := admit(slices.Sorted(maps.Keys(byValue)), state.values[label], limit)
+		state.values[label] = admitted
+
+		droppedByLabel := 0
+		for value, valueKeys := range byValue {
+			if admitted[value] {
+				continue
+			}
+			for _, key := range valueKeys {
+				if _, ok := dropped[key]; !ok {
+					dropped[key] = label
+					droppedByLabel++
+				}
+			}
+		}
+
+		// labels with their own limit are always reported, other labels only when they are over the limit
+		if _, ok := limits.Labels[label]; ok || droppedByLabel > 0 {
+			labelRow := g.row(data, label)
+			g.set(labelRow, "label_values", len(byValue))
+			g.set(labelRow, "dropped_instances", droppedByLabel)
+			g.set(labelRow, "limit", limit)
+		}
+	}
+	maps.DeleteFunc(state.values, func(label string, _ map[string]bool) bool {
+		return !slices.Contains(checked, label)
+	})
+
+	remaining := slices.DeleteFunc(slices.Clone(keys), func(key string) bool {
+		_, ok := dropped[key]
+		return ok
+	})
+	if limits.MaxInstances > 0 {
+		state.instances = admit(remaining, state.instances, limits.MaxInstances)
+		for _, key := range remaining {
+			if !state.instances[key] {
+				dropped[key] = ""
+			}
+		}
+	}
+	g.set(row, "dropped_instances", len(dropped))
+
+	if len(dropped) != state.dropped {
+		if len(dropped) > 0 {
+			g.logger.Warn(
+				"Instances over the cardinality limit are not exported",
+				slog.String("object", data.Object),
+				slog.Int("instances", len(keys)),
+				slog.Int("dropped", len(dropped)),
+				slog.Any("labels", droppedLabels(dropped)),
+			)
+		} else {
+			g.logger.Info("Instances are within the cardinality limits", slog.String("object", data.Object))
+		}
+		state.dropped = len(dropped)
+	}
+	if len(dropped) == 0 {
+		return data
+	}
+
+	clone := data.Clone()
+	for key := range dropped {
+		clone.GetInstance(key).SetExportable(false)
+	}
+	return clone
+}
+
+// labelsToCheck returns the labels that have a limit. When max_label_values is set, that is every instance label.
+func (g *cardinalityGuard) labelsToCheck(data *matrix.Matrix, keys []string, limits conf.CardinalityLimits) []string {
+	labels := make(map[string]bool, len(limits.Labels))
+	for label := range limits.Labels {
+		labels[label] = true
+	}
+	if limits.MaxLabelValues > 0 {
+		for _, key := range keys {
+			for label := range data.GetInstance(key).GetLabels() {
+				labels[label] = true
+			}
+		}
+	}
+	return slices.Sorted(maps.Keys(labels))
+}
+
+// admit returns up to limit of candidates, preferring those admitted before
+func admit(candidates []string, previous map[string]bool, limit int) map[string]bool {
+	admitted := make(map[string]bool, min(limit, len(candidates)))
+	for _, c := range candidates {
+		if len(admitted) < limit && previous[c] {
+			admitted[c] = true
+		}
+	}
+	for _, c := range candidates {
+		if len(admitted) >= limit {
+			break
+		}
+		admitted[c] = true
+	}
+	return admitted
+}
+
+func droppedLabels(dropped map[string]string) []string {
+	labels := make(map[string]bool)
+	for _, label := range dropped {
+		if label == "" {
+			label = "max_instances"
+		}
+		labels[label] = true
+	}
+	return slices.Sorted(maps.Keys(labels))
+}
+
+// row returns the metadata instance of an object, or of one of its labels
+func (g *cardinalityGuard) row(data *matrix.Matrix, label string) *matrix.Instance {
+	key := data.Identifier + "/" + label
+	if instance := g.metadata.GetInstance(key); instance != nil {
+		return instance
+	}
+	instance, _ := g.metadata.NewInstance(key)
+	instance.SetLabel("object", data.Object)
+	instance.SetLabel("label", label)
+	return instance
+}
+
+func (g *cardinalityGuard) set(instance *matrix.Instance, metric string, value int) {
+	g.metadata.GetMetric(metric).SetValueUint64(instance, uint64(max(value, 0)))
+}
@@ -0,0 +1,143 @@
+package collector
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/netapp/harvest/v2/assert"
+	"github.com/netapp/harvest/v2/cmd/exporters/prometheus"
+	"github.com/netapp/harvest/v2/cmd/poller/exporter"
+	"github.com/netapp/harvest/v2/cmd/poller/options"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+)
+
+// newSessionMatrix returns a cifs_session matrix with one instance per user
+func newSessionMatrix(t *testing.T, users ...string) *matrix.Matrix {
+	t.Helper()
+
+	data := matrix.New("Rest", "cifs_session", "cifs_session")
+	m, err := data.NewMetricFloat64("connection_count")
+	assert.Nil(t, err)
+	for i, user := range users {
+		instance, err := data.NewInstance(fmt.Sprintf("session%d", i))
+		assert.Nil(t, err)
+		instance.SetLabel("user", user)
+		instance.SetLabel("svm", "svm1")
+		m.SetValueFloat64(instance, 1)
+	}
+	return data
+}
+
+func newGuard(limits conf.Cardinality) *cardinalityGuard {
+	return newCardinalityGuard(limits, "Rest", "CIFSSession", map[string]string{"poller": "p1", "object": "CIFSSession"}, slog.Default())
+}
+
+func exported(data *matrix.Matrix) []string {
+	var keys []string
+	for key, instance := range data.GetInstances() {
+		if instance.IsExportable() {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func metadataValue(t *testing.T, g *cardinalityGuard, key string, metric string) uint64 {
+	t.Helper()
+
+	instance := g.metadata.GetInstance(key)
+	assert.NotNil(t, instance)
+	v, _ := g.metadata.GetMetric(metric).GetValueUint64(instance)
+	return v
+}
+
+func TestCardinalityMaxInstances(t *testing.T) {
+	g := newGuard(conf.Cardinality{
+		Objects: map[string]conf.CardinalityLimits{"cifs_session": {MaxInstances: 2}},
+	})
+
+	data := newSessionMatrix(t, "a", "b", "c")
+	result := g.apply([]*matrix.Matrix{data})[0]
+	assert.Equal(t, exported(result), []string{"session0", "session1"})
+	// the collector's matrix is not changed
+	assert.Equal(t, len(exported(data)), 3)
+
+	assert.Equal(t, g.metadata.GetGlobalLabels()["poller"], "p1")
+	assert.Equal(t, g.metadata.GetInstance("cifs_session/").GetLabel("object"), "cifs_session")
+	assert.Equal(t, metadataValue(t, g, "cifs_session/", "instances"), uint64(3))
+	assert.Equal(t, metadataValue(t, g, "cifs_session/", "dropped_instances"), uint64(1))
+	assert.Equal(t, metadataValue(t, g, "cifs_session/", "limit"), uint64(2))
+
+	// instances exported by the previous poll are kept when a new instance sorts before them
+	instance, err := data.NewInstance("new")
+	assert.Nil(t, err)
+	instance.SetLabel("user", "d")
+	result = g.apply([]*matrix.Matrix{data})[0]
+	assert.Equal(t, exported(result), []string{"session0", "session1"})
+
+	// a freed slot goes to the first new instance
+	data.RemoveInstance("session0")
+	result = g.apply([]*matrix.Matrix{data})[0]
+	assert.Equal(t, exported(result), []string{"new", "session1"})
+}
+
+func TestCardinalityLabelValues(t *testing.T) {
+	g := newGuard(conf.Cardinality{
+		CardinalityLimits: conf.CardinalityLimits{Labels: map[string]int{"user": 2}},
+	})
+
+	result := g.apply([]*matrix.Matrix{newSessionMatrix(t, "a", "b", "b", "c")})[0]
+	assert.Equal(t, exported(result), []string{"session0", "session1", "session2"})
+	assert.Equal(t, metadataValue(t, g, "cifs_session/user", "label_values"), uint64(3))
+	assert.Equal(t, metadataValue(t, g, "cifs_session/user", "dropped_instances"), uint64(1))
+	assert.Equal(t, g.metadata.GetInstance("cifs_session/user").GetLabel("label"), "user")
+
+	// max_label_values applies to every label, svm has one value
+	g = newGuard(conf.Cardinality{CardinalityLimits: conf.CardinalityLimits{MaxLabelValues: 1}})
+	result = g.apply([]*matrix.Matrix{newSessionMatrix(t, "a", "b")})[0]
+	assert.Equal(t, exported(result), []string{"session0"})
+	assert.Nil(t, g.metadata.GetInstance("cifs_session/svm"))
+	assert.Equal(t, metadataValue(t, g, "cifs_session/user", "dropped_instances"), uint64(1))
+}
+
+func TestCardinalityWithoutLimits(t *testing.T) {
+	g := newGuard(conf.Cardinality{})
+	data := newSessionMatrix(t, "a", "b")
+	result := g.apply([]*matrix.Matrix{data})[0]
+
+	// the matrix is exported as is and its cardinality is reported
+	assert.True(t, result == data)
+	assert.Equal(t, metadataValue(t, g, "cifs_session/", "instances"), uint64(2))
+	assert.Equal(t, metadataValue(t, g, "cifs_session/", "metrics"), uint64(1))
+}
+
+func TestCardinalityExportTwoObjects(t *testing.T) {
+	absExp := exporter.New("Prometheus", "prom1", &options.Options{PromPort: 1}, conf.Exporter{IsTest: true, SortLabels: true}, nil)
+	prom := prometheus.New(absExp)
+	assert.Nil(t, prom.Init())
+
+	// two collectors of the same kind share an exporter, their cardinality matrices must not replace each other
+	for _, object := range []string{"CIFSSession", "Volume"} {
+		g := newCardinalityGuard(conf.Cardinality{}, "Rest", object, map[string]string{"poller": "p1"}, slog.Default())
+		data := matrix.New("Rest", strings.ToLower(object), strings.ToLower(object))
+		_, err := data.NewInstance("a")
+		assert.Nil(t, err)
+		g.apply([]*matrix.Matrix{data})
+		_, err = prom.Export(g.metadata)
+		assert.Nil(t, err)
+	}
+
+	w := httptest.NewRecorder()
+	r := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	prom.(*prometheus.Prometheus).ServeMetrics(w, r)
+	body := w.Body.String()
+	assert.True(t, strings.Contains(body, `metadata_cardinality_instances{label="",object="cifssession",poller="p1"} 1`))
+	assert.True(t, strings.Contains(body, `metadata_cardinality_instances{label="",object="volume",poller="p1"} 1`))
+}
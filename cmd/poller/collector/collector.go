@@ -112,6 +112,8 @@ type AbstractCollector struct {
 	countMux *sync.Mutex       // used for atomic access to collectCount
 	Auth     *auth.Credentials // used for authing the collector
 	Remote   conf.Remote
+	// enforces the poller's cardinality limits before export
+	cardinality *cardinalityGuard
 }
 
 func New(name, object string, o *options.Options, params *node.Node, credentials *auth.Credentials, remote conf.Remote) *AbstractCollector {
@@ -320,6 +322,14 @@ func (c *AbstractCollector) Start(
 	retryDelay := 1
 	c.SetStatus(0, "running")
 
+	var limits conf.Cardinality
+	if c.Options != nil {
+		if poller, err := conf.PollerNamed(c.Options.Poller); err == nil {
+			limits = poller.Cardinality
+		}
+	}
+	c.cardinality = newCardinalityGuard(limits, c.Name, c.Object, c.Metadata.GetGlobalLabels(), c.Logger)
+
 	for {
 
 		// We can't reset metadata here because autosupport metadata is reset
@@ -531,6 +541,10 @@ func (c *AbstractCollector) Start(
 		exportStart = time.Now()
 		exporterStats := exporter.Stats{}
 
+		if len(results) > 0 {
+			results = c.cardinality.apply(results)
+		}
+
 		for _, e := range c.Exporters {
 			if code, status, reason := e.GetStatus(); code != 0 {
 				c.Logger.Warn(
@@ -552,12 +566,22 @@ func (c *AbstractCollector) Start(
 				)
 			}
 
+			if c.cardinality.metadata != nil {
+				if _, err := e.Export(c.cardinality.metadata); err != nil {
+					c.Logger.Warn(
+						"Unable to export cardinality metadata",
+						slogx.Err(err),
+						slog.String("exporter", e.GetName()),
+					)
+				}
+			}
+
 			// Continue if metadata failed, since it might be specific to metadata
 			for _, data := range results {
 				if !data.IsExportable() {
 					continue
 				}
-				stats, err := e.Export(data)
+				stats, err := e.Export(e.Relabel(data))
 				if err != nil {
 					c.Logger.Error(
 						"export data",
@@ -12,7 +12,11 @@ import (
 	"fmt"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin/aggregator"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin/anomaly"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin/changelog"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin/enrich"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin/external"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin/forecast"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin/labelagent"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin/maxplugin"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin/metricagent"
@@ -262,6 +266,22 @@ func GetBuiltinPlugin(name string, abc *plugin.AbstractPlugin) plugin.Plugin {
 		return changelog.New(abc)
 	}
 
+	if name == "Forecast" {
+		return forecast.New(abc)
+	}
+
+	if name == "Anomaly" {
+		return anomaly.New(abc)
+	}
+
+	if name == "Enrich" {
+		return enrich.New(abc)
+	}
+
+	if name == "External" {
+		return external.New(abc)
+	}
+
 	return nil
 }
 
@@ -29,6 +29,7 @@ type Exporter interface {
 	GetStatus() (uint8, string, string)   // return current state of the exporter
 	Export(*matrix.Matrix) (Stats, error) // render data in matrix to the desired format and emit
 	// this is the only function that should be implemented by "real" exporters
+	Relabel(*matrix.Matrix) *matrix.Matrix // apply the exporter's relabel_configs to data before it is exported
 }
 
 // status defines the possible states of an exporter
@@ -59,6 +60,7 @@ type AbstractExporter struct {
 	*sync.Mutex                // mutex to block exporter during export
 	exportCount uint64         // atomic
 	countMux    *sync.Mutex
+	relabeler   *Relabeler
 }
 
 // New creates an AbstractExporter instance with the given arguments:
@@ -91,6 +93,12 @@ func New(c, n string, o *options.Options, p conf.Exporter, params *conf.Poller)
 
 // InitAbc initializes AbstractExporter
 func (e *AbstractExporter) InitAbc() error {
+	relabeler, err := NewRelabeler(e.Params.RelabelConfigs)
+	if err != nil {
+		return err
+	}
+	e.relabeler = relabeler
+
 	e.Metadata.SetGlobalLabel("hostname", e.Options.Hostname)
 	e.Metadata.SetGlobalLabel("version", e.Options.Version)
 	e.Metadata.SetGlobalLabel("poller", e.Options.Poller)
@@ -120,6 +128,12 @@ func (e *AbstractExporter) InitAbc() error {
 	return nil
 }
 
+// Relabel returns data after applying the exporter's relabel_configs.
+// Data is returned unchanged when the exporter has none.
+func (e *AbstractExporter) Relabel(data *matrix.Matrix) *matrix.Matrix {
+	return e.relabeler.Apply(data)
+}
+
 // GetClass returns the class of the AbstractExporter
 func (e *AbstractExporter) GetClass() string {
 	return e.Class
@@ -0,0 +1,348 @@
+package exporter
+
+import (
+	"crypto/md5" //nolint:gosec // used for sharding, the same hash Prometheus uses for hashmod
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+)
+
+const (
+	relabelReplace   = "replace"
+	relabelKeep      = "keep"
+	relabelDrop      = "drop"
+	relabelHashMod   = "hashmod"
+	relabelLabelMap  = "labelmap"
+	relabelLabelDrop = "labeldrop"
+	relabelLabelKeep = "labelkeep"
+
+	// nameLabel is the metric name, e.g. volume_read_ops. Only keep and drop can use it.
+	nameLabel = "__name__"
+	// Labels starting with tmpLabelPrefix are removed after relabeling
+	tmpLabelPrefix = "__"
+)
+
+type relabelRule struct {
+	action       string
+	sourceLabels []string
+	separator    string
+	regex        *regexp.Regexp
+	modulus      uint64
+	targetLabel  string
+	replacement  string
+	usesName     bool
+}
+
+// Relabeler applies an exporter's relabel_configs to a matrix before it is rendered
+type Relabeler struct {
+	rules []relabelRule
+}
+
+// NewRelabeler compiles the relabel configs. It returns nil when there are none.
+func NewRelabeler(configs []conf.RelabelConfig) (*Relabeler, error) {
+	if len(configs) == 0 {
+		return nil, nil
+	}
+	r := &Relabeler{rules: make([]relabelRule, 0, len(configs))}
+	for i, c := range configs {
+		rule, err := compileRelabelRule(c)
+		if err != nil {
+			return nil, fmt.Errorf("relabel_configs[%d]: %w", i, err)
+		}
+		r.rules = append(r.rules, rule)
+	}
+	return r, nil
+}
+
+func compileRelabelRule(c conf.RelabelConfig) (relabelRule, error) {
+	rule := relabelRule{
+		action:       c.Action,
+		sourceLabels: c.SourceLabels,
+		separator:    ";",
+		modulus:      c.Modulus,
+		targetLabel:  c.TargetLabel,
+		replacement:  "$1",
+		usesName:     slices.Contains(c.SourceLabels, nameLabel),
+	}
+	if rule.action == "" {
+		rule.action = relabelReplace
+	}
+	if c.Separator != nil {
+		rule.separator = *c.Separator
+	}
+	if c.Replacement != nil {
+		rule.replacement = *c.Replacement
+	}
+	regex := "(.*)"
+	if c.Regex != nil {
+		regex = *c.Regex
+	}
+	var err error
+	if rule.regex, err = regexp.Compile("^(?:" + regex + ")$"); err != nil {
+		return rule, fmt.Errorf("invalid regex %s: %w", regex, err)
+	}
+
+	switch rule.action {
+	case relabelKeep, relabelDrop:
+		if len(rule.sourceLabels) == 0 {
+			return rule, errs.New(errs.ErrMissingParam, rule.action+" requires source_labels")
+		}
+	case relabelReplace, relabelHashMod:
+		if rule.targetLabel == "" {
+			return rule, errs.New(errs.ErrMissingParam, rule.action+" requires target_label")
+		}
+		if rule.usesName || rule.targetLabel == nameLabel {
+			return rule, errs.New(errs.ErrInvalidParam, rule.action+" can not use "+nameLabel+", only keep and drop can")
+		}
+		if rule.action == relabelHashMod && rule.modulus == 0 {
+			return rule, errs.New(errs.ErrMissingParam, "hashmod requires a modulus")
+		}
+	case relabelLabelMap, relabelLabelDrop, relabelLabelKeep:
+	default:
+		return rule, errs.New(errs.ErrInvalidParam, "unknown action "+rule.action)
+	}
+	return rule, nil
+}
+
+// nameCheck is a keep or drop rule that depends on the metric name, with the labels it saw
+type nameCheck struct {
+	rule   *relabelRule
+	labels map[string]string
+}
+
+// Apply returns a relabeled copy of data. data is not modified since it is shared by all exporters.
+// Labels are relabeled per instance, keep and drop rules that use __name__ are evaluated per metric.
+func (r *Relabeler) Apply(data *matrix.Matrix) *matrix.Matrix {
+	if r == nil {
+		return data
+	}
+	clone := data.Clone()
+	globals := clone.GetGlobalLabels()
+	metrics := clone.GetMetrics()
+
+	results := make(map[*matrix.Instance]map[string]string, len(clone.GetInstances()))
+	for _, instance := range clone.GetInstances() {
+		if !instance.IsExportable() {
+			continue
+		}
+		labels := make(map[string]string, len(globals)+len(instance.GetLabels()))
+		maps.Copy(labels, globals)
+		maps.Copy(labels, instance.GetLabels())
+
+		var checks []nameCheck
+		keep := true
+		for i := range r.rules {
+			rule := &r.rules[i]
+			if rule.usesName {
+				checks = append(checks, nameCheck{rule: rule, labels: maps.Clone(labels)})
+				continue
+			}
+			if !rule.apply(labels) {
+				keep = false
+				break
+			}
+		}
+		if !keep {
+			instance.SetExportable(false)
+			continue
+		}
+		maps.DeleteFunc(labels, func(k string, _ string) bool {
+			return strings.HasPrefix(k, tmpLabelPrefix)
+		})
+		results[instance] = labels
+
+		if len(checks) == 0 {
+			continue
+		}
+		for _, metric := range metrics {
+			name := clone.Object + "_" + metric.GetName()
+			for _, c := range checks {
+				c.labels[nameLabel] = name
+				if !c.rule.apply(c.labels) {
+					metric.SetValueNAN(instance)
+					break
+				}
+			}
+		}
+	}
+
+	r.rewriteExportOptions(clone, globals)
+
+	// A global label stays global when every instance has its value. Otherwise, it becomes an instance label.
+	demoted := make(map[string]bool)
+	for name, value := range globals {
+		present := false
+		for _, labels := range results {
+			v, ok := labels[name]
+			if ok {
+				present = true
+			}
+			if v != value {
+				demoted[name] = true
+			}
+		}
+		if !present {
+			delete(globals, name)
+			delete(demoted, name)
+		}
+	}
+	if len(demoted) > 0 {
+		if keys := clone.GetExportOptions().GetChildS("instance_keys"); keys != nil {
+			for _, name := range slices.Sorted(maps.Keys(demoted)) {
+				if !slices.Contains(keys.GetAllChildContentS(), name) {
+					keys.NewChildS("", name)
+				}
+			}
+		}
+		for name := range demoted {
+			delete(globals, name)
+		}
+	}
+	for instance, labels := range results {
+		maps.DeleteFunc(labels, func(k string, _ string) bool {
+			_, ok := globals[k]
+			return ok
+		})
+		instance.SetLabels(labels)
+	}
+	return clone
+}
+
+// rewriteExportOptions updates instance_keys and instance_labels so that labels written by the rules are
+// exported like their source labels and dropped labels are no longer exported.
+func (r *Relabeler) rewriteExportOptions(m *matrix.Matrix, globals map[string]string) {
+	options := m.GetExportOptions()
+	if options == nil {
+		return
+	}
+	keys := listOption(options, "instance_keys")
+	labels := listOption(options, "instance_labels")
+	// global labels are always exported, so a label written from them is exported as a key
+	exported := maps.Clone(globals)
+
+	for _, rule := range r.rules {
+		switch rule.action {
+		case relabelReplace, relabelHashMod:
+			if strings.HasPrefix(rule.targetLabel, tmpLabelPrefix) || strings.Contains(rule.targetLabel, "$") {
+				continue
+			}
+			for _, source := range rule.sourceLabels {
+				if _, ok := exported[source]; ok || slices.Contains(*keys, source) {
+					*keys = appendMissing(*keys, rule.targetLabel)
+				}
+				if slices.Contains(*labels, source) {
+					*labels = appendMissing(*labels, rule.targetLabel)
+				}
+			}
+		case relabelLabelMap:
+			for _, list := range []*[]string{keys, labels} {
+				for _, name := range *list {
+					if rule.regex.MatchString(name) {
+						*list = appendMissing(*list, rule.regex.ReplaceAllString(name, rule.replacement))
+					}
+				}
+			}
+		case relabelLabelDrop:
+			for _, list := range []*[]string{keys, labels} {
+				*list = slices.DeleteFunc(*list, rule.regex.MatchString)
+			}
+		case relabelLabelKeep:
+			for _, list := range []*[]string{keys, labels} {
+				*list = slices.DeleteFunc(*list, func(name string) bool { return !rule.regex.MatchString(name) })
+			}
+		}
+	}
+
+	setListOption(options, "instance_keys", *keys)
+	setListOption(options, "instance_labels", *labels)
+}
+
+func listOption(options *node.Node, name string) *[]string {
+	var values []string
+	if n := options.GetChildS(name); n != nil {
+		values = n.GetAllChildContentS()
+	}
+	return &values
+}
+
+func setListOption(options *node.Node, name string, values []string) {
+	n := options.GetChildS(name)
+	if n == nil {
+		if len(values) == 0 {
+			return
+		}
+		n = options.NewChildS(name, "")
+	}
+	n.Children = nil
+	for _, v := range values {
+		n.NewChildS("", v)
+	}
+}
+
+func appendMissing(list []string, value string) []string {
+	if slices.Contains(list, value) {
+		return list
+	}
+	return append(list, value)
+}
+
+// apply runs the rule on labels. It returns false when the series is dropped.
+func (rule *relabelRule) apply(labels map[string]string) bool {
+	switch rule.action {
+	case relabelKeep:
+		return rule.regex.MatchString(rule.sourceValue(labels))
+	case relabelDrop:
+		return !rule.regex.MatchString(rule.sourceValue(labels))
+	case relabelReplace:
+		value := rule.sourceValue(labels)
+		indexes := rule.regex.FindStringSubmatchIndex(value)
+		if indexes == nil {
+			return true
+		}
+		target := string(rule.regex.ExpandString(nil, rule.targetLabel, value, indexes))
+		result := string(rule.regex.ExpandString(nil, rule.replacement, value, indexes))
+		if result == "" {
+			delete(labels, target)
+		} else {
+			labels[target] = result
+		}
+	case relabelHashMod:
+		sum := md5.Sum([]byte(rule.sourceValue(labels))) //nolint:gosec
+		var hash uint64
+		for _, b := range sum[8:] {
+			hash = hash<<8 | uint64(b)
+		}
+		labels[rule.targetLabel] = strconv.FormatUint(hash%rule.modulus, 10)
+	case relabelLabelMap:
+		for name, value := range maps.Clone(labels) {
+			if rule.regex.MatchString(name) {
+				labels[rule.regex.ReplaceAllString(name, rule.replacement)] = value
+			}
+		}
+	case relabelLabelDrop:
+		maps.DeleteFunc(labels, func(name string, _ string) bool {
+			return rule.regex.MatchString(name)
+		})
+	case relabelLabelKeep:
+		maps.DeleteFunc(labels, func(name string, _ string) bool {
+			return !rule.regex.MatchString(name)
+		})
+	}
+	return true
+}
+
+func (rule *relabelRule) sourceValue(labels map[string]string) string {
+	values := make([]string, len(rule.sourceLabels))
+	for i, name := range rule.sourceLabels {
+		values[i] = labels[name]
+	}
+	return strings.Join(values, rule.separator)
+}
@@ -0,0 +1,174 @@
+package exporter
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/netapp/harvest/v2/assert"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+)
+
+func ptr(s string) *string {
+	return &s
+}
+
+func newVolumeMatrix(t *testing.T) *matrix.Matrix {
+	t.Helper()
+
+	data := matrix.New("Rest", "volume", "volume")
+	data.SetGlobalLabel("datacenter", "dc1")
+	data.SetGlobalLabel("cluster", "cluster1")
+	data.GetExportOptions().NewChildS("instance_keys", "").NewChildS("", "volume")
+	data.GetExportOptions().GetChildS("instance_keys").NewChildS("", "svm")
+	data.GetExportOptions().NewChildS("instance_labels", "").NewChildS("", "state")
+
+	for _, name := range []string{"size", "size_used"} {
+		_, err := data.NewMetricFloat64(name)
+		assert.Nil(t, err)
+	}
+	for _, v := range []struct{ volume, svm string }{{"vol1", "svm1"}, {"vol2", "svm1"}, {"temp_vol", "svm2"}} {
+		instance, err := data.NewInstance(v.volume)
+		assert.Nil(t, err)
+		instance.SetLabel("volume", v.volume)
+		instance.SetLabel("svm", v.svm)
+		instance.SetLabel("state", "online")
+		data.GetMetric("size").SetValueFloat64(instance, 100)
+		data.GetMetric("size_used").SetValueFloat64(instance, 10)
+	}
+	return data
+}
+
+func relabel(t *testing.T, data *matrix.Matrix, configs ...conf.RelabelConfig) *matrix.Matrix {
+	t.Helper()
+
+	r, err := NewRelabeler(configs)
+	assert.Nil(t, err)
+	return r.Apply(data)
+}
+
+func exportedInstances(data *matrix.Matrix) []string {
+	var keys []string
+	for key, instance := range data.GetInstances() {
+		if instance.IsExportable() {
+			keys = append(keys, key)
+		}
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+func TestKeepDrop(t *testing.T) {
+	data := newVolumeMatrix(t)
+
+	kept := relabel(t, data, conf.RelabelConfig{Action: "keep", SourceLabels: []string{"svm"}, Regex: ptr("svm1")})
+	assert.Equal(t, exportedInstances(kept), []string{"vol1", "vol2"})
+
+	dropped := relabel(t, data, conf.RelabelConfig{
+		Action:       "drop",
+		SourceLabels: []string{"svm", "volume"},
+		Regex:        ptr("svm2;temp_.*"),
+	})
+	assert.Equal(t, exportedInstances(dropped), []string{"vol1", "vol2"})
+
+	// the original matrix is shared by the other exporters and is not changed
+	assert.Equal(t, exportedInstances(data), []string{"temp_vol", "vol1", "vol2"})
+}
+
+func TestKeepByMetricName(t *testing.T) {
+	data := newVolumeMatrix(t)
+	result := relabel(t, data, conf.RelabelConfig{
+		Action:       "drop",
+		SourceLabels: []string{"__name__", "volume"},
+		Regex:        ptr("volume_size_used;vol1"),
+	})
+
+	vol1 := result.GetInstance("vol1")
+	_, ok := result.GetMetric("size_used").GetValueFloat64(vol1)
+	assert.False(t, ok)
+	_, ok = result.GetMetric("size").GetValueFloat64(vol1)
+	assert.True(t, ok)
+	_, ok = result.GetMetric("size_used").GetValueFloat64(result.GetInstance("vol2"))
+	assert.True(t, ok)
+}
+
+func TestReplace(t *testing.T) {
+	data := newVolumeMatrix(t)
+	result := relabel(t, data,
+		conf.RelabelConfig{SourceLabels: []string{"svm"}, TargetLabel: "vserver"},
+		conf.RelabelConfig{
+			SourceLabels: []string{"volume"},
+			Regex:        ptr("vol(.*)"),
+			TargetLabel:  "volume_id",
+			Replacement:  ptr("id-$1"),
+		},
+		conf.RelabelConfig{Action: "labeldrop", Regex: ptr("svm")},
+	)
+
+	vol1 := result.GetInstance("vol1")
+	assert.Equal(t, vol1.GetLabel("vserver"), "svm1")
+	assert.Equal(t, vol1.GetLabel("volume_id"), "id-1")
+	assert.Equal(t, vol1.GetLabel("svm"), "")
+	// the regex does not match, the label is not added
+	assert.Equal(t, result.GetInstance("temp_vol").GetLabel("volume_id"), "")
+
+	keys := result.GetExportOptions().GetChildS("instance_keys").GetAllChildContentS()
+	assert.Equal(t, keys, []string{"volume", "vserver", "volume_id"})
+}
+
+func TestGlobalLabels(t *testing.T) {
+	data := newVolumeMatrix(t)
+	result := relabel(t, data,
+		conf.RelabelConfig{Action: "labeldrop", Regex: ptr("datacenter")},
+		conf.RelabelConfig{
+			SourceLabels: []string{"svm"},
+			Regex:        ptr("svm2"),
+			TargetLabel:  "cluster",
+			Replacement:  ptr("cluster2"),
+		},
+	)
+
+	globals := result.GetGlobalLabels()
+	_, ok := globals["datacenter"]
+	assert.False(t, ok)
+	// cluster differs per instance, so it is no longer a global label
+	_, ok = globals["cluster"]
+	assert.False(t, ok)
+	assert.Equal(t, result.GetInstance("vol1").GetLabel("cluster"), "cluster1")
+	assert.Equal(t, result.GetInstance("temp_vol").GetLabel("cluster"), "cluster2")
+	keys := result.GetExportOptions().GetChildS("instance_keys").GetAllChildContentS()
+	assert.True(t, slices.Contains(keys, "cluster"))
+}
+
+func TestHashMod(t *testing.T) {
+	data := newVolumeMatrix(t)
+	result := relabel(t, data,
+		conf.RelabelConfig{Action: "hashmod", SourceLabels: []string{"volume"}, Modulus: 2, TargetLabel: "__shard"},
+		conf.RelabelConfig{Action: "keep", SourceLabels: []string{"__shard"}, Regex: ptr("1")},
+	)
+
+	// the same shards Prometheus assigns
+	assert.Equal(t, exportedInstances(result), []string{"vol2"})
+	// temporary labels are removed
+	assert.Equal(t, result.GetInstance("vol2").GetLabel("__shard"), "")
+}
+
+func TestInvalidRelabelConfigs(t *testing.T) {
+	tests := []struct {
+		name   string
+		config conf.RelabelConfig
+	}{
+		{name: "unknown action", config: conf.RelabelConfig{Action: "rename"}},
+		{name: "invalid regex", config: conf.RelabelConfig{Action: "labeldrop", Regex: ptr("(")}},
+		{name: "keep without source", config: conf.RelabelConfig{Action: "keep"}},
+		{name: "replace without target", config: conf.RelabelConfig{SourceLabels: []string{"svm"}}},
+		{name: "hashmod without modulus", config: conf.RelabelConfig{Action: "hashmod", SourceLabels: []string{"svm"}, TargetLabel: "shard"}},
+		{name: "replace name", config: conf.RelabelConfig{SourceLabels: []string{"__name__"}, TargetLabel: "metric"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewRelabeler([]conf.RelabelConfig{tt.config})
+			assert.NotNil(t, err)
+		})
+	}
+}
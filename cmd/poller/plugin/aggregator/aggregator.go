@@ -14,8 +14,10 @@ import (
 	"github.com/netapp/harvest/v2/pkg/slogx"
 	"log/slog"
 	"maps"
+	"math"
 	"regexp"
 	"slices"
+	"strconv"
 	"strings"
 )
 
@@ -37,6 +39,85 @@ type rule struct {
 	includeLabels []string
 	allLabels     bool
 	counts        map[string]map[string]float64
+	functions     []*function
+	// weights maps a metric to the metric its weighted average uses as weight
+	weights map[string]string
+	// the following are set at each poll
+	stats      map[string][]*function
+	weightKeys map[string]string
+	derived    map[string]bool
+	values     map[string]map[string][]float64
+}
+
+// function is an aggregation function of a rule, such as p99=read_latency,write_latency
+type function struct {
+	name string
+	// suffix is the suffix of the function's metrics, p99.9 creates metrics with the suffix p99_9
+	suffix   string
+	quantile float64
+	metrics  []string
+}
+
+func parseFunction(field string) (*function, error) {
+	name, metrics, _ := strings.Cut(field, "=")
+	f := &function{name: name, suffix: strings.ReplaceAll(name, ".", "_")}
+	switch {
+	case name == "min" || name == "max" || name == "avg" || name == "count" || name == "stddev":
+	case strings.HasPrefix(name, "p"):
+		q, err := strconv.ParseFloat(name[1:], 64)
+		if err != nil || q <= 0 || q >= 100 {
+			return nil, errs.New(errs.ErrInvalidParam, "invalid quantile "+name)
+		}
+		f.quantile = q
+	default:
+		return nil, errs.New(errs.ErrInvalidParam, "unknown aggregation function "+name)
+	}
+	for m := range strings.SplitSeq(metrics, ",") {
+		if m = strings.TrimSpace(m); m != "" {
+			f.metrics = append(f.metrics, m)
+		}
+	}
+	if len(f.metrics) == 0 {
+		return nil, errs.New(errs.ErrInvalidParam, "aggregation function "+name+" without metrics")
+	}
+	return f, nil
+}
+
+// apply returns the result of the function for the values of the instances in the group
+func (f *function) apply(values []float64) float64 {
+	switch f.name {
+	case "count":
+		return float64(len(values))
+	case "min":
+		return slices.Min(values)
+	case "max":
+		return slices.Max(values)
+	}
+
+	var sum float64
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	switch f.name {
+	case "avg":
+		return mean
+	case "stddev":
+		// population standard deviation, the group has all instances
+		var squares float64
+		for _, v := range values {
+			squares += (v - mean) * (v - mean)
+		}
+		return math.Sqrt(squares / float64(len(values)))
+	}
+
+	// quantile with linear interpolation between the closest ranks
+	sorted := slices.Clone(values)
+	slices.Sort(sorted)
+	rank := f.quantile / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := int(math.Ceil(rank))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo))
 }
 
 func (a *Aggregator) Init(remote conf.Remote) error {
@@ -63,6 +144,33 @@ func (a *Aggregator) parseRules() error {
 		r := rule{}
 
 		fields := strings.Fields(line)
+
+		// aggregation functions follow the label and the optional labels to include
+		for len(fields) > 1 && strings.Contains(fields[len(fields)-1], "=") {
+			field := fields[len(fields)-1]
+			fields = fields[:len(fields)-1]
+			if metrics, ok := strings.CutPrefix(field, "weighted_avg="); ok {
+				if r.weights == nil {
+					r.weights = make(map[string]string)
+				}
+				for pair := range strings.SplitSeq(metrics, ",") {
+					metric, weight, ok := strings.Cut(pair, ":")
+					if !ok || metric == "" || weight == "" {
+						return errs.New(errs.ErrInvalidParam, "invalid weighted average "+pair+", expected METRIC:WEIGHT")
+					}
+					r.weights[metric] = weight
+				}
+				continue
+			}
+			f, err := parseFunction(field)
+			if err != nil {
+				return err
+			}
+			r.functions = append(r.functions, f)
+		}
+		// keep the functions in the order of the rule
+		slices.Reverse(r.functions)
+
 		if len(fields) == 2 || len(fields) == 1 {
 			// parse label, possibly followed by value and object
 			prefix := strings.SplitN(fields[0], "<", 2)
@@ -125,6 +233,9 @@ func (a *Aggregator) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *
 		matrices[i].SetExportOptions(matrix.DefaultExportOptions())
 		matrices[i].SetExportable(true)
 		rule.counts = make(map[string]map[string]float64)
+		if err := a.initFunctions(rule, data, matrices[i]); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	// create instances and summarize metric values
@@ -184,6 +295,7 @@ func (a *Aggregator) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *
 
 			if objInstance = matrices[i].GetInstance(objKey); objInstance == nil {
 				rule.counts[objKey] = make(map[string]float64)
+				rule.values[objKey] = make(map[string][]float64)
 				if objInstance, err = matrices[i].NewInstance(objKey); err != nil {
 					return nil, nil, err
 				}
@@ -206,13 +318,29 @@ func (a *Aggregator) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *
 					continue
 				}
 
+				if _, ok := rule.stats[key]; ok {
+					rule.values[objKey][key] = append(rule.values[objKey][key], value)
+				}
+
 				if objMetric = matrices[i].GetMetric(key); objMetric == nil {
 					a.SLogger.Warn("metric not found in cache", slog.String("metric", key), slog.String("label", rule.label))
 					continue
 				}
 
-				// latency metric: weighted sum
-				if strings.Contains(key, "_latency") {
+				switch weightKey := rule.weightKeys[key]; {
+				case weightKey != "":
+					// weighted average, instances without weight are skipped
+					if opsValue, ok = data.GetMetric(weightKey).GetValueFloat64(instance); !ok {
+						continue
+					}
+					objMetric.AddValueFloat64(objInstance, opsValue*value)
+					rule.counts[objKey][key] += opsValue
+				case metric.IsHistogram():
+					// histograms are merged bucket by bucket
+					objMetric.AddValueFloat64(objInstance, value)
+					rule.counts[objKey][key]++
+				case strings.Contains(key, "_latency"):
+					// latency metric: weighted sum
 					opsKey := objMetric.GetComment()
 					if opsKey != "" {
 						if opsMetric = data.GetMetric(opsKey); opsMetric == nil {
@@ -229,7 +357,7 @@ func (a *Aggregator) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *
 						objMetric.AddValueFloat64(objInstance, opsValue*value)
 						rule.counts[objKey][key] += opsValue
 					}
-				} else {
+				default:
 					objMetric.AddValueFloat64(objInstance, value)
 					rule.counts[objKey][key]++
 				}
@@ -246,8 +374,15 @@ func (a *Aggregator) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *
 				ok, avg bool
 			)
 
+			// metrics of aggregation functions are computed below
+			if a.rules[i].derived[mk] {
+				continue
+			}
+
 			mn := metric.GetName()
 			switch {
+			case a.rules[i].weightKeys[mk] != "":
+				avg = true
 			case metric.GetProperty() == "average" || metric.GetProperty() == "percent":
 				avg = true
 			case strings.Contains(mn, "average_") || strings.Contains(mn, "avg_"):
@@ -279,9 +414,73 @@ func (a *Aggregator) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *
 		}
 	}
 
+	for i, m := range matrices {
+		a.applyFunctions(a.rules[i], m)
+	}
+
 	return matrices, metadata, nil
 }
 
+// initFunctions resolves the metrics of the rule's aggregation functions and creates their metrics in the
+// aggregated matrix. Metrics are found by key or by display name. The metric of a function is named after the
+// metric and the function, e.g. read_latency_p99.
+func (a *Aggregator) initFunctions(rule *rule, data, m *matrix.Matrix) error {
+	rule.stats = make(map[string][]*function)
+	rule.weightKeys = make(map[string]string)
+	rule.derived = make(map[string]bool)
+	rule.values = make(map[string]map[string][]float64)
+
+	metricKey := func(name string) string {
+		if data.GetMetric(name) != nil {
+			return name
+		}
+		return data.DisplayMetricKey(name)
+	}
+
+	for _, f := range rule.functions {
+		for _, name := range f.metrics {
+			key := metricKey(name)
+			if key == "" {
+				a.SLogger.Debug("metric not found, skipped", slog.String("metric", name), slog.String("function", f.name))
+				continue
+			}
+			derivedKey := key + "_" + f.suffix
+			if m.GetMetric(derivedKey) == nil {
+				metric, err := m.NewMetricFloat64(derivedKey, data.GetMetric(key).GetName()+"_"+f.suffix)
+				if err != nil {
+					return err
+				}
+				metric.SetExportable(true)
+			}
+			rule.stats[key] = append(rule.stats[key], f)
+			rule.derived[derivedKey] = true
+		}
+	}
+
+	for name, weight := range rule.weights {
+		key, weightKey := metricKey(name), metricKey(weight)
+		if key == "" || weightKey == "" {
+			a.SLogger.Debug("metric or weight not found, skipped", slog.String("metric", name), slog.String("weight", weight))
+			continue
+		}
+		rule.weightKeys[key] = weightKey
+	}
+
+	return nil
+}
+
+// applyFunctions sets the metrics of the rule's aggregation functions from the values of the instances in each group
+func (a *Aggregator) applyFunctions(rule *rule, m *matrix.Matrix) {
+	for objKey, metrics := range rule.values {
+		instance := m.GetInstance(objKey)
+		for key, values := range metrics {
+			for _, f := range rule.stats[key] {
+				m.GetMetric(key+"_"+f.suffix).SetValueFloat64(instance, f.apply(values))
+			}
+		}
+	}
+}
+
 // NewLabels returns the new labels the receiver creates
 func (a *Aggregator) NewLabels() []string {
 	newLabelNames := make([]string, 0, len(a.rules))
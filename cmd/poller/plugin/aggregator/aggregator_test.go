@@ -5,6 +5,8 @@
 package aggregator
 
 import (
+	"math"
+	"strconv"
 	"testing"
 
 	"github.com/netapp/harvest/v2/assert"
@@ -459,3 +461,70 @@ func newArtificialData() *matrix.Matrix {
 
 	return m
 }
+
+func TestRuleFunctions(t *testing.T) {
+	params := node.NewS("Aggregator")
+	params.NewChildS("", "node min=latency,missing max=latency count=latency avg=ops stddev=latency p50=latency p90=latency p99.9=latency weighted_avg=latency:ops,service_time:ops")
+	p := &Aggregator{AbstractPlugin: plugin.New("Test", nil, params, nil, "", nil)}
+	assert.Nil(t, p.Init(conf.Remote{}))
+	assert.Equal(t, len(p.rules[0].functions), 8)
+
+	m := matrix.New("", "", "")
+	latency, _ := m.NewMetricFloat64("latency")
+	serviceTime, _ := m.NewMetricFloat64("service_time")
+	ops, _ := m.NewMetricFloat64("ops")
+	bucket, _ := m.NewMetricFloat64("read_latency_hist.<1ms")
+	bucket.SetHistogram(true)
+	for i, v := range [][]float64{{10, 1}, {20, 1}, {30, 2}, {40, 0}} {
+		instance, _ := m.NewInstance(strconv.Itoa(i))
+		instance.SetLabel("node", "nodeA")
+		latency.SetValueFloat64(instance, v[0])
+		serviceTime.SetValueFloat64(instance, v[0])
+		ops.SetValueFloat64(instance, v[1])
+		bucket.SetValueFloat64(instance, v[1])
+	}
+	// instances without weight are not part of the weighted average
+	ops.SetValueNAN(m.GetInstance("3"))
+
+	results, _, err := p.Run(map[string]*matrix.Matrix{m.Object: m})
+	assert.Nil(t, err)
+	n := results[0]
+	instance := n.GetInstance("nodeA")
+
+	value := func(metric string) float64 {
+		v, ok := n.GetMetric(metric).GetValueFloat64(instance)
+		assert.True(t, ok)
+		return v
+	}
+	assert.Equal(t, value("latency_min"), 10.0)
+	assert.Equal(t, value("latency_max"), 40.0)
+	assert.Equal(t, value("latency_count"), 4.0)
+	assert.Equal(t, value("ops_avg"), 4.0/3)
+	assert.Equal(t, value("latency_stddev"), math.Sqrt(125))
+	assert.Equal(t, value("latency_p50"), 25.0)
+	assert.Equal(t, value("latency_p90"), 37.0)
+	assert.True(t, value("latency_p99_9") > 39.9)
+	// (10*1 + 20*1 + 30*2) / 4
+	assert.Equal(t, value("latency"), 22.5)
+	assert.Equal(t, value("service_time"), 22.5)
+	// histogram buckets are summed
+	assert.Equal(t, value("read_latency_hist.<1ms"), 4.0)
+	assert.Nil(t, n.GetMetric("missing_min"))
+}
+
+func TestRuleInvalidFunctions(t *testing.T) {
+	rules := []string{
+		"node median=latency",
+		"node p100=latency",
+		"node min=",
+		"node weighted_avg=latency",
+	}
+	for _, r := range rules {
+		t.Run(r, func(t *testing.T) {
+			params := node.NewS("Aggregator")
+			params.NewChildS("", r)
+			p := &Aggregator{AbstractPlugin: plugin.New("Test", nil, params, nil, "", nil)}
+			assert.NotNil(t, p.Init(conf.Remote{}))
+		})
+	}
+}
@@ -0,0 +1,373 @@
+/*
+ * Copyright NetApp Inc, 2026 All rights reserved
+ */
+
+// Package anomaly implements the Anomaly plugin. It learns a seasonal baseline of counters per instance
+// and scores how far each poll is from its baseline.
+package anomaly
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/collector"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/slogx"
+	"github.com/netapp/harvest/v2/pkg/statefile"
+)
+
+const (
+	defaultAlpha        = 0.1
+	defaultThreshold    = 3.0
+	defaultWarmup       = 10
+	defaultSeasonality  = "day"
+	defaultSaveInterval = 15 * time.Minute
+	defaultPath         = "anomaly"
+	// minStdRatio is the smallest standard deviation relative to the mean. It keeps flat counters from
+	// scoring every small change as an anomaly.
+	minStdRatio = 0.01
+
+	scoreSuffix   = "_anomaly_score"
+	anomalySuffix = "_anomaly"
+)
+
+// Slots per seasonality. A slot is one hour of the day or one hour of the week.
+var seasonalities = map[string]int{
+	"none": 1,
+	"day":  24,
+	"week": 7 * 24,
+}
+
+type Anomaly struct {
+	*plugin.AbstractPlugin
+	counters     []string
+	alpha        float64
+	threshold    float64
+	minStd       float64
+	warmup       int
+	seasonality  string
+	slots        int
+	saveInterval time.Duration
+	path         string
+	baselines    map[string]map[string]*baseline // instance key -> counter -> baseline
+	loaded       bool
+	lastSave     time.Time
+	dirty        bool
+	now          func() time.Time
+}
+
+// baseline is the exponentially weighted mean and variance of a counter for each slot
+type baseline struct {
+	Mean     []float64 `json:"m"`
+	Var      []float64 `json:"v"`
+	Count    []int     `json:"n"`
+	LastSeen int64     `json:"t"`
+}
+
+type stateFile struct {
+	Seasonality string                          `json:"seasonality"`
+	Baselines   map[string]map[string]*baseline `json:"baselines"`
+}
+
+func New(p *plugin.AbstractPlugin) *Anomaly {
+	return &Anomaly{AbstractPlugin: p, now: time.Now}
+}
+
+func (a *Anomaly) Init(remote conf.Remote) error {
+	if err := a.AbstractPlugin.Init(remote); err != nil {
+		return err
+	}
+	if err := a.parseParams(); err != nil {
+		return err
+	}
+	a.baselines = make(map[string]map[string]*baseline)
+	a.SLogger.Debug("parsed anomaly counters", slog.Any("counters", a.counters), slog.String("path", a.path))
+	return nil
+}
+
+func (a *Anomaly) parseParams() error {
+	var err error
+
+	counters := a.Params.GetChildS("counters")
+	if counters == nil || len(counters.GetAllChildContentS()) == 0 {
+		return errs.New(errs.ErrMissingParam, "counters")
+	}
+	a.counters = counters.GetAllChildContentS()
+
+	if a.alpha, err = parseFloat(a.Params.GetChildContentS("alpha"), defaultAlpha); err != nil {
+		return fmt.Errorf("invalid alpha: %w", err)
+	}
+	if a.alpha <= 0 || a.alpha > 1 {
+		return errs.New(errs.ErrInvalidParam, "alpha must be greater than 0 and at most 1")
+	}
+	if a.threshold, err = parseFloat(a.Params.GetChildContentS("threshold"), defaultThreshold); err != nil {
+		return fmt.Errorf("invalid threshold: %w", err)
+	}
+	if a.threshold <= 0 {
+		return errs.New(errs.ErrInvalidParam, "threshold must be positive")
+	}
+
+	if a.minStd, err = parseFloat(a.Params.GetChildContentS("min_std"), 0); err != nil {
+		return fmt.Errorf("invalid min_std: %w", err)
+	}
+	if a.minStd < 0 {
+		return errs.New(errs.ErrInvalidParam, "min_std must not be negative")
+	}
+
+	a.warmup = defaultWarmup
+	if w := a.Params.GetChildContentS("warmup"); w != "" {
+		if a.warmup, err = strconv.Atoi(w); err != nil || a.warmup < 1 {
+			return errs.New(errs.ErrInvalidParam, "warmup must be a positive integer")
+		}
+	}
+
+	a.seasonality = a.Params.GetChildContentS("seasonality")
+	if a.seasonality == "" {
+		a.seasonality = defaultSeasonality
+	}
+	var ok bool
+	if a.slots, ok = seasonalities[a.seasonality]; !ok {
+		return errs.New(errs.ErrInvalidParam, "seasonality must be none, day, or week")
+	}
+
+	a.saveInterval = defaultSaveInterval
+	if s := a.Params.GetChildContentS("save_interval"); s != "" {
+		if a.saveInterval, err = time.ParseDuration(s); err != nil {
+			return fmt.Errorf("invalid save_interval: %w", err)
+		}
+	}
+
+	dir := a.Params.GetChildContentS("path")
+	if dir == "" {
+		dir = defaultPath
+	}
+	poller := ""
+	if a.Options != nil {
+		poller = a.Options.Poller
+	}
+	a.path = statefile.Path(dir, poller, a.Parent, a.Object)
+	return nil
+}
+
+func parseFloat(s string, defaultValue float64) (float64, error) {
+	if s == "" {
+		return defaultValue, nil
+	}
+	return strconv.ParseFloat(s, 64)
+}
+
+func (a *Anomaly) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *collector.Metadata, error) {
+	data := dataMap[a.Object]
+	now := a.now()
+
+	if !a.loaded {
+		a.loaded = true
+		a.lastSave = now
+		if err := a.load(); err != nil {
+			a.SLogger.Warn("Failed to load anomaly baselines", slogx.Err(err), slog.String("path", a.path))
+		}
+	}
+
+	slot := a.slot(now)
+	metadata := &collector.Metadata{}
+	anomalies := 0
+
+	for _, counter := range a.counters {
+		metric := getMetric(data, counter)
+		if metric == nil {
+			continue
+		}
+		score := a.metric(data, counter+scoreSuffix)
+		anomaly := a.metric(data, counter+anomalySuffix)
+		if score == nil || anomaly == nil {
+			continue
+		}
+
+		for key, instance := range data.GetInstances() {
+			score.SetValueNAN(instance)
+			anomaly.SetValueNAN(instance)
+			if !instance.IsExportable() {
+				continue
+			}
+			value, ok := metric.GetValueFloat64(instance)
+			if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+				continue
+			}
+
+			b := a.baseline(key, counter)
+			s, scored := b.score(slot, value, a.warmup, a.minStd)
+			b.update(slot, value, a.alpha)
+			b.LastSeen = now.Unix()
+			a.dirty = true
+			if !scored {
+				continue
+			}
+
+			metadata.PluginInstances.Add(1)
+			score.SetValueFloat64(instance, s)
+			if math.Abs(s) >= a.threshold {
+				anomaly.SetValueFloat64(instance, 1)
+				anomalies++
+			} else {
+				anomaly.SetValueFloat64(instance, 0)
+			}
+		}
+	}
+
+	if anomalies > 0 {
+		a.SLogger.Debug("anomalies", slog.Int("count", anomalies))
+	}
+
+	if a.dirty && now.Sub(a.lastSave) >= a.saveInterval {
+		a.prune(now)
+		if err := a.save(); err != nil {
+			a.SLogger.Warn("Failed to save anomaly baselines", slogx.Err(err), slog.String("path", a.path))
+		}
+		a.lastSave = now
+		a.dirty = false
+	}
+
+	return nil, metadata, nil
+}
+
+// slot returns the index of the hour of the day or week of t in the poller's time zone
+func (a *Anomaly) slot(t time.Time) int {
+	switch a.seasonality {
+	case "day":
+		return t.Hour()
+	case "week":
+		return int(t.Weekday())*24 + t.Hour()
+	default:
+		return 0
+	}
+}
+
+func getMetric(m *matrix.Matrix, name string) *matrix.Metric {
+	if metric := m.DisplayMetric(name); metric != nil {
+		return metric
+	}
+	return m.GetMetric(name)
+}
+
+func (a *Anomaly) metric(data *matrix.Matrix, name string) *matrix.Metric {
+	if m := data.GetMetric(name); m != nil {
+		return m
+	}
+	m, err := data.NewMetricFloat64(name)
+	if err != nil {
+		a.SLogger.Error("Failed to create metric", slogx.Err(err), slog.String("metric", name))
+		return nil
+	}
+	m.SetProperty("anomaly")
+	return m
+}
+
+func (a *Anomaly) baseline(key string, counter string) *baseline {
+	counters, ok := a.baselines[key]
+	if !ok {
+		counters = make(map[string]*baseline)
+		a.baselines[key] = counters
+	}
+	b, ok := counters[counter]
+	if !ok {
+		b = &baseline{
+			Mean:  make([]float64, a.slots),
+			Var:   make([]float64, a.slots),
+			Count: make([]int, a.slots),
+		}
+		counters[counter] = b
+	}
+	return b
+}
+
+// score returns the number of standard deviations value is from the slot's mean.
+// scored is false until the slot has seen warmup values and while the slot's values have been zero.
+func (b *baseline) score(slot int, value float64, warmup int, minStd float64) (float64, bool) {
+	if b.Count[slot] < warmup {
+		return 0, false
+	}
+	mean := b.Mean[slot]
+	std := max(math.Sqrt(b.Var[slot]), minStdRatio*math.Abs(mean), minStd)
+	if std == 0 {
+		return 0, false
+	}
+	return (value - mean) / std, true
+}
+
+// update adds value to the slot's exponentially weighted mean and variance
+func (b *baseline) update(slot int, value float64, alpha float64) {
+	if b.Count[slot] == 0 {
+		b.Mean[slot] = value
+		b.Var[slot] = 0
+		b.Count[slot] = 1
+		return
+	}
+	diff := value - b.Mean[slot]
+	incr := alpha * diff
+	b.Mean[slot] += incr
+	b.Var[slot] = (1 - alpha) * (b.Var[slot] + diff*incr)
+	b.Count[slot]++
+}
+
+// prune removes the baselines of instances that have not been seen for two seasons
+func (a *Anomaly) prune(now time.Time) {
+	retention := 2 * time.Duration(a.slots) * time.Hour
+	retention = max(retention, 24*time.Hour)
+	oldest := now.Add(-retention).Unix()
+	for key, counters := range a.baselines {
+		for name, b := range counters {
+			if b.LastSeen < oldest {
+				delete(counters, name)
+			}
+		}
+		if len(counters) == 0 {
+			delete(a.baselines, key)
+		}
+	}
+}
+
+func (a *Anomaly) load() error {
+	var s stateFile
+	if err := statefile.Load(a.path, &s); err != nil {
+		return err
+	}
+	if s.Seasonality != a.seasonality {
+		a.SLogger.Info("Seasonality changed, baselines are relearned",
+			slog.String("previous", s.Seasonality),
+			slog.String("seasonality", a.seasonality),
+		)
+		return nil
+	}
+	for key, counters := range s.Baselines {
+		for name, bl := range counters {
+			if bl == nil || len(bl.Mean) != a.slots || len(bl.Var) != a.slots || len(bl.Count) != a.slots {
+				delete(counters, name)
+			}
+		}
+		a.baselines[key] = counters
+	}
+	return nil
+}
+
+// save writes the baselines to the state file
+func (a *Anomaly) save() error {
+	return statefile.Save(a.path, stateFile{Seasonality: a.seasonality, Baselines: a.baselines})
+}
+
+// NewMetrics returns the new metrics the receiver creates
+func (a *Anomaly) NewMetrics() []plugin.DerivedMetric {
+	derivedMetrics := make([]plugin.DerivedMetric, 0, 2*len(a.counters))
+	for _, counter := range a.counters {
+		derivedMetrics = append(derivedMetrics,
+			plugin.DerivedMetric{Name: counter + scoreSuffix, Source: counter},
+			plugin.DerivedMetric{Name: counter + anomalySuffix, Source: counter},
+		)
+	}
+	return derivedMetrics
+}
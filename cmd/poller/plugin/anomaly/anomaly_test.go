@@ -0,0 +1,150 @@
+package anomaly
+
+import (
+	"testing"
+	"time"
+
+	"github.com/netapp/harvest/v2/assert"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+)
+
+var start = time.Date(2026, 3, 3, 10, 0, 0, 0, time.UTC)
+
+func newAnomaly(t *testing.T, dir string, seasonality string) *Anomaly {
+	t.Helper()
+
+	params := node.NewS("Anomaly")
+	// score the volume latency
+	params.NewChildS("counters", "").NewChildS("", "avg_latency")
+	// an hour is scored after three polls
+	params.NewChildS("warmup", "3")
+	params.NewChildS("seasonality", seasonality)
+	// save on every poll that is a minute after the previous save
+	params.NewChildS("save_interval", "1m")
+	params.NewChildS("path", dir)
+
+	p := New(plugin.New("RestPerf", nil, params, nil, "volume", nil))
+	assert.Nil(t, p.Init(conf.Remote{}))
+	return p
+}
+
+func newLatencyData() *matrix.Matrix {
+	m := matrix.New("RestPerf", "volume", "volume")
+	_, _ = m.NewMetricFloat64("avg_latency")
+	_, _ = m.NewInstance("vol1")
+	return m
+}
+
+func TestAnomaly(t *testing.T) {
+	p := newAnomaly(t, t.TempDir(), "day")
+	m := newLatencyData()
+	vol1 := m.GetInstance("vol1")
+
+	steps := []struct {
+		name    string
+		at      time.Duration
+		latency float64
+		scored  bool
+		anomaly float64
+	}{
+		{name: "warmup", at: 0, latency: 900},
+		{name: "warmup", at: time.Minute, latency: 1100},
+		{name: "warmup", at: 2 * time.Minute, latency: 900},
+		{name: "normal", at: 3 * time.Minute, latency: 950, scored: true},
+		{name: "normal", at: 4 * time.Minute, latency: 930, scored: true},
+		{name: "spike", at: 5 * time.Minute, latency: 5000, scored: true, anomaly: 1},
+		// the 11am slot has its own baseline
+		{name: "next hour", at: time.Hour, latency: 1000},
+	}
+	for _, step := range steps {
+		m.GetMetric("avg_latency").SetValueFloat64(vol1, step.latency)
+		p.now = func() time.Time { return start.Add(step.at) }
+		_, _, err := p.Run(map[string]*matrix.Matrix{"volume": m})
+		assert.Nil(t, err)
+
+		_, scored := m.GetMetric("avg_latency_anomaly_score").GetValueFloat64(vol1)
+		assert.Equal(t, scored, step.scored)
+		if scored {
+			anomaly, _ := m.GetMetric("avg_latency_anomaly").GetValueFloat64(vol1)
+			assert.Equal(t, anomaly, step.anomaly)
+		}
+	}
+}
+
+func TestScore(t *testing.T) {
+	b := &baseline{Mean: make([]float64, 1), Var: make([]float64, 1), Count: make([]int, 1)}
+	for range 3 {
+		b.update(0, 0, 0.1)
+	}
+
+	// an idle volume has no deviation to compare against
+	_, ok := b.score(0, 10, 3, 0)
+	assert.False(t, ok)
+
+	// unless min_std is set
+	score, ok := b.score(0, 10, 3, 1)
+	assert.True(t, ok)
+	assert.Equal(t, score, 10.0)
+
+	// the standard deviation is at least 1% of the mean
+	b.update(0, 1000, 1)
+	score, _ = b.score(0, 1010, 3, 0)
+	assert.Equal(t, score, 1.0)
+}
+
+func TestBaselinesArePersisted(t *testing.T) {
+	dir := t.TempDir()
+	run := func(p *Anomaly, at time.Duration) bool {
+		m := newLatencyData()
+		vol1 := m.GetInstance("vol1")
+		m.GetMetric("avg_latency").SetValueFloat64(vol1, 1000)
+		p.now = func() time.Time { return start.Add(at) }
+		_, _, err := p.Run(map[string]*matrix.Matrix{"volume": m})
+		assert.Nil(t, err)
+		_, scored := m.GetMetric("avg_latency_anomaly_score").GetValueFloat64(vol1)
+		return scored
+	}
+
+	p := newAnomaly(t, dir, "week")
+	for i := range 5 {
+		run(p, time.Duration(i)*time.Minute)
+	}
+
+	restarted := newAnomaly(t, dir, "week")
+	assert.True(t, run(restarted, 10*time.Minute))
+	// the five persisted values plus this poll
+	assert.Equal(t, restarted.baselines["vol1"]["avg_latency"].Count[restarted.slot(start)], 6)
+
+	// baselines of another seasonality are discarded
+	relearn := newAnomaly(t, dir, "day")
+	assert.False(t, run(relearn, 11*time.Minute))
+}
+
+func TestParams(t *testing.T) {
+	tests := []struct {
+		name  string
+		param string
+		value string
+		ok    bool
+	}{
+		{name: "week", param: "seasonality", value: "week", ok: true},
+		{name: "threshold", param: "threshold", value: "5", ok: true},
+		{name: "invalid seasonality", param: "seasonality", value: "month"},
+		{name: "invalid alpha", param: "alpha", value: "2"},
+		{name: "invalid warmup", param: "warmup", value: "0"},
+		{name: "invalid threshold", param: "threshold", value: "-1"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := node.NewS("Anomaly")
+			params.NewChildS("counters", "").NewChildS("", "avg_latency")
+			params.NewChildS(tt.param, tt.value)
+			p := New(plugin.New("RestPerf", nil, params, nil, "volume", nil))
+			err := p.Init(conf.Remote{})
+			assert.Equal(t, err == nil, tt.ok)
+		})
+	}
+}
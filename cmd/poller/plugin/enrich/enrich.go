@@ -0,0 +1,469 @@
+/*
+ * Copyright NetApp Inc, 2026 All rights reserved
+ */
+
+// Package enrich implements the Enrich plugin. It joins instances against a lookup table on key labels and
+// adds the table's columns as labels, e.g. the owner and cost center of each volume.
+package enrich
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/collector"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/slogx"
+	"github.com/netapp/harvest/v2/third_party/tidwall/gjson"
+)
+
+const (
+	formatCSV  = "csv"
+	formatJSON = "json"
+
+	defaultRefresh = 5 * time.Minute
+	defaultTimeout = 10 * time.Second
+	// maxSourceSize is the largest source that is loaded
+	maxSourceSize = 64 * 1024 * 1024
+	// maxLoggedKeys is the number of unmatched keys logged
+	maxLoggedKeys = 10
+)
+
+type Enrich struct {
+	*plugin.AbstractPlugin
+	source  string
+	isURL   bool
+	format  string
+	records string
+	headers map[string]string
+	refresh time.Duration
+	client  *http.Client
+	keys    []mapping // instance label => column
+	labels  []mapping // column => instance label
+
+	table       map[string]map[string]string // key => label => value
+	rows        int
+	loaded      time.Time
+	modTime     time.Time
+	size        int64
+	stale       bool
+	exported    bool
+	unmatched   int
+	summary     *matrix.Matrix
+	sourceLabel string
+	now         func() time.Time
+}
+
+type mapping struct {
+	from string
+	to   string
+}
+
+func New(p *plugin.AbstractPlugin) *Enrich {
+	return &Enrich{AbstractPlugin: p, now: time.Now}
+}
+
+func (e *Enrich) Init(remote conf.Remote) error {
+	if err := e.AbstractPlugin.Init(remote); err != nil {
+		return err
+	}
+	if err := e.parseParams(); err != nil {
+		return err
+	}
+
+	// the identifier includes the object, so the summaries of the objects of one collector do not replace each other
+	e.summary = matrix.New(e.Parent+".Enrich", "enrich", e.Object+"_enrich")
+	for _, name := range []string{"rows", "matched", "unmatched", "stale"} {
+		if _, err := e.summary.NewMetricUint64(name); err != nil {
+			return err
+		}
+	}
+	e.summary.SetExportOptions(matrix.DefaultExportOptions())
+
+	e.SLogger.Debug(
+		"initialized",
+		slog.String("source", e.source),
+		slog.String("format", e.format),
+		slog.Int("numKeys", len(e.keys)),
+		slog.Int("numLabels", len(e.labels)),
+	)
+	return nil
+}
+
+func (e *Enrich) parseParams() error {
+	var err error
+
+	if e.source = e.Params.GetChildContentS("source"); e.source == "" {
+		return errs.New(errs.ErrMissingParam, "source")
+	}
+	e.isURL = strings.HasPrefix(e.source, "http://") || strings.HasPrefix(e.source, "https://")
+	e.sourceLabel = e.source
+	if !e.isURL {
+		e.source = conf.Path(e.source)
+	}
+
+	e.format = strings.ToLower(e.Params.GetChildContentS("format"))
+	if e.format == "" {
+		e.format = formatJSON
+		if strings.EqualFold(filepath.Ext(e.sourceLabel), ".csv") {
+			e.format = formatCSV
+		}
+	}
+	if e.format != formatCSV && e.format != formatJSON {
+		return errs.New(errs.ErrInvalidParam, "format ("+e.format+"), must be csv or json")
+	}
+	e.records = e.Params.GetChildContentS("records")
+
+	if e.keys, err = parseMappings(e.list("key")); err != nil {
+		return fmt.Errorf("invalid key: %w", err)
+	}
+	if len(e.keys) == 0 {
+		return errs.New(errs.ErrMissingParam, "key")
+	}
+	if e.labels, err = parseMappings(e.list("labels")); err != nil {
+		return fmt.Errorf("invalid labels: %w", err)
+	}
+	if len(e.labels) == 0 {
+		return errs.New(errs.ErrMissingParam, "labels")
+	}
+
+	if e.refresh, err = parseDuration(e.Params.GetChildContentS("refresh"), defaultRefresh); err != nil {
+		return fmt.Errorf("invalid refresh: %w", err)
+	}
+	timeout, err := parseDuration(e.Params.GetChildContentS("timeout"), defaultTimeout)
+	if err != nil {
+		return fmt.Errorf("invalid timeout: %w", err)
+	}
+	e.client = &http.Client{Timeout: timeout}
+
+	e.headers = make(map[string]string)
+	if headers := e.Params.GetChildS("headers"); headers != nil {
+		for _, h := range headers.GetChildren() {
+			e.headers[h.GetNameS()] = h.GetContentS()
+		}
+	}
+
+	return nil
+}
+
+// list returns the contents of a list parameter. A parameter that is not a list is a list of one.
+func (e *Enrich) list(name string) []string {
+	n := e.Params.GetChildS(name)
+	if n == nil {
+		return nil
+	}
+	if len(n.GetChildren()) == 0 {
+		if c := n.GetContentS(); c != "" {
+			return []string{c}
+		}
+		return nil
+	}
+	return n.GetAllChildContentS()
+}
+
+// parseMappings parses lines like "from => to". A line without => maps a name to itself.
+func parseMappings(lines []string) ([]mapping, error) {
+	mappings := make([]mapping, 0, len(lines))
+	for _, line := range lines {
+		from, to, found := strings.Cut(line, "=>")
+		m := mapping{from: strings.TrimSpace(from), to: strings.TrimSpace(to)}
+		if !found {
+			m.to = m.from
+		}
+		if m.from == "" || m.to == "" {
+			return nil, errors.New(line)
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, nil
+}
+
+func parseDuration(s string, defaultDuration time.Duration) (time.Duration, error) {
+	if s == "" {
+		return defaultDuration, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err == nil && d <= 0 {
+		err = errors.New("must be positive")
+	}
+	return d, err
+}
+
+func (e *Enrich) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *collector.Metadata, error) {
+	data := dataMap[e.Object]
+	metadata := &collector.Metadata{}
+
+	if err := e.reload(metadata); err != nil {
+		e.stale = true
+		// keep the previous table
+		e.SLogger.Warn("Failed to load enrichment source", slogx.Err(err), slog.String("source", e.sourceLabel))
+	}
+
+	e.exportLabels(data)
+
+	var (
+		matched   uint64
+		unmatched []string
+	)
+	for key, instance := range data.GetInstances() {
+		values := make([]string, 0, len(e.keys))
+		for _, k := range e.keys {
+			values = append(values, instance.GetLabel(k.from))
+		}
+		row, ok := e.table[joinKey(values)]
+		if !ok {
+			for _, l := range e.labels {
+				instance.RemoveLabel(l.to)
+			}
+			if instance.IsExportable() {
+				unmatched = append(unmatched, key)
+			}
+			continue
+		}
+		for _, l := range e.labels {
+			if v, ok := row[l.from]; ok {
+				instance.SetLabel(l.to, v)
+			} else {
+				instance.RemoveLabel(l.to)
+			}
+		}
+		if instance.IsExportable() {
+			matched++
+		}
+	}
+
+	if len(unmatched) != e.unmatched {
+		e.unmatched = len(unmatched)
+		if len(unmatched) > 0 {
+			slices.Sort(unmatched)
+			e.SLogger.Info(
+				"Instances without a match in the enrichment source",
+				slog.Int("count", len(unmatched)),
+				slog.Any("keys", unmatched[:min(len(unmatched), maxLoggedKeys)]),
+			)
+		}
+	}
+
+	if metadata.NumCalls.Load() == 0 {
+		metadata = nil
+	}
+	return []*matrix.Matrix{e.metrics(data, matched, uint64(len(unmatched)))}, metadata, nil
+}
+
+// exportLabels adds the labels to the instance_labels of the object, so they are exported with the object's
+// labels metric
+func (e *Enrich) exportLabels(data *matrix.Matrix) {
+	if e.exported {
+		return
+	}
+	e.exported = true
+
+	exportOptions := data.GetExportOptions()
+	if exportOptions == nil || exportOptions.GetChildContentS("include_all_labels") == "true" {
+		return
+	}
+	instanceLabels := exportOptions.GetChildS("instance_labels")
+	if instanceLabels == nil {
+		instanceLabels = exportOptions.NewChildS("instance_labels", "")
+	}
+	existing := instanceLabels.GetAllChildContentS()
+	if instanceKeys := exportOptions.GetChildS("instance_keys"); instanceKeys != nil {
+		existing = append(existing, instanceKeys.GetAllChildContentS()...)
+	}
+	for _, l := range e.labels {
+		if !slices.Contains(existing, l.to) {
+			instanceLabels.NewChildS("", l.to)
+			existing = append(existing, l.to)
+		}
+	}
+}
+
+func (e *Enrich) metrics(data *matrix.Matrix, matched uint64, unmatched uint64) *matrix.Matrix {
+	e.summary.SetGlobalLabels(data.GetGlobalLabels())
+	instance, created := e.summary.GetOrCreateInstance(e.Object)
+	if created {
+		instance.SetLabel("object", e.Object)
+		instance.SetLabel("source", e.sourceLabel)
+	}
+	stale := uint64(0)
+	if e.stale {
+		stale = 1
+	}
+	e.summary.MustSetValueUint64("rows", instance, uint64(e.rows))
+	e.summary.MustSetValueUint64("matched", instance, matched)
+	e.summary.MustSetValueUint64("unmatched", instance, unmatched)
+	e.summary.MustSetValueUint64("stale", instance, stale)
+	return e.summary
+}
+
+// reload loads the source when a file changed or when a URL is due for a refresh
+func (e *Enrich) reload(metadata *collector.Metadata) error {
+	var (
+		content []byte
+		err     error
+	)
+
+	if e.isURL {
+		if e.table != nil && e.now().Sub(e.loaded) < e.refresh {
+			return nil
+		}
+		// a failed request is retried at the next refresh
+		e.loaded = e.now()
+		if content, err = e.fetch(); err != nil {
+			return err
+		}
+		metadata.NumCalls.Add(1)
+		metadata.BytesRx.Add(uint64(len(content)))
+	} else {
+		info, err := os.Stat(e.source)
+		if err != nil {
+			return err
+		}
+		if e.table != nil && info.ModTime().Equal(e.modTime) && info.Size() == e.size {
+			return nil
+		}
+		if info.Size() > maxSourceSize {
+			return fmt.Errorf("file is larger than %d bytes", maxSourceSize)
+		}
+		e.modTime, e.size = info.ModTime(), info.Size()
+		if content, err = os.ReadFile(e.source); err != nil {
+			return err
+		}
+	}
+
+	var rows []map[string]string
+	if e.format == formatCSV {
+		rows, err = parseCSV(content)
+	} else {
+		rows, err = parseJSON(content, e.records)
+	}
+	if err != nil {
+		return err
+	}
+
+	table := make(map[string]map[string]string, len(rows))
+	for i, row := range rows {
+		values := make([]string, 0, len(e.keys))
+		for _, k := range e.keys {
+			v, ok := row[k.to]
+			if !ok {
+				return fmt.Errorf("row %d: missing key column %s", i+1, k.to)
+			}
+			values = append(values, v)
+		}
+		table[joinKey(values)] = row
+	}
+
+	e.table = table
+	e.rows = len(rows)
+	e.stale = false
+	e.SLogger.Debug("loaded enrichment source", slog.String("source", e.sourceLabel), slog.Int("rows", len(rows)))
+	return nil
+}
+
+func (e *Enrich) fetch() ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), e.client.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, e.source, nil)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", e.sourceLabel, resp.Status)
+	}
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxSourceSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(content) > maxSourceSize {
+		return nil, fmt.Errorf("response is larger than %d bytes", maxSourceSize)
+	}
+	return content, nil
+}
+
+// joinKey joins the values of the key labels. The separator can not be part of a label value.
+func joinKey(values []string) string {
+	return strings.Join(values, "\x00")
+}
+
+// parseCSV parses a CSV file whose first row is the header
+func parseCSV(content []byte) ([]map[string]string, error) {
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.TrimLeadingSpace = true
+	reader.Comment = '#'
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, errors.New("missing header")
+	}
+
+	header := records[0]
+	for i := range header {
+		header[i] = strings.TrimSpace(header[i])
+	}
+	rows := make([]map[string]string, 0, len(records)-1)
+	for _, record := range records[1:] {
+		row := make(map[string]string, len(header))
+		for i, column := range header {
+			row[column] = strings.TrimSpace(record[i])
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// parseJSON parses a list of objects. records is the gjson path of the list, when the document is not the list.
+// Values that are not strings are converted to their JSON text.
+func parseJSON(content []byte, records string) ([]map[string]string, error) {
+	if !gjson.ValidBytes(content) {
+		return nil, errors.New("invalid JSON")
+	}
+	list := gjson.ParseBytes(content)
+	if records != "" {
+		list = list.Get(records)
+	}
+	if !list.IsArray() {
+		return nil, errors.New("records is not a list")
+	}
+
+	var rows []map[string]string
+	for i, r := range list.Array() {
+		if !r.IsObject() {
+			return nil, fmt.Errorf("record %d is not an object", i+1)
+		}
+		row := make(map[string]string)
+		r.ForEach(func(key, value gjson.Result) bool {
+			if value.Type == gjson.String {
+				row[key.ClonedString()] = value.ClonedString()
+			} else {
+				row[key.ClonedString()] = strings.Clone(value.Raw)
+			}
+			return true
+		})
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
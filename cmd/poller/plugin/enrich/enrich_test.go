@@ -0,0 +1,181 @@
+package enrich
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/netapp/harvest/v2/assert"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+)
+
+const owners = `svm, volume, owner, cost_center
+svm1, vol1, alice, cc-100
+svm1, vol2, bob, cc-200
+`
+
+// newParams returns the params of an Enrich plugin that adds the owner and cost center of a volume from source
+func newParams(source string) *node.Node {
+	params := node.NewS("Enrich")
+	params.NewChildS("source", source)
+	// join on the svm and volume labels
+	key := params.NewChildS("key", "")
+	key.NewChildS("", "svm")
+	key.NewChildS("", "volume")
+	// add the owner column as is and rename the cost_center column
+	labels := params.NewChildS("labels", "")
+	labels.NewChildS("", "owner")
+	labels.NewChildS("", "cost_center => cost")
+	return params
+}
+
+func newEnrich(t *testing.T, params *node.Node) *Enrich {
+	t.Helper()
+
+	e := New(plugin.New("Rest", nil, params, nil, "volume", nil))
+	assert.Nil(t, e.Init(conf.Remote{}))
+	return e
+}
+
+func newVolumes(t *testing.T) *matrix.Matrix {
+	t.Helper()
+
+	data := matrix.New("Rest", "volume", "volume")
+	data.SetExportOptions(matrix.NewExportOptions("svm", "volume"))
+	data.SetGlobalLabel("cluster", "c1")
+	for _, v := range [][]string{{"svm1", "vol1"}, {"svm1", "vol2"}, {"svm2", "vol1"}} {
+		instance, err := data.NewInstance(v[0] + "/" + v[1])
+		assert.Nil(t, err)
+		instance.SetLabel("svm", v[0])
+		instance.SetLabel("volume", v[1])
+	}
+	return data
+}
+
+func summaryValue(t *testing.T, m *matrix.Matrix, metric string) uint64 {
+	t.Helper()
+	v, ok := m.GetMetric(metric).GetValueUint64(m.GetInstance("volume"))
+	assert.True(t, ok)
+	return v
+}
+
+func TestCSV(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "owners.csv")
+	assert.Nil(t, os.WriteFile(path, []byte(owners), 0600))
+	e := newEnrich(t, newParams(path))
+	assert.Equal(t, e.format, formatCSV)
+
+	data := newVolumes(t)
+	results, _, err := e.Run(map[string]*matrix.Matrix{"volume": data})
+	assert.Nil(t, err)
+
+	vol1 := data.GetInstance("svm1/vol1")
+	assert.Equal(t, vol1.GetLabel("owner"), "alice")
+	assert.Equal(t, vol1.GetLabel("cost"), "cc-100")
+	assert.Equal(t, data.GetInstance("svm1/vol2").GetLabel("owner"), "bob")
+	assert.Equal(t, data.GetInstance("svm2/vol1").GetLabel("owner"), "")
+
+	// the labels are exported
+	assert.Equal(t, data.GetExportOptions().GetChildS("instance_labels").GetAllChildContentS(), []string{"owner", "cost"})
+
+	assert.Equal(t, len(results), 1)
+	summary := results[0]
+	assert.Equal(t, summary.Object, "enrich")
+	assert.Equal(t, summary.Identifier, "volume_enrich")
+	assert.Equal(t, summary.GetGlobalLabels()["cluster"], "c1")
+	assert.Equal(t, summary.GetInstance("volume").GetLabel("source"), path)
+	assert.Equal(t, summaryValue(t, summary, "rows"), uint64(2))
+	assert.Equal(t, summaryValue(t, summary, "matched"), uint64(2))
+	assert.Equal(t, summaryValue(t, summary, "unmatched"), uint64(1))
+	assert.Equal(t, summaryValue(t, summary, "stale"), uint64(0))
+
+	// the file is reloaded when it changes, labels of rows that are gone are removed
+	assert.Nil(t, os.WriteFile(path, []byte("svm,volume,owner,cost_center\nsvm2,vol1,carol,cc-300\n"), 0600))
+	assert.Nil(t, os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute)))
+	results, _, err = e.Run(map[string]*matrix.Matrix{"volume": data})
+	assert.Nil(t, err)
+	assert.Equal(t, data.GetInstance("svm2/vol1").GetLabel("owner"), "carol")
+	assert.Equal(t, vol1.GetLabel("owner"), "")
+	assert.Equal(t, summaryValue(t, results[0], "matched"), uint64(1))
+
+	// a broken file keeps the previous table
+	assert.Nil(t, os.WriteFile(path, []byte("svm,volume\nsvm1"), 0600))
+	results, _, err = e.Run(map[string]*matrix.Matrix{"volume": data})
+	assert.Nil(t, err)
+	assert.Equal(t, data.GetInstance("svm2/vol1").GetLabel("owner"), "carol")
+	assert.Equal(t, summaryValue(t, results[0], "stale"), uint64(1))
+}
+
+func TestHTTP(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"records": [
+			{"svm": "svm1", "volume": "vol1", "owner": "alice", "cost_center": 100},
+			{"svm": "svm2", "volume": "vol1", "owner": "carol"}
+		]}`))
+	}))
+	defer server.Close()
+
+	params := newParams(server.URL + "/owners")
+	params.NewChildS("records", "records")
+	params.NewChildS("refresh", "1h")
+	params.NewChildS("headers", "").NewChildS("Authorization", "Bearer token")
+	e := newEnrich(t, params)
+	assert.Equal(t, e.format, formatJSON)
+
+	now := time.Now()
+	e.now = func() time.Time { return now }
+	data := newVolumes(t)
+	_, metadata, err := e.Run(map[string]*matrix.Matrix{"volume": data})
+	assert.Nil(t, err)
+	assert.Equal(t, metadata.NumCalls.Load(), uint64(1))
+
+	vol1 := data.GetInstance("svm1/vol1")
+	assert.Equal(t, vol1.GetLabel("owner"), "alice")
+	assert.Equal(t, vol1.GetLabel("cost"), "100")
+	// a missing column removes the label
+	assert.Equal(t, data.GetInstance("svm2/vol1").GetLabel("owner"), "carol")
+	_, ok := data.GetInstance("svm2/vol1").GetLabels()["cost"]
+	assert.False(t, ok)
+
+	// the URL is requested again after refresh
+	_, _, err = e.Run(map[string]*matrix.Matrix{"volume": data})
+	assert.Nil(t, err)
+	assert.Equal(t, calls, 1)
+	now = now.Add(2 * time.Hour)
+	_, _, err = e.Run(map[string]*matrix.Matrix{"volume": data})
+	assert.Nil(t, err)
+	assert.Equal(t, calls, 2)
+}
+
+func TestParams(t *testing.T) {
+	tests := []struct {
+		name   string
+		change func(params *node.Node)
+	}{
+		{name: "missing source", change: func(p *node.Node) { p.SetChildContentS("source", "") }},
+		{name: "missing labels", change: func(p *node.Node) { p.PopChildS("labels") }},
+		{name: "invalid format", change: func(p *node.Node) { p.NewChildS("format", "xml") }},
+		{name: "invalid mapping", change: func(p *node.Node) { p.GetChildS("key").NewChildS("", "volume =>") }},
+		{name: "invalid refresh", change: func(p *node.Node) { p.NewChildS("refresh", "-1m") }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params := newParams("a.csv")
+			tt.change(params)
+			e := New(plugin.New("Rest", nil, params, nil, "volume", nil))
+			assert.NotNil(t, e.Init(conf.Remote{}))
+		})
+	}
+}
@@ -0,0 +1,297 @@
+/*
+ * Copyright NetApp Inc, 2026 All rights reserved
+ */
+
+// Package external implements the External plugin and the SDK of external plugins.
+//
+// The External plugin runs a plugin in its own process. At each poll, the poller sends the collector's
+// matrices to the process and applies the matrices the process sends back. Use Serve to write the process
+// in Go, the same way as a built-in plugin.
+package external
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/collector"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/slogx"
+	"github.com/netapp/harvest/v2/pkg/subprocess"
+)
+
+const defaultTimeout = 10 * time.Second
+
+type External struct {
+	*plugin.AbstractPlugin
+	command []string
+	env     []string
+	timeout time.Duration
+	process *process
+}
+
+func New(p *plugin.AbstractPlugin) *External {
+	return &External{AbstractPlugin: p}
+}
+
+func (e *External) Init(remote conf.Remote) error {
+	if err := e.AbstractPlugin.Init(remote); err != nil {
+		return err
+	}
+
+	command := e.Params.GetChildS("command")
+	if command != nil {
+		if len(command.GetChildren()) > 0 {
+			e.command = command.GetAllChildContentS()
+		} else {
+			e.command = strings.Fields(command.GetContentS())
+		}
+	}
+	if len(e.command) == 0 {
+		return errs.New(errs.ErrMissingParam, "command")
+	}
+
+	e.timeout = defaultTimeout
+	if t := e.Params.GetChildContentS("timeout"); t != "" {
+		var err error
+		if e.timeout, err = time.ParseDuration(t); err != nil || e.timeout <= 0 {
+			return errs.New(errs.ErrInvalidParam, "timeout ("+t+")")
+		}
+	}
+
+	e.env = os.Environ()
+	if vars := e.Params.GetChildS("env"); vars != nil {
+		for _, v := range vars.GetChildren() {
+			e.env = append(e.env, v.GetNameS()+"="+v.GetContentS())
+		}
+	}
+
+	// start the process now, so a plugin that does not start fails the collector's init
+	if err := e.start(remote); err != nil {
+		return err
+	}
+
+	e.SLogger.Debug("initialized", slog.Any("command", e.command), slog.Duration("timeout", e.timeout))
+	return nil
+}
+
+func (e *External) start(remote conf.Remote) error {
+	p, err := startProcess(e.command, e.env, e.SLogger)
+	if err != nil {
+		return err
+	}
+
+	poller := ""
+	if e.Options != nil {
+		poller = e.Options.Poller
+	}
+	request := &Request{
+		Type:   RequestInit,
+		Remote: remote,
+		Plugin: &Info{Parent: e.Parent, Object: e.Object, Poller: poller, Params: EncodeNode(e.Params)},
+	}
+	response, err := p.call(request, e.timeout)
+	if err == nil && response.Error != "" {
+		err = errors.New(response.Error)
+	}
+	if err != nil {
+		p.stop()
+		return fmt.Errorf("init %s: %w", e.command[0], err)
+	}
+
+	e.process = p
+	e.SLogger.Info("started plugin process", slog.Int("pid", p.cmd.Process.Pid), slog.Any("command", e.command))
+	return nil
+}
+
+func (e *External) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *collector.Metadata, error) {
+	if e.process == nil {
+		if err := e.start(e.Remote); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	request := &Request{Type: RequestRun, Remote: e.Remote, Data: make([]*Matrix, 0, len(dataMap))}
+	for key, m := range dataMap {
+		request.Data = append(request.Data, EncodeMatrix(key, m))
+	}
+
+	response, err := e.process.call(request, e.timeout)
+	if err != nil {
+		// the process is restarted by the next poll
+		e.process.stop()
+		e.process = nil
+		return nil, nil, fmt.Errorf("run %s: %w", e.command[0], err)
+	}
+	if response.Error != "" {
+		return nil, nil, errors.New(response.Error)
+	}
+
+	var created []*matrix.Matrix
+	for _, m := range response.Data {
+		if m.Key == "" {
+			d, err := m.Decode()
+			if err != nil {
+				return nil, nil, err
+			}
+			created = append(created, d)
+			continue
+		}
+		d, ok := dataMap[m.Key]
+		if !ok {
+			e.SLogger.Warn("plugin returned an unknown matrix", slog.String("key", m.Key))
+			continue
+		}
+		if err := m.ApplyTo(d); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return created, nil, nil
+}
+
+// process is a running plugin process
+type process struct {
+	cmd       *exec.Cmd
+	stdin     io.WriteCloser
+	encoder   *json.Encoder
+	responses chan *Response
+	stderr    *subprocess.TailBuffer
+	readErr   error
+	done      chan struct{}
+	stopOnce  sync.Once
+}
+
+// startProcess starts the command in its own process group, so the command and the processes it starts
+// are killed together. Lines the command writes to stderr are logged.
+func startProcess(command []string, env []string, logger *slog.Logger) (*process, error) {
+	cmd := subprocess.Command(context.Background(), command, env)
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	p := &process{
+		cmd:       cmd,
+		stdin:     stdin,
+		encoder:   json.NewEncoder(stdin),
+		responses: make(chan *Response),
+		stderr:    &subprocess.TailBuffer{},
+		done:      make(chan struct{}),
+	}
+	cmd.Stderr = io.MultiWriter(p.stderr, &logWriter{logger: logger})
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("start %s: %w", command[0], err)
+	}
+
+	go func() {
+		defer close(p.responses)
+		decoder := json.NewDecoder(stdout)
+		for {
+			var response Response
+			if err := decoder.Decode(&response); err != nil {
+				p.readErr = err
+				return
+			}
+			select {
+			case p.responses <- &response:
+			case <-p.done:
+				return
+			}
+		}
+	}()
+
+	return p, nil
+}
+
+// call sends the request and waits for the response. An error means the process is not usable anymore.
+// Errors of the plugin are in the response.
+func (p *process) call(request *Request, timeout time.Duration) (*Response, error) {
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+
+	written := make(chan error, 1)
+	go func() {
+		written <- p.encoder.Encode(request)
+	}()
+
+	select {
+	case err := <-written:
+		if err != nil {
+			return nil, p.error(fmt.Errorf("write request: %w", err))
+		}
+	case <-timer.C:
+		return nil, p.error(errors.New("timeout writing request"))
+	}
+
+	select {
+	case response, ok := <-p.responses:
+		if !ok {
+			if errors.Is(p.readErr, io.EOF) {
+				return nil, p.error(errors.New("process exited"))
+			}
+			return nil, p.error(fmt.Errorf("read response: %w", p.readErr))
+		}
+		return response, nil
+	case <-timer.C:
+		return nil, p.error(fmt.Errorf("no response after %s", timeout))
+	}
+}
+
+// error adds the end of the process's stderr to err
+func (p *process) error(err error) error {
+	return subprocess.Error(err, p.stderr)
+}
+
+// stop closes the process's stdin and kills its process group
+func (p *process) stop() {
+	p.stopOnce.Do(func() {
+		close(p.done)
+		_ = p.stdin.Close()
+		if err := subprocess.Kill(p.cmd); err != nil {
+			slog.Default().Debug("failed to kill plugin process", slogx.Err(err), slog.Int("pid", p.cmd.Process.Pid))
+		}
+	})
+}
+
+// logWriter logs each line written to it
+type logWriter struct {
+	logger *slog.Logger
+	buf    []byte
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
+		}
+		if line := strings.TrimSpace(string(w.buf[:i])); line != "" {
+			w.logger.Info(line, slog.String("source", "stderr"))
+		}
+		w.buf = w.buf[i+1:]
+	}
+	// a line without a newline is logged when it is too long to keep
+	if len(w.buf) > subprocess.StderrTail {
+		w.logger.Info(string(w.buf), slog.String("source", "stderr"))
+		w.buf = w.buf[:0]
+	}
+	return len(p), nil
+}
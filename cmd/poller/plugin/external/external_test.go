@@ -0,0 +1,210 @@
+package external
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/netapp/harvest/v2/assert"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/collector"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+)
+
+// TestMain runs the test binary as the plugin process when HARVEST_TEST_PLUGIN is set
+func TestMain(m *testing.M) {
+	if os.Getenv("HARVEST_TEST_PLUGIN") != "" {
+		err := Serve(func(p *plugin.AbstractPlugin) plugin.Plugin {
+			return &enrich{AbstractPlugin: p}
+		})
+		if err != nil {
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+	os.Exit(m.Run())
+}
+
+// enrich adds a site label, doubles size, hides instances of the test svm, and creates a matrix with the
+// number of volumes
+type enrich struct {
+	*plugin.AbstractPlugin
+	site string
+}
+
+func (e *enrich) Init(remote conf.Remote) error {
+	if err := e.AbstractPlugin.Init(remote); err != nil {
+		return err
+	}
+	if sites := e.Params.GetChildS("sites"); sites != nil {
+		e.site = sites.GetChildContentS(e.Object)
+	}
+	if e.site == "" {
+		return errors.New("missing site of " + e.Object)
+	}
+	return nil
+}
+
+func (e *enrich) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *collector.Metadata, error) {
+	data := dataMap[e.Object]
+	switch data.GetGlobalLabels()["fail"] {
+	case "error":
+		return nil, nil, errors.New("failed on purpose")
+	case "panic":
+		panic("panicked on purpose")
+	case "hang":
+		time.Sleep(time.Minute)
+	case "exit":
+		os.Exit(2)
+	}
+
+	doubled, err := data.NewMetricFloat64("size_doubled")
+	if err != nil {
+		return nil, nil, err
+	}
+	count := 0.0
+	for _, instance := range data.GetInstances() {
+		instance.SetLabel("site", e.site)
+		if instance.GetLabel("svm") == "test" {
+			instance.SetExportable(false)
+			continue
+		}
+		if size, ok := data.GetMetric("size").GetValueFloat64(instance); ok {
+			doubled.SetValueFloat64(instance, 2*size)
+		}
+		count++
+	}
+
+	summary := matrix.New("Summary", "volume_summary", "volume_summary")
+	instance, _ := summary.NewInstance("all")
+	summary.SetExportOptions(matrix.DefaultExportOptions())
+	metric, _ := summary.NewMetricFloat64("count")
+	metric.SetValueFloat64(instance, count)
+	return []*matrix.Matrix{summary}, nil, nil
+}
+
+func newExternal(t *testing.T, timeout string) *External {
+	t.Helper()
+	t.Setenv("HARVEST_TEST_PLUGIN", "1")
+
+	params := node.NewS("External")
+	params.NewChildS("command", os.Args[0])
+	params.NewChildS("timeout", timeout)
+	params.NewChildS("sites", "").NewChildS("volume", "rtp")
+
+	e := New(plugin.New("Rest", nil, params, nil, "volume", nil))
+	assert.Nil(t, e.Init(conf.Remote{}))
+	t.Cleanup(func() {
+		if e.process != nil {
+			e.process.stop()
+		}
+	})
+	return e
+}
+
+func newVolumes(t *testing.T) *matrix.Matrix {
+	t.Helper()
+	data := matrix.New("Volume", "volume", "volume")
+	data.SetExportOptions(matrix.NewExportOptions("volume", "svm"))
+	size, err := data.NewMetricFloat64("size")
+	assert.Nil(t, err)
+	for _, v := range [][]string{{"vol1", "svm1"}, {"vol2", "test"}, {"vol3", "svm1"}} {
+		instance, err := data.NewInstance(v[0])
+		assert.Nil(t, err)
+		instance.SetLabel("volume", v[0])
+		instance.SetLabel("svm", v[1])
+		size.SetValueFloat64(instance, 10)
+	}
+	return data
+}
+
+func TestRun(t *testing.T) {
+	e := newExternal(t, "10s")
+	data := newVolumes(t)
+
+	created, _, err := e.Run(map[string]*matrix.Matrix{"volume": data})
+	assert.Nil(t, err)
+
+	vol1 := data.GetInstance("vol1")
+	assert.Equal(t, vol1.GetLabel("site"), "rtp")
+	assert.True(t, vol1.IsExportable())
+	assert.False(t, data.GetInstance("vol2").IsExportable())
+	doubled, ok := data.GetMetric("size_doubled").GetValueFloat64(vol1)
+	assert.True(t, ok)
+	assert.Equal(t, doubled, 20.0)
+	// export options are kept
+	assert.Equal(t, data.GetExportOptions().GetChildS("instance_keys").GetAllChildContentS(), []string{"volume", "svm"})
+
+	assert.Equal(t, len(created), 1)
+	assert.Equal(t, created[0].Object, "volume_summary")
+	count, _ := created[0].GetMetric("count").GetValueFloat64(created[0].GetInstance("all"))
+	assert.Equal(t, count, 2.0)
+}
+
+func TestErrors(t *testing.T) {
+	e := newExternal(t, "2s")
+
+	// an error of the plugin keeps the process
+	data := newVolumes(t)
+	data.SetGlobalLabel("fail", "error")
+	_, _, err := e.Run(map[string]*matrix.Matrix{"volume": data})
+	assert.NotNil(t, err)
+	assert.Equal(t, err.Error(), "failed on purpose")
+	assert.NotNil(t, e.process)
+
+	data.SetGlobalLabel("fail", "panic")
+	_, _, err = e.Run(map[string]*matrix.Matrix{"volume": data})
+	assert.True(t, strings.Contains(err.Error(), "panicked on purpose"))
+	assert.NotNil(t, e.process)
+
+	// a process that exits or hangs is restarted by the next poll
+	for _, fail := range []string{"exit", "hang"} {
+		data.SetGlobalLabel("fail", fail)
+		_, _, err = e.Run(map[string]*matrix.Matrix{"volume": data})
+		assert.NotNil(t, err)
+		assert.Nil(t, e.process)
+
+		data = newVolumes(t)
+		_, _, err = e.Run(map[string]*matrix.Matrix{"volume": data})
+		assert.Nil(t, err)
+		assert.Equal(t, data.GetInstance("vol1").GetLabel("site"), "rtp")
+	}
+}
+
+func TestInitError(t *testing.T) {
+	t.Setenv("HARVEST_TEST_PLUGIN", "1")
+
+	params := node.NewS("External")
+	params.NewChildS("command", os.Args[0])
+	e := New(plugin.New("Rest", nil, params, nil, "volume", nil))
+	err := e.Init(conf.Remote{})
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "missing site of volume"))
+
+	params = node.NewS("External")
+	e = New(plugin.New("Rest", nil, params, nil, "volume", nil))
+	assert.NotNil(t, e.Init(conf.Remote{}))
+}
+
+func TestEncodeMatrix(t *testing.T) {
+	data := newVolumes(t)
+	data.SetGlobalLabel("cluster", "c1")
+	data.GetMetric("size").SetValueNAN(data.GetInstance("vol3"))
+
+	e := EncodeMatrix("volume", data)
+	assert.Equal(t, e.Key, "volume")
+	assert.Equal(t, len(e.Instances), 3)
+	assert.Equal(t, e.Instances[0].Key, "vol1")
+	assert.Equal(t, len(e.Metrics[0].Values), 2)
+
+	d, err := e.Decode()
+	assert.Nil(t, err)
+	assert.Equal(t, d.GetGlobalLabels()["cluster"], "c1")
+	assert.Equal(t, d.GetInstance("vol2").GetLabel("svm"), "test")
+	_, ok := d.GetMetric("size").GetValueFloat64(d.GetInstance("vol3"))
+	assert.False(t, ok)
+}
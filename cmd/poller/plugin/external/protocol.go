@@ -0,0 +1,198 @@
+package external
+
+import (
+	"math"
+	"slices"
+
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+)
+
+// The poller and the plugin process exchange one JSON message per line. The poller writes a Request to the
+// process's stdin and reads a Response from its stdout. The process logs to stderr.
+const (
+	RequestInit = "init"
+	RequestRun  = "run"
+)
+
+type Request struct {
+	Type   string      `json:"type"`
+	Remote conf.Remote `json:"remote"`
+	// Plugin is sent with init
+	Plugin *Info `json:"plugin,omitempty"`
+	// Data is sent with run. It holds the matrices of the collector's object.
+	Data []*Matrix `json:"data,omitempty"`
+}
+
+// Info describes the plugin to the process
+type Info struct {
+	Parent string `json:"parent"`
+	Object string `json:"object"`
+	Poller string `json:"poller"`
+	// Params are the plugin's parameters from the template
+	Params *Node `json:"params"`
+}
+
+type Response struct {
+	// Data holds the matrices of the request, after the plugin ran, followed by the matrices the plugin created.
+	// A created matrix has an empty Key.
+	Data  []*Matrix `json:"data,omitempty"`
+	Error string    `json:"error,omitempty"`
+}
+
+type Matrix struct {
+	// Key is the key of the matrix in the collector's data
+	Key           string            `json:"key,omitempty"`
+	UUID          string            `json:"uuid"`
+	Object        string            `json:"object"`
+	Identifier    string            `json:"identifier"`
+	Exportable    bool              `json:"exportable"`
+	GlobalLabels  map[string]string `json:"global_labels,omitempty"`
+	ExportOptions *Node             `json:"export_options,omitempty"`
+	Instances     []*Instance       `json:"instances"`
+	Metrics       []*Metric         `json:"metrics"`
+}
+
+type Instance struct {
+	Key        string            `json:"key"`
+	Exportable bool              `json:"exportable"`
+	Labels     map[string]string `json:"labels,omitempty"`
+}
+
+type Metric struct {
+	Key        string `json:"key"`
+	Name       string `json:"name"`
+	Exportable bool   `json:"exportable"`
+	// Values are the metric's values by instance key. Instances without a value are missing.
+	Values map[string]float64 `json:"values"`
+}
+
+// Node is a tree of template parameters
+type Node struct {
+	Name     string  `json:"name,omitempty"`
+	Content  string  `json:"content,omitempty"`
+	Children []*Node `json:"children,omitempty"`
+}
+
+func EncodeNode(n *node.Node) *Node {
+	if n == nil {
+		return nil
+	}
+	e := &Node{Name: n.GetNameS(), Content: n.GetContentS()}
+	for _, child := range n.GetChildren() {
+		e.Children = append(e.Children, EncodeNode(child))
+	}
+	return e
+}
+
+func (n *Node) Decode() *node.Node {
+	if n == nil {
+		return nil
+	}
+	d := node.NewS(n.Name)
+	d.SetContentS(n.Content)
+	for _, child := range n.Children {
+		d.AddChild(child.Decode())
+	}
+	return d
+}
+
+// EncodeMatrix encodes the matrix m, stored under key in the collector's data. Instances and metrics are sorted
+// by key, so the same matrix is always encoded the same way.
+func EncodeMatrix(key string, m *matrix.Matrix) *Matrix {
+	e := &Matrix{
+		Key:           key,
+		UUID:          m.UUID,
+		Object:        m.Object,
+		Identifier:    m.Identifier,
+		Exportable:    m.IsExportable(),
+		GlobalLabels:  m.GetGlobalLabels(),
+		ExportOptions: EncodeNode(m.GetExportOptions()),
+		Instances:     make([]*Instance, 0, len(m.GetInstances())),
+		Metrics:       make([]*Metric, 0, len(m.GetMetrics())),
+	}
+
+	instanceKeys := m.GetInstanceKeys()
+	slices.Sort(instanceKeys)
+	for _, k := range instanceKeys {
+		instance := m.GetInstance(k)
+		e.Instances = append(e.Instances, &Instance{Key: k, Exportable: instance.IsExportable(), Labels: instance.GetLabels()})
+	}
+
+	metricKeys := make([]string, 0, len(m.GetMetrics()))
+	for k := range m.GetMetrics() {
+		metricKeys = append(metricKeys, k)
+	}
+	slices.Sort(metricKeys)
+	for _, k := range metricKeys {
+		metric := m.GetMetric(k)
+		em := &Metric{Key: k, Name: metric.GetName(), Exportable: metric.IsExportable(), Values: make(map[string]float64)}
+		for _, ik := range instanceKeys {
+			// JSON can not encode NaN or infinity
+			if v, ok := metric.GetValueFloat64(m.GetInstance(ik)); ok && !math.IsNaN(v) && !math.IsInf(v, 0) {
+				em.Values[ik] = v
+			}
+		}
+		e.Metrics = append(e.Metrics, em)
+	}
+
+	return e
+}
+
+// Decode returns a new matrix with the contents of m
+func (m *Matrix) Decode() (*matrix.Matrix, error) {
+	d := matrix.New(m.UUID, m.Object, m.Identifier)
+	if err := m.ApplyTo(d); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+// ApplyTo updates d to the contents of m. Instances of d that are missing from m are not exported.
+// Labels of the instances are replaced. Values that are missing from m are removed.
+func (m *Matrix) ApplyTo(d *matrix.Matrix) error {
+	d.SetExportable(m.Exportable)
+	for k, v := range m.GlobalLabels {
+		d.SetGlobalLabel(k, v)
+	}
+	if m.ExportOptions != nil {
+		d.SetExportOptions(m.ExportOptions.Decode())
+	}
+
+	seen := make(map[string]struct{}, len(m.Instances))
+	for _, i := range m.Instances {
+		seen[i.Key] = struct{}{}
+		instance, _ := d.GetOrCreateInstance(i.Key)
+		instance.SetExportable(i.Exportable)
+		instance.ClearLabels()
+		for k, v := range i.Labels {
+			instance.SetLabel(k, v)
+		}
+	}
+	for k, instance := range d.GetInstances() {
+		if _, ok := seen[k]; !ok {
+			instance.SetExportable(false)
+		}
+	}
+
+	for _, em := range m.Metrics {
+		metric := d.GetMetric(em.Key)
+		if metric == nil {
+			var err error
+			if metric, err = d.NewMetricFloat64(em.Key, em.Name); err != nil {
+				return err
+			}
+		}
+		metric.SetExportable(em.Exportable)
+		for k, instance := range d.GetInstances() {
+			if v, ok := em.Values[k]; ok {
+				metric.SetValueFloat64(instance, v)
+			} else {
+				metric.SetValueNAN(instance)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,123 @@
+package external
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/netapp/harvest/v2/cmd/poller/options"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+)
+
+// Serve runs an external plugin. It reads requests from stdin and writes responses to stdout until stdin is closed.
+// newPlugin creates the plugin the same way a built-in plugin is created, for example
+//
+//	func main() {
+//		err := external.Serve(func(p *plugin.AbstractPlugin) plugin.Plugin {
+//			return &Enrich{AbstractPlugin: p}
+//		})
+//		if err != nil {
+//			log.Fatal(err)
+//		}
+//	}
+//
+// Stdout is used by the protocol, the plugin must log to stderr.
+func Serve(newPlugin func(*plugin.AbstractPlugin) plugin.Plugin) error {
+	return ServeIO(os.Stdin, os.Stdout, newPlugin)
+}
+
+// ServeIO is like Serve but reads requests from r and writes responses to w
+func ServeIO(r io.Reader, w io.Writer, newPlugin func(*plugin.AbstractPlugin) plugin.Plugin) error {
+	decoder := json.NewDecoder(r)
+	encoder := json.NewEncoder(w)
+	var p plugin.Plugin
+
+	for {
+		var request Request
+		if err := decoder.Decode(&request); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("read request: %w", err)
+		}
+
+		var (
+			response *Response
+			err      error
+		)
+		switch request.Type {
+		case RequestInit:
+			p, err = initPlugin(&request, newPlugin)
+			response = &Response{}
+		case RequestRun:
+			response, err = runPlugin(p, &request)
+		default:
+			err = fmt.Errorf("unknown request type %s", request.Type)
+		}
+		if err != nil {
+			response = &Response{Error: err.Error()}
+		}
+
+		if err := encoder.Encode(response); err != nil {
+			return fmt.Errorf("write response: %w", err)
+		}
+	}
+}
+
+func initPlugin(request *Request, newPlugin func(*plugin.AbstractPlugin) plugin.Plugin) (p plugin.Plugin, err error) {
+	defer recoverPanic(&err)
+
+	info := request.Plugin
+	if info == nil || info.Params == nil {
+		return nil, errors.New("init request without plugin")
+	}
+	opts := options.New()
+	opts.Poller = info.Poller
+	p = newPlugin(plugin.New(info.Parent, opts, info.Params.Decode(), nil, info.Object, nil))
+	if err := p.Init(request.Remote); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func runPlugin(p plugin.Plugin, request *Request) (response *Response, err error) {
+	if p == nil {
+		return nil, errors.New("plugin is not initialized")
+	}
+
+	defer recoverPanic(&err)
+
+	data := make(map[string]*matrix.Matrix, len(request.Data))
+	for _, m := range request.Data {
+		d, err := m.Decode()
+		if err != nil {
+			return nil, err
+		}
+		data[m.Key] = d
+	}
+
+	p.SetRemote(request.Remote)
+	created, _, err := p.Run(data)
+	if err != nil {
+		return nil, err
+	}
+
+	response = &Response{Data: make([]*Matrix, 0, len(request.Data)+len(created))}
+	for _, m := range request.Data {
+		response.Data = append(response.Data, EncodeMatrix(m.Key, data[m.Key]))
+	}
+	for _, m := range created {
+		response.Data = append(response.Data, EncodeMatrix("", m))
+	}
+	return response, nil
+}
+
+// recoverPanic returns a panic of the plugin as an error, so the panic fails the request and not the process
+func recoverPanic(err *error) {
+	if r := recover(); r != nil {
+		*err = fmt.Errorf("plugin panicked: %v", r)
+	}
+}
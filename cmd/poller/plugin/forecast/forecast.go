@@ -0,0 +1,353 @@
+/*
+ * Copyright NetApp Inc, 2026 All rights reserved
+ */
+
+// Package forecast implements the Forecast plugin. It keeps a rolling history of capacity metrics and
+// projects when they reach their limit.
+package forecast
+
+import (
+	"fmt"
+	"log/slog"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/collector"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/slogx"
+	"github.com/netapp/harvest/v2/pkg/statefile"
+)
+
+const (
+	defaultHorizon        = 30 * 24 * time.Hour
+	defaultHistory        = 28 * 24 * time.Hour
+	defaultSampleInterval = time.Hour
+	defaultPath           = "forecast"
+	// minSamples is the number of samples required before a forecast is exported
+	minSamples = 6
+	day        = 24 * time.Hour
+
+	daysUntilFullSuffix = "_days_until_full"
+	forecastSuffix      = "_forecast"
+	lowerSuffix         = "_forecast_lower"
+	upperSuffix         = "_forecast_upper"
+)
+
+type Forecast struct {
+	*plugin.AbstractPlugin
+	rules          []rule
+	horizon        time.Duration
+	history        time.Duration
+	sampleInterval time.Duration
+	season         time.Duration
+	path           string
+	series         map[string]map[string]*series // instance key -> metric -> samples
+	loaded         bool
+	now            func() time.Time
+}
+
+// rule forecasts the used metric. When capacity is set, days until full is computed too.
+type rule struct {
+	used     string
+	capacity string
+}
+
+// series is the rolling history of one metric of an instance
+type series struct {
+	Times  []int64   `json:"t"`
+	Values []float64 `json:"v"`
+}
+
+type historyFile struct {
+	Series map[string]map[string]*series `json:"series"`
+}
+
+func New(p *plugin.AbstractPlugin) *Forecast {
+	return &Forecast{AbstractPlugin: p, now: time.Now}
+}
+
+func (f *Forecast) Init(remote conf.Remote) error {
+	if err := f.AbstractPlugin.Init(remote); err != nil {
+		return err
+	}
+	if err := f.parseParams(); err != nil {
+		return err
+	}
+	f.series = make(map[string]map[string]*series)
+	f.SLogger.Debug("parsed forecast rules", slog.Int("numRules", len(f.rules)), slog.String("path", f.path))
+	return nil
+}
+
+func (f *Forecast) parseParams() error {
+	var err error
+
+	metrics := f.Params.GetChildS("metrics")
+	if metrics == nil || len(metrics.GetAllChildContentS()) == 0 {
+		return errs.New(errs.ErrMissingParam, "metrics")
+	}
+	for _, line := range metrics.GetAllChildContentS() {
+		used, capacity, _ := strings.Cut(line, "=>")
+		r := rule{used: strings.TrimSpace(used), capacity: strings.TrimSpace(capacity)}
+		if r.used == "" {
+			return errs.New(errs.ErrInvalidParam, "metrics: "+line)
+		}
+		f.rules = append(f.rules, r)
+	}
+
+	if f.horizon, err = parseDuration(f.Params.GetChildContentS("horizon"), defaultHorizon); err != nil {
+		return fmt.Errorf("invalid horizon: %w", err)
+	}
+	if f.history, err = parseDuration(f.Params.GetChildContentS("history"), defaultHistory); err != nil {
+		return fmt.Errorf("invalid history: %w", err)
+	}
+	if f.sampleInterval, err = parseDuration(f.Params.GetChildContentS("sample_interval"), defaultSampleInterval); err != nil {
+		return fmt.Errorf("invalid sample_interval: %w", err)
+	}
+	if f.season, err = parseDuration(f.Params.GetChildContentS("season"), 0); err != nil {
+		return fmt.Errorf("invalid season: %w", err)
+	}
+	if f.horizon <= 0 || f.history <= 0 || f.sampleInterval <= 0 {
+		return errs.New(errs.ErrInvalidParam, "horizon, history, and sample_interval must be positive")
+	}
+	if f.season != 0 && f.season < 2*f.sampleInterval {
+		return errs.New(errs.ErrInvalidParam, "season must be at least two sample intervals")
+	}
+
+	dir := f.Params.GetChildContentS("path")
+	if dir == "" {
+		dir = defaultPath
+	}
+	poller := ""
+	if f.Options != nil {
+		poller = f.Options.Poller
+	}
+	f.path = statefile.Path(dir, poller, f.Parent, f.Object)
+	return nil
+}
+
+// parseDuration parses a Go duration. A number followed by d is a number of days.
+func parseDuration(s string, defaultDuration time.Duration) (time.Duration, error) {
+	if s == "" {
+		return defaultDuration, nil
+	}
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.ParseFloat(days, 64)
+		if err != nil {
+			return 0, err
+		}
+		return time.Duration(n * float64(day)), nil
+	}
+	return time.ParseDuration(s)
+}
+
+func (f *Forecast) Run(dataMap map[string]*matrix.Matrix) ([]*matrix.Matrix, *collector.Metadata, error) {
+	data := dataMap[f.Object]
+	now := f.now()
+
+	if !f.loaded {
+		f.loaded = true
+		if err := f.load(); err != nil {
+			f.SLogger.Warn("Failed to load forecast history", slogx.Err(err), slog.String("path", f.path))
+		}
+	}
+
+	changed := f.prune(now)
+	metadata := &collector.Metadata{}
+
+	for _, r := range f.rules {
+		used := data.GetMetric(r.used)
+		if used == nil {
+			continue
+		}
+		var capacity *matrix.Metric
+		if r.capacity != "" {
+			capacity = data.GetMetric(r.capacity)
+		}
+		forecast := f.metric(data, r.used+forecastSuffix)
+		lower := f.metric(data, r.used+lowerSuffix)
+		upper := f.metric(data, r.used+upperSuffix)
+		var daysUntilFull *matrix.Metric
+		if capacity != nil {
+			daysUntilFull = f.metric(data, r.used+daysUntilFullSuffix)
+		}
+		if forecast == nil || lower == nil || upper == nil || (capacity != nil && daysUntilFull == nil) {
+			continue
+		}
+
+		for key, instance := range data.GetInstances() {
+			forecast.SetValueNAN(instance)
+			lower.SetValueNAN(instance)
+			upper.SetValueNAN(instance)
+			if daysUntilFull != nil {
+				daysUntilFull.SetValueNAN(instance)
+			}
+			if !instance.IsExportable() {
+				continue
+			}
+			value, ok := used.GetValueFloat64(instance)
+			if !ok {
+				continue
+			}
+			s, added := f.add(key, r.used, now, value)
+			changed = changed || added
+
+			tr, ok := s.fit(f.season, f.sampleInterval)
+			if !ok {
+				continue
+			}
+			metadata.PluginInstances.Add(1)
+
+			at := toDays(now.Add(f.horizon))
+			projected := tr.predict(at)
+			band := tr.band(at)
+			forecast.SetValueFloat64(instance, projected)
+			lower.SetValueFloat64(instance, projected-band)
+			upper.SetValueFloat64(instance, projected+band)
+
+			if daysUntilFull == nil {
+				continue
+			}
+			if c, ok := capacity.GetValueFloat64(instance); ok && c > 0 {
+				if days, ok := tr.daysUntilFull(value, c); ok {
+					daysUntilFull.SetValueFloat64(instance, days)
+				}
+			}
+		}
+	}
+
+	if changed {
+		if err := f.save(); err != nil {
+			f.SLogger.Warn("Failed to save forecast history", slogx.Err(err), slog.String("path", f.path))
+		}
+	}
+
+	return nil, metadata, nil
+}
+
+func (f *Forecast) metric(data *matrix.Matrix, name string) *matrix.Metric {
+	if m := data.GetMetric(name); m != nil {
+		return m
+	}
+	m, err := data.NewMetricFloat64(name)
+	if err != nil {
+		f.SLogger.Error("Failed to create metric", slogx.Err(err), slog.String("metric", name))
+		return nil
+	}
+	m.SetProperty("forecast")
+	return m
+}
+
+// add records the value unless the last sample of the series is more recent than the sample interval.
+// It returns the series and whether the value was added.
+func (f *Forecast) add(key string, metric string, now time.Time, value float64) (*series, bool) {
+	metrics, ok := f.series[key]
+	if !ok {
+		metrics = make(map[string]*series)
+		f.series[key] = metrics
+	}
+	s, ok := metrics[metric]
+	if !ok {
+		s = &series{}
+		metrics[metric] = s
+	}
+	ts := now.Unix()
+	if n := len(s.Times); n > 0 && time.Duration(ts-s.Times[n-1])*time.Second < f.sampleInterval {
+		return s, false
+	}
+	s.Times = append(s.Times, ts)
+	s.Values = append(s.Values, value)
+	return s, true
+}
+
+// prune removes samples older than the history and series without samples. It returns true when
+// something was removed.
+func (f *Forecast) prune(now time.Time) bool {
+	oldest := now.Add(-f.history).Unix()
+	pruned := false
+	for key, metrics := range f.series {
+		for name, s := range metrics {
+			i := 0
+			for i < len(s.Times) && s.Times[i] < oldest {
+				i++
+			}
+			if i > 0 {
+				s.Times = s.Times[i:]
+				s.Values = s.Values[i:]
+				pruned = true
+			}
+			if len(s.Times) == 0 {
+				delete(metrics, name)
+			}
+		}
+		if len(metrics) == 0 {
+			delete(f.series, key)
+		}
+	}
+	return pruned
+}
+
+func (s *series) fit(season time.Duration, sampleInterval time.Duration) (trend, bool) {
+	if len(s.Times) < minSamples {
+		return trend{}, false
+	}
+	t := make([]float64, len(s.Times))
+	for i, ts := range s.Times {
+		t[i] = toDays(time.Unix(ts, 0))
+	}
+	buckets := 0
+	if season > 0 {
+		buckets = int(season / sampleInterval)
+	}
+	tr, ok := fitTrend(t, s.Values, season.Hours()/24, buckets)
+	if !ok || math.IsNaN(tr.slope) {
+		return trend{}, false
+	}
+	return tr, true
+}
+
+func toDays(t time.Time) float64 {
+	return float64(t.Unix()) / day.Seconds()
+}
+
+func (f *Forecast) load() error {
+	var h historyFile
+	if err := statefile.Load(f.path, &h); err != nil {
+		return err
+	}
+	for key, metrics := range h.Series {
+		for name, s := range metrics {
+			if s == nil || len(s.Times) != len(s.Values) {
+				delete(metrics, name)
+			}
+		}
+		f.series[key] = metrics
+	}
+	return nil
+}
+
+// save writes the history to the history file
+func (f *Forecast) save() error {
+	return statefile.Save(f.path, historyFile{Series: f.series})
+}
+
+// NewMetrics returns the new metrics the receiver creates
+func (f *Forecast) NewMetrics() []plugin.DerivedMetric {
+	derivedMetrics := make([]plugin.DerivedMetric, 0, 4*len(f.rules))
+	for _, r := range f.rules {
+		for _, suffix := range []string{forecastSuffix, lowerSuffix, upperSuffix} {
+			derivedMetrics = append(derivedMetrics, plugin.DerivedMetric{Name: r.used + suffix, Source: r.used})
+		}
+		if r.capacity != "" {
+			derivedMetrics = append(derivedMetrics, plugin.DerivedMetric{
+				Name:   r.used + daysUntilFullSuffix,
+				Source: r.used + ", " + r.capacity,
+			})
+		}
+	}
+	return derivedMetrics
+}
@@ -0,0 +1,154 @@
+package forecast
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/netapp/harvest/v2/assert"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/tree"
+)
+
+// newForecast creates the plugin from the YAML of a template's Forecast section
+func newForecast(t *testing.T, template string) *Forecast {
+	t.Helper()
+
+	params, err := tree.LoadYaml([]byte(template))
+	assert.Nil(t, err)
+	params.SetNameS("Forecast")
+
+	f := New(plugin.New("Rest", nil, params, nil, "volume", nil))
+	assert.Nil(t, f.Init(conf.Remote{}))
+	return f
+}
+
+func TestLinearForecast(t *testing.T) {
+	f := newForecast(t, `
+metrics:
+  - size_used => size
+horizon: 10d
+sample_interval: 1d
+path: `+t.TempDir())
+
+	data := matrix.New("Rest", "volume", "volume")
+	used, _ := data.NewMetricFloat64("size_used")
+	size, _ := data.NewMetricFloat64("size")
+	vol1, _ := data.NewInstance("vol1")
+	size.SetValueFloat64(vol1, 1000)
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// grows 10 per day, from 100 to 190
+	for i := range 10 {
+		used.SetValueFloat64(vol1, 100+10*float64(i))
+		f.now = func() time.Time { return start.Add(time.Duration(i) * day) }
+		_, _, err := f.Run(map[string]*matrix.Matrix{"volume": data})
+		assert.Nil(t, err)
+
+		_, ok := data.GetMetric("size_used_forecast").GetValueFloat64(vol1)
+		assert.Equal(t, ok, i >= minSamples-1)
+	}
+
+	// the last sample is day 9, the horizon is day 19
+	forecast, _ := data.GetMetric("size_used_forecast").GetValueFloat64(vol1)
+	assert.True(t, math.Abs(forecast-290) < 1e-6)
+
+	// the samples are on a line, so the prediction interval is empty
+	lower, _ := data.GetMetric("size_used_forecast_lower").GetValueFloat64(vol1)
+	upper, _ := data.GetMetric("size_used_forecast_upper").GetValueFloat64(vol1)
+	assert.True(t, math.Abs(upper-lower) < 1e-6)
+
+	days, ok := data.GetMetric("size_used_days_until_full").GetValueFloat64(vol1)
+	assert.True(t, ok)
+	assert.True(t, math.Abs(days-81) < 1e-6)
+
+	// samples more recent than the sample interval are not stored
+	used.SetValueFloat64(vol1, 500)
+	f.now = func() time.Time { return start.Add(9*day + time.Hour) }
+	_, _, err := f.Run(map[string]*matrix.Matrix{"volume": data})
+	assert.Nil(t, err)
+	assert.Equal(t, len(f.series["vol1"]["size_used"].Times), 10)
+}
+
+func TestHistoryIsPersisted(t *testing.T) {
+	template := `
+metrics:
+  - size_used
+sample_interval: 1d
+history: 5d
+path: ` + t.TempDir()
+	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	f := newForecast(t, template)
+	data := matrix.New("Rest", "volume", "volume")
+	used, _ := data.NewMetricFloat64("size_used")
+	vol1, _ := data.NewInstance("vol1")
+	for i := range 8 {
+		used.SetValueFloat64(vol1, 100+float64(i))
+		f.now = func() time.Time { return start.Add(time.Duration(i) * day) }
+		_, _, err := f.Run(map[string]*matrix.Matrix{"volume": data})
+		assert.Nil(t, err)
+	}
+
+	restarted := newForecast(t, template)
+	data = matrix.New("Rest", "volume", "volume")
+	used, _ = data.NewMetricFloat64("size_used")
+	vol1, _ = data.NewInstance("vol1")
+	used.SetValueFloat64(vol1, 108)
+	restarted.now = func() time.Time { return start.Add(8 * day) }
+	_, _, err := restarted.Run(map[string]*matrix.Matrix{"volume": data})
+	assert.Nil(t, err)
+
+	// samples older than the history are dropped, days 3 to 8 remain
+	s := restarted.series["vol1"]["size_used"]
+	assert.Equal(t, len(s.Times), 6)
+	assert.Equal(t, s.Values[0], 103.0)
+	_, ok := data.GetMetric("size_used_forecast").GetValueFloat64(vol1)
+	assert.True(t, ok)
+}
+
+func TestSeasonalForecast(t *testing.T) {
+	// a weekly cycle on top of a trend of 1 per day
+	weekly := []float64{0, 5, 10, 15, 10, 5, 0}
+	var ts, vs []float64
+	for i := range 28 {
+		ts = append(ts, float64(i))
+		vs = append(vs, float64(i)+weekly[i%7])
+	}
+
+	tr, ok := fitTrend(ts, vs, 7, 7)
+	assert.True(t, ok)
+	assert.True(t, math.Abs(tr.slope-1) < 1e-6)
+	assert.True(t, math.Abs(tr.predict(30)-(30+weekly[30%7])) < 1e-6)
+	assert.True(t, tr.stdErr < 1e-6)
+
+	linear, ok := fitTrend(ts, vs, 0, 0)
+	assert.True(t, ok)
+	assert.True(t, linear.stdErr > 1)
+}
+
+func TestParams(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		ok       bool
+	}{
+		{name: "defaults", template: "metrics: [size_used]", ok: true},
+		{name: "days", template: "metrics: [size_used]\nhorizon: 90d\nseason: 7d", ok: true},
+		{name: "missing metrics", template: "horizon: 90d"},
+		{name: "invalid horizon", template: "metrics: [size_used]\nhorizon: soon"},
+		{name: "negative history", template: "metrics: [size_used]\nhistory: -1d"},
+		{name: "short season", template: "metrics: [size_used]\nseason: 1h"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			params, err := tree.LoadYaml([]byte(tt.template))
+			assert.Nil(t, err)
+			f := New(plugin.New("Rest", nil, params, nil, "volume", nil))
+			err = f.Init(conf.Remote{})
+			assert.Equal(t, err == nil, tt.ok)
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package forecast
+
+import (
+	"math"
+)
+
+// z value of the 95% confidence band
+const confidenceZ = 1.96
+
+// trend is a linear fit of a series with an optional seasonal profile. Times are in days.
+type trend struct {
+	intercept float64
+	slope     float64 // change per day
+	seasonal  []float64
+	season    float64 // length of the season in days
+	n         float64
+	meanT     float64
+	sxx       float64
+	stdErr    float64
+}
+
+// fitTrend fits a line to the samples with least squares. When the samples cover at least two seasons,
+// the mean residual of each of the season's buckets is removed before the line is fit again, so that
+// e.g. a weekly backup cycle does not skew the trend.
+// t is the absolute time of the samples in days.
+func fitTrend(t, v []float64, season float64, buckets int) (trend, bool) {
+	if len(t) < 2 || len(t) != len(v) {
+		return trend{}, false
+	}
+	tr, ok := fitLine(t, v)
+	if !ok {
+		return trend{}, false
+	}
+
+	if season > 0 && buckets > 1 && t[len(t)-1]-t[0] >= 2*season {
+		sums := make([]float64, buckets)
+		counts := make([]float64, buckets)
+		for i := range t {
+			b := bucket(t[i], season, buckets)
+			sums[b] += v[i] - tr.at(t[i])
+			counts[b]++
+		}
+		seasonal := make([]float64, buckets)
+		mean, filled := 0.0, 0.0
+		for b := range seasonal {
+			if counts[b] > 0 {
+				seasonal[b] = sums[b] / counts[b]
+				mean += seasonal[b]
+				filled++
+			}
+		}
+		// center the profile so it does not shift the trend
+		mean /= filled
+		for b := range seasonal {
+			if counts[b] > 0 {
+				seasonal[b] -= mean
+			}
+		}
+
+		deseasoned := make([]float64, len(v))
+		for i := range v {
+			deseasoned[i] = v[i] - seasonal[bucket(t[i], season, buckets)]
+		}
+		if tr, ok = fitLine(t, deseasoned); !ok {
+			return trend{}, false
+		}
+		tr.seasonal = seasonal
+		tr.season = season
+	}
+
+	if len(t) > 2 {
+		sse := 0.0
+		for i := range t {
+			d := v[i] - tr.predict(t[i])
+			sse += d * d
+		}
+		tr.stdErr = math.Sqrt(sse / float64(len(t)-2))
+	}
+	return tr, true
+}
+
+func fitLine(t, v []float64) (trend, bool) {
+	n := float64(len(t))
+	var sumT, sumV float64
+	for i := range t {
+		sumT += t[i]
+		sumV += v[i]
+	}
+	meanT, meanV := sumT/n, sumV/n
+	var sxx, sxy float64
+	for i := range t {
+		sxx += (t[i] - meanT) * (t[i] - meanT)
+		sxy += (t[i] - meanT) * (v[i] - meanV)
+	}
+	if sxx == 0 {
+		return trend{}, false
+	}
+	slope := sxy / sxx
+	return trend{
+		intercept: meanV - slope*meanT,
+		slope:     slope,
+		n:         n,
+		meanT:     meanT,
+		sxx:       sxx,
+	}, true
+}
+
+func bucket(t, season float64, buckets int) int {
+	phase := math.Mod(t, season) / season
+	return min(int(phase*float64(buckets)), buckets-1)
+}
+
+// at returns the value of the linear trend at t
+func (tr trend) at(t float64) float64 {
+	return tr.intercept + tr.slope*t
+}
+
+// predict returns the value of the trend including the seasonal profile at t
+func (tr trend) predict(t float64) float64 {
+	v := tr.at(t)
+	if len(tr.seasonal) > 0 {
+		v += tr.seasonal[bucket(t, tr.season, len(tr.seasonal))]
+	}
+	return v
+}
+
+// band returns the half width of the 95% prediction interval at t
+func (tr trend) band(t float64) float64 {
+	if tr.n == 0 || tr.sxx == 0 {
+		return 0
+	}
+	return confidenceZ * tr.stdErr * math.Sqrt(1+1/tr.n+(t-tr.meanT)*(t-tr.meanT)/tr.sxx)
+}
+
+// daysUntilFull returns the number of days until the trend reaches capacity starting from current.
+// ok is false when the trend does not grow.
+func (tr trend) daysUntilFull(current, capacity float64) (float64, bool) {
+	if current >= capacity {
+		return 0, true
+	}
+	if tr.slope <= 0 {
+		return 0, false
+	}
+	return (capacity - current) / tr.slope, true
+}
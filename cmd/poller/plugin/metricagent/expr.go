@@ -0,0 +1,683 @@
+/*
+ * Copyright NetApp Inc, 2026 All rights reserved
+ */
+
+package metricagent
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/netapp/harvest/v2/pkg/matrix"
+)
+
+// An expression is compiled into closures that are evaluated once per instance. Numbers are float64, a
+// missing value is NaN and propagates through the expression. Conditions are numbers too, zero is false.
+
+type exprType int
+
+const (
+	typeNumber exprType = iota
+	typeString
+)
+
+func (t exprType) String() string {
+	if t == typeString {
+		return "string"
+	}
+	return "number"
+}
+
+// metricRef is a metric used by an expression. An empty matrix is the matrix of the plugin's object.
+type metricRef struct {
+	matrix string
+	metric string
+}
+
+func (r metricRef) String() string {
+	if r.matrix == "" {
+		return r.metric
+	}
+	return r.matrix + ":" + r.metric
+}
+
+// exprEnv is the instance an expression is evaluated for
+type exprEnv struct {
+	agent    *MetricAgent
+	dataMap  map[string]*matrix.Matrix
+	data     *matrix.Matrix
+	key      string
+	instance *matrix.Instance
+	metrics  map[metricRef]*matrix.Metric
+	missing  map[metricRef]bool
+}
+
+func (e *exprEnv) value(ref metricRef) float64 {
+	m := e.data
+	instance := e.instance
+	if ref.matrix != "" {
+		if m = e.dataMap[ref.matrix]; m == nil {
+			e.missing[ref] = true
+			return math.NaN()
+		}
+		// instances of other matrices are matched by key
+		if instance = m.GetInstance(e.key); instance == nil {
+			return math.NaN()
+		}
+	}
+
+	metric, ok := e.metrics[ref]
+	if !ok {
+		metric = e.agent.getMetric(m, ref.metric)
+		e.metrics[ref] = metric
+	}
+	if metric == nil {
+		e.missing[ref] = true
+		return math.NaN()
+	}
+	if v, ok := metric.GetValueFloat64(instance); ok {
+		return v
+	}
+	return math.NaN()
+}
+
+type expr struct {
+	typ exprType
+	num func(*exprEnv) float64
+	str func(*exprEnv) string
+}
+
+// compileExpr parses and type-checks an expression that evaluates to a number and returns the metrics it uses
+func compileExpr(s string) (func(*exprEnv) float64, []metricRef, error) {
+	tokens, err := tokenize(s)
+	if err != nil {
+		return nil, nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	e, err := p.parseExpr()
+	if err != nil {
+		return nil, nil, err
+	}
+	if t := p.peek(); t.kind != tokenEOF {
+		return nil, nil, fmt.Errorf("unexpected %s at position %d", t, t.pos)
+	}
+	if e.typ != typeNumber {
+		return nil, nil, errors.New("expression must evaluate to a number, not a string")
+	}
+	return e.num, p.refs, nil
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenNumber
+	tokenString
+	tokenIdent
+	tokenOp
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	num  float64
+	pos  int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of expression"
+	case tokenString:
+		return strconv.Quote(t.text)
+	default:
+		return "'" + t.text + "'"
+	}
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isIdent(r rune) bool {
+	return r == '_' || r == '.' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+var operators = []string{"==", "!=", "<=", ">=", "&&", "||", "+", "-", "*", "/", "%", "<", ">", "!", "(", ")", ",", ":"}
+
+func tokenize(s string) ([]token, error) {
+	var tokens []token
+	runes := []rune(s)
+	i := 0
+	for i < len(runes) {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			start := i
+			for i < len(runes) && (unicode.IsDigit(runes[i]) || runes[i] == '.') {
+				i++
+			}
+			// exponent, as in 1e6 or 2.5E-3
+			if i < len(runes) && (runes[i] == 'e' || runes[i] == 'E') {
+				j := i + 1
+				if j < len(runes) && (runes[j] == '+' || runes[j] == '-') {
+					j++
+				}
+				if j < len(runes) && unicode.IsDigit(runes[j]) {
+					i = j
+					for i < len(runes) && unicode.IsDigit(runes[i]) {
+						i++
+					}
+				}
+			}
+			text := string(runes[start:i])
+			v, err := strconv.ParseFloat(text, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid number %s at position %d", text, start)
+			}
+			tokens = append(tokens, token{kind: tokenNumber, text: text, num: v, pos: start})
+		case isIdentStart(r):
+			start := i
+			for i < len(runes) && isIdent(runes[i]) {
+				i++
+			}
+			tokens = append(tokens, token{kind: tokenIdent, text: string(runes[start:i]), pos: start})
+		case r == '"' || r == '\'':
+			start := i
+			i++
+			var b strings.Builder
+			for ; i < len(runes) && runes[i] != r; i++ {
+				if runes[i] == '\\' && i+1 < len(runes) {
+					i++
+				}
+				b.WriteRune(runes[i])
+			}
+			if i == len(runes) {
+				return nil, fmt.Errorf("unterminated string at position %d", start)
+			}
+			i++
+			tokens = append(tokens, token{kind: tokenString, text: b.String(), pos: start})
+		default:
+			op := ""
+			for _, o := range operators {
+				if strings.HasPrefix(string(runes[i:min(i+2, len(runes))]), o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at position %d", r, i)
+			}
+			tokens = append(tokens, token{kind: tokenOp, text: op, pos: i})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokenEOF, pos: len(runes)}), nil
+}
+
+// exprParser is a recursive descent parser. From lowest to highest precedence:
+//
+//	||
+//	&&
+//	== != < <= > >=
+//	+ -
+//	* / %
+//	unary - !
+type exprParser struct {
+	tokens []token
+	pos    int
+	refs   []metricRef
+}
+
+func (p *exprParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *exprParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *exprParser) isOp(ops ...string) (string, bool) {
+	t := p.peek()
+	if t.kind != tokenOp {
+		return "", false
+	}
+	for _, op := range ops {
+		if t.text == op {
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) expect(op string) error {
+	if t := p.next(); t.kind != tokenOp || t.text != op {
+		return fmt.Errorf("expected '%s' at position %d, got %s", op, t.pos, t)
+	}
+	return nil
+}
+
+func (p *exprParser) parseExpr() (*expr, error) {
+	return p.parseOr()
+}
+
+func (p *exprParser) parseOr() (*expr, error) {
+	return p.parseLogical("||", p.parseAnd)
+}
+
+func (p *exprParser) parseAnd() (*expr, error) {
+	return p.parseLogical("&&", p.parseComparison)
+}
+
+func (p *exprParser) parseLogical(op string, operand func() (*expr, error)) (*expr, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		if _, ok := p.isOp(op); !ok {
+			return left, nil
+		}
+		t := p.next()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkNumbers(t, left, right); err != nil {
+			return nil, err
+		}
+		l, r := left.num, right.num
+		if op == "&&" {
+			left = &expr{typ: typeNumber, num: func(e *exprEnv) float64 {
+				a := l(e)
+				if math.IsNaN(a) || a == 0 {
+					return a
+				}
+				return condition(r(e))
+			}}
+		} else {
+			left = &expr{typ: typeNumber, num: func(e *exprEnv) float64 {
+				a := l(e)
+				if math.IsNaN(a) {
+					return a
+				}
+				if a != 0 {
+					return 1
+				}
+				return condition(r(e))
+			}}
+		}
+	}
+}
+
+func (p *exprParser) parseComparison() (*expr, error) {
+	left, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	op, ok := p.isOp("==", "!=", "<", "<=", ">", ">=")
+	if !ok {
+		return left, nil
+	}
+	t := p.next()
+	right, err := p.parseAdditive()
+	if err != nil {
+		return nil, err
+	}
+	if left.typ != right.typ {
+		return nil, fmt.Errorf("cannot compare %s with %s at position %d", left.typ, right.typ, t.pos)
+	}
+
+	if left.typ == typeString {
+		if op != "==" && op != "!=" {
+			return nil, fmt.Errorf("operator %s at position %d is not defined for strings", t, t.pos)
+		}
+		l, r := left.str, right.str
+		equal := op == "=="
+		return &expr{typ: typeNumber, num: func(e *exprEnv) float64 {
+			return boolean((l(e) == r(e)) == equal)
+		}}, nil
+	}
+
+	var cmp func(a, b float64) bool
+	switch op {
+	case "==":
+		cmp = func(a, b float64) bool { return a == b }
+	case "!=":
+		cmp = func(a, b float64) bool { return a != b }
+	case "<":
+		cmp = func(a, b float64) bool { return a < b }
+	case "<=":
+		cmp = func(a, b float64) bool { return a <= b }
+	case ">":
+		cmp = func(a, b float64) bool { return a > b }
+	default:
+		cmp = func(a, b float64) bool { return a >= b }
+	}
+	l, r := left.num, right.num
+	return &expr{typ: typeNumber, num: func(e *exprEnv) float64 {
+		a, b := l(e), r(e)
+		if math.IsNaN(a) || math.IsNaN(b) {
+			return math.NaN()
+		}
+		return boolean(cmp(a, b))
+	}}, nil
+}
+
+func (p *exprParser) parseAdditive() (*expr, error) {
+	return p.parseArithmetic([]string{"+", "-"}, p.parseMultiplicative)
+}
+
+func (p *exprParser) parseMultiplicative() (*expr, error) {
+	return p.parseArithmetic([]string{"*", "/", "%"}, p.parseUnary)
+}
+
+func (p *exprParser) parseArithmetic(ops []string, operand func() (*expr, error)) (*expr, error) {
+	left, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.isOp(ops...)
+		if !ok {
+			return left, nil
+		}
+		t := p.next()
+		right, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		if err := checkNumbers(t, left, right); err != nil {
+			return nil, err
+		}
+		l, r := left.num, right.num
+		var f func(*exprEnv) float64
+		switch op {
+		case "+":
+			f = func(e *exprEnv) float64 { return l(e) + r(e) }
+		case "-":
+			f = func(e *exprEnv) float64 { return l(e) - r(e) }
+		case "*":
+			f = func(e *exprEnv) float64 { return l(e) * r(e) }
+		case "/":
+			f = func(e *exprEnv) float64 {
+				a, b := l(e), r(e)
+				if b == 0 {
+					// the result of a division by zero is missing
+					return math.NaN()
+				}
+				return a / b
+			}
+		default:
+			f = func(e *exprEnv) float64 {
+				a, b := l(e), r(e)
+				if b == 0 {
+					return math.NaN()
+				}
+				return math.Mod(a, b)
+			}
+		}
+		left = &expr{typ: typeNumber, num: f}
+	}
+}
+
+func (p *exprParser) parseUnary() (*expr, error) {
+	op, ok := p.isOp("-", "!")
+	if !ok {
+		return p.parsePrimary()
+	}
+	t := p.next()
+	operand, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	if err := checkNumbers(t, operand); err != nil {
+		return nil, err
+	}
+	f := operand.num
+	if op == "-" {
+		return &expr{typ: typeNumber, num: func(e *exprEnv) float64 { return -f(e) }}, nil
+	}
+	return &expr{typ: typeNumber, num: func(e *exprEnv) float64 {
+		v := f(e)
+		if math.IsNaN(v) {
+			return v
+		}
+		return boolean(v == 0)
+	}}, nil
+}
+
+func (p *exprParser) parsePrimary() (*expr, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenNumber:
+		v := t.num
+		return &expr{typ: typeNumber, num: func(*exprEnv) float64 { return v }}, nil
+	case tokenString:
+		s := t.text
+		return &expr{typ: typeString, str: func(*exprEnv) string { return s }}, nil
+	case tokenIdent:
+		if _, ok := p.isOp("("); ok {
+			p.next()
+			return p.parseCall(t)
+		}
+		ref := metricRef{metric: t.text}
+		if _, ok := p.isOp(":"); ok {
+			p.next()
+			m := p.next()
+			if m.kind != tokenIdent {
+				return nil, fmt.Errorf("expected metric after '%s:' at position %d, got %s", t.text, m.pos, m)
+			}
+			ref = metricRef{matrix: t.text, metric: m.text}
+		}
+		p.refs = append(p.refs, ref)
+		return &expr{typ: typeNumber, num: func(e *exprEnv) float64 { return e.value(ref) }}, nil
+	case tokenOp:
+		if t.text == "(" {
+			e, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect(")"); err != nil {
+				return nil, err
+			}
+			return e, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected %s at position %d", t, t.pos)
+}
+
+// parseCall parses the arguments of the function call and checks their number and types
+func (p *exprParser) parseCall(name token) (*expr, error) {
+	var args []*expr
+	// literals has the string of each argument that is a string literal, label names and regular expressions
+	// must be literals so they are checked at init
+	var literals []*string
+	if _, ok := p.isOp(")"); !ok {
+		for {
+			start := p.pos
+			arg, err := p.parseExpr()
+			if err != nil {
+				return nil, err
+			}
+			args = append(args, arg)
+			var literal *string
+			if t := p.tokens[start]; p.pos == start+1 && t.kind == tokenString {
+				literal = &t.text
+			}
+			literals = append(literals, literal)
+			if _, ok := p.isOp(","); !ok {
+				break
+			}
+			p.next()
+		}
+	}
+	if err := p.expect(")"); err != nil {
+		return nil, err
+	}
+
+	arity := func(lo, hi int) error {
+		if len(args) < lo || (hi >= 0 && len(args) > hi) {
+			want := strconv.Itoa(lo)
+			switch {
+			case hi < 0:
+				want = "at least " + want
+			case hi != lo:
+				want += " to " + strconv.Itoa(hi)
+			}
+			return fmt.Errorf("%s() at position %d takes %s arguments, got %d", name.text, name.pos, want, len(args))
+		}
+		return nil
+	}
+
+	switch name.text {
+	case "min", "max":
+		if err := arity(1, -1); err != nil {
+			return nil, err
+		}
+		if err := checkNumbers(name, args...); err != nil {
+			return nil, err
+		}
+		pick := math.Min
+		if name.text == "max" {
+			pick = math.Max
+		}
+		return &expr{typ: typeNumber, num: func(e *exprEnv) float64 {
+			v := args[0].num(e)
+			for _, a := range args[1:] {
+				v = pick(v, a.num(e))
+			}
+			return v
+		}}, nil
+
+	case "abs":
+		if err := arity(1, 1); err != nil {
+			return nil, err
+		}
+		if err := checkNumbers(name, args...); err != nil {
+			return nil, err
+		}
+		return &expr{typ: typeNumber, num: func(e *exprEnv) float64 { return math.Abs(args[0].num(e)) }}, nil
+
+	case "clamp":
+		if err := arity(3, 3); err != nil {
+			return nil, err
+		}
+		if err := checkNumbers(name, args...); err != nil {
+			return nil, err
+		}
+		return &expr{typ: typeNumber, num: func(e *exprEnv) float64 {
+			return math.Min(math.Max(args[0].num(e), args[1].num(e)), args[2].num(e))
+		}}, nil
+
+	case "default":
+		if err := arity(2, 2); err != nil {
+			return nil, err
+		}
+		if err := checkNumbers(name, args...); err != nil {
+			return nil, err
+		}
+		return &expr{typ: typeNumber, num: func(e *exprEnv) float64 {
+			if v := args[0].num(e); !math.IsNaN(v) {
+				return v
+			}
+			return args[1].num(e)
+		}}, nil
+
+	case "if":
+		if err := arity(3, 3); err != nil {
+			return nil, err
+		}
+		if err := checkNumbers(name, args[0]); err != nil {
+			return nil, err
+		}
+		cond, then, otherwise := args[0], args[1], args[2]
+		if then.typ != otherwise.typ {
+			return nil, fmt.Errorf("if() at position %d returns %s or %s, both must have the same type", name.pos, then.typ, otherwise.typ)
+		}
+		// only the selected branch is evaluated, so if(b == 0, 0, a / b) is not missing when b is zero
+		if then.typ == typeString {
+			return &expr{typ: typeString, str: func(e *exprEnv) string {
+				if truthy(cond.num(e)) {
+					return then.str(e)
+				}
+				return otherwise.str(e)
+			}}, nil
+		}
+		return &expr{typ: typeNumber, num: func(e *exprEnv) float64 {
+			c := cond.num(e)
+			if math.IsNaN(c) {
+				return c
+			}
+			if c != 0 {
+				return then.num(e)
+			}
+			return otherwise.num(e)
+		}}, nil
+
+	case "label":
+		if err := arity(1, 1); err != nil {
+			return nil, err
+		}
+		if literals[0] == nil {
+			return nil, fmt.Errorf("label() at position %d takes the name of the label as a string", name.pos)
+		}
+		label := *literals[0]
+		return &expr{typ: typeString, str: func(e *exprEnv) string { return e.instance.GetLabel(label) }}, nil
+
+	case "matches":
+		if err := arity(2, 2); err != nil {
+			return nil, err
+		}
+		if args[0].typ != typeString || literals[1] == nil {
+			return nil, fmt.Errorf("matches() at position %d takes a string and a regular expression", name.pos)
+		}
+		re, err := regexp.Compile(*literals[1])
+		if err != nil {
+			return nil, fmt.Errorf("matches() at position %d: %w", name.pos, err)
+		}
+		s := args[0].str
+		return &expr{typ: typeNumber, num: func(e *exprEnv) float64 { return boolean(re.MatchString(s(e))) }}, nil
+	}
+
+	return nil, fmt.Errorf("unknown function %s() at position %d", name.text, name.pos)
+}
+
+func checkNumbers(op token, operands ...*expr) error {
+	for _, o := range operands {
+		if o.typ != typeNumber {
+			return fmt.Errorf("%s at position %d expects numbers, got a %s", op, op.pos, o.typ)
+		}
+	}
+	return nil
+}
+
+func truthy(v float64) bool {
+	return !math.IsNaN(v) && v != 0
+}
+
+// condition returns 1 or 0 for a number used as a condition, a missing number stays missing
+func condition(v float64) float64 {
+	if math.IsNaN(v) {
+		return v
+	}
+	return boolean(v != 0)
+}
+
+func boolean(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
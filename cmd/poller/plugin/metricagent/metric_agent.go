@@ -14,6 +14,8 @@ import (
 	"github.com/netapp/harvest/v2/pkg/slogx"
 	"log/slog"
 	"maps"
+	"math"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -39,7 +41,10 @@ func (a *MetricAgent) Init(remote conf.Remote) error {
 		return err
 	}
 
-	if count = a.parseRules(); count == 0 {
+	if count, err = a.parseRules(); err != nil {
+		return err
+	}
+	if count == 0 {
 		err = errs.New(errs.ErrMissingParam, "valid rules")
 	} else {
 		a.SLogger.Debug("parsed rules", slog.Int("count", count), slog.Int("actions", len(a.actions)))
@@ -78,6 +83,10 @@ func (a *MetricAgent) computeMetrics(dataMap map[string]*matrix.Matrix) error {
 
 	// map values for compute_metric mapping rules
 	for _, r := range a.computeMetricRules {
+		if r.eval != nil {
+			a.computeExpression(dataMap, data, r)
+			continue
+		}
 		m = make([]*matrix.Matrix, len(r.metricNames))
 		sgLabelMap = make(map[string]string)
 		for i := range r.metricNames {
@@ -205,6 +214,55 @@ func (a *MetricAgent) computeMetrics(dataMap map[string]*matrix.Matrix) error {
 	return nil
 }
 
+// computeExpression evaluates the expression of the rule for each instance of data. The metric is not set
+// for instances where the result is missing, for example when a metric it uses has no value.
+func (a *MetricAgent) computeExpression(dataMap map[string]*matrix.Matrix, data *matrix.Matrix, r computeMetricRule) {
+	if data == nil {
+		a.SLogger.Error("matrix not found", slog.String("object", a.Object), slog.String("metric", r.metric))
+		return
+	}
+
+	metric := a.getMetric(data, r.metric)
+	if metric == nil {
+		var err error
+		if metric, err = data.NewMetricFloat64(r.metric); err != nil {
+			a.SLogger.Error("Failed to create metric", slogx.Err(err), slog.String("metric", r.metric))
+			return
+		}
+		metric.SetProperty("compute_metric mapping")
+	}
+
+	env := &exprEnv{
+		agent:   a,
+		dataMap: dataMap,
+		data:    data,
+		metrics: make(map[metricRef]*matrix.Metric),
+		missing: make(map[metricRef]bool),
+	}
+	for key, instance := range data.GetInstances() {
+		env.key = key
+		env.instance = instance
+		if v := r.eval(env); math.IsNaN(v) || math.IsInf(v, 0) {
+			metric.SetValueNAN(instance)
+		} else {
+			metric.SetValueFloat64(instance, v)
+		}
+	}
+
+	if len(env.missing) > 0 {
+		missing := make([]string, 0, len(env.missing))
+		for ref := range env.missing {
+			missing = append(missing, ref.String())
+		}
+		slices.Sort(missing)
+		a.SLogger.Warn(
+			"computeMetrics: metric not found",
+			slog.String("metric", r.metric),
+			slog.Any("missing", missing),
+		)
+	}
+}
+
 func (a *MetricAgent) getMetric(m *matrix.Matrix, name string) *matrix.Metric {
 	metric := m.DisplayMetric(name)
 	if metric != nil {
@@ -217,9 +275,18 @@ func (a *MetricAgent) getMetric(m *matrix.Matrix, name string) *matrix.Metric {
 func (a *MetricAgent) NewMetrics() []plugin.DerivedMetric {
 	derivedMetrics := make([]plugin.DerivedMetric, 0, len(a.computeMetricRules))
 	for _, rule := range a.computeMetricRules {
+		source := rule.metricNames
+		if rule.eval != nil {
+			source = make([]string, 0, len(rule.refs))
+			for _, ref := range rule.refs {
+				if !slices.Contains(source, ref.String()) {
+					source = append(source, ref.String())
+				}
+			}
+		}
 		derivedMetrics = append(derivedMetrics, plugin.DerivedMetric{
 			Name:   rule.metric,
-			Source: strings.Join(rule.metricNames, ", "),
+			Source: strings.Join(source, ", "),
 		})
 	}
 	return derivedMetrics
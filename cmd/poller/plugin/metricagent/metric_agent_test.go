@@ -336,3 +336,103 @@ func TestComputeMetricsRuleWithMultiMatrix(t *testing.T) {
 	_, ok = metricStorage.GetValueFloat64(instanceG)
 	assert.False(t, ok)
 }
+
+func TestComputeMetricsRuleWithExpression(t *testing.T) {
+	params := node.NewS("MetricAgent")
+	rules := params.NewChildS("compute_metric", "")
+	rules.NewChildS("", "used_percent = 100 * (size - available) / size")
+	rules.NewChildS("", "headroom = clamp(max(available, 0) / 1e3, 0, 2) + abs(-1) - min(1, 2)")
+	rules.NewChildS("", `weight = if(label("svm") == "svm1" && !matches(label("volume"), "^tmp"), 2, 0.5) * size`)
+	rules.NewChildS("", "safe_ratio = if(available == 0, 0, size / available)")
+	rules.NewChildS("", "ratio = size / available")
+	rules.NewChildS("", "with_default = default(optional, 7) % 4")
+	rules.NewChildS("", "snapshot_share = snapshot:used / size")
+	rules.NewChildS("", "double_percent = used_percent * 2")
+	p := &MetricAgent{AbstractPlugin: plugin.New("Test", nil, params, nil, "volume", nil)}
+	assert.Nil(t, p.Init(conf.Remote{}))
+
+	data := matrix.New("Test", "volume", "volume")
+	snapshot := matrix.New("Test", "snapshot", "snapshot")
+	size, _ := data.NewMetricFloat64("size")
+	available, _ := data.NewMetricFloat64("available")
+	_, _ = data.NewMetricFloat64("optional")
+	used, _ := snapshot.NewMetricFloat64("used")
+	for _, v := range [][]string{{"vol1", "svm1"}, {"tmp1", "svm1"}, {"vol2", "svm2"}} {
+		instance, _ := data.NewInstance(v[0])
+		instance.SetLabel("volume", v[0])
+		instance.SetLabel("svm", v[1])
+		size.SetValueFloat64(instance, 1000)
+		available.SetValueFloat64(instance, 250)
+	}
+	available.SetValueFloat64(data.GetInstance("vol2"), 0)
+	s, _ := snapshot.NewInstance("vol1")
+	used.SetValueFloat64(s, 100)
+
+	_, _, err := p.Run(map[string]*matrix.Matrix{"volume": data, "snapshot": snapshot})
+	assert.Nil(t, err)
+
+	value := func(metric, instance string) (float64, bool) {
+		return data.GetMetric(metric).GetValueFloat64(data.GetInstance(instance))
+	}
+	tests := []struct {
+		metric   string
+		instance string
+		want     float64
+		ok       bool
+	}{
+		{metric: "used_percent", instance: "vol1", want: 75, ok: true},
+		{metric: "used_percent", instance: "vol2", want: 100, ok: true},
+		{metric: "headroom", instance: "vol1", want: 0.25, ok: true},
+		{metric: "weight", instance: "vol1", want: 2000, ok: true},
+		{metric: "weight", instance: "tmp1", want: 500, ok: true},
+		{metric: "weight", instance: "vol2", want: 500, ok: true},
+		{metric: "safe_ratio", instance: "vol2", want: 0, ok: true},
+		// a division by zero is missing
+		{metric: "ratio", instance: "vol1", want: 4, ok: true},
+		{metric: "ratio", instance: "vol2", ok: false},
+		{metric: "with_default", instance: "vol1", want: 3, ok: true},
+		// metrics of other matrices are matched by instance key
+		{metric: "snapshot_share", instance: "vol1", want: 0.1, ok: true},
+		{metric: "snapshot_share", instance: "vol2", ok: false},
+		// rules can use metrics computed by earlier rules
+		{metric: "double_percent", instance: "vol1", want: 150, ok: true},
+	}
+	for _, tt := range tests {
+		got, ok := value(tt.metric, tt.instance)
+		assert.Equal(t, ok, tt.ok)
+		if tt.ok {
+			assert.Equal(t, got, tt.want)
+		}
+	}
+
+	derived := p.NewMetrics()
+	assert.Equal(t, derived[0].Source, "size, available")
+	assert.Equal(t, derived[6].Source, "snapshot:used, size")
+}
+
+func TestComputeMetricsRuleWithInvalidExpression(t *testing.T) {
+	rules := []string{
+		"a = b +",
+		"a = (b + c",
+		"a = b c",
+		"a = b = c",
+		`a = label("svm")`,
+		`a = label("svm") + 1`,
+		`a = label("svm") < "b"`,
+		"a = label(svm) == 1",
+		"a = clamp(b, 1)",
+		"a = unknown(b)",
+		`a = if(b, 1, "x")`,
+		`a = matches(label("svm"), "(")`,
+		`a = "unterminated`,
+		"a = b # c",
+	}
+	for _, rule := range rules {
+		t.Run(rule, func(t *testing.T) {
+			params := node.NewS("MetricAgent")
+			params.NewChildS("compute_metric", "").NewChildS("", rule)
+			p := &MetricAgent{AbstractPlugin: plugin.New("Test", nil, params, nil, "volume", nil)}
+			assert.NotNil(t, p.Init(conf.Remote{}))
+		})
+	}
+}
@@ -5,13 +5,16 @@
 package metricagent
 
 import (
+	"fmt"
+	"github.com/netapp/harvest/v2/pkg/errs"
 	"github.com/netapp/harvest/v2/pkg/matrix"
 	"log/slog"
+	"regexp"
 	"strings"
 )
 
 // parse rules from plugin parameters and return number of rules parsed
-func (a *MetricAgent) parseRules() int {
+func (a *MetricAgent) parseRules() (int, error) {
 
 	a.computeMetricRules = make([]computeMetricRule, 0)
 
@@ -25,7 +28,9 @@ func (a *MetricAgent) parseRules() int {
 
 			switch name {
 			case "compute_metric":
-				a.parseComputeMetricRule(rule)
+				if err := a.parseComputeMetricRule(rule); err != nil {
+					return 0, err
+				}
 			default:
 				a.SLogger.Warn(
 					"Unknown rule name",
@@ -56,16 +61,40 @@ func (a *MetricAgent) parseRules() int {
 		}
 	}
 
-	return count
+	return count, nil
 }
 
 type computeMetricRule struct {
 	metric      string
 	operation   string
 	metricNames []string
+	// expression is set for rules with the METRIC = EXPRESSION syntax
+	expression string
+	eval       func(*exprEnv) float64
+	refs       []metricRef
 }
 
-func (a *MetricAgent) parseComputeMetricRule(rule string) {
+// expressionRule matches the target metric of a rule with the METRIC = EXPRESSION syntax
+var expressionRule = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_.]*)\s*=([^=].*)$`)
+
+func (a *MetricAgent) parseComputeMetricRule(rule string) error {
+	if match := expressionRule.FindStringSubmatch(rule); match != nil {
+		r := computeMetricRule{metric: match[1], expression: strings.TrimSpace(match[2])}
+		eval, refs, err := compileExpr(r.expression)
+		if err != nil {
+			return errs.New(errs.ErrInvalidParam, fmt.Sprintf("compute_metric rule (%s): %v", rule, err))
+		}
+		r.eval = eval
+		r.refs = refs
+		a.computeMetricRules = append(a.computeMetricRules, r)
+		a.SLogger.Debug(
+			"(compute_metric) parsed rule",
+			slog.String("metric", r.metric),
+			slog.String("expression", r.expression),
+		)
+		return nil
+	}
+
 	if fields := strings.Fields(rule); len(fields) >= 4 {
 		r := computeMetricRule{metric: fields[0], operation: fields[1], metricNames: make([]string, 0)}
 
@@ -79,7 +108,8 @@ func (a *MetricAgent) parseComputeMetricRule(rule string) {
 			slog.String("metric", r.metric),
 			slog.String("operation", r.operation),
 		)
-		return
+		return nil
 	}
 	a.SLogger.Warn("(compute_metric) rule has invalid format", slog.String("rule", rule))
+	return nil
 }
@@ -61,7 +61,10 @@ import (
 	"github.com/netapp/harvest/v2/cmd/collectors/cmperf"
 	_ "github.com/netapp/harvest/v2/cmd/collectors/ems"
 	_ "github.com/netapp/harvest/v2/cmd/collectors/eseries"
+	_ "github.com/netapp/harvest/v2/cmd/collectors/eseriesevents"
 	_ "github.com/netapp/harvest/v2/cmd/collectors/eseriesperf"
+	_ "github.com/netapp/harvest/v2/cmd/collectors/exec"
+	_ "github.com/netapp/harvest/v2/cmd/collectors/httpjson"
 	_ "github.com/netapp/harvest/v2/cmd/collectors/keyperf"
 	_ "github.com/netapp/harvest/v2/cmd/collectors/restperf"
 	_ "github.com/netapp/harvest/v2/cmd/collectors/simple"
@@ -84,6 +87,7 @@ import (
 	"github.com/netapp/harvest/v2/pkg/errs"
 	"github.com/netapp/harvest/v2/pkg/logging"
 	"github.com/netapp/harvest/v2/pkg/matrix"
+	"github.com/netapp/harvest/v2/pkg/ratelimit"
 	"github.com/netapp/harvest/v2/pkg/requests"
 	"github.com/netapp/harvest/v2/pkg/slogx"
 	harvestTemplate "github.com/netapp/harvest/v2/pkg/template"
@@ -592,6 +596,8 @@ func (p *Poller) Run() {
 			}
 
 			p.addMemoryMetadata()
+			p.addRestCacheMetadata()
+			p.addRateLimitMetadata()
 
 			// add number of goroutines to metadata
 			p.metadataTarget.MustSetValueInt64("goroutines", p.metadataHostInstance, int64(runtime.NumGoroutine()))
@@ -1341,6 +1347,11 @@ func (p *Poller) loadMetadata() {
 	newMemoryMetric(p.status, "memory", "vms")
 	newMemoryMetric(p.status, "memory", "swap")
 	_, _ = p.status.NewMetricFloat64("concurrent_collectors")
+	_, _ = p.status.NewMetricUint64("rest_cache_hits")
+	_, _ = p.status.NewMetricUint64("rest_cache_misses")
+	_, _ = p.status.NewMetricFloat64("rate_limit_rate")
+	_, _ = p.status.NewMetricUint64("rate_limit_throttled")
+	_, _ = p.status.NewMetricUint64("rate_limit_rejected")
 
 	instance, _ := p.metadataTarget.NewInstance("host")
 	pInstance, _ := p.status.NewInstance("host")
@@ -1803,6 +1814,29 @@ func (p *Poller) addMemoryMetadata() {
 	p.maxRssBytes = max(p.maxRssBytes, memMetrics.RSSBytes)
 }
 
+// addRestCacheMetadata adds the hits and misses of the REST response cache shared by the poller's collectors and
+// plugins. Nothing is added when the poller has not sent REST requests.
+func (p *Poller) addRestCacheMetadata() {
+	hits, misses := rest.CacheFor(p.params).Stats()
+	if hits+misses == 0 {
+		return
+	}
+	p.status.MustSetValueUint64("rest_cache_hits", p.statusHostInstance, hits)
+	p.status.MustSetValueUint64("rest_cache_misses", p.statusHostInstance, misses)
+}
+
+// addRateLimitMetadata adds the allowed request rate and the number of throttled and rejected requests when the
+// poller has a rate_limit
+func (p *Poller) addRateLimitMetadata() {
+	limiter := ratelimit.For(p.params)
+	if limiter == nil {
+		return
+	}
+	p.status.MustSetValueFloat64("rate_limit_rate", p.statusHostInstance, limiter.Rate())
+	p.status.MustSetValueUint64("rate_limit_throttled", p.statusHostInstance, limiter.Throttled())
+	p.status.MustSetValueUint64("rate_limit_rejected", p.statusHostInstance, limiter.Rejected())
+}
+
 func (p *Poller) logPollerMetadata() (map[string]*matrix.Matrix, error) {
 	err := p.sendHarvestVersion()
 	if err != nil {
@@ -991,3 +991,30 @@ func TestPoller_getCmManifestName(t *testing.T) {
 		})
 	}
 }
+
+// stubCollector is a collector that is never initialized, only its name is used
+type stubCollector struct {
+	*collectorPkg.AbstractCollector
+}
+
+func (stubCollector) Init(*collectorPkg.AbstractCollector) error { return nil }
+
+func newStubCollector(name string) collectorPkg.Collector {
+	return stubCollector{collectorPkg.New(name, "test", options.New(), nil, nil, conf.Remote{})}
+}
+
+func TestPingSkipsLocalCollectors(t *testing.T) {
+	// nothing listens on port 1, so a ping of the target fails
+	p := &Poller{target: "127.0.0.1:1", params: &conf.Poller{}}
+
+	for _, name := range []string{"Exec", "HTTPJSON", "HttpJSON"} {
+		p.collectors = []collectorPkg.Collector{newStubCollector(name)}
+		ms, ok := p.ping()
+		assert.True(t, ok)
+		assert.Equal(t, ms, float32(0))
+	}
+
+	p.collectors = append(p.collectors, newStubCollector("Rest"))
+	_, ok := p.ping()
+	assert.False(t, ok)
+}
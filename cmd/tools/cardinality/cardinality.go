@@ -0,0 +1,267 @@
+// Package cardinality implements the harvest cardinality command. It prints the number of instances and series
+// each object of a running poller exports, read from the metadata_cardinality metrics of the poller's Prometheus
+// exporter.
+package cardinality
+
+import (
+	"bufio"
+	"cmp"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/ps"
+	tw "github.com/netapp/harvest/v2/third_party/olekukonko/tablewriter"
+	"github.com/spf13/cobra"
+)
+
+const metricPrefix = "metadata_cardinality_"
+
+type options struct {
+	addr    string
+	port    int
+	timeout time.Duration
+}
+
+var opts = &options{}
+
+var Cmd = &cobra.Command{
+	Use:   "cardinality [poller...]",
+	Short: "Print the instances and series each object of a running poller exports",
+	Long: `Print the instances and series each object of a running poller exports.
+Objects are sorted by series, the largest first. Instances dropped by the poller's cardinality limits are
+listed with the label that dropped them.
+
+The numbers are read from the poller's Prometheus exporter.`,
+	Run: doCardinality,
+}
+
+func init() {
+	Cmd.Flags().StringVar(&opts.addr, "addr", "localhost", "Address of the poller's Prometheus exporter")
+	Cmd.Flags().IntVar(&opts.port, "port", 0, "Port of the poller's Prometheus exporter. Defaults to the port of the running poller")
+	Cmd.Flags().DurationVar(&opts.timeout, "timeout", 10*time.Second, "Timeout of the request to the poller")
+}
+
+// row is the cardinality of an object, or of one of its labels when label is not empty
+type row struct {
+	collector string
+	object    string
+	label     string
+	instances uint64
+	metrics   uint64
+	values    uint64
+	limit     uint64
+	dropped   uint64
+}
+
+func (r *row) series() uint64 {
+	return r.instances * r.metrics
+}
+
+func doCardinality(cmd *cobra.Command, args []string) {
+	config := cmd.Root().PersistentFlags().Lookup("config")
+	if _, err := conf.LoadHarvestConfig(config.Value.String()); err != nil {
+		fmt.Printf("config [%s]: %v\n", config.Value.String(), err)
+		os.Exit(1)
+	}
+
+	pollers := args
+	if len(pollers) == 0 {
+		pollers = conf.Config.PollersOrdered
+	}
+
+	ports := make(map[string]string)
+	statuses, err := ps.GetPollerStatuses()
+	if err != nil {
+		fmt.Printf("Unable to get poller statuses err=%v\n", err)
+	}
+	for _, s := range statuses {
+		if s.Status == ps.StatusRunning && s.PromPort != "" {
+			ports[s.Name] = s.PromPort
+		}
+	}
+
+	client := &http.Client{Timeout: opts.timeout}
+	exitCode := 0
+	for _, name := range pollers {
+		if _, ok := conf.Config.Pollers[name]; !ok {
+			fmt.Printf("poller [%s] not defined\n", name)
+			exitCode = 1
+			continue
+		}
+		port := ports[name]
+		if opts.port > 0 {
+			port = strconv.Itoa(opts.port)
+		}
+		if port == "" {
+			fmt.Printf("poller [%s] is not running or does not have a Prometheus exporter\n", name)
+			continue
+		}
+
+		rows, err := fetch(client, "http://"+net.JoinHostPort(opts.addr, port)+"/metrics")
+		if err != nil {
+			fmt.Printf("poller [%s]: %v\n", name, err)
+			exitCode = 1
+			continue
+		}
+		if len(rows) == 0 {
+			fmt.Printf("poller [%s] has not exported cardinality metrics yet\n", name)
+			continue
+		}
+		printRows(os.Stdout, name, rows)
+	}
+	os.Exit(exitCode)
+}
+
+func fetch(client *http.Client, url string) ([]*row, error) {
+	resp, err := client.Get(url) //nolint:noctx
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return parse(resp.Body)
+}
+
+// parse reads the metadata_cardinality metrics from the Prometheus exposition format
+func parse(r io.Reader) ([]*row, error) {
+	byKey := make(map[string]*row)
+	var rows []*row
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if !strings.HasPrefix(line, metricPrefix) {
+			continue
+		}
+		open := strings.IndexByte(line, '{')
+		closing := strings.LastIndexByte(line, '}')
+		if open < 0 || closing < open {
+			continue
+		}
+		value, err := strconv.ParseFloat(strings.Fields(line[closing+1:])[0], 64)
+		if err != nil {
+			continue
+		}
+		labels := parseLabels(line[open+1 : closing])
+
+		key := labels["collector"] + "/" + labels["object"] + "/" + labels["label"]
+		rw, ok := byKey[key]
+		if !ok {
+			rw = &row{collector: labels["collector"], object: labels["object"], label: labels["label"]}
+			byKey[key] = rw
+			rows = append(rows, rw)
+		}
+		v := uint64(value)
+		switch line[len(metricPrefix):open] {
+		case "instances":
+			rw.instances = v
+		case "metrics":
+			rw.metrics = v
+		case "label_values":
+			rw.values = v
+		case "limit":
+			rw.limit = v
+		case "dropped_instances":
+			rw.dropped = v
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return sortRows(rows), nil
+}
+
+// parseLabels parses the labels of a sample, e.g. object="volume",label=""
+func parseLabels(s string) map[string]string {
+	labels := make(map[string]string)
+	for s != "" {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 || eq+1 >= len(s) || s[eq+1] != '"' {
+			break
+		}
+		name := strings.TrimSpace(s[:eq])
+		var value strings.Builder
+		i := eq + 2
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				if s[i] == 'n' {
+					value.WriteByte('\n')
+					continue
+				}
+			}
+			value.WriteByte(s[i])
+		}
+		labels[name] = value.String()
+		s = strings.TrimPrefix(s[min(i+1, len(s)):], ",")
+	}
+	return labels
+}
+
+// sortRows sorts objects by series, the largest first. Each object is followed by its labels.
+func sortRows(rows []*row) []*row {
+	objects := make(map[string]*row)
+	for _, r := range rows {
+		if r.label == "" {
+			objects[r.collector+"/"+r.object] = r
+		}
+	}
+	parent := func(r *row) *row {
+		if p, ok := objects[r.collector+"/"+r.object]; ok {
+			return p
+		}
+		return r
+	}
+	slices.SortStableFunc(rows, func(a, b *row) int {
+		pa, pb := parent(a), parent(b)
+		return cmp.Or(
+			cmp.Compare(pb.series(), pa.series()),
+			cmp.Compare(pa.collector, pb.collector),
+			cmp.Compare(pa.object, pb.object),
+			cmp.Compare(a.label, b.label),
+		)
+	})
+	return rows
+}
+
+func printRows(w io.Writer, poller string, rows []*row) {
+	table := tw.NewWriter(w)
+	table.SetBorder(false)
+	table.SetAutoFormatHeaders(false)
+	table.SetHeader([]string{"Poller", "Collector", "Object", "Label", "Instances", "Metrics", "Series", "Values", "Limit", "Dropped"})
+	table.SetColumnAlignment([]int{tw.ALIGN_LEFT, tw.ALIGN_LEFT, tw.ALIGN_LEFT, tw.ALIGN_LEFT,
+		tw.ALIGN_RIGHT, tw.ALIGN_RIGHT, tw.ALIGN_RIGHT, tw.ALIGN_RIGHT, tw.ALIGN_RIGHT, tw.ALIGN_RIGHT})
+
+	for _, r := range rows {
+		limit := "-"
+		if r.limit > 0 {
+			limit = strconv.FormatUint(r.limit, 10)
+		}
+		if r.label == "" {
+			table.Append([]string{poller, r.collector, r.object, "",
+				strconv.FormatUint(r.instances, 10),
+				strconv.FormatUint(r.metrics, 10),
+				strconv.FormatUint(r.series(), 10),
+				"", limit,
+				strconv.FormatUint(r.dropped, 10),
+			})
+			continue
+		}
+		table.Append([]string{poller, r.collector, r.object, r.label, "", "", "",
+			strconv.FormatUint(r.values, 10), limit,
+			strconv.FormatUint(r.dropped, 10),
+		})
+	}
+	table.Render()
+}
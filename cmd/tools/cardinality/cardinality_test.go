@@ -0,0 +1,49 @@
+package cardinality
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/netapp/harvest/v2/assert"
+)
+
+const scrape = `# HELP metadata_cardinality_instances Pseudo-metric
+metadata_cardinality_instances{poller="p1",collector="Rest",object="volume",label=""} 120
+metadata_cardinality_metrics{poller="p1",collector="Rest",object="volume",label=""} 40
+metadata_cardinality_limit{poller="p1",collector="Rest",object="volume",label=""} 0
+metadata_cardinality_dropped_instances{poller="p1",collector="Rest",object="volume",label=""} 0
+metadata_cardinality_instances{poller="p1",collector="Rest",object="cifs_session",label=""} 5000
+metadata_cardinality_metrics{poller="p1",collector="Rest",object="cifs_session",label=""} 3
+metadata_cardinality_dropped_instances{poller="p1",collector="Rest",object="cifs_session",label=""} 4000
+metadata_cardinality_label_values{poller="p1",collector="Rest",object="cifs_session",label="user"} 900
+metadata_cardinality_limit{poller="p1",collector="Rest",object="cifs_session",label="user"} 100
+metadata_cardinality_dropped_instances{poller="p1",collector="Rest",object="cifs_session",label="user"} 4000
+volume_size{volume="vol\"1"} 10
+`
+
+func TestParse(t *testing.T) {
+	rows, err := parse(strings.NewReader(scrape))
+	assert.Nil(t, err)
+	assert.Equal(t, len(rows), 3)
+
+	// cifs_session has the most series and is followed by its label
+	assert.Equal(t, rows[0].object, "cifs_session")
+	assert.Equal(t, rows[0].series(), uint64(15000))
+	assert.Equal(t, rows[0].dropped, uint64(4000))
+	assert.Equal(t, rows[1].label, "user")
+	assert.Equal(t, rows[1].values, uint64(900))
+	assert.Equal(t, rows[1].limit, uint64(100))
+	assert.Equal(t, rows[2].object, "volume")
+
+	var out bytes.Buffer
+	printRows(&out, "p1", rows)
+	assert.True(t, strings.Contains(out.String(), "cifs_session"))
+}
+
+func TestParseLabels(t *testing.T) {
+	labels := parseLabels(`object="volume",label="",svm="a\"b\\c"`)
+	assert.Equal(t, labels["object"], "volume")
+	assert.Equal(t, labels["label"], "")
+	assert.Equal(t, labels["svm"], `a"b\c`)
+}
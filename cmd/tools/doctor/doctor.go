@@ -684,6 +684,9 @@ var sanitizeWords = map[string]bool{
 	"password":          true,
 	"grafana_api_token": true,
 	"token":             true,
+	"secret_id":         true,
+	"client_secret":     true,
+	"role_id":           true,
 	"host":              true,
 	"addr":              true,
 }
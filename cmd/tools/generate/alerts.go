@@ -0,0 +1,408 @@
+package generate
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/netapp/harvest/v2/cmd/tools"
+	"github.com/netapp/harvest/v2/cmd/tools/grafana"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/template"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"github.com/spf13/cobra"
+)
+
+var alertOpts = struct {
+	source    string
+	outputDir string
+	pollers   []string
+}{}
+
+var alertsCmd = &cobra.Command{
+	Use:   "alerts",
+	Short: "generate Prometheus alert rules for the pollers defined in config",
+	Run:   doAlerts,
+	Example: `
+# Generate container/prometheus/alert_rules.yml and ems_alert_rules.yml with the global_prefix and labels of the pollers
+bin/harvest generate alerts --config harvest.yml`,
+}
+
+// alertSource is the declarative source of the alert rule files
+type alertSource struct {
+	Groups []alertGroup `yaml:"groups"`
+	EMS    emsAlerts    `yaml:"ems"`
+}
+
+type alertGroup struct {
+	Name  string     `yaml:"name"`
+	File  string     `yaml:"file"`
+	Rules []alertDef `yaml:"rules"`
+}
+
+type alertDef struct {
+	Alert       string `yaml:"alert"`
+	Expr        string `yaml:"expr"`
+	Metric      string `yaml:"metric"`
+	Match       string `yaml:"match"`
+	Threshold   string `yaml:"threshold"`
+	For         string `yaml:"for"`
+	Severity    string `yaml:"severity"`
+	Summary     string `yaml:"summary"`
+	Description string `yaml:"description"`
+}
+
+type emsAlerts struct {
+	Name     string        `yaml:"name"`
+	File     string        `yaml:"file"`
+	Template string        `yaml:"template"`
+	Window   string        `yaml:"window"`
+	Runbook  string        `yaml:"runbook"`
+	Rules    []emsAlertDef `yaml:"rules"`
+}
+
+type emsAlertDef struct {
+	Alert   string `yaml:"alert"`
+	Event   string `yaml:"event"`
+	Window  string `yaml:"window"`
+	Summary string `yaml:"summary"`
+	Impact  string `yaml:"impact"`
+}
+
+// ruleFile is a Prometheus or VictoriaMetrics alerting rule file
+type ruleFile struct {
+	Groups []ruleGroup `yaml:"groups"`
+}
+
+type ruleGroup struct {
+	Name  string `yaml:"name"`
+	Rules []rule `yaml:"rules"`
+}
+
+type rule struct {
+	Alert       string          `yaml:"alert"`
+	Expr        string          `yaml:"expr"`
+	For         string          `yaml:"for,omitempty"`
+	Labels      ruleLabels      `yaml:"labels"`
+	Annotations ruleAnnotations `yaml:"annotations"`
+}
+
+type ruleLabels struct {
+	Severity string `yaml:"severity"`
+}
+
+type ruleAnnotations struct {
+	Summary     string `yaml:"summary"`
+	Description string `yaml:"description,omitempty"`
+	Impact      string `yaml:"impact,omitempty"`
+	Runbook     string `yaml:"runbook,omitempty"`
+}
+
+// alertLabels are the labels and prefix the pollers add to the metrics they export
+type alertLabels struct {
+	prefix string
+	labels []string
+}
+
+// emsSeverity maps the severity of an EMS event to the severity of its alert
+const emsSeverity = `{{- if $labels.severity -}}
+{{- if eq $labels.severity "alert" -}}
+critical
+{{- else if eq $labels.severity "error" -}}
+warning
+{{- else if eq $labels.severity "emergency" -}}
+critical
+{{- else if eq $labels.severity "notice" -}}
+info
+{{- else if eq $labels.severity "informational" -}}
+info
+{{- else -}}
+{{ $labels.severity }}
+{{- end -}}
+{{- end -}}`
+
+const alertFileHeader = "# Generated by bin/harvest generate alerts from %s. Do not edit.\n\n"
+
+// metrics exported by Prometheus instead of Harvest
+var prometheusMetrics = []string{"up"}
+
+// labels every poller adds to the EMS events
+var emsPollerLabels = []string{"datacenter", "cluster"}
+
+var templateLabelRe = regexp.MustCompile(`\$labels\.(\w+)`)
+
+func doAlerts(cmd *cobra.Command, _ []string) {
+	addRootOptions(cmd)
+
+	source, err := readAlertSource(alertOpts.source)
+	if err != nil {
+		tools.LogErrAndExit(err)
+	}
+	al, err := pollerAlertLabels(opts.ConfigPath, alertOpts.pollers)
+	if err != nil {
+		tools.LogErrAndExit(err)
+	}
+	files, err := generateAlerts(source, al)
+	if err != nil {
+		tools.LogErrAndExit(err)
+	}
+
+	outputDir := alertOpts.outputDir
+	if outputDir == "" {
+		outputDir = filepath.Dir(alertOpts.source)
+	}
+	for _, name := range slices.Sorted(maps.Keys(files)) {
+		data, err := marshalRuleFile(files[name], alertOpts.source)
+		if err != nil {
+			tools.LogErrAndExit(err)
+		}
+		fp := filepath.Join(outputDir, name)
+		if err := os.WriteFile(fp, data, grafana.GPerm); err != nil {
+			tools.LogErrAndExit(fmt.Errorf("failed to write %s: %w", fp, err))
+		}
+		count := 0
+		for _, g := range files[name].Groups {
+			count += len(g.Rules)
+		}
+		fmt.Printf("OK - %d rules [%s]\n", count, fp)
+	}
+}
+
+func marshalRuleFile(f *ruleFile, source string) ([]byte, error) {
+	data, err := yaml.MarshalWithOptions(f, yaml.UseLiteralStyleIfMultiline(true), yaml.IndentSequence(true))
+	if err != nil {
+		return nil, err
+	}
+	return append(fmt.Appendf(nil, alertFileHeader, filepath.Base(source)), data...), nil
+}
+
+func readAlertSource(path string) (alertSource, error) {
+	var source alertSource
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return source, err
+	}
+	if err := yaml.Unmarshal(data, &source); err != nil {
+		return source, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return source, nil
+}
+
+// pollerAlertLabels returns the global_prefix and labels of the pollers. When the config file does not exist,
+// the rules are generated without a prefix and labels.
+func pollerAlertLabels(configPath string, pollerNames []string) (alertLabels, error) {
+	var al alertLabels
+	if _, err := conf.LoadHarvestConfig(configPath); err != nil {
+		if errors.Is(err, os.ErrNotExist) && len(pollerNames) == 0 {
+			return al, nil
+		}
+		return al, err
+	}
+	if len(pollerNames) == 0 {
+		pollerNames = conf.Config.PollersOrdered
+	}
+
+	prefixes := make(map[string][]string)
+	for _, name := range pollerNames {
+		poller, err := conf.PollerNamed(name)
+		if err != nil {
+			return al, err
+		}
+		prefix := ""
+		for _, e := range poller.Exporters {
+			exporter, ok := conf.Config.Exporters[e]
+			if !ok || exporter.GlobalPrefix == nil {
+				continue
+			}
+			if exporter.Type == "Prometheus" || exporter.Type == "VictoriaMetrics" {
+				prefix = *exporter.GlobalPrefix
+			}
+		}
+		if prefix != "" && !strings.HasSuffix(prefix, "_") {
+			prefix += "_"
+		}
+		prefixes[prefix] = append(prefixes[prefix], name)
+
+		if poller.Labels != nil {
+			for _, labels := range *poller.Labels {
+				for label := range labels {
+					if !slices.Contains(al.labels, label) {
+						al.labels = append(al.labels, label)
+					}
+				}
+			}
+		}
+	}
+
+	if len(prefixes) > 1 {
+		return al, fmt.Errorf("pollers export with different global_prefix values %v, use --poller to select pollers with the same prefix", prefixes)
+	}
+	for prefix := range prefixes {
+		al.prefix = prefix
+	}
+	slices.Sort(al.labels)
+	return al, nil
+}
+
+// generateAlerts returns the rule files of the source, keyed by file name
+func generateAlerts(source alertSource, al alertLabels) (map[string]*ruleFile, error) {
+	var errs []error
+	files := make(map[string]*ruleFile)
+	fileNamed := func(name string) *ruleFile {
+		if files[name] == nil {
+			files[name] = &ruleFile{}
+		}
+		return files[name]
+	}
+
+	for _, g := range source.Groups {
+		group := ruleGroup{Name: g.Name}
+		for _, def := range g.Rules {
+			r, err := thresholdRule(def, al)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("alert %s: %w", def.Alert, err))
+				continue
+			}
+			group.Rules = append(group.Rules, r)
+		}
+		f := fileNamed(g.File)
+		f.Groups = append(f.Groups, group)
+	}
+
+	if len(source.EMS.Rules) > 0 {
+		group, err := emsRules(source.EMS, al)
+		if err != nil {
+			errs = append(errs, err)
+		} else {
+			f := fileNamed(source.EMS.File)
+			f.Groups = append(f.Groups, group)
+		}
+	}
+
+	return files, errors.Join(errs...)
+}
+
+func thresholdRule(def alertDef, al alertLabels) (rule, error) {
+	expr := def.Expr
+	if def.Metric != "" {
+		if expr != "" {
+			return rule{}, errors.New("expr and metric are mutually exclusive")
+		}
+		if def.Threshold == "" {
+			return rule{}, errors.New("metric requires a threshold")
+		}
+		expr = def.Metric
+		if def.Match != "" {
+			expr += "{" + def.Match + "}"
+		}
+		expr += " " + def.Threshold
+	}
+	if expr == "" {
+		return rule{}, errors.New("expr or metric is required")
+	}
+	expr, err := grafana.RelabelPromQL(expr, al.prefix, al.labels, prometheusMetrics)
+	if err != nil {
+		return rule{}, err
+	}
+
+	description := def.Description
+	if description == "" {
+		description = def.Summary
+	}
+	return rule{
+		Alert:       def.Alert,
+		Expr:        expr,
+		For:         def.For,
+		Labels:      ruleLabels{Severity: def.Severity},
+		Annotations: ruleAnnotations{Summary: def.Summary, Description: description},
+	}, nil
+}
+
+func emsRules(ems emsAlerts, al alertLabels) (ruleGroup, error) {
+	group := ruleGroup{Name: ems.Name}
+	eventLabels, err := readEmsEventLabels(conf.Path(ems.Template))
+	if err != nil {
+		return group, err
+	}
+
+	var errs []error
+	for _, def := range ems.Rules {
+		labels, ok := eventLabels[def.Event]
+		if !ok {
+			errs = append(errs, fmt.Errorf("alert %s: event %s is not in %s", def.Alert, def.Event, ems.Template))
+			continue
+		}
+		for _, m := range templateLabelRe.FindAllStringSubmatch(def.Summary, -1) {
+			label := m[1]
+			if !slices.Contains(labels, label) && !slices.Contains(emsPollerLabels, label) && !slices.Contains(al.labels, label) {
+				errs = append(errs, fmt.Errorf("alert %s: label %s is not exported by event %s", def.Alert, label, def.Event))
+			}
+		}
+
+		window := def.Window
+		if window == "" {
+			window = ems.Window
+		}
+		expr := fmt.Sprintf("last_over_time(ems_events{message=%q}[%s]) == 1", def.Event, window)
+		expr, err := grafana.RelabelPromQL(expr, al.prefix, al.labels, prometheusMetrics)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("alert %s: %w", def.Alert, err))
+			continue
+		}
+
+		annotations := ruleAnnotations{Summary: def.Summary, Impact: def.Impact}
+		if ems.Runbook != "" {
+			annotations.Runbook = ems.Runbook + "#" + runbookAnchor(def.Alert)
+		}
+		group.Rules = append(group.Rules, rule{
+			Alert:       def.Alert,
+			Expr:        expr,
+			Labels:      ruleLabels{Severity: emsSeverity},
+			Annotations: annotations,
+		})
+	}
+	return group, errors.Join(errs...)
+}
+
+// readEmsEventLabels returns the labels each event of an EMS template exports
+func readEmsEventLabels(path string) (map[string][]string, error) {
+	t, err := tree.ImportYaml(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read EMS template %s: %w", path, err)
+	}
+	var defaults []string
+	if exports := t.GetChildS("exports"); exports != nil {
+		for _, export := range exports.GetAllChildContentS() {
+			_, display, _, _ := template.ParseMetric(export)
+			defaults = append(defaults, display)
+		}
+	}
+
+	eventLabels := make(map[string][]string)
+	if events := t.GetChildS("events"); events != nil {
+		for _, e := range events.GetChildren() {
+			labels := slices.Clone(defaults)
+			if exports := e.GetChildS("exports"); exports != nil {
+				for _, export := range exports.GetAllChildContentS() {
+					_, display, _, _ := template.ParseMetric(export)
+					labels = append(labels, display)
+				}
+			}
+			eventLabels[e.GetChildContentS("name")] = labels
+		}
+	}
+	return eventLabels, nil
+}
+
+var anchorRe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// runbookAnchor returns the anchor of the runbook heading with the alert's name
+func runbookAnchor(alert string) string {
+	return strings.Trim(anchorRe.ReplaceAllString(strings.ToLower(alert), "-"), "-")
+}
@@ -0,0 +1,95 @@
+package generate
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/netapp/harvest/v2/assert"
+)
+
+const alertsSource = "../../../container/prometheus/alerts.yaml"
+
+func generateTestAlerts(t *testing.T, al alertLabels) map[string]*ruleFile {
+	t.Helper()
+	t.Setenv("HARVEST_CONF", "../../..")
+	source, err := readAlertSource(alertsSource)
+	assert.Nil(t, err)
+	files, err := generateAlerts(source, al)
+	assert.Nil(t, err)
+	return files
+}
+
+func findRule(t *testing.T, f *ruleFile, alert string) rule {
+	t.Helper()
+	for _, g := range f.Groups {
+		for _, r := range g.Rules {
+			if r.Alert == alert {
+				return r
+			}
+		}
+	}
+	t.Fatalf("alert %s not found", alert)
+	return rule{}
+}
+
+// The rule files in container/prometheus must be regenerated with bin/harvest generate alerts when alerts.yaml changes
+func TestAlertRulesAreGenerated(t *testing.T) {
+	files := generateTestAlerts(t, alertLabels{})
+	assert.Equal(t, len(files), 2)
+	for name, f := range files {
+		want, err := marshalRuleFile(f, alertsSource)
+		assert.Nil(t, err)
+		got, err := os.ReadFile("../../../container/prometheus/" + name)
+		assert.Nil(t, err)
+		if string(got) != string(want) {
+			t.Errorf("%s is out of date, run bin/harvest generate alerts", name)
+		}
+	}
+}
+
+func TestAlertsPrefixAndLabels(t *testing.T) {
+	files := generateTestAlerts(t, alertLabels{prefix: "ntap_", labels: []string{"org"}})
+
+	rules := files["alert_rules.yml"]
+	assert.Equal(t, findRule(t, rules, "InstanceDown").Expr, "up == 0")
+	assert.Equal(t, findRule(t, rules, "Volume state offline").Expr, `ntap_volume_labels{state="offline"} == 1`)
+	assert.Equal(t, findRule(t, rules, "Certificates expired").Expr,
+		`((ntap_security_certificate_expiry_time * on (uuid, org) group_left (name, expiry_time) ntap_security_certificate_labels) - time()) < 0`)
+	assert.Equal(t, findRule(t, rules, "Volume Used Percentage Breach").Annotations.Description,
+		findRule(t, rules, "Volume Used Percentage Breach").Annotations.Summary)
+
+	lunDestroyed := findRule(t, files["ems_alert_rules.yml"], "LUN Destroyed")
+	assert.Equal(t, lunDestroyed.Expr, `last_over_time(ntap_ems_events{message="LUN.destroy"}[5m]) == 1`)
+	assert.True(t, strings.HasSuffix(lunDestroyed.Annotations.Runbook, "#lun-destroyed"))
+}
+
+func TestAlertsValidation(t *testing.T) {
+	t.Setenv("HARVEST_CONF", "../../..")
+	source := alertSource{
+		Groups: []alertGroup{{Name: "test", File: "test.yml", Rules: []alertDef{
+			{Alert: "bad expr", Expr: "sum(volume_read_ops"},
+			{Alert: "no threshold", Metric: "volume_read_ops"},
+			{Alert: "ok", Metric: "volume_read_ops", Threshold: "> 100"},
+		}}},
+		EMS: emsAlerts{Name: "ems", File: "ems.yml", Template: "conf/ems/9.6.0/ems.yaml", Window: "5m", Rules: []emsAlertDef{
+			{Alert: "unknown event", Event: "LUN.bogus"},
+			{Alert: "unknown label", Event: "LUN.destroy", Summary: "{{ $labels.lun_path }} {{ $labels.bogus }}"},
+		}},
+	}
+	_, err := generateAlerts(source, alertLabels{})
+	assert.NotNil(t, err)
+	for _, want := range []string{"alert bad expr", "alert no threshold", "event LUN.bogus is not in", "label bogus is not exported"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("got %q want it to contain %q", err.Error(), want)
+		}
+	}
+	assert.False(t, strings.Contains(err.Error(), "alert ok"))
+	assert.False(t, strings.Contains(err.Error(), "label lun_path"))
+}
+
+func TestRunbookAnchor(t *testing.T) {
+	assert.Equal(t, runbookAnchor("LUN Destroyed"), "lun-destroyed")
+	assert.Equal(t, runbookAnchor("Directory size is approaching the maximum directory size (maxdirsize) limit"),
+		"directory-size-is-approaching-the-maximum-directory-size-maxdirsize-limit")
+}
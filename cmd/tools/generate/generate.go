@@ -621,6 +621,7 @@ func init() {
 	Cmd.AddCommand(metricCmd)
 	Cmd.AddCommand(descCmd)
 	Cmd.AddCommand(dockerCmd)
+	Cmd.AddCommand(alertsCmd)
 	dockerCmd.AddCommand(fullCmd)
 
 	dFlags := dockerCmd.PersistentFlags()
@@ -650,4 +651,9 @@ func init() {
 	fFlags.IntVar(&opts.GrafanaPort, "grafanaPort", 3000, "Grafana Port")
 
 	metricCmd.PersistentFlags().StringVar(&opts.PromURL, "prom-url", "", "Prometheus URL for CI validation")
+
+	aFlags := alertsCmd.PersistentFlags()
+	aFlags.StringVar(&alertOpts.source, "source", "container/prometheus/alerts.yaml", "Alert rules source file")
+	aFlags.StringVarP(&alertOpts.outputDir, "output-dir", "o", "", "Directory the rule files are written to. Defaults to the directory of the source file")
+	aFlags.StringSliceVar(&alertOpts.pollers, "poller", nil, "Apply the global_prefix and labels of these pollers. Defaults to all pollers")
 }
@@ -0,0 +1,775 @@
+package grafana
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/netapp/harvest/v2/cmd/exporters/influxdb"
+	"github.com/netapp/harvest/v2/third_party/tidwall/gjson"
+	"github.com/netapp/harvest/v2/third_party/tidwall/sjson"
+	"github.com/spf13/cobra"
+)
+
+const (
+	toFlux     = "flux"
+	toInfluxQL = "influxql"
+	// fullRange is the Grafana variable for the dashboard's time range, used by table panels
+	fullRange = "$__range"
+)
+
+// objects that are not defined by a template
+var builtinObjects = []string{"metadata", "metadata_collector", "metadata_component", "metadata_exporter",
+	"metadata_target", "poller"}
+
+var convertOpts = struct {
+	to        string
+	dir       string
+	outputDir string
+	confDir   string
+}{}
+
+var convertCmd = &cobra.Command{
+	Use:   "convert",
+	Short: "convert Prometheus dashboards to InfluxDB dashboards",
+	Run:   doConvert,
+	Example: `
+# Convert the cmode dashboards to InfluxDB dashboards that use Flux and write them to ~/influxdb-dashboards
+grafana convert --to flux --directory grafana/dashboards/cmode --output-dir ~/influxdb-dashboards`,
+}
+
+// converter rewrites the PromQL queries of a dashboard into Flux or InfluxQL queries against the measurements the
+// InfluxDB exporter writes: one measurement per object, instance keys and global labels as tags, and metrics as
+// fields named without the object prefix.
+type converter struct {
+	to string
+	// objects sorted longest first, so a metric name is split at its longest object prefix
+	objects []string
+}
+
+type convertFailure struct {
+	Panel  string
+	Expr   string
+	Reason string
+}
+
+type convertReport struct {
+	Targets   int
+	Converted int
+	Failures  []convertFailure
+}
+
+func doConvert(_ *cobra.Command, _ []string) {
+	if convertOpts.to != toFlux && convertOpts.to != toInfluxQL {
+		log.Fatalf("--to must be %s or %s, got %s", toFlux, toInfluxQL, convertOpts.to)
+	}
+	exitIfExist(convertOpts.outputDir, "output-dir")
+
+	objects, err := templateObjects(convertOpts.confDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+	c := newConverter(convertOpts.to, objects)
+
+	var total convertReport
+	VisitDashboards([]string{convertOpts.dir}, func(path string, data []byte) {
+		converted, report := c.convertDashboard(data)
+
+		rel, err := filepath.Rel(convertOpts.dir, path)
+		if err != nil || rel == "." {
+			rel = filepath.Base(path)
+		}
+		fp := filepath.Join(convertOpts.outputDir, rel)
+		if err := os.MkdirAll(filepath.Dir(fp), 0750); err != nil {
+			log.Fatalf("error makedir [%s]: %v", filepath.Dir(fp), err)
+		}
+		if err := os.WriteFile(fp, converted, GPerm); err != nil {
+			log.Fatalf("error writing converted dashboard to file %s: %v", fp, err)
+		}
+
+		fmt.Printf("OK - converted %d/%d queries [%s]\n", report.Converted, report.Targets, fp)
+		for _, f := range report.Failures {
+			fmt.Printf("  %s: %s\n    %s\n", f.Panel, f.Reason, strings.Join(strings.Fields(f.Expr), " "))
+		}
+		total.Targets += report.Targets
+		total.Converted += report.Converted
+	})
+	fmt.Printf("converted %d/%d queries, queries that could not be converted are hidden in their panels\n",
+		total.Converted, total.Targets)
+}
+
+// templateObjects returns the objects of all templates in confDir
+func templateObjects(confDir string) ([]string, error) {
+	objectRe := regexp.MustCompile(`(?m)^object:\s*(\S+)`)
+	seen := make(map[string]struct{})
+	err := filepath.WalkDir(confDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || filepath.Ext(path) != ".yaml" {
+			return nil
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		for _, m := range objectRe.FindAllSubmatch(data, -1) {
+			seen[string(m[1])] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read templates from %s: %w", confDir, err)
+	}
+	return setToList(seen), nil
+}
+
+func newConverter(to string, objects []string) *converter {
+	all := slices.Concat(objects, builtinObjects)
+	slices.SortFunc(all, func(a, b string) int { return len(b) - len(a) })
+	return &converter{to: to, objects: slices.Compact(all)}
+}
+
+// split returns the measurement and field of a Prometheus metric
+func (c *converter) split(metric string) (string, string, error) {
+	if strings.HasSuffix(metric, "_labels") {
+		return "", "", errors.New("label metrics have no InfluxDB equivalent, labels are string fields of their object")
+	}
+	for _, o := range c.objects {
+		if field, ok := strings.CutPrefix(metric, o+"_"); ok {
+			return o, influxdb.FieldName(field), nil
+		}
+	}
+	return "", "", fmt.Errorf("no object found for metric %s", metric)
+}
+
+// convertDashboard converts the datasource, variables, and queries of a Prometheus dashboard
+func (c *converter) convertDashboard(data []byte) ([]byte, convertReport) {
+	var report convertReport
+
+	data = bytes.ReplaceAll(data, []byte("${DS_PROMETHEUS}"), []byte("${DS_INFLUXDB}"))
+	data = bytes.ReplaceAll(data, []byte(`"type": "prometheus"`), []byte(`"type": "influxdb"`))
+
+	inputs := gjson.GetBytes(data, "__inputs")
+	inputs.ForEach(func(key, value gjson.Result) bool {
+		if value.Get("pluginId").ClonedString() == "prometheus" {
+			path := "__inputs." + key.ClonedString()
+			data, _ = sjson.SetBytes(data, path+".name", "DS_INFLUXDB")
+			data, _ = sjson.SetBytes(data, path+".label", "InfluxDB")
+			data, _ = sjson.SetBytes(data, path+".pluginId", "influxdb")
+			data, _ = sjson.SetBytes(data, path+".pluginName", "InfluxDB")
+		}
+		return true
+	})
+
+	data = c.convertVariables(data)
+
+	VisitAllPanels(data, func(path string, _ gjson.Result, value gjson.Result) {
+		title := value.Get("title").ClonedString()
+		value.Get("targets").ForEach(func(key, target gjson.Result) bool {
+			expr := target.Get("expr").ClonedString()
+			if expr == "" {
+				return true
+			}
+			report.Targets++
+			targetPath := path + ".targets." + key.ClonedString()
+			isTable := target.Get("format").ClonedString() == "table" || target.Get("instant").Bool()
+
+			query, err := c.convert(expr, isTable)
+			if err != nil {
+				report.Failures = append(report.Failures, convertFailure{Panel: title, Expr: expr, Reason: err.Error()})
+				data, _ = sjson.SetBytes(data, targetPath+".hide", true)
+				return true
+			}
+			report.Converted++
+			data, _ = sjson.SetBytes(data, targetPath+".query", query)
+			data, _ = sjson.DeleteBytes(data, targetPath+".expr")
+			if c.to == toInfluxQL {
+				data, _ = sjson.SetBytes(data, targetPath+".rawQuery", true)
+				format := "time_series"
+				if isTable {
+					format = "table"
+				}
+				data, _ = sjson.SetBytes(data, targetPath+".resultFormat", format)
+				if legend := target.Get("legendFormat").ClonedString(); legend != "" {
+					data, _ = sjson.SetBytes(data, targetPath+".alias", legendRe.ReplaceAllString(legend, "$$tag_$1"))
+				}
+			}
+			data, _ = sjson.DeleteBytes(data, targetPath+".legendFormat")
+			return true
+		})
+	})
+
+	if formatted, err := formatJSON(data); err == nil {
+		data = formatted
+	}
+	return data, report
+}
+
+var legendRe = regexp.MustCompile(`\{\{\s*(\w+)\s*}}`)
+
+// convertVariables converts the datasource variable and label_values query variables. Flux dashboards also get the
+// Bucket variable the InfluxDB dashboards use.
+func (c *converter) convertVariables(data []byte) []byte {
+	labelValuesRe := regexp.MustCompile(`^\s*label_values\((.*),\s*(\w+)\s*\)\s*$`)
+	bucketAt := -1
+
+	gjson.GetBytes(data, "templating.list").ForEach(func(key, value gjson.Result) bool {
+		path := "templating.list." + key.ClonedString()
+		switch value.Get("type").ClonedString() {
+		case "datasource":
+			if value.Get("query").ClonedString() != "prometheus" {
+				return true
+			}
+			data, _ = sjson.SetBytes(data, path+".name", "DS_INFLUXDB")
+			data, _ = sjson.SetBytes(data, path+".query", "influxdb")
+			data, _ = sjson.SetBytes(data, path+".current", map[string]any{"selected": false, "text": "InfluxDB", "value": "InfluxDB"})
+			bucketAt = int(key.Int()) + 1
+		case "query":
+			q := value.Get("query.query").ClonedString()
+			if q == "" {
+				q = value.Get("query").ClonedString()
+			}
+			m := labelValuesRe.FindStringSubmatch(q)
+			if m == nil {
+				return true
+			}
+			converted, err := c.labelValues(m[1], m[2])
+			if err != nil {
+				return true
+			}
+			data, _ = sjson.SetBytes(data, path+".query", converted)
+			data, _ = sjson.SetBytes(data, path+".definition", converted)
+		}
+		return true
+	})
+
+	if c.to == toFlux && bucketAt >= 0 {
+		bucket := map[string]any{
+			"current":    map[string]any{},
+			"datasource": "${DS_INFLUXDB}",
+			"definition": "buckets()",
+			"hide":       0,
+			"includeAll": false,
+			"label":      "Bucket",
+			"multi":      false,
+			"name":       "Bucket",
+			"options":    []any{},
+			"query":      "buckets()",
+			"refresh":    2,
+			"type":       "query",
+		}
+		b, _ := json.Marshal(bucket)
+		list := gjson.GetBytes(data, "templating.list").Array()
+		raw := make([]string, 0, len(list)+1)
+		for _, v := range list {
+			raw = append(raw, v.Raw)
+		}
+		raw = slices.Insert(raw, bucketAt, string(b))
+		data, _ = sjson.SetRawBytes(data, "templating.list", []byte("["+strings.Join(raw, ",")+"]"))
+	}
+	return data
+}
+
+// labelValues converts the Grafana label_values(selector, label) variable query
+func (c *converter) labelValues(selector string, label string) (string, error) {
+	n, err := parsePromQL(selector)
+	if err != nil {
+		return "", err
+	}
+	s, ok := n.(*vectorSelector)
+	if !ok {
+		return "", errors.New("label_values expects a selector")
+	}
+	measurement := strings.TrimSuffix(s.metric, "_labels")
+	if s.metric == measurement {
+		if measurement, _, err = c.split(s.metric); err != nil {
+			return "", err
+		}
+	}
+	filters := s.matchers
+	if c.to == toFlux {
+		return fmt.Sprintf("from(bucket: \"${Bucket}\")\n"+
+			"  |> range(start: v.timeRangeStart, stop: v.timeRangeStop)\n"+
+			"  |> filter(fn: (r) => r[\"_measurement\"] == %q)\n"+
+			"%s"+
+			"  |> keep(columns: [%q])\n"+
+			"  |> group()\n"+
+			"  |> distinct(column: %q)", measurement, fluxFilters(filters), label, label), nil
+	}
+	q := fmt.Sprintf(`SHOW TAG VALUES FROM %q WITH KEY = %q`, measurement, label)
+	if len(filters) > 0 {
+		q += " WHERE " + influxQLFilters(filters)
+	}
+	return q, nil
+}
+
+// influxQuery is a PromQL expression in terms of InfluxDB measurements and fields
+type influxQuery struct {
+	measurement string
+	fields      []string
+	// fieldOp is the operator between the two fields of a vector-vector expression
+	fieldOp  string
+	filters  []labelMatcher
+	rate     bool
+	window   string // empty is the dashboard interval
+	windowFn string
+	grouped  bool
+	groupBy  []string
+	groupFn  string
+	math     []arithmetic
+	compare  *arithmetic
+}
+
+type arithmetic struct {
+	op          string
+	scalar      string
+	scalarFirst bool
+}
+
+var overTimeFns = map[string]string{
+	"avg_over_time":   "mean",
+	"max_over_time":   "max",
+	"min_over_time":   "min",
+	"sum_over_time":   "sum",
+	"count_over_time": "count",
+	"last_over_time":  "last",
+}
+
+var aggregateFns = map[string]string{
+	"avg":   "mean",
+	"count": "count",
+	"max":   "max",
+	"min":   "min",
+	"sum":   "sum",
+}
+
+// convert translates a PromQL expression. Instant queries, used by table panels, return one value per series.
+func (c *converter) convert(expr string, instant bool) (string, error) {
+	n, err := parsePromQL(expr)
+	if err != nil {
+		return "", fmt.Errorf("unable to parse PromQL: %w", err)
+	}
+	q, err := c.translate(n)
+	if err != nil {
+		return "", err
+	}
+	if instant && q.window == "" {
+		q.window = fullRange
+		q.windowFn = "last"
+	}
+	if c.to == toFlux {
+		return q.flux(), nil
+	}
+	return q.influxQL()
+}
+
+func (c *converter) translate(n promNode) (*influxQuery, error) {
+	switch n := n.(type) {
+	case *vectorSelector:
+		if n.offset != "" || n.at != "" {
+			return nil, errors.New("offset and @ modifiers are not supported")
+		}
+		if n.rangeDur != "" {
+			return nil, errors.New("range vectors must be wrapped in a function")
+		}
+		if n.metric == "" {
+			return nil, errors.New("selectors without a metric name are not supported")
+		}
+		measurement, field, err := c.split(n.metric)
+		if err != nil {
+			return nil, err
+		}
+		return &influxQuery{measurement: measurement, fields: []string{field}, filters: n.matchers, windowFn: "mean"}, nil
+
+	case *call:
+		return c.translateCall(n)
+
+	case *aggregateExpr:
+		fn, ok := aggregateFns[n.op]
+		if !ok {
+			return nil, fmt.Errorf("aggregation %s is not supported", n.op)
+		}
+		if n.without {
+			return nil, errors.New("aggregation without (...) is not supported")
+		}
+		q, err := c.translate(n.expr)
+		if err != nil {
+			return nil, err
+		}
+		if q.grouped || len(q.math) > 0 || q.compare != nil {
+			return nil, errors.New("nested aggregations are not supported")
+		}
+		if n.op == "count" {
+			q.windowFn = "last"
+		}
+		q.grouped = true
+		q.groupBy = n.by
+		q.groupFn = fn
+		return q, nil
+
+	case *binaryExpr:
+		return c.translateBinary(n)
+
+	case *subquery:
+		return nil, errors.New("subqueries are not supported")
+
+	case *unaryExpr:
+		q, err := c.translate(n.expr)
+		if err != nil {
+			return nil, err
+		}
+		if n.op == "-" {
+			q.math = append(q.math, arithmetic{op: "*", scalar: "-1"})
+		}
+		return q, nil
+	}
+	return nil, errors.New("scalar expressions are not supported")
+}
+
+func (c *converter) translateCall(n *call) (*influxQuery, error) {
+	if fn, ok := overTimeFns[n.fn]; ok {
+		s, ok := n.args[0].(*vectorSelector)
+		if !ok || len(n.args) != 1 {
+			return nil, fmt.Errorf("%s expects a range vector selector", n.fn)
+		}
+		if s.at != "" || s.offset != "" {
+			return nil, errors.New("offset and @ modifiers are not supported")
+		}
+		q, err := c.translate(&vectorSelector{metric: s.metric, matchers: s.matchers})
+		if err != nil {
+			return nil, err
+		}
+		q.window = s.rangeDur
+		q.windowFn = fn
+		return q, nil
+	}
+
+	switch n.fn {
+	case "rate", "irate", "deriv":
+		s, ok := n.args[0].(*vectorSelector)
+		if !ok || len(n.args) != 1 || s.rangeDur == "" {
+			return nil, fmt.Errorf("%s expects a range vector selector", n.fn)
+		}
+		q, err := c.translate(&vectorSelector{metric: s.metric, matchers: s.matchers})
+		if err != nil {
+			return nil, err
+		}
+		q.rate = true
+		return q, nil
+	}
+	return nil, fmt.Errorf("function %s is not supported", n.fn)
+}
+
+func (c *converter) translateBinary(n *binaryExpr) (*influxQuery, error) {
+	if n.matching {
+		return nil, errors.New("vector matching (on, ignoring, group_left, group_right) is not supported")
+	}
+	switch n.op {
+	case "and", "or", "unless":
+		return nil, fmt.Errorf("set operator %s is not supported", n.op)
+	}
+
+	lhsScalar, lhsIsScalar := n.lhs.(numberLit)
+	rhsScalar, rhsIsScalar := n.rhs.(numberLit)
+	isCompare := slices.Contains([]string{"==", "!=", ">", "<", ">=", "<="}, n.op)
+
+	switch {
+	case lhsIsScalar && rhsIsScalar:
+		return nil, errors.New("scalar expressions are not supported")
+	case lhsIsScalar || rhsIsScalar:
+		vector, scalar, scalarFirst := n.lhs, rhsScalar.value, false
+		if lhsIsScalar {
+			vector, scalar, scalarFirst = n.rhs, lhsScalar.value, true
+		}
+		if strings.HasPrefix(scalar, "$") {
+			return nil, errors.New("variables are not supported as scalars")
+		}
+		q, err := c.translate(vector)
+		if err != nil {
+			return nil, err
+		}
+		if q.compare != nil {
+			return nil, errors.New("arithmetic after a comparison is not supported")
+		}
+		a := arithmetic{op: n.op, scalar: scalar, scalarFirst: scalarFirst}
+		if isCompare {
+			if n.returnBool || scalarFirst {
+				return nil, errors.New("only comparisons of the form <vector> <op> <scalar> are supported")
+			}
+			q.compare = &a
+			return q, nil
+		}
+		if !slices.Contains([]string{"+", "-", "*", "/"}, n.op) {
+			return nil, fmt.Errorf("operator %s is not supported", n.op)
+		}
+		q.math = append(q.math, a)
+		return q, nil
+	}
+
+	if isCompare || !slices.Contains([]string{"+", "-", "*", "/"}, n.op) {
+		return nil, fmt.Errorf("operator %s between vectors is not supported", n.op)
+	}
+	lhs, err := c.translate(n.lhs)
+	if err != nil {
+		return nil, err
+	}
+	rhs, err := c.translate(n.rhs)
+	if err != nil {
+		return nil, err
+	}
+	// scalar multiplication of the left side, e.g. 100 * used / total, is applied after combining the fields
+	var math []arithmetic
+	if n.op == "*" || n.op == "/" {
+		multiplicative := !slices.ContainsFunc(lhs.math, func(a arithmetic) bool {
+			return a.op != "*" && (a.op != "/" || a.scalarFirst)
+		})
+		if multiplicative {
+			math, lhs.math = lhs.math, nil
+		}
+	}
+	// both sides must be the same series of one measurement, so the fields can be combined row by row
+	if len(lhs.fields) != 1 || len(rhs.fields) != 1 || lhs.measurement != rhs.measurement ||
+		!slices.Equal(lhs.filters, rhs.filters) || lhs.rate != rhs.rate || lhs.window != rhs.window ||
+		lhs.windowFn != rhs.windowFn || lhs.grouped != rhs.grouped || !slices.Equal(lhs.groupBy, rhs.groupBy) ||
+		lhs.groupFn != rhs.groupFn || len(lhs.math) > 0 || len(rhs.math) > 0 || lhs.compare != nil || rhs.compare != nil {
+		return nil, errors.New("binary operations are only supported between fields of the same measurement with the same label matchers")
+	}
+	lhs.fields = append(lhs.fields, rhs.fields[0])
+	lhs.fieldOp = n.op
+	lhs.math = math
+	return lhs, nil
+}
+
+var grafanaVarRe = regexp.MustCompile(`\$\{(\w+)(?::\w+)?}|\$(\w+)`)
+
+// varName returns the name of the Grafana variable in a match of grafanaVarRe
+func varName(m []string) string {
+	if m[1] != "" {
+		return m[1]
+	}
+	return m[2]
+}
+
+// regexValue converts a PromQL regex, which is fully anchored, to an InfluxDB regex literal.
+// Variables are interpolated with the regex format, so multi-value variables become an alternation.
+func regexValue(value string) string {
+	var sb strings.Builder
+	last := 0
+	for _, idx := range grafanaVarRe.FindAllStringSubmatchIndex(value, -1) {
+		sb.WriteString(value[last:idx[0]])
+		m := []string{"", "", ""}
+		if idx[2] >= 0 {
+			m[1] = value[idx[2]:idx[3]]
+		}
+		if idx[4] >= 0 {
+			m[2] = value[idx[4]:idx[5]]
+		}
+		sb.WriteString("${" + varName(m) + ":regex}")
+		last = idx[1]
+	}
+	sb.WriteString(value[last:])
+	return "/^(" + strings.ReplaceAll(sb.String(), "/", `\/`) + ")$/"
+}
+
+// stringValue normalizes variables to the ${Var} form
+func stringValue(value string) string {
+	return grafanaVarRe.ReplaceAllStringFunc(value, func(s string) string {
+		return "${" + varName(grafanaVarRe.FindStringSubmatch(s)) + "}"
+	})
+}
+
+func fluxFilters(filters []labelMatcher) string {
+	if len(filters) == 0 {
+		return ""
+	}
+	conditions := make([]string, 0, len(filters))
+	for _, f := range filters {
+		switch f.op {
+		case "=~", "!~":
+			conditions = append(conditions, fmt.Sprintf("r[%q] %s %s", f.name, f.op, regexValue(f.value)))
+		case "=":
+			conditions = append(conditions, fmt.Sprintf("r[%q] == %q", f.name, stringValue(f.value)))
+		default:
+			conditions = append(conditions, fmt.Sprintf("r[%q] %s %q", f.name, f.op, stringValue(f.value)))
+		}
+	}
+	return "  |> filter(fn: (r) => " + strings.Join(conditions, " and ") + ")\n"
+}
+
+func influxQLFilters(filters []labelMatcher) string {
+	conditions := make([]string, 0, len(filters))
+	for _, f := range filters {
+		value := "'" + strings.ReplaceAll(stringValue(f.value), "'", `\'`) + "'"
+		if f.op == "=~" || f.op == "!~" {
+			value = regexValue(f.value)
+		}
+		conditions = append(conditions, fmt.Sprintf("%q %s %s", f.name, f.op, value))
+	}
+	return strings.Join(conditions, " AND ")
+}
+
+// fluxNumber returns a float literal, since the InfluxDB exporter writes numbers as floats
+func fluxNumber(s string) string {
+	if _, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return s + ".0"
+	}
+	return s
+}
+
+func fluxDuration(window string) string {
+	if window == "" || strings.HasPrefix(window, "$__interval") {
+		return "v.windowPeriod"
+	}
+	return stringValue(window)
+}
+
+func (q *influxQuery) flux() string {
+	var sb strings.Builder
+	sb.WriteString("from(bucket: \"${Bucket}\")\n")
+	sb.WriteString("  |> range(start: v.timeRangeStart, stop: v.timeRangeStop)\n")
+
+	fields := make([]string, 0, len(q.fields))
+	for _, f := range q.fields {
+		fields = append(fields, fmt.Sprintf("r[\"_field\"] == %q", f))
+	}
+	fieldFilter := strings.Join(fields, " or ")
+	if len(fields) > 1 {
+		fieldFilter = "(" + fieldFilter + ")"
+	}
+	fmt.Fprintf(&sb, "  |> filter(fn: (r) => r[\"_measurement\"] == %q and %s)\n", q.measurement, fieldFilter)
+	sb.WriteString(fluxFilters(q.filters))
+
+	if q.rate {
+		sb.WriteString("  |> derivative(unit: 1s, nonNegative: true)\n")
+	}
+	aggregate := func(fn string) {
+		if q.window == fullRange {
+			fmt.Fprintf(&sb, "  |> %s()\n", fn)
+			return
+		}
+		fmt.Fprintf(&sb, "  |> aggregateWindow(every: %s, fn: %s, createEmpty: false)\n", fluxDuration(q.window), fn)
+	}
+	aggregate(q.windowFn)
+	if q.grouped {
+		columns := make([]string, 0, len(q.groupBy)+1)
+		for _, g := range q.groupBy {
+			columns = append(columns, strconv.Quote(g))
+		}
+		columns = append(columns, `"_field"`)
+		fmt.Fprintf(&sb, "  |> group(columns: [%s])\n", strings.Join(columns, ", "))
+		if q.window == fullRange {
+			fmt.Fprintf(&sb, "  |> %s()\n", q.groupFn)
+		} else {
+			fmt.Fprintf(&sb, "  |> aggregateWindow(every: %s, fn: %s, createEmpty: false)\n", fluxDuration(q.window), q.groupFn)
+		}
+	}
+	if len(q.fields) > 1 {
+		sb.WriteString("  |> pivot(rowKey: [\"_time\"], columnKey: [\"_field\"], valueColumn: \"_value\")\n")
+		fmt.Fprintf(&sb, "  |> map(fn: (r) => ({r with _value: r[%q] %s r[%q]}))\n", q.fields[0], q.fieldOp, q.fields[1])
+	}
+	for _, m := range q.math {
+		if m.scalarFirst {
+			fmt.Fprintf(&sb, "  |> map(fn: (r) => ({r with _value: %s %s r._value}))\n", fluxNumber(m.scalar), m.op)
+		} else {
+			fmt.Fprintf(&sb, "  |> map(fn: (r) => ({r with _value: r._value %s %s}))\n", m.op, fluxNumber(m.scalar))
+		}
+	}
+	if q.compare != nil {
+		fmt.Fprintf(&sb, "  |> filter(fn: (r) => r._value %s %s)\n", q.compare.op, fluxNumber(q.compare.scalar))
+	}
+	return strings.TrimSuffix(sb.String(), "\n")
+}
+
+func (q *influxQuery) influxQL() (string, error) {
+	if q.compare != nil {
+		return "", errors.New("InfluxQL does not support filtering on values, use --to flux")
+	}
+	if q.windowFn == "last" && q.rate {
+		return "", errors.New("InfluxQL does not support a derivative of last values")
+	}
+
+	timeGroup := ""
+	switch {
+	case q.window == fullRange:
+	case q.window == "":
+		timeGroup = "time($__interval)"
+	default:
+		timeGroup = "time(" + stringValue(q.window) + ")"
+	}
+	groupBy := func(tags ...string) string {
+		parts := make([]string, 0, len(tags)+1)
+		if timeGroup != "" {
+			parts = append(parts, timeGroup)
+		}
+		parts = append(parts, tags...)
+		if len(parts) == 0 {
+			return ""
+		}
+		return " GROUP BY " + strings.Join(parts, ", ")
+	}
+
+	where := "$timeFilter"
+	if len(q.filters) > 0 {
+		where = influxQLFilters(q.filters) + " AND " + where
+	}
+
+	fieldExpr := func(f string) string {
+		e := fmt.Sprintf("%s(%q)", q.windowFn, f)
+		if q.rate {
+			e = "non_negative_derivative(" + e + ", 1s)"
+		}
+		return e
+	}
+
+	var selectExpr, from string
+	if !q.grouped {
+		selectExpr = fieldExpr(q.fields[0])
+		if len(q.fields) > 1 {
+			selectExpr += " " + q.fieldOp + " " + fieldExpr(q.fields[1])
+		}
+		from = fmt.Sprintf("%q WHERE %s%s", q.measurement, where, groupBy("*"))
+	} else {
+		// aggregate each series first, then aggregate across series
+		inner := make([]string, 0, len(q.fields))
+		outer := make([]string, 0, len(q.fields))
+		for i, f := range q.fields {
+			alias := "v" + strconv.Itoa(i)
+			inner = append(inner, fmt.Sprintf("%s AS %q", fieldExpr(f), alias))
+			outer = append(outer, fmt.Sprintf("%s(%q)", q.groupFn, alias))
+		}
+		selectExpr = strings.Join(outer, " "+q.fieldOp+" ")
+		tags := make([]string, 0, len(q.groupBy))
+		for _, g := range q.groupBy {
+			tags = append(tags, strconv.Quote(g))
+		}
+		from = fmt.Sprintf("(SELECT %s FROM %q WHERE %s%s) WHERE $timeFilter%s",
+			strings.Join(inner, ", "), q.measurement, where, groupBy("*"), groupBy(tags...))
+	}
+
+	for _, m := range q.math {
+		if strings.Contains(selectExpr, " ") {
+			selectExpr = "(" + selectExpr + ")"
+		}
+		if m.scalarFirst {
+			selectExpr = m.scalar + " " + m.op + " " + selectExpr
+		} else {
+			selectExpr = selectExpr + " " + m.op + " " + m.scalar
+		}
+	}
+
+	q2 := "SELECT " + selectExpr + " FROM " + from
+	if timeGroup != "" {
+		q2 += " fill(null)"
+	}
+	return q2, nil
+}
@@ -0,0 +1,199 @@
+package grafana
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/netapp/harvest/v2/assert"
+	"github.com/netapp/harvest/v2/third_party/tidwall/gjson"
+)
+
+func newTestConverter(t *testing.T, to string) *converter {
+	t.Helper()
+	objects, err := templateObjects("../../../conf")
+	assert.Nil(t, err)
+	return newConverter(to, objects)
+}
+
+func TestConvertSplit(t *testing.T) {
+	c := newTestConverter(t, toFlux)
+	tests := []struct {
+		metric      string
+		measurement string
+		field       string
+	}{
+		{metric: "volume_read_ops", measurement: "volume", field: "read_ops"},
+		{metric: "svm_vscan_scanner_stats_pct_cpu_used", measurement: "svm_vscan", field: "scanner_stats_pct_cpu_used"},
+		{metric: "aggr_new_status", measurement: "aggr", field: "new_status_code"},
+		{metric: "metadata_collector_api_time", measurement: "metadata_collector", field: "api_time"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.metric, func(t *testing.T) {
+			measurement, field, err := c.split(tt.metric)
+			assert.Nil(t, err)
+			assert.Equal(t, measurement, tt.measurement)
+			assert.Equal(t, field, tt.field)
+		})
+	}
+}
+
+func TestConvertExpressions(t *testing.T) {
+	tests := []struct {
+		name     string
+		expr     string
+		flux     string
+		influxQL string
+	}{
+		{
+			name: "selector",
+			expr: `volume_read_ops{cluster=~"$Cluster",svm="$SVM"}`,
+			flux: `from(bucket: "${Bucket}")
+  |> range(start: v.timeRangeStart, stop: v.timeRangeStop)
+  |> filter(fn: (r) => r["_measurement"] == "volume" and r["_field"] == "read_ops")
+  |> filter(fn: (r) => r["cluster"] =~ /^(${Cluster:regex})$/ and r["svm"] == "${SVM}")
+  |> aggregateWindow(every: v.windowPeriod, fn: mean, createEmpty: false)`,
+			influxQL: `SELECT mean("read_ops") FROM "volume" WHERE "cluster" =~ /^(${Cluster:regex})$/ AND "svm" = '${SVM}' AND $timeFilter GROUP BY time($__interval), * fill(null)`,
+		},
+		{
+			name: "sum of rate",
+			expr: `sum by (cluster) (rate(lif_sent_data{cluster=~"$Cluster"}[4m]))`,
+			flux: `from(bucket: "${Bucket}")
+  |> range(start: v.timeRangeStart, stop: v.timeRangeStop)
+  |> filter(fn: (r) => r["_measurement"] == "lif" and r["_field"] == "sent_data")
+  |> filter(fn: (r) => r["cluster"] =~ /^(${Cluster:regex})$/)
+  |> derivative(unit: 1s, nonNegative: true)
+  |> aggregateWindow(every: v.windowPeriod, fn: mean, createEmpty: false)
+  |> group(columns: ["cluster", "_field"])
+  |> aggregateWindow(every: v.windowPeriod, fn: sum, createEmpty: false)`,
+			influxQL: `SELECT sum("v0") FROM (SELECT non_negative_derivative(mean("sent_data"), 1s) AS "v0" FROM "lif" WHERE "cluster" =~ /^(${Cluster:regex})$/ AND $timeFilter GROUP BY time($__interval), *) WHERE $timeFilter GROUP BY time($__interval), "cluster" fill(null)`,
+		},
+		{
+			name: "percent of two fields",
+			expr: `volume_size_used{cluster=~"$Cluster"} / volume_size_total{cluster=~"$Cluster"} * 100`,
+			flux: `from(bucket: "${Bucket}")
+  |> range(start: v.timeRangeStart, stop: v.timeRangeStop)
+  |> filter(fn: (r) => r["_measurement"] == "volume" and (r["_field"] == "size_used" or r["_field"] == "size_total"))
+  |> filter(fn: (r) => r["cluster"] =~ /^(${Cluster:regex})$/)
+  |> aggregateWindow(every: v.windowPeriod, fn: mean, createEmpty: false)
+  |> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+  |> map(fn: (r) => ({r with _value: r["size_used"] / r["size_total"]}))
+  |> map(fn: (r) => ({r with _value: r._value * 100.0}))`,
+			influxQL: `SELECT (mean("size_used") / mean("size_total")) * 100 FROM "volume" WHERE "cluster" =~ /^(${Cluster:regex})$/ AND $timeFilter GROUP BY time($__interval), * fill(null)`,
+		},
+		{
+			name: "scaled ratio of sums",
+			expr: `100 * sum(aggr_space_used{cluster="$Cluster"}) / sum(aggr_space_total{cluster="$Cluster"})`,
+			flux: `from(bucket: "${Bucket}")
+  |> range(start: v.timeRangeStart, stop: v.timeRangeStop)
+  |> filter(fn: (r) => r["_measurement"] == "aggr" and (r["_field"] == "space_used" or r["_field"] == "space_total"))
+  |> filter(fn: (r) => r["cluster"] == "${Cluster}")
+  |> aggregateWindow(every: v.windowPeriod, fn: mean, createEmpty: false)
+  |> group(columns: ["_field"])
+  |> aggregateWindow(every: v.windowPeriod, fn: sum, createEmpty: false)
+  |> pivot(rowKey: ["_time"], columnKey: ["_field"], valueColumn: "_value")
+  |> map(fn: (r) => ({r with _value: r["space_used"] / r["space_total"]}))
+  |> map(fn: (r) => ({r with _value: 100.0 * r._value}))`,
+			influxQL: `SELECT 100 * (sum("v0") / sum("v1")) FROM (SELECT mean("space_used") AS "v0", mean("space_total") AS "v1" FROM "aggr" WHERE "cluster" = '${Cluster}' AND $timeFilter GROUP BY time($__interval), *) WHERE $timeFilter GROUP BY time($__interval) fill(null)`,
+		},
+		{
+			name: "over time with range",
+			expr: `max_over_time(node_cpu_busy{node="$Node"}[$__range])`,
+			flux: `from(bucket: "${Bucket}")
+  |> range(start: v.timeRangeStart, stop: v.timeRangeStop)
+  |> filter(fn: (r) => r["_measurement"] == "node" and r["_field"] == "cpu_busy")
+  |> filter(fn: (r) => r["node"] == "${Node}")
+  |> max()`,
+			influxQL: `SELECT max("cpu_busy") FROM "node" WHERE "node" = '${Node}' AND $timeFilter GROUP BY *`,
+		},
+	}
+	flux := newTestConverter(t, toFlux)
+	influxQL := newTestConverter(t, toInfluxQL)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := flux.convert(tt.expr, false)
+			assert.Nil(t, err)
+			assert.Equal(t, got, tt.flux)
+
+			got, err = influxQL.convert(tt.expr, false)
+			assert.Nil(t, err)
+			assert.Equal(t, got, tt.influxQL)
+		})
+	}
+}
+
+func TestConvertUnsupported(t *testing.T) {
+	tests := []struct {
+		expr   string
+		reason string
+	}{
+		{expr: `volume_read_ops and topk(5, volume_read_ops)`, reason: "set operator and"},
+		{expr: `topk(5, volume_read_ops)`, reason: "aggregation topk"},
+		{expr: `volume_labels{cluster="a"}`, reason: "label metrics"},
+		{expr: `volume_read_ops / on(cluster) group_left node_cpu_busy`, reason: "vector matching"},
+		{expr: `volume_read_ops / node_cpu_busy`, reason: "same measurement"},
+		{expr: `label_replace(volume_read_ops, "a", "$1", "b", "(.*)")`, reason: "function label_replace"},
+		{expr: `avg_over_time(rate(volume_read_ops[5m])[1h:])`, reason: "expects a range vector selector"},
+		{expr: `volume_read_ops offset 1h`, reason: "offset"},
+	}
+	c := newTestConverter(t, toFlux)
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := c.convert(tt.expr, false)
+			assert.NotNil(t, err)
+			if !strings.Contains(err.Error(), tt.reason) {
+				t.Errorf("got %q want it to contain %q", err.Error(), tt.reason)
+			}
+		})
+	}
+}
+
+func TestConvertDashboard(t *testing.T) {
+	data, err := os.ReadFile("../../../grafana/dashboards/cmode/aggregate.json")
+	assert.Nil(t, err)
+
+	for _, to := range []string{toFlux, toInfluxQL} {
+		t.Run(to, func(t *testing.T) {
+			converted, report := newTestConverter(t, to).convertDashboard(data)
+
+			assert.True(t, report.Converted > 0)
+			assert.Equal(t, report.Converted+len(report.Failures), report.Targets)
+			assert.False(t, strings.Contains(string(converted), "DS_PROMETHEUS"))
+			assert.Equal(t, gjson.GetBytes(converted, "__inputs.0.pluginId").ClonedString(), "influxdb")
+
+			hasBucket := false
+			gjson.GetBytes(converted, "templating.list").ForEach(func(_, value gjson.Result) bool {
+				if value.Get("name").ClonedString() == "Bucket" {
+					hasBucket = true
+				}
+				return true
+			})
+			assert.Equal(t, hasBucket, to == toFlux)
+
+			// converted targets have a query, the others keep their expression and are hidden
+			hidden := 0
+			VisitAllPanels(converted, func(_ string, _ gjson.Result, value gjson.Result) {
+				value.Get("targets").ForEach(func(_, target gjson.Result) bool {
+					if target.Get("expr").Exists() {
+						assert.True(t, target.Get("hide").Bool())
+						hidden++
+					}
+					return true
+				})
+			})
+			assert.Equal(t, hidden, len(report.Failures))
+		})
+	}
+}
+
+func TestConvertAllDashboards(t *testing.T) {
+	c := newTestConverter(t, toFlux)
+	VisitDashboards([]string{"../../../grafana/dashboards/cmode"}, func(path string, data []byte) {
+		_, report := c.convertDashboard(data)
+		for _, f := range report.Failures {
+			if strings.HasPrefix(f.Reason, "unable to parse") {
+				t.Errorf("dashboard=%s panel=%s %s", ShortPath(path), f.Panel, f.Reason)
+			}
+		}
+	})
+}
@@ -76,6 +76,10 @@ type options struct {
 	addMultiSelect      bool
 	svmRegex            string
 	customizeDir        string
+	customizeFormat     string // one of: dir, provisioning, operator, configmap
+	provisioningPath    string
+	namespace           string
+	instanceSelector    string
 	customAllValue      string
 	customCluster       string
 	addCluster          string
@@ -126,10 +130,17 @@ func askForToken() {
 
 func doCustomize(_ *cobra.Command, _ []string) {
 	adjustOptions()
+	validateCustomizeFormat()
 	exitIfExist(opts.customizeDir, "output-dir")
 
 	initImportVars()
 	importDashboards(opts)
+
+	if opts.customizeFormat == formatProvisioning {
+		if err := writeDashboardProviders(); err != nil {
+			printErrorAndExit(err)
+		}
+	}
 }
 
 func doExport(_ *cobra.Command, _ []string) {
@@ -731,7 +742,7 @@ func importFiles(dir string, folder *Folder) {
 		}
 
 		if opts.customizeDir != "" {
-			err := writeCustomDashboard(dashboard, dir, file)
+			err := writeCustomDashboard(dashboard, dir, folder, file)
 			if err != nil {
 				fmt.Printf("error customizing dashboard [%s] %+v\n", file.Name(), err)
 			}
@@ -1148,7 +1159,7 @@ func rewriteCluster(input string, cluster string) string {
 	return clusterRegex.ReplaceAllLiteralString(input, cluster)
 }
 
-func writeCustomDashboard(dashboard map[string]any, dir string, file os.DirEntry) error {
+func writeCustomDashboard(dashboard map[string]any, dir string, folder *Folder, file os.DirEntry) error {
 	buffer := &bytes.Buffer{}
 	encoder := json.NewEncoder(buffer)
 	encoder.SetEscapeHTML(false)
@@ -1164,7 +1175,11 @@ func writeCustomDashboard(dashboard map[string]any, dir string, file os.DirEntry
 		return err
 	}
 	sub := filepath.Base(dir)
-	fp := filepath.Join(opts.customizeDir, sub, file.Name())
+	data, fileName, err := provisionDashboard(data, sub, filepath.Base(folder.name), file.Name())
+	if err != nil {
+		return err
+	}
+	fp := filepath.Join(opts.customizeDir, sub, fileName)
 	if err := os.MkdirAll(filepath.Dir(fp), 0750); err != nil {
 		return fmt.Errorf("error makedir [%s]: %w", filepath.Dir(fp), err)
 	}
@@ -1811,20 +1826,46 @@ var customizeCmd = &cobra.Command{
 	Run:   doCustomize,
 	Example: `
 # Customize all the dashboards recursively contained in grafana/dashboards and write them to ~/harvest-dashboards.
-grafana customize --directory grafana/dashboards --output-dir ~/harvest-dashboards --prefix netapp_ --datasource my_datasource`,
+grafana customize --directory grafana/dashboards --output-dir ~/harvest-dashboards --prefix netapp_ --datasource my_datasource
+
+# Write the dashboards as Grafana Operator GrafanaDashboard resources in the monitoring namespace
+grafana customize --directory grafana/dashboards --output-dir ~/harvest-dashboards --format operator --namespace monitoring`,
 }
 
 func init() {
-	Cmd.AddCommand(importCmd, exportCmd, customizeCmd, metricsCmd)
+	Cmd.AddCommand(importCmd, exportCmd, customizeCmd, metricsCmd, convertCmd, verifyCmd)
 	addCommonFlags(importCmd, exportCmd, customizeCmd)
 	addImportExportFlags(importCmd, exportCmd)
 	addImportCustomizeFlags(importCmd, customizeCmd)
 	addImportFlags(importCmd)
 
+	addImportFlags(customizeCmd)
+
 	customizeCmd.PersistentFlags().StringVarP(&opts.customizeDir, "output-dir", "o", "", "Write customized dashboards to the local directory. The directory must not exist")
+	customizeCmd.PersistentFlags().StringVar(&opts.customizeFormat, "format", formatDir,
+		"Output format: dir (dashboard JSON), provisioning (Grafana provisioning directory), operator (Grafana Operator GrafanaDashboard resources), or configmap (Kubernetes ConfigMaps)")
+	customizeCmd.PersistentFlags().StringVar(&opts.provisioningPath, "provisioning-path", defaultProvisioningPath,
+		"With --format provisioning, the path on the Grafana server where the output directory is copied")
+	customizeCmd.PersistentFlags().StringVar(&opts.namespace, "namespace", "",
+		"With --format operator or configmap, the Kubernetes namespace of the resources")
+	customizeCmd.PersistentFlags().StringVar(&opts.instanceSelector, "instance-selector", defaultInstanceSelector,
+		"With --format operator, the labels of the Grafana instances that receive the dashboards in the format 'key1=value1,key2=value2'")
 
 	metricsCmd.PersistentFlags().StringVarP(&opts.dir, "directory", "d",
 		"", "local directory that contains dashboards (searched recursively).")
+
+	convertCmd.PersistentFlags().StringVar(&convertOpts.to, "to", toFlux, "Query language of the converted dashboards, flux or influxql")
+	convertCmd.PersistentFlags().StringVarP(&convertOpts.dir, "directory", "d", "", "local directory that contains Prometheus dashboards (searched recursively).")
+	convertCmd.PersistentFlags().StringVarP(&convertOpts.outputDir, "output-dir", "o", "", "Write converted dashboards to the local directory. The directory must not exist")
+	convertCmd.PersistentFlags().StringVar(&convertOpts.confDir, "conf", conf.Path("conf"), "Harvest template directory used to map metrics to InfluxDB measurements")
+	_ = convertCmd.MarkPersistentFlagRequired("directory")
+	_ = convertCmd.MarkPersistentFlagRequired("output-dir")
+
+	verifyCmd.PersistentFlags().StringVar(&verifyOpts.config, "config", "./harvest.yml", "harvest config file path")
+	verifyCmd.PersistentFlags().StringSliceVar(&verifyOpts.pollers, "poller", nil, "Verify against the collectors of these pollers. Defaults to all pollers")
+	verifyCmd.PersistentFlags().StringVar(&verifyOpts.version, "ontap-version", "", "ONTAP version used to select templates. Defaults to the newest templates")
+	verifyCmd.PersistentFlags().StringVarP(&verifyOpts.dir, "directory", "d", "", "local directory that contains dashboards (searched recursively).")
+	_ = verifyCmd.MarkPersistentFlagRequired("directory")
 }
 
 func addImportFlags(cmd *cobra.Command) {
@@ -0,0 +1,570 @@
+package grafana
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// A small PromQL parser for the subset of PromQL used by the Harvest dashboards.
+// Grafana variables ($Cluster, ${Interval}, $__range) are accepted wherever an identifier, number, or duration is.
+
+type promNode interface {
+	isNode()
+}
+
+type numberLit struct {
+	value string
+}
+
+type stringLit struct {
+	value string
+}
+
+type labelMatcher struct {
+	name  string
+	op    string // =, !=, =~, !~
+	value string
+}
+
+type vectorSelector struct {
+	metric   string
+	matchers []labelMatcher
+	// rangeDur is the range of a range vector, e.g. 5m in metric[5m]
+	rangeDur string
+	offset   string
+	at       string
+}
+
+type call struct {
+	fn   string
+	args []promNode
+}
+
+type aggregateExpr struct {
+	op      string
+	by      []string
+	without bool
+	param   promNode
+	expr    promNode
+}
+
+type binaryExpr struct {
+	op         string
+	lhs        promNode
+	rhs        promNode
+	returnBool bool
+	// matching is set when the expression has on, ignoring, group_left, or group_right
+	matching bool
+}
+
+type unaryExpr struct {
+	op   string
+	expr promNode
+}
+
+// subquery is an expression evaluated over a range, e.g. rate(metric[5m])[1h:]
+type subquery struct {
+	expr     promNode
+	rangeDur string
+}
+
+func (numberLit) isNode()       {}
+func (stringLit) isNode()       {}
+func (*vectorSelector) isNode() {}
+func (*call) isNode()           {}
+func (*aggregateExpr) isNode()  {}
+func (*binaryExpr) isNode()     {}
+func (*unaryExpr) isNode()      {}
+func (*subquery) isNode()       {}
+
+var aggregateOps = []string{"avg", "bottomk", "count", "count_values", "group", "max", "min", "quantile", "stddev",
+	"stdvar", "sum", "topk"}
+
+type tokenKind int
+
+const (
+	tokEOF tokenKind = iota
+	tokIdent
+	tokNumber
+	tokString
+	tokDuration
+	tokOp
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	// start and end are the rune offsets of the token in the expression
+	start int
+	end   int
+}
+
+type promParser struct {
+	tokens []token
+	pos    int
+	// metrics are the indexes of the tokens that are metric names
+	metrics []int
+	// labelLists are the by, without, on, and ignoring label lists
+	labelLists []labelList
+}
+
+// labelList is a list of labels in parentheses, e.g. by (cluster, node)
+type labelList struct {
+	keyword string
+	labels  []string
+	// closing is the index of the closing parenthesis token
+	closing int
+}
+
+func parsePromQL(expr string) (promNode, error) {
+	n, _, err := parsePromQLTokens(expr)
+	return n, err
+}
+
+func parsePromQLTokens(expr string) (promNode, *promParser, error) {
+	tokens, err := lexPromQL(expr)
+	if err != nil {
+		return nil, nil, err
+	}
+	p := &promParser{tokens: tokens}
+	n, err := p.parseExpr(0)
+	if err != nil {
+		return nil, nil, err
+	}
+	if p.peek().kind != tokEOF {
+		return nil, nil, fmt.Errorf("unexpected %q", p.peek().value)
+	}
+	return n, p, nil
+}
+
+// RelabelPromQL validates a PromQL expression and returns it with prefix added to each metric name, except the
+// metrics in skip, and with labels added to each by and on clause. The rest of the expression is unchanged.
+func RelabelPromQL(expr string, prefix string, labels []string, skip []string) (string, error) {
+	_, p, err := parsePromQLTokens(expr)
+	if err != nil {
+		return "", err
+	}
+
+	type edit struct {
+		start int
+		end   int
+		text  string
+	}
+	var edits []edit
+	if prefix != "" {
+		for _, i := range p.metrics {
+			t := p.tokens[i]
+			if !slices.Contains(skip, t.value) {
+				edits = append(edits, edit{start: t.start, end: t.start, text: prefix})
+			}
+		}
+	}
+	for _, l := range p.labelLists {
+		if l.keyword != "by" && l.keyword != "on" {
+			continue
+		}
+		var missing []string
+		for _, label := range labels {
+			if !slices.Contains(l.labels, label) {
+				missing = append(missing, label)
+			}
+		}
+		if len(missing) == 0 {
+			continue
+		}
+		text := strings.Join(missing, ", ")
+		if len(l.labels) > 0 {
+			text = ", " + text
+		}
+		closing := p.tokens[l.closing].start
+		edits = append(edits, edit{start: closing, end: closing, text: text})
+	}
+
+	runes := []rune(expr)
+	slices.SortFunc(edits, func(a, b edit) int { return b.start - a.start })
+	for _, e := range edits {
+		runes = slices.Concat(runes[:e.start], []rune(e.text), runes[e.end:])
+	}
+	relabeled := string(runes)
+	if _, err := parsePromQL(relabeled); err != nil {
+		return "", fmt.Errorf("invalid expression %s: %w", relabeled, err)
+	}
+	return relabeled, nil
+}
+
+func isIdentChar(r rune) bool {
+	return r == '_' || r == ':' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func lexPromQL(s string) ([]token, error) {
+	var tokens []token
+	runes := []rune(s)
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+		case r == '#':
+			for i < len(runes) && runes[i] != '\n' {
+				i++
+			}
+		case r == '"' || r == '\'' || r == '`':
+			j := i + 1
+			var sb strings.Builder
+			for ; j < len(runes) && runes[j] != r; j++ {
+				if runes[j] == '\\' && r != '`' && j+1 < len(runes) {
+					j++
+					if runes[j] != r && runes[j] != '\\' {
+						sb.WriteRune('\\')
+					}
+				}
+				sb.WriteRune(runes[j])
+			}
+			if j >= len(runes) {
+				return nil, errors.New("unterminated string")
+			}
+			tokens = append(tokens, token{kind: tokString, value: sb.String(), start: i, end: j + 1})
+			i = j + 1
+		case r == '[':
+			j := i + 1
+			for j < len(runes) && runes[j] != ']' {
+				j++
+			}
+			if j >= len(runes) {
+				return nil, errors.New("unterminated range")
+			}
+			tokens = append(tokens, token{kind: tokDuration, value: strings.TrimSpace(string(runes[i+1 : j])), start: i, end: j + 1})
+			i = j + 1
+		case unicode.IsDigit(r) || (r == '.' && i+1 < len(runes) && unicode.IsDigit(runes[i+1])):
+			j := i
+			// durations, e.g. offset 5m, are lexed as numbers
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || runes[j] == '.' || unicode.IsLetter(runes[j]) ||
+				((runes[j] == '+' || runes[j] == '-') && runes[j-1] == 'e')) {
+				j++
+			}
+			tokens = append(tokens, token{kind: tokNumber, value: string(runes[i:j]), start: i, end: j})
+			i = j
+		case isIdentChar(r) || r == '$':
+			j := i
+			for j < len(runes) {
+				if isIdentChar(runes[j]) {
+					j++
+					continue
+				}
+				// Grafana variables, $Var or ${Var} or ${Var:format}
+				if runes[j] == '$' {
+					j++
+					if j < len(runes) && runes[j] == '{' {
+						for j < len(runes) && runes[j] != '}' {
+							j++
+						}
+						j++
+					}
+					continue
+				}
+				break
+			}
+			value := string(runes[i:j])
+			kind := tokIdent
+			if strings.HasPrefix(value, "$") {
+				// a variable on its own, e.g. $TopResources, is a scalar
+				kind = tokNumber
+			}
+			tokens = append(tokens, token{kind: kind, value: value, start: i, end: j})
+			i = j
+		default:
+			op := string(r)
+			if i+1 < len(runes) {
+				two := string(runes[i : i+2])
+				if slices.Contains([]string{"==", "!=", "=~", "!~", ">=", "<="}, two) {
+					op = two
+				}
+			}
+			if !strings.Contains("+-*/%^=!<>(){},@", string(r)) {
+				return nil, fmt.Errorf("unexpected character %q", r)
+			}
+			tokens = append(tokens, token{kind: tokOp, value: op, start: i, end: i + len(op)})
+			i += len(op)
+		}
+	}
+	return append(tokens, token{kind: tokEOF, start: len(runes), end: len(runes)}), nil
+}
+
+func (p *promParser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *promParser) next() token {
+	t := p.tokens[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *promParser) expect(value string) error {
+	t := p.next()
+	if t.value != value {
+		return fmt.Errorf("expected %q got %q", value, t.value)
+	}
+	return nil
+}
+
+func precedence(t token) int {
+	if t.kind != tokOp && t.kind != tokIdent {
+		return 0
+	}
+	switch t.value {
+	case "or":
+		return 1
+	case "and", "unless":
+		return 2
+	case "==", "!=", ">", "<", ">=", "<=":
+		return 3
+	case "+", "-":
+		return 4
+	case "*", "/", "%":
+		return 5
+	case "^":
+		return 6
+	}
+	return 0
+}
+
+func (p *promParser) parseExpr(minPrec int) (promNode, error) {
+	lhs, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		t := p.peek()
+		prec := precedence(t)
+		if prec == 0 || prec <= minPrec && !(t.value == "^" && prec == minPrec) {
+			return lhs, nil
+		}
+		p.next()
+		b := &binaryExpr{op: t.value, lhs: lhs}
+		if p.peek().value == "bool" {
+			p.next()
+			b.returnBool = true
+		}
+		for slices.Contains([]string{"on", "ignoring", "group_left", "group_right"}, p.peek().value) {
+			keyword := p.next().value
+			b.matching = true
+			if p.peek().value == "(" {
+				if _, err := p.parseLabelList(keyword); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if b.rhs, err = p.parseExpr(prec); err != nil {
+			return nil, err
+		}
+		lhs = b
+	}
+}
+
+func (p *promParser) parseUnary() (promNode, error) {
+	if t := p.peek(); t.kind == tokOp && (t.value == "-" || t.value == "+") {
+		p.next()
+		expr, err := p.parseExpr(5)
+		if err != nil {
+			return nil, err
+		}
+		return &unaryExpr{op: t.value, expr: expr}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *promParser) parsePrimary() (promNode, error) {
+	n, err := p.parseOperand()
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := n.(*vectorSelector); !ok && p.peek().kind == tokDuration {
+		s := &subquery{expr: n, rangeDur: p.next().value}
+		// modifiers of the subquery are not needed by the converter
+		for p.peek().value == "offset" || p.peek().value == "@" {
+			p.next()
+			p.next()
+			if p.peek().value == "(" {
+				if _, err := p.parseArgs(); err != nil {
+					return nil, err
+				}
+			}
+		}
+		return s, nil
+	}
+	return n, nil
+}
+
+func (p *promParser) parseOperand() (promNode, error) {
+	t := p.next()
+	switch t.kind {
+	case tokNumber:
+		return numberLit{value: t.value}, nil
+	case tokString:
+		return stringLit{value: t.value}, nil
+	case tokOp:
+		switch t.value {
+		case "(":
+			n, err := p.parseExpr(0)
+			if err != nil {
+				return nil, err
+			}
+			return n, p.expect(")")
+		case "{":
+			p.pos--
+			return p.parseSelector("")
+		}
+	case tokIdent:
+		if slices.Contains(aggregateOps, t.value) {
+			return p.parseAggregate(t.value)
+		}
+		if p.peek().value == "(" {
+			return p.parseCall(t.value)
+		}
+		p.metrics = append(p.metrics, p.pos-1)
+		return p.parseSelector(t.value)
+	default:
+	}
+	return nil, fmt.Errorf("unexpected %q", t.value)
+}
+
+func (p *promParser) parseLabels() ([]string, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var labels []string
+	for p.peek().value != ")" {
+		t := p.next()
+		if t.kind != tokIdent {
+			return nil, fmt.Errorf("expected label got %q", t.value)
+		}
+		labels = append(labels, t.value)
+		if p.peek().value == "," {
+			p.next()
+		}
+	}
+	p.next()
+	return labels, nil
+}
+
+// parseLabelList parses the label list of a keyword, e.g. by (cluster, node), and records its position
+func (p *promParser) parseLabelList(keyword string) ([]string, error) {
+	labels, err := p.parseLabels()
+	if err != nil {
+		return nil, err
+	}
+	p.labelLists = append(p.labelLists, labelList{keyword: keyword, labels: labels, closing: p.pos - 1})
+	return labels, nil
+}
+
+func (p *promParser) parseArgs() ([]promNode, error) {
+	if err := p.expect("("); err != nil {
+		return nil, err
+	}
+	var args []promNode
+	for p.peek().value != ")" {
+		arg, err := p.parseExpr(0)
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if p.peek().value == "," {
+			p.next()
+		} else if p.peek().value != ")" {
+			return nil, fmt.Errorf("expected , or ) got %q", p.peek().value)
+		}
+	}
+	p.next()
+	return args, nil
+}
+
+func (p *promParser) parseCall(fn string) (promNode, error) {
+	args, err := p.parseArgs()
+	if err != nil {
+		return nil, err
+	}
+	return &call{fn: fn, args: args}, nil
+}
+
+func (p *promParser) parseAggregate(op string) (promNode, error) {
+	a := &aggregateExpr{op: op}
+	var err error
+	modifier := func() error {
+		if v := p.peek().value; v == "by" || v == "without" {
+			p.next()
+			a.without = v == "without"
+			a.by, err = p.parseLabelList(v)
+			if a.by == nil {
+				a.by = []string{}
+			}
+		}
+		return err
+	}
+	if err := modifier(); err != nil {
+		return nil, err
+	}
+	args, err := p.parseArgs()
+	if err != nil {
+		return nil, err
+	}
+	if err := modifier(); err != nil {
+		return nil, err
+	}
+	switch len(args) {
+	case 1:
+		a.expr = args[0]
+	case 2:
+		a.param, a.expr = args[0], args[1]
+	default:
+		return nil, fmt.Errorf("%s expects one or two arguments", op)
+	}
+	return a, nil
+}
+
+func (p *promParser) parseSelector(metric string) (promNode, error) {
+	s := &vectorSelector{metric: metric}
+	if p.peek().value == "{" {
+		p.next()
+		for p.peek().value != "}" {
+			name := p.next()
+			op := p.next()
+			value := p.next()
+			if name.kind != tokIdent || value.kind != tokString || !slices.Contains([]string{"=", "!=", "=~", "!~"}, op.value) {
+				return nil, fmt.Errorf("invalid label matcher %s%s%s", name.value, op.value, value.value)
+			}
+			s.matchers = append(s.matchers, labelMatcher{name: name.value, op: op.value, value: value.value})
+			if p.peek().value == "," {
+				p.next()
+			}
+		}
+		p.next()
+	}
+	for {
+		switch t := p.peek(); {
+		case t.kind == tokDuration:
+			p.next()
+			s.rangeDur = t.value
+		case t.value == "offset":
+			p.next()
+			s.offset = p.next().value
+		case t.value == "@":
+			p.next()
+			at := p.next().value
+			if p.peek().value == "(" {
+				if _, err := p.parseArgs(); err != nil {
+					return nil, err
+				}
+				at += "()"
+			}
+			s.at = at
+		default:
+			return s, nil
+		}
+	}
+}
@@ -0,0 +1,37 @@
+package grafana
+
+import (
+	"testing"
+
+	"github.com/netapp/harvest/v2/assert"
+)
+
+func TestRelabelPromQL(t *testing.T) {
+	tests := []struct {
+		name   string
+		expr   string
+		prefix string
+		labels []string
+		want   string
+	}{
+		{name: "unchanged", expr: `volume_read_ops{svm="a"} > 5`, want: `volume_read_ops{svm="a"} > 5`},
+		{name: "prefix", expr: `rate(volume_read_ops{svm="volume_read_ops"}[5m]) / up`, prefix: "ntap_",
+			want: `rate(ntap_volume_read_ops{svm="volume_read_ops"}[5m]) / up`},
+		{name: "by", expr: `sum by (cluster) (volume_read_ops) > on (cluster) group_left () node_cpu_busy`, labels: []string{"org", "cluster"},
+			want: `sum by (cluster, org) (volume_read_ops) > on (cluster, org) group_left () node_cpu_busy`},
+		{name: "empty by and without", expr: `sum by () (a) + sum without (node) (b) + ignoring (node) c`, labels: []string{"org"},
+			want: `sum by (org) (a) + sum without (node) (b) + ignoring (node) c`},
+		{name: "prefix and labels", expr: `topk(5, sum(a) by (node))`, prefix: "p_", labels: []string{"org"},
+			want: `topk(5, sum(p_a) by (node, org))`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RelabelPromQL(tt.expr, tt.prefix, tt.labels, []string{"up"})
+			assert.Nil(t, err)
+			assert.Equal(t, got, tt.want)
+		})
+	}
+
+	_, err := RelabelPromQL(`sum(volume_read_ops`, "", nil, nil)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,201 @@
+package grafana
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+)
+
+// Output formats of grafana customize
+const (
+	formatDir          = "dir"
+	formatProvisioning = "provisioning"
+	formatOperator     = "operator"
+	formatConfigMap    = "configmap"
+)
+
+var customizeFormats = []string{formatDir, formatProvisioning, formatOperator, formatConfigMap}
+
+const (
+	// providersFile is the dashboard providers file written for the provisioning format
+	providersFile           = "harvest-dashboards.yaml"
+	defaultProvisioningPath = "/var/lib/grafana/dashboards/harvest"
+	defaultInstanceSelector = "dashboards=grafana"
+)
+
+// provisionedFolders maps each customized dashboard directory to its Grafana folder
+var provisionedFolders = make(map[string]string)
+
+type dashboardProviders struct {
+	APIVersion int                 `yaml:"apiVersion"`
+	Providers  []dashboardProvider `yaml:"providers"`
+}
+
+type dashboardProvider struct {
+	Name                  string          `yaml:"name"`
+	OrgID                 int             `yaml:"orgId"`
+	Folder                string          `yaml:"folder"`
+	Type                  string          `yaml:"type"`
+	DisableDeletion       bool            `yaml:"disableDeletion"`
+	AllowUIUpdates        bool            `yaml:"allowUiUpdates"`
+	UpdateIntervalSeconds int             `yaml:"updateIntervalSeconds"`
+	Options               providerOptions `yaml:"options"`
+}
+
+type providerOptions struct {
+	Path string `yaml:"path"`
+}
+
+type k8sMetadata struct {
+	Name        string            `yaml:"name"`
+	Namespace   string            `yaml:"namespace,omitempty"`
+	Labels      map[string]string `yaml:"labels,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+}
+
+type grafanaDashboardCR struct {
+	APIVersion string               `yaml:"apiVersion"`
+	Kind       string               `yaml:"kind"`
+	Metadata   k8sMetadata          `yaml:"metadata"`
+	Spec       grafanaDashboardSpec `yaml:"spec"`
+}
+
+type grafanaDashboardSpec struct {
+	Folder           string           `yaml:"folder"`
+	InstanceSelector instanceSelector `yaml:"instanceSelector"`
+	JSON             string           `yaml:"json"`
+}
+
+type instanceSelector struct {
+	MatchLabels map[string]string `yaml:"matchLabels"`
+}
+
+type configMap struct {
+	APIVersion string            `yaml:"apiVersion"`
+	Kind       string            `yaml:"kind"`
+	Metadata   k8sMetadata       `yaml:"metadata"`
+	Data       map[string]string `yaml:"data"`
+}
+
+func validateCustomizeFormat() {
+	if !slices.Contains(customizeFormats, opts.customizeFormat) {
+		fmt.Printf("error: --format must be one of %s, got %s\n", strings.Join(customizeFormats, ", "), opts.customizeFormat)
+		os.Exit(1)
+	}
+	if opts.customizeFormat == formatOperator {
+		if _, err := parseInstanceSelector(opts.instanceSelector); err != nil {
+			printErrorAndExit(err)
+		}
+	}
+}
+
+// provisionDashboard converts a customized dashboard into the file contents and file name of the output format
+func provisionDashboard(data []byte, sub string, folder string, fileName string) ([]byte, string, error) {
+	stem := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+
+	switch opts.customizeFormat {
+	case formatProvisioning:
+		provisionedFolders[sub] = folder
+		return data, fileName, nil
+	case formatOperator:
+		matchLabels, err := parseInstanceSelector(opts.instanceSelector)
+		if err != nil {
+			return nil, "", err
+		}
+		cr := grafanaDashboardCR{
+			APIVersion: "grafana.integreatly.org/v1beta1",
+			Kind:       "GrafanaDashboard",
+			Metadata:   k8sMetadata{Name: k8sName("harvest", sub, stem), Namespace: opts.namespace},
+			Spec: grafanaDashboardSpec{
+				Folder:           folder,
+				InstanceSelector: instanceSelector{MatchLabels: matchLabels},
+				JSON:             string(data),
+			},
+		}
+		out, err := marshalK8s(cr)
+		return out, stem + ".yaml", err
+	case formatConfigMap:
+		cm := configMap{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+			Metadata: k8sMetadata{
+				Name:      k8sName("harvest", sub, stem),
+				Namespace: opts.namespace,
+				// the label and annotation the Grafana Helm chart's dashboard sidecar uses by default
+				Labels:      map[string]string{"grafana_dashboard": "1"},
+				Annotations: map[string]string{"grafana_folder": folder},
+			},
+			Data: map[string]string{fileName: string(data)},
+		}
+		out, err := marshalK8s(cm)
+		return out, stem + ".yaml", err
+	}
+	return data, fileName, nil
+}
+
+// writeDashboardProviders writes the providers file that points Grafana at the provisioned dashboards,
+// one provider and folder per dashboard directory
+func writeDashboardProviders() error {
+	subs := make([]string, 0, len(provisionedFolders))
+	for sub := range provisionedFolders {
+		subs = append(subs, sub)
+	}
+	slices.Sort(subs)
+
+	providers := dashboardProviders{APIVersion: 1}
+	for _, sub := range subs {
+		providers.Providers = append(providers.Providers, dashboardProvider{
+			Name:                  provisionedFolders[sub],
+			OrgID:                 opts.orgID,
+			Folder:                provisionedFolders[sub],
+			Type:                  "file",
+			UpdateIntervalSeconds: 30,
+			Options:               providerOptions{Path: strings.TrimSuffix(opts.provisioningPath, "/") + "/" + sub},
+		})
+	}
+	data, err := yaml.Marshal(providers)
+	if err != nil {
+		return err
+	}
+	fp := filepath.Join(opts.customizeDir, providersFile)
+	if err := os.WriteFile(fp, data, GPerm); err != nil {
+		return fmt.Errorf("error writing dashboard providers to file %s: %w", fp, err)
+	}
+	fmt.Printf("OK - dashboard providers [%s]\n", fp)
+	return nil
+}
+
+func marshalK8s(v any) ([]byte, error) {
+	return yaml.MarshalWithOptions(v, yaml.UseLiteralStyleIfMultiline(true))
+}
+
+// parseInstanceSelector parses labels in the format 'key1=value1,key2=value2'
+func parseInstanceSelector(s string) (map[string]string, error) {
+	labels := make(map[string]string)
+	for pair := range strings.SplitSeq(s, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf("error: invalid --instance-selector [%s]. Expected format is 'key1=value1,key2=value2'", s)
+		}
+		labels[key] = strings.TrimSpace(value)
+	}
+	return labels, nil
+}
+
+var k8sNameRe = regexp.MustCompile(`[^a-z0-9]+`)
+
+// k8sName returns a valid Kubernetes resource name, a lowercase RFC 1123 subdomain
+func k8sName(parts ...string) string {
+	name := k8sNameRe.ReplaceAllString(strings.ToLower(strings.Join(parts, "-")), "-")
+	name = strings.Trim(name, "-")
+	if len(name) > 253 {
+		name = strings.TrimRight(name[:253], "-")
+	}
+	return name
+}
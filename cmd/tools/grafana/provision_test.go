@@ -0,0 +1,92 @@
+package grafana
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/goccy/go-yaml"
+	"github.com/netapp/harvest/v2/assert"
+)
+
+func TestProvisionDashboard(t *testing.T) {
+	dashboard := []byte("{\n  \"title\": \"ONTAP: Aggregate\"\n}")
+	saved := *opts
+	t.Cleanup(func() { *opts = saved })
+	opts.namespace = "monitoring"
+	opts.instanceSelector = "dashboards=grafana,env=prod"
+
+	t.Run("operator", func(t *testing.T) {
+		opts.customizeFormat = formatOperator
+		data, fileName, err := provisionDashboard(dashboard, "cmode", "Harvest-main-cDOT", "aggregate.json")
+		assert.Nil(t, err)
+		assert.Equal(t, fileName, "aggregate.yaml")
+
+		var cr grafanaDashboardCR
+		assert.Nil(t, yaml.Unmarshal(data, &cr))
+		assert.Equal(t, cr.Kind, "GrafanaDashboard")
+		assert.Equal(t, cr.Metadata.Name, "harvest-cmode-aggregate")
+		assert.Equal(t, cr.Metadata.Namespace, "monitoring")
+		assert.Equal(t, cr.Spec.Folder, "Harvest-main-cDOT")
+		assert.Equal(t, cr.Spec.InstanceSelector.MatchLabels, map[string]string{"dashboards": "grafana", "env": "prod"})
+		assert.Equal(t, cr.Spec.JSON, string(dashboard))
+	})
+
+	t.Run("configmap", func(t *testing.T) {
+		opts.customizeFormat = formatConfigMap
+		data, fileName, err := provisionDashboard(dashboard, "cmode-details", "Harvest-main-cDOT Details", "volumeBySVM.json")
+		assert.Nil(t, err)
+		assert.Equal(t, fileName, "volumeBySVM.yaml")
+
+		var cm configMap
+		assert.Nil(t, yaml.Unmarshal(data, &cm))
+		assert.Equal(t, cm.Kind, "ConfigMap")
+		assert.Equal(t, cm.Metadata.Name, "harvest-cmode-details-volumebysvm")
+		assert.Equal(t, cm.Metadata.Labels["grafana_dashboard"], "1")
+		assert.Equal(t, cm.Metadata.Annotations["grafana_folder"], "Harvest-main-cDOT Details")
+		assert.Equal(t, cm.Data["volumeBySVM.json"], string(dashboard))
+	})
+
+	t.Run("provisioning", func(t *testing.T) {
+		opts.customizeFormat = formatProvisioning
+		opts.customizeDir = t.TempDir()
+		opts.provisioningPath = "/etc/dashboards/"
+		opts.orgID = 2
+		clear(provisionedFolders)
+
+		data, fileName, err := provisionDashboard(dashboard, "cmode", "Harvest-main-cDOT", "aggregate.json")
+		assert.Nil(t, err)
+		assert.Equal(t, fileName, "aggregate.json")
+		assert.Equal(t, string(data), string(dashboard))
+		_, _, err = provisionDashboard(dashboard, "cisco", "Harvest-main-Cisco", "cisco.json")
+		assert.Nil(t, err)
+
+		assert.Nil(t, writeDashboardProviders())
+		raw, err := os.ReadFile(filepath.Join(opts.customizeDir, providersFile))
+		assert.Nil(t, err)
+		var providers dashboardProviders
+		assert.Nil(t, yaml.Unmarshal(raw, &providers))
+		assert.Equal(t, providers.APIVersion, 1)
+		assert.Equal(t, len(providers.Providers), 2)
+		assert.Equal(t, providers.Providers[0].Folder, "Harvest-main-Cisco")
+		assert.Equal(t, providers.Providers[0].Options.Path, "/etc/dashboards/cisco")
+		assert.Equal(t, providers.Providers[1].Options.Path, "/etc/dashboards/cmode")
+		assert.Equal(t, providers.Providers[1].OrgID, 2)
+	})
+}
+
+func TestParseInstanceSelector(t *testing.T) {
+	labels, err := parseInstanceSelector("dashboards=grafana")
+	assert.Nil(t, err)
+	assert.Equal(t, labels, map[string]string{"dashboards": "grafana"})
+
+	_, err = parseInstanceSelector("dashboards")
+	assert.NotNil(t, err)
+	_, err = parseInstanceSelector("")
+	assert.NotNil(t, err)
+}
+
+func TestK8sName(t *testing.T) {
+	assert.Equal(t, k8sName("harvest", "cmode", "nfs4storePool"), "harvest-cmode-nfs4storepool")
+	assert.Equal(t, k8sName("harvest", "cmode", "S3 Object_Storage"), "harvest-cmode-s3-object-storage")
+}
@@ -0,0 +1,509 @@
+package grafana
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/netapp/harvest/v2/cmd/poller/collector"
+	"github.com/netapp/harvest/v2/cmd/tools"
+	template2 "github.com/netapp/harvest/v2/cmd/tools/template"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/template"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	goversion "github.com/netapp/harvest/v2/third_party/go-version"
+	"github.com/netapp/harvest/v2/third_party/tidwall/gjson"
+	"github.com/spf13/cobra"
+)
+
+var verifyOpts = struct {
+	config  string
+	pollers []string
+	version string
+	dir     string
+}{}
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "verify dashboard queries use metrics the configured collectors export",
+	Run:   doVerify,
+	Example: `
+# Verify the cmode dashboards against the collectors of the pollers in harvest.yml for ONTAP 9.14.1
+grafana verify --directory grafana/dashboards/cmode --config harvest.yml --ontap-version 9.14.1`,
+}
+
+// counter files that document the metrics created by collector plugins
+var counterFiles = []string{"counter.yaml", "storagegrid_counter.yaml", "cisco_counter.yaml", "arista_counter.yaml",
+	"eseries_counter.yaml"}
+
+// counterFileAPIs maps the APIs of the counter files to collector template directories
+var counterFileAPIs = map[string]string{
+	"rest":     "rest",
+	"zapi":     "zapi",
+	"restperf": "restperf",
+	"zapiperf": "zapiperf",
+	"keyperf":  "keyperf",
+	"statperf": "statperf",
+	"nxapi":    "ciscorest",
+	"eapi":     "aristarest",
+}
+
+// metrics the poller exports for every collector
+var pollerMetricPrefixes = []string{"metadata_", "poller_"}
+
+// template models, the directories between a collector's directory and its version directories
+var templateModels = []string{"", conf.CDOT, "nxos", "eos"}
+
+// metricCatalog is the set of metrics a set of collectors can export
+type metricCatalog struct {
+	metrics map[string]struct{}
+	// templates maps each collector template directory, e.g. rest, to the template files it loads
+	templates map[string]map[string]struct{}
+}
+
+type emptyPanel struct {
+	Title   string
+	Row     string
+	Missing []string
+}
+
+func doVerify(_ *cobra.Command, _ []string) {
+	if _, err := conf.LoadHarvestConfig(verifyOpts.config); err != nil {
+		printErrorAndExit(err)
+	}
+	pollers, err := selectPollers(verifyOpts.pollers)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+	catalog, err := newMetricCatalog(conf.Path(""), pollers, verifyOpts.version)
+	if err != nil {
+		printErrorAndExit(err)
+	}
+
+	failed := 0
+	VisitDashboards([]string{verifyOpts.dir}, func(path string, data []byte) {
+		empty := catalog.emptyPanels(data)
+		if len(empty) == 0 {
+			fmt.Printf("OK - %s\n", ShortPath(path))
+			return
+		}
+		failed++
+		fmt.Printf("FAIL - %s\n", ShortPath(path))
+		for _, e := range empty {
+			fmt.Printf("  %s / %s: missing %s\n", e.Row, e.Title, strings.Join(e.Missing, ", "))
+		}
+	})
+	if failed > 0 {
+		fmt.Printf("%d dashboards have panels that will always be empty\n", failed)
+		os.Exit(1)
+	}
+}
+
+func selectPollers(names []string) ([]*conf.Poller, error) {
+	if len(names) == 0 {
+		names = conf.Config.PollersOrdered
+	}
+	pollers := make([]*conf.Poller, 0, len(names))
+	for _, name := range names {
+		p, err := conf.PollerNamed(name)
+		if err != nil {
+			return nil, err
+		}
+		pollers = append(pollers, p)
+	}
+	return pollers, nil
+}
+
+// newMetricCatalog returns the metrics the collectors of pollers export. The templates are resolved the same way
+// the poller resolves them, using ontapVersion for ONTAP collectors and the newest templates otherwise.
+func newMetricCatalog(homePath string, pollers []*conf.Poller, ontapVersion string) (*metricCatalog, error) {
+	c := &metricCatalog{metrics: make(map[string]struct{}), templates: make(map[string]map[string]struct{})}
+
+	var ver *goversion.Version
+	if ontapVersion != "" {
+		v, err := goversion.NewVersion(ontapVersion)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ONTAP version %s: %w", ontapVersion, err)
+		}
+		ver = v
+	}
+
+	for _, p := range pollers {
+		confPath := p.ConfPath
+		if confPath == "" {
+			confPath = conf.DefaultConfPath
+		}
+		confDirs := make([]string, 0)
+		for cp := range strings.SplitSeq(confPath, ":") {
+			confDirs = append(confDirs, filepath.Join(homePath, cp))
+		}
+		for _, col := range p.Collectors {
+			if err := c.addCollector(confDirs, col, ver); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := c.addPluginMetrics(homePath); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *metricCatalog) addCollector(confDirs []string, col conf.Collector, ver *goversion.Version) error {
+	templates := conf.DefaultTemplates
+	if col.Templates != nil {
+		templates = col.Templates
+	}
+
+	for _, t := range *templates {
+		fp := findInConfDirs(confDirs, strings.ToLower(col.Name), t)
+		if fp == "" {
+			continue
+		}
+		root, err := tree.ImportYaml(fp)
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", fp, err)
+		}
+		objects := root.GetChildS("objects")
+		if objects == nil {
+			continue
+		}
+		for _, o := range objects.GetChildren() {
+			for name := range strings.SplitSeq(o.GetContentS(), ",") {
+				className := col.Name
+				if refClass, refName, ok := collector.ParseTemplateRef(name); ok {
+					className, name = refClass, refName
+				}
+				if err := c.addTemplate(confDirs, className, strings.TrimSpace(name), ver); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func findInConfDirs(confDirs []string, elem ...string) string {
+	for _, dir := range confDirs {
+		fp := filepath.Join(append([]string{dir}, elem...)...)
+		if _, err := os.Stat(fp); err == nil {
+			return fp
+		}
+	}
+	return ""
+}
+
+func (c *metricCatalog) addTemplate(confDirs []string, className string, name string, ver *goversion.Version) error {
+	classDir := strings.ToLower(className)
+	if _, isONTAP := conf.IsONTAPCollector[className]; !isONTAP && className != "KeyPerf" && className != "StatPerf" {
+		ver = nil
+	}
+	fp := bestFitTemplate(confDirs, classDir, name, ver)
+	if fp == "" {
+		return nil
+	}
+	if c.templates[classDir] == nil {
+		c.templates[classDir] = make(map[string]struct{})
+	}
+	c.templates[classDir][name] = struct{}{}
+
+	metrics, err := templateMetrics(className, fp)
+	if err != nil {
+		return err
+	}
+	for _, m := range metrics {
+		c.metrics[m] = struct{}{}
+	}
+	return nil
+}
+
+var versionDirRe = regexp.MustCompile(`^\d+\.\d+\.\d+$`)
+
+// bestFitTemplate returns the path of the template version closest to, without exceeding, ver.
+// When ver is nil, or older than all versions, the newest or oldest template is returned respectively.
+func bestFitTemplate(confDirs []string, classDir string, name string, ver *goversion.Version) string {
+	for _, dir := range confDirs {
+		for _, model := range templateModels {
+			modelDir := filepath.Join(dir, classDir, model)
+			entries, err := os.ReadDir(modelDir)
+			if err != nil {
+				continue
+			}
+			var versions []*goversion.Version
+			for _, e := range entries {
+				if !e.IsDir() || !versionDirRe.MatchString(e.Name()) {
+					continue
+				}
+				if _, err := os.Stat(filepath.Join(modelDir, e.Name(), name)); err != nil {
+					continue
+				}
+				if v, err := goversion.NewVersion(e.Name()); err == nil {
+					versions = append(versions, v)
+				}
+			}
+			if len(versions) == 0 {
+				continue
+			}
+			slices.SortFunc(versions, func(a, b *goversion.Version) int { return a.Compare(b) })
+			selected := versions[len(versions)-1]
+			if ver != nil {
+				selected = versions[0]
+				for _, v := range versions {
+					if v.LessThanOrEqual(ver) {
+						selected = v
+					}
+				}
+			}
+			return filepath.Join(modelDir, selected.Original(), name)
+		}
+	}
+	return ""
+}
+
+// templateMetrics returns the names of the metrics a template exports, including the metrics created by the
+// built-in plugins
+func templateMetrics(className string, path string) ([]string, error) {
+	t, err := tree.ImportYaml(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template %s: %w", path, err)
+	}
+	object := t.GetChildContentS("object")
+	if object == "" {
+		return nil, nil
+	}
+
+	var displays []string
+	addCounters := func(counters *node.Node) {
+		visitCounters(counters, nil, func(raw string, parents []string) {
+			name, display, kind, _ := template.ParseMetric(raw)
+			if kind != "float" {
+				return
+			}
+			if className == "Zapi" && !strings.Contains(raw, "=>") {
+				display = template.ParseZAPIDisplay(object, append(slices.Clone(parents), name))
+			}
+			displays = append(displays, display)
+		})
+	}
+	addCounters(t.GetChildS("counters"))
+	if endpoints := t.GetChildS("endpoints"); endpoints != nil {
+		for _, e := range endpoints.GetChildren() {
+			addCounters(e.GetChildS("counters"))
+		}
+	}
+
+	metrics := make([]string, 0, len(displays)+1)
+	// the plugins of templates that do not export their own data still see the counters
+	if t.GetChildContentS("export_data") != "false" {
+		for _, d := range displays {
+			metrics = append(metrics, object+"_"+d)
+		}
+	}
+	if exportOptions := t.GetChildS("export_options"); exportOptions != nil {
+		if exportOptions.GetChildS("instance_labels") != nil || exportOptions.GetChildContentS("include_all_labels") == "true" {
+			metrics = append(metrics, object+"_labels")
+		}
+	}
+
+	model, err := template2.BuiltInPluginMetrics(t)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read plugins of template %s: %w", path, err)
+	}
+	for _, m := range model.PluginMetrics {
+		metrics = append(metrics, object+"_"+m.Name)
+	}
+	for _, m := range model.MultiplierMetrics {
+		for _, d := range displays {
+			switch {
+			case m.IsMax:
+				metrics = append(metrics, m.Name+"_"+d)
+			case m.HasCustomName:
+				metrics = append(metrics, m.Source+"_"+d)
+			default:
+				metrics = append(metrics, m.Name+"_"+object+"_"+d)
+			}
+		}
+	}
+	return metrics, nil
+}
+
+// visitCounters calls fn with each counter of a template and the names of its parents, e.g. the ZAPI path
+func visitCounters(n *node.Node, parents []string, fn func(raw string, parents []string)) {
+	if n == nil {
+		return
+	}
+	for _, child := range n.GetChildren() {
+		name := child.GetNameS()
+		if name == "hidden_fields" || name == "filter" {
+			continue
+		}
+		if content := child.GetContentS(); content != "" && len(child.GetChildren()) == 0 {
+			fn(content, parents)
+			continue
+		}
+		childParents := parents
+		if name != "" {
+			childParents = append(slices.Clone(parents), name)
+		}
+		visitCounters(child, childParents, fn)
+	}
+}
+
+// addPluginMetrics adds the metrics documented in the counter files that are created by the plugins of the
+// loaded templates or by the collectors themselves
+func (c *metricCatalog) addPluginMetrics(homePath string) error {
+	for _, f := range counterFiles {
+		fp := filepath.Join(homePath, "cmd", "tools", "generate", f)
+		data, err := os.ReadFile(fp)
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+			return err
+		}
+		var counters tools.Counters
+		if err := yaml.Unmarshal(data, &counters); err != nil {
+			return fmt.Errorf("failed to parse %s: %w", fp, err)
+		}
+		for _, counter := range counters.C {
+			for _, api := range counter.APIs {
+				if c.hasTemplate(api) {
+					c.metrics[counter.Name] = struct{}{}
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// hasTemplate returns true when the template of a documented counter is loaded. Counters without a template,
+// like the metadata metrics, are created by the collector and exist when the collector is loaded.
+func (c *metricCatalog) hasTemplate(api tools.MetricDef) bool {
+	tmpl, _, _ := strings.Cut(api.Template, " ")
+	if tmpl == "" || tmpl == "NA" {
+		_, ok := c.templates[counterFileAPIs[strings.ToLower(api.API)]]
+		return ok
+	}
+	// conf/rest/9.12.0/volume.yaml => rest, volume.yaml
+	parts := strings.Split(filepath.ToSlash(tmpl), "/")
+	if len(parts) < 3 || parts[0] != "conf" {
+		return false
+	}
+	_, ok := c.templates[parts[1]][parts[len(parts)-1]]
+	return ok
+}
+
+func (c *metricCatalog) has(metric string) bool {
+	for _, prefix := range pollerMetricPrefixes {
+		if strings.HasPrefix(metric, prefix) {
+			return true
+		}
+	}
+	if _, ok := c.metrics[metric]; ok {
+		return true
+	}
+	// histograms are exported with a _bucket suffix
+	_, ok := c.metrics[strings.TrimSuffix(metric, "_bucket")]
+	return ok
+}
+
+// produces returns true when the expression can return data with the catalog's metrics, and the metrics that are
+// missing
+func (c *metricCatalog) produces(n promNode) (bool, []string) {
+	switch n := n.(type) {
+	case *vectorSelector:
+		// metric names built from dashboard variables can not be checked
+		if n.metric == "" || strings.Contains(n.metric, "$") || c.has(n.metric) {
+			return true, nil
+		}
+		return false, []string{n.metric}
+	case *call:
+		// absent returns data when its argument does not
+		if n.fn == "absent" || n.fn == "absent_over_time" || n.fn == "vector" || n.fn == "time" {
+			return true, nil
+		}
+		return c.producesAll(n.args...)
+	case *aggregateExpr:
+		return c.produces(n.expr)
+	case *subquery:
+		return c.produces(n.expr)
+	case *unaryExpr:
+		return c.produces(n.expr)
+	case *binaryExpr:
+		switch n.op {
+		case "or":
+			lok, lmissing := c.produces(n.lhs)
+			rok, rmissing := c.produces(n.rhs)
+			return lok || rok, slices.Concat(lmissing, rmissing)
+		case "unless":
+			return c.produces(n.lhs)
+		}
+		return c.producesAll(n.lhs, n.rhs)
+	}
+	return true, nil
+}
+
+func (c *metricCatalog) producesAll(nodes ...promNode) (bool, []string) {
+	ok := true
+	var missing []string
+	for _, n := range nodes {
+		nok, nmissing := c.produces(n)
+		ok = ok && nok
+		missing = append(missing, nmissing...)
+	}
+	return ok, missing
+}
+
+// emptyPanels returns the panels of a dashboard whose queries can not return data
+func (c *metricCatalog) emptyPanels(data []byte) []emptyPanel {
+	var empty []emptyPanel
+	rowTitle := DefaultRowTitle
+	VisitAllPanels(data, func(_ string, _ gjson.Result, value gjson.Result) {
+		if value.Get("type").ClonedString() == "row" {
+			rowTitle = value.Get("title").ClonedString()
+			return
+		}
+		targets := 0
+		producing := 0
+		missing := make(map[string]struct{})
+		value.Get("targets").ForEach(func(_, target gjson.Result) bool {
+			expr := target.Get("expr").ClonedString()
+			if expr == "" || target.Get("hide").Bool() {
+				return true
+			}
+			targets++
+			n, err := parsePromQL(expr)
+			if err != nil {
+				// unparsable expressions are not checked
+				producing++
+				return true
+			}
+			ok, m := c.produces(n)
+			if ok {
+				producing++
+				return true
+			}
+			for _, metric := range m {
+				missing[metric] = struct{}{}
+			}
+			return true
+		})
+		if targets > 0 && producing == 0 {
+			empty = append(empty, emptyPanel{
+				Title:   value.Get("title").ClonedString(),
+				Row:     rowTitle,
+				Missing: slices.Sorted(maps.Keys(missing)),
+			})
+		}
+	})
+	return empty
+}
@@ -0,0 +1,87 @@
+package grafana
+
+import (
+	"os"
+	"testing"
+
+	"github.com/netapp/harvest/v2/assert"
+	"github.com/netapp/harvest/v2/pkg/conf"
+)
+
+func newTestCatalog(t *testing.T, poller string, version string) *metricCatalog {
+	t.Helper()
+	conf.TestLoadHarvestConfig("testdata/verify/harvest.yml")
+	pollers, err := selectPollers([]string{poller})
+	assert.Nil(t, err)
+	c, err := newMetricCatalog("../../..", pollers, version)
+	assert.Nil(t, err)
+	return c
+}
+
+func TestMetricCatalog(t *testing.T) {
+	rest := newTestCatalog(t, "rest", "9.14.1")
+	zapi := newTestCatalog(t, "zapi", "9.14.1")
+
+	tests := []struct {
+		metric string
+		rest   bool
+		zapi   bool
+	}{
+		{metric: "volume_total_ops", rest: true, zapi: false},
+		{metric: "volume_size_total", rest: true, zapi: true},
+		{metric: "volume_labels", rest: true, zapi: true},
+		{metric: "aggr_disk_busy", rest: true, zapi: false},
+		{metric: "node_disk_max_busy", rest: true, zapi: false},
+		{metric: "smb2_close_ops", rest: true, zapi: false},
+		{metric: "volume_new_status", rest: true, zapi: true},
+		{metric: "metadata_collector_api_time", rest: true, zapi: true},
+		{metric: "volume_total_opps", rest: false, zapi: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.metric, func(t *testing.T) {
+			assert.Equal(t, rest.has(tt.metric), tt.rest)
+			assert.Equal(t, zapi.has(tt.metric), tt.zapi)
+		})
+	}
+}
+
+func TestProduces(t *testing.T) {
+	c := newTestCatalog(t, "rest", "9.14.1")
+	tests := []struct {
+		expr     string
+		produces bool
+	}{
+		{expr: `volume_read_ops`, produces: true},
+		{expr: `bogus`, produces: false},
+		{expr: `sum(rate(bogus[5m]))`, produces: false},
+		{expr: `volume_read_ops / bogus`, produces: false},
+		{expr: `volume_read_ops and on(cluster) bogus`, produces: false},
+		{expr: `bogus or volume_read_ops`, produces: true},
+		{expr: `volume_read_ops unless bogus`, produces: true},
+		{expr: `absent(bogus)`, produces: true},
+		{expr: `headroom_cpu_ewma_${Interval}`, produces: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			n, err := parsePromQL(tt.expr)
+			assert.Nil(t, err)
+			produces, _ := c.produces(n)
+			assert.Equal(t, produces, tt.produces)
+		})
+	}
+}
+
+func TestEmptyPanels(t *testing.T) {
+	data, err := os.ReadFile("testdata/verify/dashboard.json")
+	assert.Nil(t, err)
+
+	empty := newTestCatalog(t, "rest", "9.14.1").emptyPanels(data)
+	assert.Equal(t, empty, []emptyPanel{
+		{Title: "Typo", Row: "Highlights", Missing: []string{"volume_total_opps"}},
+	})
+
+	empty = newTestCatalog(t, "zapi", "9.14.1").emptyPanels(data)
+	assert.Equal(t, len(empty), 3)
+	assert.Equal(t, empty[0].Title, "Volume IOPs")
+	assert.Equal(t, empty[1].Missing, []string{"aggr_disk_busy"})
+}
@@ -0,0 +1,143 @@
+package rest
+
+import (
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/netapp/harvest/v2/pkg/conf"
+)
+
+// DefaultCacheTTL is how long a GET response is reused by the poller's other collectors and plugins.
+// Keep it shorter than the shortest data schedule, so a collector never reads its own previous poll.
+const DefaultCacheTTL = 10 * time.Second
+
+// ResponseCache deduplicates identical GET requests issued by the collectors and plugins of one poller.
+// Concurrent requests for the same href wait for the request in flight, and its response is reused until the
+// TTL expires. Only successful responses are cached.
+// Cached responses are shared, callers must not modify them.
+type ResponseCache struct {
+	ttl       time.Duration
+	mu        sync.Mutex
+	entries   map[string]*cacheEntry
+	lastSweep time.Time
+	hits      atomic.Uint64
+	misses    atomic.Uint64
+}
+
+type cacheEntry struct {
+	done    chan struct{}
+	body    []byte
+	err     error
+	expires time.Time
+}
+
+func NewResponseCache(ttl time.Duration) *ResponseCache {
+	return &ResponseCache{
+		ttl:     ttl,
+		entries: make(map[string]*cacheEntry),
+	}
+}
+
+var (
+	cachesMu sync.Mutex
+	caches   = make(map[string]*ResponseCache)
+)
+
+// CacheFor returns the poller's response cache, or nil when the cache is disabled with a rest_cache_ttl of 0s
+func CacheFor(poller *conf.Poller) *ResponseCache {
+	ttl := DefaultCacheTTL
+	if poller.RestCacheTTL != "" {
+		d, err := time.ParseDuration(poller.RestCacheTTL)
+		if err == nil {
+			ttl = d
+		}
+	}
+	if ttl <= 0 {
+		return nil
+	}
+
+	key := poller.Name + "|" + poller.Addr
+	cachesMu.Lock()
+	defer cachesMu.Unlock()
+	cache, ok := caches[key]
+	if !ok {
+		cache = NewResponseCache(ttl)
+		caches[key] = cache
+	}
+	return cache
+}
+
+// Get returns the cached response for key. When the response is not cached, fetch is called and its
+// response is cached. hit is true when fetch was not called.
+func (r *ResponseCache) Get(key string, fetch func() ([]byte, error)) ([]byte, bool, error) {
+	now := time.Now()
+
+	r.mu.Lock()
+	r.sweep(now)
+	if entry, ok := r.entries[key]; ok && (entry.expires.IsZero() || now.Before(entry.expires)) {
+		r.mu.Unlock()
+		<-entry.done
+		if entry.err == nil {
+			r.hits.Add(1)
+			return entry.body, true, nil
+		}
+		// the request in flight failed, send our own
+		body, err := fetch()
+		r.misses.Add(1)
+		return body, false, err
+	}
+	entry := &cacheEntry{done: make(chan struct{})}
+	r.entries[key] = entry
+	r.mu.Unlock()
+
+	r.misses.Add(1)
+	entry.body, entry.err = fetch()
+
+	r.mu.Lock()
+	entry.expires = time.Now().Add(r.ttl)
+	if entry.err != nil && r.entries[key] == entry {
+		delete(r.entries, key)
+	}
+	r.mu.Unlock()
+	close(entry.done)
+
+	return entry.body, false, entry.err
+}
+
+// sweep removes expired entries at most once per TTL. The caller must hold r.mu
+func (r *ResponseCache) sweep(now time.Time) {
+	if now.Sub(r.lastSweep) < r.ttl {
+		return
+	}
+	r.lastSweep = now
+	maps.DeleteFunc(r.entries, func(_ string, entry *cacheEntry) bool {
+		return !entry.expires.IsZero() && now.After(entry.expires)
+	})
+}
+
+// Stats returns the number of cache hits and misses since the cache was created
+func (r *ResponseCache) Stats() (uint64, uint64) {
+	if r == nil {
+		return 0, 0
+	}
+	return r.hits.Load(), r.misses.Load()
+}
+
+// cacheKey returns the key of a GET request: the href and the request headers
+func cacheKey(href string, headers []map[string]string) string {
+	if len(headers) == 0 {
+		return href
+	}
+	var b strings.Builder
+	b.WriteString(href)
+	for _, hs := range headers {
+		for _, k := range slices.Sorted(maps.Keys(hs)) {
+			b.WriteString("\n" + k + ": " + hs[k])
+		}
+	}
+	return b.String()
+}
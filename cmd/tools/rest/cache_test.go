@@ -0,0 +1,142 @@
+package rest
+
+import (
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/netapp/harvest/v2/assert"
+	"github.com/netapp/harvest/v2/pkg/auth"
+	"github.com/netapp/harvest/v2/pkg/collector"
+	"github.com/netapp/harvest/v2/pkg/conf"
+)
+
+func TestResponseCache(t *testing.T) {
+	cache := NewResponseCache(50 * time.Millisecond)
+	calls := 0
+	fetch := func() ([]byte, error) {
+		calls++
+		return []byte("body"), nil
+	}
+
+	body, hit, err := cache.Get("api/volumes", fetch)
+	assert.Nil(t, err)
+	assert.False(t, hit)
+	assert.Equal(t, string(body), "body")
+
+	_, hit, _ = cache.Get("api/volumes", fetch)
+	assert.True(t, hit)
+	assert.Equal(t, calls, 1)
+
+	_, hit, _ = cache.Get("api/aggregates", fetch)
+	assert.False(t, hit)
+	assert.Equal(t, calls, 2)
+
+	time.Sleep(60 * time.Millisecond)
+	_, hit, _ = cache.Get("api/volumes", fetch)
+	assert.False(t, hit)
+	assert.Equal(t, calls, 3)
+
+	hits, misses := cache.Stats()
+	assert.Equal(t, hits, uint64(1))
+	assert.Equal(t, misses, uint64(3))
+}
+
+func TestResponseCacheErrorsNotCached(t *testing.T) {
+	cache := NewResponseCache(time.Minute)
+	calls := 0
+	_, _, err := cache.Get("api/volumes", func() ([]byte, error) {
+		calls++
+		return nil, errors.New("timeout")
+	})
+	assert.NotNil(t, err)
+
+	body, hit, err := cache.Get("api/volumes", func() ([]byte, error) {
+		calls++
+		return []byte("body"), nil
+	})
+	assert.Nil(t, err)
+	assert.False(t, hit)
+	assert.Equal(t, string(body), "body")
+	assert.Equal(t, calls, 2)
+}
+
+func TestResponseCacheCoalesce(t *testing.T) {
+	cache := NewResponseCache(time.Minute)
+	var calls atomic.Int64
+	release := make(chan struct{})
+
+	const workers = 16
+	var wg sync.WaitGroup
+	for range workers {
+		wg.Go(func() {
+			body, _, err := cache.Get("api/storage/volumes", func() ([]byte, error) {
+				calls.Add(1)
+				<-release
+				return []byte("body"), nil
+			})
+			assert.Nil(t, err)
+			assert.Equal(t, string(body), "body")
+		})
+	}
+
+	// give the workers time to queue behind the request in flight
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	assert.Equal(t, calls.Load(), int64(1))
+	hits, misses := cache.Stats()
+	assert.Equal(t, hits, uint64(workers-1))
+	assert.Equal(t, misses, uint64(1))
+}
+
+func TestClientGetRestCached(t *testing.T) {
+	var requests atomic.Int64
+	client := &Client{
+		client: &http.Client{Transport: roundTripFunc(func(_ *http.Request) (*http.Response, error) {
+			requests.Add(1)
+			return &http.Response{
+				StatusCode: http.StatusOK,
+				Body:       io.NopCloser(strings.NewReader(`{"records":[],"num_records":0}`)),
+				Header:     make(http.Header),
+			}, nil
+		})},
+		Logger:  slog.Default(),
+		baseURL: "https://cluster.example/",
+		auth: auth.NewCredentials(&conf.Poller{
+			Name:     "test",
+			Addr:     "cluster.example",
+			Username: "user",
+			Password: "pass",
+		}, slog.Default()),
+		cache: NewResponseCache(time.Minute),
+	}
+
+	collectorMetadata := &collector.Metadata{}
+	pluginMetadata := &collector.Metadata{}
+	_, err := client.GetRest(collectorMetadata, "api/storage/volumes?fields=name")
+	assert.Nil(t, err)
+	_, err = client.GetRest(pluginMetadata, "api/storage/volumes?fields=name")
+	assert.Nil(t, err)
+	_, err = client.GetRest(pluginMetadata, "api/storage/volumes?fields=name", map[string]string{"Accept": "application/hal+json"})
+	assert.Nil(t, err)
+
+	assert.Equal(t, requests.Load(), int64(2))
+	assert.Equal(t, collectorMetadata.NumCalls.Load(), uint64(1))
+	assert.Equal(t, pluginMetadata.NumCalls.Load(), uint64(1))
+}
+
+func TestCacheFor(t *testing.T) {
+	poller := &conf.Poller{Name: "cache-test", Addr: "10.0.0.1"}
+	assert.True(t, CacheFor(poller) == CacheFor(poller))
+	assert.Equal(t, CacheFor(poller).ttl, DefaultCacheTTL)
+
+	assert.Nil(t, CacheFor(&conf.Poller{Name: "cache-off", Addr: "10.0.0.1", RestCacheTTL: "0s"}))
+}
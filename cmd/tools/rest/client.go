@@ -10,6 +10,7 @@ import (
 	"github.com/netapp/harvest/v2/pkg/collector"
 	"github.com/netapp/harvest/v2/pkg/conf"
 	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/ratelimit"
 	"github.com/netapp/harvest/v2/pkg/requests"
 	"github.com/netapp/harvest/v2/third_party/tidwall/gjson"
 	"io"
@@ -35,6 +36,8 @@ type Client struct {
 	logRest         bool // used to log Rest request/response
 	isGCNVOntapMode bool
 	auth            *auth.Credentials
+	cache           *ResponseCache
+	limiter         *ratelimit.Limiter
 }
 
 func New(poller *conf.Poller, timeout time.Duration, credentials *auth.Credentials) (*Client, error) {
@@ -61,6 +64,8 @@ func New(poller *conf.Poller, timeout time.Duration, credentials *auth.Credentia
 	}
 	client.baseURL = url
 	client.isGCNVOntapMode = poller.GCNVOntapMode
+	client.cache = CacheFor(poller)
+	client.limiter = ratelimit.For(poller)
 
 	transport, err = credentials.Transport(nil, poller)
 	if err != nil {
@@ -121,6 +126,8 @@ func recordRequestMetadata(metadata *collector.Metadata, response []byte) {
 
 // GetPlainRest makes a REST request to the cluster and returns a json response as a []byte.
 // If metadata is non-nil, BytesRx and NumCalls are incremented.
+// Identical requests sent by the poller's collectors and plugins within the cache TTL share one response, and only
+// the request sent to the cluster increments metadata.
 func (c *Client) GetPlainRest(metadata *collector.Metadata, request string, encodeURL bool, headers ...map[string]string) ([]byte, error) {
 	var err error
 	if strings.Index(request, "/") == 0 {
@@ -158,8 +165,18 @@ func (c *Client) GetPlainRest(metadata *collector.Metadata, request string, enco
 		req.SetBasicAuth(pollerAuth.Username, pollerAuth.Password)
 	}
 
-	result, err := c.invokeWithAuthRetry(req)
-	recordRequestMetadata(metadata, result)
+	invoke := func() ([]byte, error) {
+		result, err := c.invokeWithAuthRetry(req)
+		recordRequestMetadata(metadata, result)
+		return result, err
+	}
+
+	var result []byte
+	if c.cache != nil {
+		result, _, err = c.cache.Get(cacheKey(u, headers), invoke)
+	} else {
+		result, err = invoke()
+	}
 
 	result = c.unwrapGCNVBody(result)
 
@@ -262,13 +279,20 @@ func (c *Client) invokeWithAuthRetry(req *http.Request) ([]byte, error) {
 		if c.client == nil {
 			return nil, errors.New("connection error: nil http client")
 		}
+		if err := c.limiter.Wait(req.Context()); err != nil {
+			return nil, err
+		}
+		start := time.Now()
 		response, innerErr = c.client.Do(req)
 		if innerErr != nil {
+			c.limiter.Observe(0, time.Since(start), false)
 			return nil, fmt.Errorf("connection error: %w", innerErr)
 		}
 		//goland:noinspection GoUnhandledErrorResult
 		defer response.Body.Close()
 		innerBody, innerErr = io.ReadAll(response.Body)
+		cmReject := gjson.GetBytes(innerBody, "error."+Code).Int() == errs.CMReject.Code
+		c.limiter.Observe(response.StatusCode, time.Since(start), cmReject)
 		if innerErr != nil {
 			return nil, errs.NewRest().
 				StatusCode(response.StatusCode).
@@ -341,7 +365,7 @@ func (c *Client) invokeWithAuthRetry(req *http.Request) ([]byte, error) {
 				if err2 != nil {
 					return nil, err2
 				}
-				if pollerAuth.HasCredentialScript {
+				if pollerAuth.Refreshable {
 					c.auth.Expire()
 					pollerAuth2, err2 := c.auth.GetPollerAuth()
 					if err2 != nil {
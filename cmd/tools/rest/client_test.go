@@ -6,6 +6,7 @@ import (
 	"io"
 	"log/slog"
 	"net/http"
+	"net/http/httptest"
 	"strconv"
 	"strings"
 	"sync"
@@ -127,3 +128,51 @@ func TestClientPostRestAuthRetryReplaysBody(t *testing.T) {
 		t.Fatalf("retried request body = %q, want %q", bodies[1], expectedBody)
 	}
 }
+
+func TestClientOAuth2Bearer(t *testing.T) {
+	tokens := 0
+	issuer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		tokens++
+		_, _ = w.Write([]byte(`{"access_token":"token-` + strconv.Itoa(tokens) + `","token_type":"Bearer","expires_in":3600}`))
+	}))
+	defer issuer.Close()
+
+	credentials := auth.NewCredentials(&conf.Poller{
+		Name:      "test",
+		Addr:      "cluster.example",
+		AuthStyle: conf.OAuth2Auth,
+		OAuth2: conf.OAuth2{
+			TokenURL:     issuer.URL,
+			ClientID:     "harvest",
+			ClientSecret: "s3cret",
+		},
+	}, slog.Default())
+
+	var authHeaders []string
+	client := &Client{
+		client: &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+			authHeaders = append(authHeaders, r.Header.Get("Authorization"))
+			// the cluster rejects the first token
+			statusCode := http.StatusOK
+			if r.Header.Get("Authorization") == "Bearer token-1" {
+				statusCode = http.StatusUnauthorized
+			}
+			return &http.Response{
+				StatusCode: statusCode,
+				Body:       io.NopCloser(strings.NewReader(`{"records":[]}`)),
+				Header:     make(http.Header),
+			}, nil
+		})},
+		Logger:  slog.Default(),
+		baseURL: "https://cluster.example/",
+		auth:    credentials,
+	}
+
+	_, err := client.GetRest(nil, "api/cluster")
+	if err != nil {
+		t.Fatalf("GetRest() error = %v", err)
+	}
+	if len(authHeaders) != 2 || authHeaders[0] != "Bearer token-1" || authHeaders[1] != "Bearer token-2" {
+		t.Fatalf("Authorization headers = %v, want [Bearer token-1 Bearer token-2]", authHeaders)
+	}
+}
@@ -718,7 +718,7 @@ func stderr(format string, a ...any) {
 func init() {
 	configPath := conf.Path(conf.HarvestYML)
 
-	Cmd.AddCommand(showCmd)
+	Cmd.AddCommand(showCmd, scaffoldCmd)
 	flags := Cmd.PersistentFlags()
 	flags.StringVarP(&args.Poller, "poller", "p", "", "Name of poller (cluster), as defined in your harvest config. * for all pollers")
 	flags.StringVar(&args.Config, "config", configPath, "Harvest config file path")
@@ -742,6 +742,14 @@ func init() {
 	showFlags.StringVarP(&args.QueryValue, "query-value", "u", "", "Pattern to search for in all fields specified by <query-fields>\n"+
 		"same query characters as <field> apply (see above)")
 
+	scaffoldFlags := scaffoldCmd.Flags()
+	scaffoldFlags.StringVarP(&scaffoldArgs.API, "api", "a", "", "REST API to sample, e.g. api/storage/qtrees")
+	scaffoldFlags.StringVar(&scaffoldArgs.Object, "object", "", "Object name of the template. Defaults to the singular of the API's last path segment")
+	scaffoldFlags.StringVar(&scaffoldArgs.Name, "name", "", "Name of the template. Defaults to the CamelCase object name")
+	scaffoldFlags.IntVarP(&scaffoldArgs.Records, "records", "r", 100, "Number of records to sample")
+	scaffoldFlags.StringVar(&scaffoldArgs.Compare, "compare", "", "Path of an existing template to diff against. Fields it does not collect are suggested")
+	scaffoldFlags.StringVarP(&scaffoldArgs.Output, "output", "o", "", "Write the template to this file instead of stdout")
+
 	Cmd.SetUsageTemplate(Cmd.UsageTemplate() + `
 Examples:
   # Print cluster infinity's' ONTAP REST API Online Reference 
@@ -758,5 +766,8 @@ Examples:
 
   # Query all clusters, in your harvest.yml file, for all qos policies. Pipe the results to jq, and print as CSV.	
   bin/harvest rest -p '*' show data --api storage/qos/policies | jq -r '.[] | [.poller, .addr, .num_records, .version, .cluster_name, .poll_ms, .api] |  @csv' | column -ts,
+
+  # Generate a template for qtrees from 100 sampled records and list the fields the existing template does not collect.
+  bin/harvest rest -p infinity scaffold --api api/storage/qtrees --compare conf/rest/9.12.0/qtree.yaml
 `)
 }
@@ -0,0 +1,448 @@
+package rest
+
+import (
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/netapp/harvest/v2/pkg/auth"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/template"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"github.com/netapp/harvest/v2/third_party/tidwall/gjson"
+	"github.com/spf13/cobra"
+)
+
+const (
+	kindKey   = "key"
+	kindLabel = "label"
+	kindFloat = "float"
+)
+
+type ScaffoldArgs struct {
+	API     string
+	Object  string
+	Name    string
+	Records int
+	Compare string
+	Output  string
+}
+
+var scaffoldArgs = &ScaffoldArgs{}
+
+var scaffoldCmd = &cobra.Command{
+	Use:   "scaffold",
+	Short: "Generate a REST template by sampling the records of an ONTAP API",
+	Run:   doScaffold,
+}
+
+// Scaffold is a REST template inferred from sampled records
+type Scaffold struct {
+	Name     string
+	Query    string
+	Object   string
+	Counters []ScaffoldCounter
+}
+
+// ScaffoldCounter is one counter of a scaffolded template. Kind is key, label, or float, the same kinds
+// template.ParseMetric returns.
+type ScaffoldCounter struct {
+	Path    string
+	Display string
+	Kind    string
+}
+
+// ScaffoldDiff lists the differences between a scaffolded template and an existing one
+type ScaffoldDiff struct {
+	// Added are counters the API returns that the existing template does not collect
+	Added []ScaffoldCounter
+	// Missing are counters of the existing template that were not seen in the sampled records
+	Missing []string
+}
+
+func doScaffold(_ *cobra.Command, _ []string) {
+	if scaffoldArgs.API == "" {
+		stderr("--api is required\n")
+		os.Exit(1)
+	}
+	if _, err := conf.LoadHarvestConfig(args.Config); err != nil {
+		log.Fatal(err)
+	}
+	poller, _, err := GetPollerAndAddr(args.Poller)
+	if err != nil {
+		log.Fatal(err)
+	}
+	timeout, err := time.ParseDuration(args.Timeout)
+	if err != nil {
+		stderr("Unable to parse timeout=%s using default %s\n", args.Timeout, DefaultTimeout)
+		timeout, _ = time.ParseDuration(DefaultTimeout)
+	}
+
+	client, err := New(poller, timeout, auth.NewCredentials(poller, slog.Default()))
+	if err != nil {
+		log.Fatalf("poller=%s %v", poller.Name, err)
+	}
+	remote, err := client.Init(1, conf.Remote{})
+	if err != nil {
+		log.Fatalf("poller=%s %v", poller.Name, err)
+	}
+
+	api := strings.TrimPrefix(scaffoldArgs.API, "/")
+	href := NewHrefBuilder().APIPath(api).Fields([]string{"*"}).Build()
+	records, err := FetchSome(client, nil, href, scaffoldArgs.Records, strconv.Itoa(scaffoldArgs.Records))
+	if err != nil {
+		log.Fatalf("poller=%s api=%s %v", poller.Name, api, err)
+	}
+	if len(records) == 0 {
+		stderr("poller=%s api=%s returned no records, nothing to infer\n", poller.Name, api)
+		os.Exit(1)
+	}
+
+	s := NewScaffold(api, records, scaffoldArgs.Object, scaffoldArgs.Name)
+	header := fmt.Sprintf("# Generated by harvest rest scaffold from %s on cluster %s ONTAP %s (%d records sampled).\n"+
+		"# Review the inferred keys, labels, and metrics before use.\n", api, remote.Name, remote.Version, len(records))
+	out := header + s.Template()
+
+	if scaffoldArgs.Output != "" {
+		if err := os.WriteFile(scaffoldArgs.Output, []byte(out), 0o600); err != nil {
+			log.Fatal(err)
+		}
+		stderr("template written to %s\n", scaffoldArgs.Output)
+	} else {
+		fmt.Print(out)
+	}
+
+	if scaffoldArgs.Compare != "" {
+		diff, err := s.Compare(scaffoldArgs.Compare)
+		if err != nil {
+			log.Fatal(err)
+		}
+		stderr("%s", diff.String(scaffoldArgs.Compare))
+	}
+}
+
+// NewScaffold infers a template from records. Fields that are numeric in every record are metrics, other scalar
+// fields are labels, and keys are the smallest set of name fields that identify each record.
+// When object or name are empty, they are derived from the api path.
+func NewScaffold(api string, records []gjson.Result, object string, name string) *Scaffold {
+	if object == "" {
+		object = objectFromAPI(api)
+	}
+	if name == "" {
+		name = nameFromObject(object)
+	}
+	s := &Scaffold{Name: name, Query: api, Object: object}
+
+	var order []string
+	kinds := make(map[string]string)
+	for _, r := range records {
+		flatten(r, "", func(path string, kind string) {
+			prev, ok := kinds[path]
+			switch {
+			case !ok:
+				order = append(order, path)
+				kinds[path] = kind
+			case prev != kind:
+				// a field that is not numeric in every record is a label
+				kinds[path] = kindLabel
+			}
+		})
+	}
+
+	keys := inferKeys(records, order, kinds)
+	used := make(map[string]bool)
+	for _, k := range keys {
+		c := ScaffoldCounter{Path: k, Display: displayName(k, object, used), Kind: kindKey}
+		s.Counters = append(s.Counters, c)
+	}
+
+	var labels, metrics []string
+	for _, path := range order {
+		if slices.Contains(keys, path) {
+			continue
+		}
+		if kinds[path] == kindFloat {
+			metrics = append(metrics, path)
+		} else {
+			labels = append(labels, path)
+		}
+	}
+	slices.Sort(labels)
+	slices.Sort(metrics)
+	for _, l := range labels {
+		s.Counters = append(s.Counters, ScaffoldCounter{Path: l, Display: displayName(l, object, used), Kind: kindLabel})
+	}
+	for _, m := range metrics {
+		s.Counters = append(s.Counters, ScaffoldCounter{Path: m, Display: displayName(m, object, used), Kind: kindFloat})
+	}
+
+	return s
+}
+
+// flatten calls fn with the path and kind of each leaf of r. Links, the uuid and id of referenced objects, and arrays
+// of objects without names are skipped. Arrays of scalars are labels, the REST collector joins their values.
+func flatten(r gjson.Result, prefix string, fn func(path string, kind string)) {
+	isReference := prefix != "" && r.Get("name").Exists()
+	r.ForEach(func(key, value gjson.Result) bool {
+		k := key.String()
+		if k == "_links" {
+			return true
+		}
+		if isReference && (k == "uuid" || k == "id") {
+			return true
+		}
+		path := prefix + k
+		switch {
+		case value.IsObject():
+			flatten(value, path+".", fn)
+		case value.IsArray():
+			elems := value.Array()
+			if len(elems) == 0 {
+				return true
+			}
+			if elems[0].IsObject() {
+				if elems[0].Get("name").Exists() {
+					fn(path+".#.name", kindLabel)
+				}
+				return true
+			}
+			fn(path, kindLabel)
+		case value.Type == gjson.Number:
+			fn(path, kindFloat)
+		case value.Type == gjson.Null:
+		default:
+			fn(path, kindLabel)
+		}
+		return true
+	})
+}
+
+// inferKeys returns the fields that uniquely identify each record. Candidates are name and the names of referenced
+// objects, svm.name first since most objects are scoped to an SVM. Candidates are added greedily until every record
+// is unique. When that fails, uuid is used if it is unique.
+func inferKeys(records []gjson.Result, order []string, kinds map[string]string) []string {
+	var candidates []string
+	if kinds["svm.name"] == kindLabel {
+		candidates = append(candidates, "svm.name")
+	}
+	for _, path := range order {
+		if kinds[path] != kindLabel || path == "svm.name" {
+			continue
+		}
+		if path == "name" || (strings.Count(path, ".") == 1 && strings.HasSuffix(path, ".name")) {
+			candidates = append(candidates, path)
+		}
+	}
+
+	var keys []string
+	if slices.Contains(candidates, "name") {
+		keys = append(keys, "name")
+		candidates = slices.DeleteFunc(candidates, func(c string) bool { return c == "name" })
+	}
+
+	best := distinct(records, keys)
+	for best < len(records) && len(candidates) > 0 {
+		pick := -1
+		for i, c := range candidates {
+			if n := distinct(records, append(slices.Clone(keys), c)); n > best {
+				best, pick = n, i
+			}
+		}
+		if pick == -1 {
+			break
+		}
+		keys = append(keys, candidates[pick])
+		candidates = slices.Delete(candidates, pick, pick+1)
+	}
+
+	if (len(keys) == 0 || best < len(records)) && kinds["uuid"] == kindLabel && distinct(records, []string{"uuid"}) == len(records) {
+		return []string{"uuid"}
+	}
+	return keys
+}
+
+func distinct(records []gjson.Result, keys []string) int {
+	if len(keys) == 0 {
+		return 0
+	}
+	seen := make(map[string]struct{})
+	for _, r := range records {
+		var sb strings.Builder
+		for _, k := range keys {
+			sb.WriteString(r.Get(k).String())
+			sb.WriteString("\x00")
+		}
+		seen[sb.String()] = struct{}{}
+	}
+	return len(seen)
+}
+
+// displayName follows the naming of the conf/rest templates: name is the object, the name of a referenced object
+// is the object it references, and other paths replace dots with underscores
+func displayName(path string, object string, used map[string]bool) string {
+	full := strings.ReplaceAll(strings.ReplaceAll(path, ".#", ""), ".", "_")
+	display := full
+	switch {
+	case path == "name":
+		display = object
+	case strings.HasSuffix(path, ".name") && strings.Count(path, ".") == 1:
+		display = strings.TrimSuffix(path, ".name")
+	case strings.HasSuffix(path, ".#.name") && strings.Count(path, ".") == 2:
+		display = strings.TrimSuffix(path, ".#.name")
+	}
+	if used[display] {
+		display = full
+	}
+	used[display] = true
+	return display
+}
+
+// objectFromAPI returns the singular form of the last path segment, e.g. api/storage/qtrees returns qtree
+func objectFromAPI(api string) string {
+	segments := strings.Split(strings.Trim(api, "/"), "/")
+	last := segments[len(segments)-1]
+	for i := len(segments) - 1; i >= 0 && strings.HasPrefix(segments[i], "{"); i-- {
+		last = segments[max(i-1, 0)]
+	}
+	last = strings.ReplaceAll(last, "-", "_")
+	switch {
+	case strings.HasSuffix(last, "ies"):
+		return strings.TrimSuffix(last, "ies") + "y"
+	case strings.HasSuffix(last, "sses"), strings.HasSuffix(last, "xes"):
+		return strings.TrimSuffix(last, "es")
+	case strings.HasSuffix(last, "s") && !strings.HasSuffix(last, "ss"):
+		return strings.TrimSuffix(last, "s")
+	}
+	return last
+}
+
+// nameFromObject returns the CamelCase template name of object, e.g. export_rule returns ExportRule
+func nameFromObject(object string) string {
+	var sb strings.Builder
+	for part := range strings.SplitSeq(object, "_") {
+		if part == "" {
+			continue
+		}
+		sb.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return sb.String()
+}
+
+func (c ScaffoldCounter) raw() string {
+	switch c.Kind {
+	case kindKey:
+		return "^^" + c.Path
+	case kindLabel:
+		return "^" + c.Path
+	}
+	return c.Path
+}
+
+// Template returns the scaffold in the conf/rest template format
+func (s *Scaffold) Template() string {
+	width := len("object:")
+	for _, c := range s.Counters {
+		width = max(width, len(c.raw()))
+	}
+	width += 4
+
+	var sb strings.Builder
+	sb.WriteString("\n")
+	for _, kv := range [][2]string{{"name:", s.Name}, {"query:", s.Query}, {"object:", s.Object}} {
+		fmt.Fprintf(&sb, "%-*s%s\n", width+4, kv[0], kv[1])
+	}
+
+	sb.WriteString("\ncounters:\n")
+	for _, c := range s.Counters {
+		fmt.Fprintf(&sb, "  - %-*s=> %s\n", width, c.raw(), c.Display)
+	}
+
+	var keys, labels []string
+	for _, c := range s.Counters {
+		switch c.Kind {
+		case kindKey:
+			keys = append(keys, c.Display)
+		case kindLabel:
+			labels = append(labels, c.Display)
+		}
+	}
+	slices.Sort(labels)
+
+	sb.WriteString("\nexport_options:\n")
+	writeList(&sb, "instance_keys", keys)
+	writeList(&sb, "instance_labels", labels)
+
+	return sb.String()
+}
+
+func writeList(sb *strings.Builder, name string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	sb.WriteString("  " + name + ":\n")
+	for _, v := range values {
+		sb.WriteString("    - " + v + "\n")
+	}
+}
+
+// Compare diffs the scaffold against the counters of the existing template at path.
+// Only the template's top-level counters are compared, not its endpoints.
+func (s *Scaffold) Compare(path string) (*ScaffoldDiff, error) {
+	t, err := tree.ImportYaml(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read template %s: %w", path, err)
+	}
+	existing := make(map[string]bool)
+	if counters := t.GetChildS("counters"); counters != nil {
+		for _, c := range counters.GetChildren() {
+			raw := c.GetContentS()
+			if raw == "" {
+				// filter, hidden_fields
+				continue
+			}
+			name, _, _, _ := template.ParseMetric(raw)
+			existing[name] = true
+		}
+	}
+
+	diff := &ScaffoldDiff{}
+	seen := make(map[string]bool)
+	for _, c := range s.Counters {
+		seen[c.Path] = true
+		if !existing[c.Path] {
+			diff.Added = append(diff.Added, c)
+		}
+	}
+	for name := range existing {
+		if !seen[name] {
+			diff.Missing = append(diff.Missing, name)
+		}
+	}
+	slices.Sort(diff.Missing)
+
+	return diff, nil
+}
+
+func (d *ScaffoldDiff) String(path string) string {
+	var sb strings.Builder
+	if len(d.Added) == 0 {
+		fmt.Fprintf(&sb, "%s collects every field returned by the API\n", path)
+	} else {
+		fmt.Fprintf(&sb, "%d fields are available that %s does not collect:\n", len(d.Added), path)
+		for _, c := range d.Added {
+			fmt.Fprintf(&sb, "  - %s => %s\n", c.raw(), c.Display)
+		}
+	}
+	if len(d.Missing) > 0 {
+		fmt.Fprintf(&sb, "%d counters of %s were not in the sampled records:\n", len(d.Missing), path)
+		for _, m := range d.Missing {
+			fmt.Fprintf(&sb, "  - %s\n", m)
+		}
+	}
+	return sb.String()
+}
@@ -0,0 +1,125 @@
+package rest
+
+import (
+	"os"
+	"testing"
+
+	"github.com/netapp/harvest/v2/assert"
+	"github.com/netapp/harvest/v2/pkg/template"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"github.com/netapp/harvest/v2/third_party/tidwall/gjson"
+)
+
+func loadScaffold(t *testing.T) *Scaffold {
+	t.Helper()
+	data, err := os.ReadFile("testdata/qtrees.json")
+	assert.Nil(t, err)
+	records := gjson.GetBytes(data, "records").Array()
+	return NewScaffold("api/storage/qtrees", records, "", "")
+}
+
+func TestNewScaffold(t *testing.T) {
+	s := loadScaffold(t)
+
+	assert.Equal(t, s.Name, "Qtree")
+	assert.Equal(t, s.Object, "qtree")
+
+	got := make(map[string]ScaffoldCounter)
+	var keys []string
+	for _, c := range s.Counters {
+		got[c.Path] = c
+		if c.Kind == kindKey {
+			keys = append(keys, c.Display)
+		}
+	}
+
+	// name and svm.name are not unique on their own, volume.name makes every record unique
+	assert.Equal(t, len(keys), 3)
+	assert.Equal(t, keys[0], "qtree")
+	assert.Equal(t, keys[1], "svm")
+	assert.Equal(t, keys[2], "volume")
+
+	tests := []struct {
+		path    string
+		display string
+		kind    string
+	}{
+		{path: "security_style", display: "security_style", kind: kindLabel},
+		{path: "export_policy.name", display: "export_policy", kind: kindLabel},
+		{path: "nas.path", display: "nas_path", kind: kindLabel},
+		{path: "id", display: "id", kind: kindFloat},
+		{path: "statistics.iops_raw.read", display: "statistics_iops_raw_read", kind: kindFloat},
+		// numeric in some records only
+		{path: "unix_permissions", display: "unix_permissions", kind: kindLabel},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			c, ok := got[tt.path]
+			assert.True(t, ok)
+			assert.Equal(t, c.Display, tt.display)
+			assert.Equal(t, c.Kind, tt.kind)
+		})
+	}
+
+	// links and the uuid and id of referenced objects are skipped
+	for _, skipped := range []string{"_links.self.href", "svm.uuid", "export_policy.id"} {
+		_, ok := got[skipped]
+		assert.False(t, ok)
+	}
+}
+
+func TestScaffoldTemplate(t *testing.T) {
+	s := loadScaffold(t)
+
+	n, err := tree.LoadYaml([]byte(s.Template()))
+	assert.Nil(t, err)
+	assert.Equal(t, n.GetChildContentS("name"), "Qtree")
+	assert.Equal(t, n.GetChildContentS("query"), "api/storage/qtrees")
+	assert.Equal(t, n.GetChildContentS("object"), "qtree")
+
+	counters := n.GetChildS("counters").GetAllChildContentS()
+	assert.Equal(t, len(counters), len(s.Counters))
+	name, display, kind, _ := template.ParseMetric(counters[0])
+	assert.Equal(t, name, "name")
+	assert.Equal(t, display, "qtree")
+	assert.Equal(t, kind, "key")
+
+	exportOptions := n.GetChildS("export_options")
+	assert.Equal(t, len(exportOptions.GetChildS("instance_keys").GetAllChildContentS()), 3)
+	assert.NotNil(t, exportOptions.GetChildS("instance_labels"))
+}
+
+func TestScaffoldCompare(t *testing.T) {
+	s := loadScaffold(t)
+
+	diff, err := s.Compare("../../../conf/rest/9.12.0/qtree.yaml")
+	assert.Nil(t, err)
+	assert.Equal(t, len(diff.Missing), 0)
+
+	added := make(map[string]bool)
+	for _, c := range diff.Added {
+		added[c.Path] = true
+	}
+	assert.True(t, added["qos_policy.name"])
+	assert.True(t, added["path"])
+	assert.False(t, added["name"])
+	assert.False(t, added["security_style"])
+}
+
+func TestObjectFromAPI(t *testing.T) {
+	tests := map[string]string{
+		"api/storage/qtrees":                    "qtree",
+		"api/storage/qos/policies":              "policy",
+		"api/network/ip/addresses":              "address",
+		"api/protocols/nfs/export-policies":     "export_policy",
+		"api/storage/volumes/{uuid}":            "volume",
+		"api/cluster/nodes/":                    "node",
+		"api/private/cli/vserver/cifs/security": "security",
+	}
+	for api, want := range tests {
+		t.Run(api, func(t *testing.T) {
+			assert.Equal(t, objectFromAPI(api), want)
+		})
+	}
+	assert.Equal(t, nameFromObject("export_policy"), "ExportPolicy")
+}
@@ -0,0 +1,546 @@
+package template
+
+import (
+	"cmp"
+	_ "embed"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/goccy/go-yaml"
+	"github.com/netapp/harvest/v2/cmd/poller/collector"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	template2 "github.com/netapp/harvest/v2/pkg/template"
+	"github.com/netapp/harvest/v2/pkg/tree"
+	"github.com/netapp/harvest/v2/third_party/go-version"
+	"github.com/spf13/cobra"
+)
+
+//go:embed migrate.yaml
+var migrateYaml []byte
+
+const perfTablePrefix = "api/cluster/counter/tables/"
+
+var migrateArgs = struct {
+	Conf   string
+	Query  string
+	Output string
+}{}
+
+var Cmd = &cobra.Command{
+	Use:   "template",
+	Short: "Template utilities",
+}
+
+var migrateCmd = &cobra.Command{
+	Use:   "migrate <zapi template>",
+	Short: "Convert a ZAPI or ZapiPerf template into a REST or RestPerf template",
+	Long: "Convert a ZAPI or ZapiPerf template into a REST or RestPerf template.\n" +
+		"Counters are mapped using the shipped template pairs and a maintained field-mapping table. " +
+		"Counters without a REST equivalent are reported and left commented out in the generated template.",
+	Args: cobra.ExactArgs(1),
+	RunE: doMigrate,
+}
+
+// Migration is the result of converting a ZAPI template
+type Migration struct {
+	Template string
+	Query    string
+	// Pair is the shipped REST template the counters were mapped from, empty when none matched
+	Pair     string
+	Mapped   int
+	Unmapped []string
+}
+
+type migrateTable struct {
+	Queries    map[string]string `yaml:"queries"`
+	Fields     map[string]string `yaml:"fields"`
+	PerfFields map[string]string `yaml:"perf_fields"`
+}
+
+// restField is where a ZAPI counter lives in REST. query is empty for fields of the template's main query.
+type restField struct {
+	query   string
+	counter string
+}
+
+// fieldMap maps the counters of one ZAPI API to REST
+type fieldMap struct {
+	query    string
+	template string
+	fields   map[string]restField
+	// endpointKeys are the key counters of each endpoint, needed to join endpoint records to instances
+	endpointKeys map[string][]migratedCounter
+}
+
+// Migrator converts ZAPI templates using the shipped template pairs under its conf directory
+type Migrator struct {
+	confDir string
+	table   migrateTable
+	pairs   map[string]map[string]*fieldMap
+}
+
+func NewMigrator(confDir string) (*Migrator, error) {
+	m := &Migrator{confDir: confDir, pairs: make(map[string]map[string]*fieldMap)}
+	if err := yaml.Unmarshal(migrateYaml, &m.table); err != nil {
+		return nil, fmt.Errorf("failed to parse field-mapping table: %w", err)
+	}
+	return m, nil
+}
+
+func doMigrate(_ *cobra.Command, a []string) error {
+	m, err := NewMigrator(migrateArgs.Conf)
+	if err != nil {
+		return err
+	}
+	result, err := m.Migrate(a[0], migrateArgs.Query)
+	if err != nil {
+		return err
+	}
+
+	if migrateArgs.Output != "" {
+		if err := os.WriteFile(migrateArgs.Output, []byte(result.Template), 0o600); err != nil {
+			return err
+		}
+		_, _ = fmt.Fprintf(os.Stderr, "template written to %s\n", migrateArgs.Output)
+	} else {
+		fmt.Print(result.Template)
+	}
+
+	if result.Pair != "" {
+		_, _ = fmt.Fprintf(os.Stderr, "mapped %d counters to %s using %s\n", result.Mapped, result.Query, result.Pair)
+	} else {
+		_, _ = fmt.Fprintf(os.Stderr, "mapped %d counters to %s using the field-mapping table\n", result.Mapped, result.Query)
+	}
+	if len(result.Unmapped) > 0 {
+		_, _ = fmt.Fprintf(os.Stderr, "%d counters have no REST equivalent:\n", len(result.Unmapped))
+		for _, u := range result.Unmapped {
+			_, _ = fmt.Fprintf(os.Stderr, "  %s\n", u)
+		}
+	}
+	return nil
+}
+
+// isPerfTemplate returns true when path is a ZapiPerf template, i.e. the nearest zapi or zapiperf directory in path
+// is zapiperf. Outside these directories, the query decides: ZAPI APIs are hyphenated, ZapiPerf queries are counter
+// object names.
+func isPerfTemplate(path string, query string) (bool, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return false, err
+	}
+	for dir := filepath.Dir(abs); dir != filepath.Dir(dir); dir = filepath.Dir(dir) {
+		switch filepath.Base(dir) {
+		case "zapiperf":
+			return true, nil
+		case "zapi":
+			return false, nil
+		}
+	}
+	return !strings.Contains(query, "-"), nil
+}
+
+type migratedCounter struct {
+	counter string
+	display string
+}
+
+func (c migratedCounter) sortKey() string {
+	switch {
+	case strings.HasPrefix(c.counter, "^^"):
+		return "0" + c.counter
+	case strings.HasPrefix(c.counter, "^"):
+		return "1" + c.counter
+	}
+	return "2" + c.counter
+}
+
+// Migrate converts the ZAPI or ZapiPerf template at path. A non-empty query overrides the REST API.
+func (m *Migrator) Migrate(path string, query string) (*Migration, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	model, err := unmarshalModel(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	isPerf, err := isPerfTemplate(path, model.Query)
+	if err != nil {
+		return nil, err
+	}
+	zapiDir, restDir := "zapi", "rest"
+	if isPerf {
+		zapiDir, restDir = "zapiperf", "restperf"
+	}
+	pairs, err := m.loadPairs(zapiDir, restDir)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &Migration{Query: query}
+	pair := pairs[model.Query]
+	if pair != nil {
+		result.Pair = pair.template
+	}
+	if result.Query == "" {
+		switch {
+		case pair != nil:
+			result.Query = pair.query
+		case m.table.Queries[model.Query] != "":
+			result.Query = m.table.Queries[model.Query]
+		case isPerf:
+			result.Query = perfTablePrefix + model.Query
+		default:
+			return nil, fmt.Errorf("no REST API is known for %s, use --query to set one", model.Query)
+		}
+	}
+
+	var (
+		counters  []migratedCounter
+		unmapped  []string
+		endpoints = make(map[string][]migratedCounter)
+	)
+	for _, metric := range model.metrics {
+		zapiPath := strings.Join(append(slices.Clone(metric.parents), metric.left), ".")
+		zapiSigil := sigil(metric.line)
+		display := metric.right
+		if display == "" {
+			if isPerf {
+				display = zapiPerfDisplay(metric.left, model.Object)
+			} else {
+				display = template2.ParseZAPIDisplay(model.Object, append(slices.Clone(metric.parents), metric.left))
+			}
+		}
+
+		field, ok := m.lookup(pair, zapiPath, metric.left, isPerf)
+		if !ok {
+			u := zapiSigil + zapiPath
+			if display != metric.left {
+				u += " => " + display
+			}
+			unmapped = append(unmapped, u)
+			continue
+		}
+		result.Mapped++
+
+		counter := field.counter
+		if !isPerf {
+			// the ZAPI template decides which fields are keys and labels
+			counter = zapiSigil + strings.TrimLeft(counter, "^")
+		}
+		c := migratedCounter{counter: counter, display: display}
+		if field.query == "" || field.query == result.Query {
+			counters = append(counters, c)
+		} else {
+			endpoints[field.query] = append(endpoints[field.query], c)
+		}
+	}
+	result.Unmapped = unmapped
+	result.Template = m.render(path, data, model, result, counters, endpoints, pair)
+
+	return result, nil
+}
+
+func (m *Migrator) lookup(pair *fieldMap, zapiPath string, leaf string, isPerf bool) (restField, bool) {
+	if pair != nil {
+		if f, ok := pair.fields[zapiPath]; ok {
+			return f, true
+		}
+	}
+	table := m.table.Fields
+	if isPerf {
+		table = m.table.PerfFields
+	}
+	if f, ok := table[leaf]; ok {
+		return restField{counter: f}, true
+	}
+	return restField{}, false
+}
+
+func (m *Migrator) render(path string, data []byte, model Model, result *Migration, counters []migratedCounter,
+	endpoints map[string][]migratedCounter, pair *fieldMap) string {
+
+	byKind := func(cs []migratedCounter) {
+		sort.SliceStable(cs, func(i, j int) bool { return cs[i].sortKey() < cs[j].sortKey() })
+	}
+	byKind(counters)
+
+	endpointQueries := make([]string, 0, len(endpoints))
+	for q, cs := range endpoints {
+		// endpoint records are joined to instances by key
+		for _, key := range pair.endpointKeys[q] {
+			if !slices.ContainsFunc(cs, func(c migratedCounter) bool { return c.counter == key.counter }) {
+				cs = append(cs, key)
+			}
+		}
+		byKind(cs)
+		endpoints[q] = cs
+		endpointQueries = append(endpointQueries, q)
+	}
+	slices.Sort(endpointQueries)
+
+	width := 0
+	for _, c := range counters {
+		width = max(width, len(c.counter))
+	}
+	for _, cs := range endpoints {
+		for _, c := range cs {
+			width = max(width, len(c.counter))
+		}
+	}
+	width += 2
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "# Migrated from %s by harvest template migrate.\n", filepath.Base(path))
+	if len(result.Unmapped) > 0 {
+		fmt.Fprintf(&sb, "# %d ZAPI counters have no REST equivalent and are commented out.\n", len(result.Unmapped))
+	}
+	sb.WriteString("\n")
+	for _, kv := range [][2]string{{"name:", model.Name}, {"query:", result.Query}, {"object:", model.Object}} {
+		fmt.Fprintf(&sb, "%-*s%s\n", width+4, kv[0], kv[1])
+	}
+	if block := topLevelBlock(data, "client_timeout"); block != "" {
+		sb.WriteString("\n" + block)
+	}
+
+	sb.WriteString("\ncounters:\n")
+	for _, c := range counters {
+		fmt.Fprintf(&sb, "  - %-*s=> %s\n", width, c.counter, c.display)
+	}
+	for _, u := range result.Unmapped {
+		fmt.Fprintf(&sb, "#  - %s\n", u)
+	}
+
+	if len(endpointQueries) > 0 {
+		sb.WriteString("\nendpoints:\n")
+		for _, q := range endpointQueries {
+			fmt.Fprintf(&sb, "  - query: %s\n    counters:\n", q)
+			for _, c := range endpoints[q] {
+				fmt.Fprintf(&sb, "      - %-*s=> %s\n", width-2, c.counter, c.display)
+			}
+		}
+	}
+
+	for _, key := range []string{"plugins", "export_options"} {
+		if block := topLevelBlock(data, key); block != "" {
+			sb.WriteString("\n" + block)
+		}
+	}
+
+	return sb.String()
+}
+
+// loadPairs maps each ZAPI API to the REST template of the same object, pairing the objects of
+// conf/<zapiDir>/default.yaml and conf/<restDir>/default.yaml by name
+func (m *Migrator) loadPairs(zapiDir string, restDir string) (map[string]*fieldMap, error) {
+	if pairs, ok := m.pairs[zapiDir]; ok {
+		return pairs, nil
+	}
+
+	zapiObjects, err := defaultObjects(filepath.Join(m.confDir, zapiDir, "default.yaml"))
+	if err != nil {
+		return nil, err
+	}
+	restObjects, err := defaultObjects(filepath.Join(m.confDir, restDir, "default.yaml"))
+	if err != nil {
+		return nil, err
+	}
+
+	pairs := make(map[string]*fieldMap)
+	for name, zapiFile := range zapiObjects {
+		restFile, ok := restObjects[name]
+		if !ok {
+			continue
+		}
+		zapiPath := latestTemplate(filepath.Join(m.confDir, zapiDir, "cdot"), zapiFile)
+		restPath := latestTemplate(filepath.Join(m.confDir, restDir), restFile)
+		if zapiPath == "" || restPath == "" {
+			continue
+		}
+		zapiModel, err := readModel(zapiPath)
+		if err != nil {
+			return nil, err
+		}
+		restModel, err := readModel(restPath)
+		if err != nil {
+			return nil, err
+		}
+		fm, ok := pairs[zapiModel.Query]
+		if !ok {
+			fm = &fieldMap{
+				query:        restModel.Query,
+				template:     restPath,
+				fields:       make(map[string]restField),
+				endpointKeys: make(map[string][]migratedCounter),
+			}
+			pairs[zapiModel.Query] = fm
+		}
+		addPair(fm, zapiModel, restModel, zapiDir == "zapiperf")
+	}
+
+	m.pairs[zapiDir] = pairs
+	return pairs, nil
+}
+
+// addPair matches the counters of a ZAPI and REST template of the same object by exported name
+func addPair(fm *fieldMap, zapiModel Model, restModel Model, isPerf bool) {
+	byDisplay := make(map[string]restField)
+	add := func(query string, metrics []Metric) {
+		for _, rm := range metrics {
+			display := rm.right
+			if display == "" {
+				display = strings.ReplaceAll(rm.left, ".", "_")
+			}
+			if _, ok := byDisplay[display]; !ok {
+				byDisplay[display] = restField{query: query, counter: sigil(rm.line) + rm.left}
+			}
+		}
+	}
+	add("", restModel.metrics)
+	for _, ep := range restModel.Endpoints {
+		add(ep.Query, ep.Metrics)
+		for _, em := range ep.Metrics {
+			if strings.HasPrefix(em.line, "^^") {
+				display := cmp.Or(em.right, em.left)
+				fm.endpointKeys[ep.Query] = append(fm.endpointKeys[ep.Query], migratedCounter{counter: "^^" + em.left, display: display})
+			}
+		}
+	}
+
+	for _, zm := range zapiModel.metrics {
+		zapiPath := strings.Join(append(slices.Clone(zm.parents), zm.left), ".")
+		display := zm.right
+		if display == "" {
+			if isPerf {
+				display = zapiPerfDisplay(zm.left, zapiModel.Object)
+			} else {
+				display = template2.ParseZAPIDisplay(zapiModel.Object, append(slices.Clone(zm.parents), zm.left))
+			}
+		}
+		if f, ok := byDisplay[display]; ok {
+			fm.fields[zapiPath] = f
+		}
+	}
+}
+
+func readModel(path string) (Model, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Model{}, err
+	}
+	model, err := unmarshalModel(data)
+	if err != nil {
+		return Model{}, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return model, nil
+}
+
+func defaultObjects(path string) (map[string]string, error) {
+	t, err := tree.ImportYaml(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	objects := t.GetChildS("objects")
+	if objects == nil {
+		return nil, errors.New(path + " has no objects")
+	}
+	result := make(map[string]string)
+	for _, o := range objects.GetChildren() {
+		file := o.GetContentS()
+		// templates of another collector, e.g. KeyPerf:volume.yaml, still name the same object
+		if _, name, ok := collector.ParseTemplateRef(file); ok {
+			file = name
+		}
+		result[o.GetNameS()] = file
+	}
+	return result, nil
+}
+
+// latestTemplate returns the path of file in the highest version directory of dir that has it
+func latestTemplate(dir string, file string) string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return ""
+	}
+	var versions []*version.Version
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		v, err := version.NewVersion(e.Name())
+		if err != nil {
+			continue
+		}
+		if _, err := os.Stat(filepath.Join(dir, e.Name(), file)); err == nil {
+			versions = append(versions, v)
+		}
+	}
+	if len(versions) == 0 {
+		return ""
+	}
+	sort.Sort(version.Collection(versions))
+	return filepath.Join(dir, versions[len(versions)-1].Original(), file)
+}
+
+// topLevelBlock returns the lines of the top-level key and its children, comments included
+func topLevelBlock(data []byte, key string) string {
+	var (
+		sb     strings.Builder
+		inside bool
+	)
+	for line := range strings.Lines(string(data)) {
+		isTopLevel := line != "" && line[0] != ' ' && line[0] != '#' && line[0] != '-' && strings.TrimSpace(line) != ""
+		if isTopLevel {
+			inside = strings.HasPrefix(line, key+":")
+		}
+		if inside {
+			sb.WriteString(line)
+		}
+	}
+	return strings.TrimRight(sb.String(), "\n") + strings.Repeat("\n", min(1, sb.Len()))
+}
+
+// zapiPerfDisplay is the name the ZapiPerf collector exports a counter without a display name as
+func zapiPerfDisplay(counter string, object string) string {
+	if counter == "instance_name" {
+		return object
+	}
+	display := strings.ReplaceAll(counter, "-", "_")
+	if after, ok := strings.CutPrefix(display, object); ok {
+		display = strings.TrimPrefix(after, "_")
+	}
+	return display
+}
+
+func sigil(line string) string {
+	switch {
+	case strings.HasPrefix(line, "^^"):
+		return "^^"
+	case strings.HasPrefix(line, "^"):
+		return "^"
+	}
+	return ""
+}
+
+func init() {
+	Cmd.AddCommand(migrateCmd)
+	flags := migrateCmd.Flags()
+	flags.StringVar(&migrateArgs.Conf, "conf", conf.Path("conf"), "Harvest conf directory with the shipped templates")
+	flags.StringVar(&migrateArgs.Query, "query", "", "REST API of the generated template. Defaults to the API of the shipped REST template of the same object")
+	flags.StringVarP(&migrateArgs.Output, "output", "o", "", "Write the template to this file instead of stdout")
+
+	Cmd.SetUsageTemplate(Cmd.UsageTemplate() + `
+Examples:
+  # Convert a custom ZAPI template and list the counters that have no REST equivalent
+  bin/harvest template migrate conf/zapi/cdot/9.8.0/custom_volume.yaml -o conf/rest/9.12.0/custom_volume.yaml
+
+  # Convert a custom ZapiPerf template
+  bin/harvest template migrate conf/zapiperf/cdot/9.8.0/custom_workload.yaml
+`)
+}
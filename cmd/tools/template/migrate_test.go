@@ -0,0 +1,126 @@
+package template
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/netapp/harvest/v2/assert"
+	"github.com/netapp/harvest/v2/pkg/tree"
+)
+
+func TestMigrate(t *testing.T) {
+	m, err := NewMigrator(toConf)
+	assert.Nil(t, err)
+
+	result, err := m.Migrate("testdata/zapi/custom_volume.yaml", "")
+	assert.Nil(t, err)
+	assert.Equal(t, result.Query, "api/private/cli/volume")
+	assert.Equal(t, result.Pair, toConf+"/rest/9.14.0/volume.yaml")
+	assert.Equal(t, result.Mapped, 6)
+	assert.Equal(t, len(result.Unmapped), 1)
+	assert.Equal(t, result.Unmapped[0], "volume-attributes.volume-inode-attributes.files-private-used => inode_files_private_used")
+
+	n, err := tree.LoadYaml([]byte(result.Template))
+	assert.Nil(t, err)
+	assert.Equal(t, n.GetChildContentS("name"), "CustomVolume")
+	assert.Equal(t, n.GetChildContentS("query"), "api/private/cli/volume")
+
+	counters := n.GetChildS("counters").GetAllChildContentS()
+	for _, want := range []string{
+		// mapped from the shipped templates
+		"^^instance_uuid => uuid",
+		"^volume => volume",
+		"^vserver => svm",
+		// exported names are those of the ZAPI template
+		"size => space_size",
+		"used => space_size_used",
+		// mapped from the field-mapping table
+		"^comment => id_comment",
+	} {
+		assert.True(t, hasCounter(counters, want))
+	}
+
+	// plugins and export_options are copied
+	assert.NotNil(t, n.GetChildS("plugins").GetChildS("LabelAgent"))
+	assert.Equal(t, len(n.GetChildS("export_options").GetChildS("instance_keys").GetAllChildContentS()), 2)
+	assert.True(t, strings.Contains(result.Template, "#  - volume-attributes.volume-inode-attributes.files-private-used"))
+}
+
+func TestMigrateQuery(t *testing.T) {
+	m, err := NewMigrator(toConf)
+	assert.Nil(t, err)
+
+	result, err := m.Migrate("testdata/zapi/custom_volume.yaml", "api/storage/volumes")
+	assert.Nil(t, err)
+	assert.Equal(t, result.Query, "api/storage/volumes")
+}
+
+// TestMigrateShipped migrates every shipped ZAPI and ZapiPerf template that has a REST pair and checks that none of
+// their counters are lost
+func TestMigrateShipped(t *testing.T) {
+	m, err := NewMigrator(toConf)
+	assert.Nil(t, err)
+
+	for _, dirs := range [][2]string{{"zapi", "rest"}, {"zapiperf", "restperf"}} {
+		pairs, err := m.loadPairs(dirs[0], dirs[1])
+		assert.Nil(t, err)
+		assert.True(t, len(pairs) > 10)
+	}
+
+	perf, err := m.Migrate(toConf+"/zapiperf/cdot/9.8.0/volume.yaml", "")
+	assert.Nil(t, err)
+	assert.Equal(t, perf.Query, "api/cluster/counter/tables/volume")
+	assert.Equal(t, len(perf.Unmapped), 0)
+	n, err := tree.LoadYaml([]byte(perf.Template))
+	assert.Nil(t, err)
+	assert.True(t, hasCounter(n.GetChildS("counters").GetAllChildContentS(), "average_latency => avg_latency"))
+
+	qtree, err := m.Migrate(toConf+"/zapi/cdot/9.8.0/qtree.yaml", "")
+	assert.Nil(t, err)
+	assert.Equal(t, len(qtree.Unmapped), 0)
+	n, err = tree.LoadYaml([]byte(qtree.Template))
+	assert.Nil(t, err)
+	// oplocks and status come from the private CLI endpoint of the REST template
+	endpoint := n.GetChildS("endpoints").GetChildren()[0]
+	assert.Equal(t, endpoint.GetChildContentS("query"), "api/private/cli/qtree")
+	assert.True(t, hasCounter(endpoint.GetChildS("counters").GetAllChildContentS(), "^^vserver => svm"))
+}
+
+func hasCounter(counters []string, want string) bool {
+	return slices.ContainsFunc(counters, func(c string) bool {
+		return strings.Join(strings.Fields(c), " ") == want
+	})
+}
+
+func TestMigrateUnknownQuery(t *testing.T) {
+	m, err := NewMigrator("testdata")
+	assert.Nil(t, err)
+	m.pairs["zapi"] = map[string]*fieldMap{}
+
+	_, err = m.Migrate("testdata/zapi/custom_volume.yaml", "")
+	assert.NotNil(t, err)
+	assert.True(t, strings.Contains(err.Error(), "--query"))
+}
+
+func TestIsPerfTemplate(t *testing.T) {
+	tests := []struct {
+		path  string
+		query string
+		want  bool
+	}{
+		{path: "conf/zapiperf/cdot/9.8.0/volume.yaml", query: "volume", want: true},
+		{path: "conf/zapi/cdot/9.8.0/volume.yaml", query: "volume-get-iter", want: false},
+		{path: "conf/zapiperf/cdot/9.8.0/zapi/custom.yaml", query: "volume", want: false},
+		// outside a zapi or zapiperf directory, the query decides
+		{path: "custom/volume.yaml", query: "volume", want: true},
+		{path: "custom/volume.yaml", query: "volume-get-iter", want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path+" "+tt.query, func(t *testing.T) {
+			got, err := isPerfTemplate(tt.path, tt.query)
+			assert.Nil(t, err)
+			assert.Equal(t, got, tt.want)
+		})
+	}
+}
@@ -8,6 +8,8 @@ import (
 	"github.com/goccy/go-yaml/parser"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin/aggregator"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin/anomaly"
+	"github.com/netapp/harvest/v2/cmd/poller/plugin/forecast"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin/labelagent"
 	max2 "github.com/netapp/harvest/v2/cmd/poller/plugin/maxplugin"
 	"github.com/netapp/harvest/v2/cmd/poller/plugin/metricagent"
@@ -188,7 +190,7 @@ func addEndpoints(tm *Model, n ast.Node, parents []string) {
 		for _, ikn := range sn.Values {
 			mn, ok := ikn.(*ast.MappingNode)
 			if ok {
-				query := node.ToString(mn.Values[0].Key)
+				query := node.ToString(searchNode(mn, "query"))
 				metrics := make([]Metric, 0)
 				countersNode := searchNode(mn, "counters")
 				flattenCounters(countersNode, &metrics, parents)
@@ -288,6 +290,13 @@ func trimComment(text string) string {
 	return strings.TrimSpace(text)
 }
 
+// BuiltInPluginMetrics returns a model with the metrics the built-in plugins of template create
+func BuiltInPluginMetrics(template *node.Node) (Model, error) {
+	var model Model
+	err := findBuiltInPlugins(template, &model)
+	return model, err
+}
+
 func findBuiltInPlugins(template *node.Node, model *Model) error {
 	var ee []error
 	template.PreprocessTemplate()
@@ -312,6 +321,16 @@ func findBuiltInPlugins(template *node.Node, model *Model) error {
 		ee = append(ee, err)
 	}
 
+	err = readForecast(template, model)
+	if err != nil {
+		ee = append(ee, err)
+	}
+
+	err = readAnomaly(template, model)
+	if err != nil {
+		ee = append(ee, err)
+	}
+
 	return errors.Join(ee...)
 }
 
@@ -330,6 +349,36 @@ func readMax(template *node.Node, model *Model) error {
 	return nil
 }
 
+func readForecast(template *node.Node, model *Model) error {
+	children := template.SearchChildren([]string{"plugins", "Forecast"})
+	if len(children) == 0 {
+		return nil
+	}
+	abc := plugin.AbstractPlugin{Params: children[0]}
+	f := forecast.New(&abc)
+	err := f.Init(conf.Remote{})
+	if err != nil {
+		return err
+	}
+	model.PluginMetrics = append(model.PluginMetrics, f.NewMetrics()...)
+	return nil
+}
+
+func readAnomaly(template *node.Node, model *Model) error {
+	children := template.SearchChildren([]string{"plugins", "Anomaly"})
+	if len(children) == 0 {
+		return nil
+	}
+	abc := plugin.AbstractPlugin{Params: children[0]}
+	a := anomaly.New(&abc)
+	err := a.Init(conf.Remote{})
+	if err != nil {
+		return err
+	}
+	model.PluginMetrics = append(model.PluginMetrics, a.NewMetrics()...)
+	return nil
+}
+
 func readMetricAgent(template *node.Node, model *Model) error {
 	children := template.SearchChildren([]string{"plugins", "MetricAgent"})
 	if len(children) == 0 {
@@ -393,6 +442,8 @@ func findCustomPlugins(path string, template *node.Node, model *Model) error {
 		"MetricAgent": true,
 		"Aggregator":  true,
 		"Max":         true,
+		"Forecast":    true,
+		"Anomaly":     true,
 		"Tenant":      true,
 	}
 	for _, child := range plug[0].Children {
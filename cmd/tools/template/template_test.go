@@ -620,3 +620,26 @@ func shortPath(path string) string {
 	}
 	return path
 }
+
+func TestEndpointQuery(t *testing.T) {
+	model, err := unmarshalModel([]byte(`
+name:  Disk
+query: api/storage/disks
+object: disk
+counters:
+  - ^^uid => uuid
+endpoints:
+  - query: api/private/cli/disk
+    counters:
+      - ^^uid       => uuid
+      - sectors_read => stats_sectors_read
+  - counters:
+      - ^type
+    query: api/storage/shelves
+`))
+	assert.Nil(t, err)
+	assert.Equal(t, len(model.Endpoints), 2)
+	assert.Equal(t, model.Endpoints[0].Query, "api/private/cli/disk")
+	assert.Equal(t, len(model.Endpoints[0].Metrics), 2)
+	assert.Equal(t, model.Endpoints[1].Query, "api/storage/shelves")
+}
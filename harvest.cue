@@ -33,6 +33,16 @@ label: [string]: string
 	httpsd?: #HTTPSD
 }
 
+#RelabelConfig: {
+	action?:        "replace" | "keep" | "drop" | "hashmod" | "labelmap" | "labeldrop" | "labelkeep"
+	source_labels?: [...string]
+	separator?:     string
+	regex?:         string
+	modulus?:       int
+	target_label?:  string
+	replacement?:   string
+}
+
 #Prom: {
 	add_meta_tags?: bool
 	addr?:          string // deprecated
@@ -41,6 +51,7 @@ label: [string]: string
 	local_http_addr?: "0.0.0.0" | "localhost" | "127.0.0.1"
 	port?:            int
 	port_range?:      string
+	relabel_configs?: [...#RelabelConfig]
 	sort_labels?:     bool
 	tls?:             #TLS
 	disk_cache?:      #DiskCache
@@ -52,6 +63,7 @@ label: [string]: string
 	bucket?:  string
 	exporter: "InfluxDB"
 	org?:     string
+	relabel_configs?: [...#RelabelConfig]
 	token?:   string
 	url?:     string
 }
@@ -67,12 +79,75 @@ label: [string]: string
 	timeout?:  string
 }
 
+#CredentialsProvider: {
+	type:            "vault" | "kubernetes" | "env"
+	schedule?:       string
+	timeout?:        string
+	username_key?:   string
+	password_key?:   string
+	auth_token_key?: string
+	cert_key?:       string
+	key_key?:        string
+
+	// vault
+	addr?:             string
+	namespace?:        string
+	mount?:            string
+	path?:             string
+	auth_method?:      "token" | "approle" | "jwt"
+	auth_mount?:       string
+	token?:            string
+	token_file?:       string
+	role_id?:          string
+	secret_id?:        string
+	secret_id_file?:   string
+	role?:             string
+	jwt_file?:         string
+	ca_cert?:          string
+	use_insecure_tls?: bool
+
+	// kubernetes
+	dir?: string
+}
+
+#OAuth2: {
+	token_url:           string
+	client_id:           string
+	client_secret?:      string
+	client_secret_file?: string
+	client_auth?:        "basic" | "post"
+	scope?:              string
+	audience?:           string
+	timeout?:            string
+	ca_cert?:            string
+	use_insecure_tls?:   bool
+}
+
+#RateLimit: {
+	rate:           number
+	burst?:         int
+	min_rate?:      number
+	slow_response?: string
+	max_wait?:      string
+}
+
 #Recorder: {
 	path: string
 	mode: "record" | "replay"
 	keep_last?: int
 }
 
+#CardinalityLimits: {
+	max_instances?:    int
+	max_label_values?: int
+	labels?: [string]: int
+}
+
+#Cardinality: {
+	#CardinalityLimits
+	objects?: [string]: #CardinalityLimits
+}
+
 #CollectorDef: {
 	[Name=_]: [...string]
 }
@@ -81,14 +156,16 @@ Pollers: [Name=_]: #Poller
 
 #Poller: {
 	addr?:               string
-	auth_style?:         "basic_auth" | "certificate_auth"
+	auth_style?:         "basic_auth" | "certificate_auth" | "oauth2"
 	ca_cert?:            string
+	cardinality?:        #Cardinality
 	certificate_script?: #CertificateScript
 	client_timeout?:     string
 	cm_perf_manifest?:   string
 	collectors?:         [...#CollectorDef] | [...string]
 	conf_path?:          string
 	credentials_file?:   string
+	credentials_provider?: #CredentialsProvider
 	credentials_script?: #CredentialsScript
 	datacenter?:         string
 	disabled?:           bool
@@ -99,11 +176,14 @@ Pollers: [Name=_]: #Poller
 	log:                 [...string]
 	log_max_bytes?:      int
 	log_max_files?:      int
+	oauth2?:             #OAuth2
 	password?:           string
 	poller_log_schedule?: string
 	prefer_zapi?:        bool
 	prom_port?:          int
+	rate_limit?:         #RateLimit
 	recorder?:           #Recorder
+	rest_cache_ttl?:     string
 	ssl_cert?:           string
 	ssl_key?:            string
 	tls_min_version?:    string
@@ -12,6 +12,7 @@ import (
 	"github.com/netapp/harvest/v2/pkg/collector"
 	"github.com/netapp/harvest/v2/pkg/conf"
 	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/ratelimit"
 	"github.com/netapp/harvest/v2/pkg/requests"
 	"github.com/netapp/harvest/v2/pkg/tree"
 	"github.com/netapp/harvest/v2/pkg/tree/node"
@@ -39,6 +40,7 @@ type Client struct {
 	auth       *auth.Credentials
 	Metadata   *collector.Metadata
 	remote     conf.Remote
+	limiter    *ratelimit.Limiter
 }
 
 type Response struct {
@@ -62,6 +64,7 @@ func New(poller *conf.Poller, c *auth.Credentials) (*Client, error) {
 	client = Client{
 		auth:     c,
 		Metadata: &collector.Metadata{},
+		limiter:  ratelimit.For(poller),
 	}
 	client.Logger = slog.Default().With(slog.String("Zapi", "Client"))
 
@@ -427,7 +430,7 @@ func (c *Client) invokeWithAuthRetry(withTimers bool, headers ...map[string]stri
 	if err != nil {
 		return nil, 0, 0, err
 	}
-	if pollerAuth.HasCredentialScript {
+	if pollerAuth.Refreshable {
 		// Save the buffer in case it needs to be replayed after an auth failure
 		// This is required because Go clears the buffer when making a POST request
 		buffer = *c.buffer
@@ -440,7 +443,7 @@ func (c *Client) invokeWithAuthRetry(withTimers bool, headers ...map[string]stri
 			// If this is an auth failure and the client is using a credential script,
 			// expire the current credentials, call the script again, update the client's password,
 			// and try again
-			if errors.Is(he, errs.ErrAuthFailed) && pollerAuth.HasCredentialScript {
+			if errors.Is(he, errs.ErrAuthFailed) && pollerAuth.Refreshable {
 				c.auth.Expire()
 				pollerAuth2, err2 := c.auth.GetPollerAuth()
 				if err2 != nil {
@@ -496,7 +499,12 @@ func (c *Client) invoke(withTimers bool, headers ...map[string]string) (*node.No
 		}
 	}
 
+	if err = c.limiter.Wait(c.request.Context()); err != nil {
+		return result, responseT, parseT, err
+	}
+	sent := time.Now()
 	if response, err = c.client.Do(c.request); err != nil {
+		c.limiter.Observe(0, time.Since(sent), false)
 		return result, responseT, parseT, errs.New(errs.ErrConnection, err.Error())
 	}
 	//goland:noinspection GoUnhandledErrorResult
@@ -506,6 +514,7 @@ func (c *Client) invoke(withTimers bool, headers ...map[string]string) (*node.No
 	}
 
 	if response.StatusCode != http.StatusOK {
+		c.limiter.Observe(response.StatusCode, time.Since(sent), false)
 		if response.StatusCode == http.StatusUnauthorized {
 			return result, responseT, parseT, errs.New(errs.ErrAuthFailed, response.Status, errs.WithStatus(response.StatusCode))
 		}
@@ -513,7 +522,11 @@ func (c *Client) invoke(withTimers bool, headers ...map[string]string) (*node.No
 	}
 
 	// read response body
-	if body, err = io.ReadAll(response.Body); err != nil {
+	body, err = io.ReadAll(response.Body)
+	// a CM reject is reported in the results of a 200 response, so the body is checked for its error number
+	cmReject := bytes.Contains(body, []byte(`errno="`+strconv.FormatInt(errs.CMReject.Code, 10)+`"`))
+	c.limiter.Observe(response.StatusCode, time.Since(sent), cmReject)
+	if err != nil {
 		return result, responseT, parseT, err
 	}
 	defer c.printRequestAndResponse(zapiReq, body)
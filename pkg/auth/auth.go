@@ -3,6 +3,7 @@ package auth
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
@@ -20,6 +21,7 @@ import (
 	"os"
 	"os/exec"
 	"path"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -33,25 +35,39 @@ const (
 	keyType         = "PRIVATE KEY"
 	// DefaultDialerTimeout limits the time spent establishing a TCP connection
 	DefaultDialerTimeout = 10 * time.Second
+	// DefaultMaxIdleConnsPerHost is the number of idle connections the shared transport keeps open to the cluster.
+	// Collectors and plugins share the transport, so keep more than http.DefaultMaxIdleConnsPerHost
+	DefaultMaxIdleConnsPerHost = 16
 )
 
 func NewCredentials(p *conf.Poller, logger *slog.Logger) *Credentials {
 	return &Credentials{
-		poller: p,
-		logger: logger,
-		authMu: &sync.Mutex{},
+		poller:      p,
+		logger:      logger,
+		authMu:      &sync.Mutex{},
+		transportMu: &sync.Mutex{},
 	}
 }
 
 type Credentials struct {
-	poller         *conf.Poller
-	nextUpdate     time.Time
-	logger         *slog.Logger
-	authMu         *sync.Mutex
-	cachedResponse ScriptResponse
+	poller             *conf.Poller
+	nextUpdate         time.Time
+	logger             *slog.Logger
+	authMu             *sync.Mutex
+	cachedResponse     ScriptResponse
+	provider           Provider
+	providerNextUpdate time.Time
+	cachedSecret       Secret
+	transportMu        *sync.Mutex
+	transport          *http.Transport
+	transportKey       string
+	tokenClient        *http.Client
+	accessToken        string
+	tokenRefresh       time.Time
 }
 
-// Expire will reset the credential schedule if the receiver has a CredentialsScript
+// Expire will reset the credential schedule if the receiver has a CredentialsScript or CredentialsProvider,
+// and drop the cached OAuth 2.0 access token.
 // Otherwise it will do nothing.
 // Resetting the schedule will cause the next call to Password to fetch the credentials
 func (c *Credentials) Expire() {
@@ -59,12 +75,14 @@ func (c *Credentials) Expire() {
 	if err != nil {
 		return
 	}
-	if !auth.HasCredentialScript {
+	if !auth.Refreshable {
 		return
 	}
 	c.authMu.Lock()
 	defer c.authMu.Unlock()
 	c.nextUpdate = time.Time{}
+	c.providerNextUpdate = time.Time{}
+	c.accessToken = ""
 }
 
 func (c *Credentials) certs(poller *conf.Poller) (string, error) {
@@ -100,6 +118,36 @@ func (c *Credentials) password(poller *conf.Poller) (ScriptResponse, error) {
 	return c.cachedResponse, nil
 }
 
+// secret returns the credentials read by the poller's CredentialsProvider.
+// The secret is cached until the provider's schedule expires or the provider reports a change.
+func (c *Credentials) secret(poller *conf.Poller) (Secret, error) {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	if c.provider == nil {
+		provider, err := newProvider(poller)
+		if err != nil {
+			return Secret{}, err
+		}
+		c.provider = provider
+	}
+
+	if time.Now().After(c.providerNextUpdate) || c.provider.Changed() {
+		secret, err := c.provider.Fetch()
+		if err != nil {
+			// Don't log the secret, only the provider type
+			c.logger.Error("Failed to fetch credentials", slogx.Err(err), slog.String("provider", poller.CredentialsProvider.Type))
+			return Secret{}, err
+		}
+		if secret.Username == "" {
+			secret.Username = poller.Username
+		}
+		c.cachedSecret = secret
+		c.providerNextUpdate = c.nextUpdateAfter(poller.CredentialsProvider.Schedule)
+	}
+	return c.cachedSecret, nil
+}
+
 func (c *Credentials) fetchPassword(p *conf.Poller) (ScriptResponse, error) {
 	response, err := c.execScript(p.CredentialsScript.Path, "credential", p.CredentialsScript.Timeout, func(ctx context.Context, path string) *exec.Cmd {
 		return exec.CommandContext(ctx, path, p.Addr, p.Username) // #nosec
@@ -216,12 +264,17 @@ func (c *Credentials) execScript(cmdPath string, kind string, timeout string, e
 }
 
 func (c *Credentials) setNextUpdate() {
-	schedule := c.poller.CredentialsScript.Schedule
+	c.nextUpdate = c.nextUpdateAfter(c.poller.CredentialsScript.Schedule)
+}
+
+// nextUpdateAfter returns when credentials fetched now should be fetched again.
+// A schedule of always returns the zero time, so every call fetches.
+func (c *Credentials) nextUpdateAfter(schedule string) time.Time {
 	if schedule == "" {
 		schedule = defaultSchedule
 	}
 	if strings.EqualFold(schedule, "always") {
-		return
+		return time.Time{}
 	}
 	duration, err := time.ParseDuration(schedule)
 	if err != nil {
@@ -233,27 +286,30 @@ func (c *Credentials) setNextUpdate() {
 		)
 		duration, _ = time.ParseDuration(defaultSchedule)
 	}
-	c.nextUpdate = time.Now().Add(duration)
+	return time.Now().Add(duration)
 }
 
 type PollerAuth struct {
-	Username             string
-	Password             string
-	AuthToken            string
-	IsCert               bool
-	HasCredentialScript  bool
-	HasCertificateScript bool
-	Schedule             string
-	PemCert              []byte
-	PemKey               []byte
-	CertPath             string
-	KeyPath              string
-	CaCertPath           string
-	insecureTLS          bool
+	Username              string
+	Password              string
+	AuthToken             string
+	IsCert                bool
+	HasCredentialScript   bool
+	HasCertificateScript  bool
+	HasCredentialProvider bool
+	IsOAuth2              bool
+	Refreshable           bool // credentials from a script, a provider, or OAuth 2.0 may change and are refreshed by Expire
+	Schedule              string
+	PemCert               []byte
+	PemKey                []byte
+	CertPath              string
+	KeyPath               string
+	CaCertPath            string
+	insecureTLS           bool
 }
 
 func (a PollerAuth) Certificate() (tls.Certificate, error) {
-	if a.HasCertificateScript {
+	if a.HasCertificateScript || a.HasCredentialProvider {
 		return tls.X509KeyPair(a.PemCert, a.PemKey)
 	}
 	if a.CertPath == "" {
@@ -300,7 +356,7 @@ func (c *Credentials) GetPollerAuth() (PollerAuth, error) {
 	if err != nil {
 		return PollerAuth{}, err
 	}
-	if auth.IsCert {
+	if auth.IsCert || auth.IsOAuth2 {
 		return auth, nil
 	}
 	if auth.Username != "" && auth.Password != "" {
@@ -334,6 +390,20 @@ func getPollerAuth(c *Credentials, poller *conf.Poller) (PollerAuth, error) {
 	if poller.AuthStyle == conf.CertificateAuth {
 		return handCertificateAuth(c, poller, insecureTLS)
 	}
+	if poller.AuthStyle == conf.OAuth2Auth {
+		token, err := c.oauth2Token(poller)
+		if err != nil {
+			return PollerAuth{}, err
+		}
+		return PollerAuth{
+			Username:    poller.Username,
+			AuthToken:   token,
+			IsOAuth2:    true,
+			Refreshable: true,
+			insecureTLS: insecureTLS,
+			CaCertPath:  poller.CaCertPath,
+		}, nil
+	}
 	if poller.Password != "" {
 		return PollerAuth{
 			Username:    poller.Username,
@@ -352,11 +422,28 @@ func getPollerAuth(c *Credentials, poller *conf.Poller) (PollerAuth, error) {
 			Password:            response.Data,
 			AuthToken:           response.AuthToken,
 			HasCredentialScript: true,
+			Refreshable:         true,
 			Schedule:            poller.CredentialsScript.Schedule,
 			insecureTLS:         insecureTLS,
 			CaCertPath:          poller.CaCertPath,
 		}, nil
 	}
+	if poller.CredentialsProvider.Type != "" {
+		secret, err := c.secret(poller)
+		if err != nil {
+			return PollerAuth{}, err
+		}
+		return PollerAuth{
+			Username:              secret.Username,
+			Password:              secret.Password,
+			AuthToken:             secret.AuthToken,
+			HasCredentialProvider: true,
+			Refreshable:           true,
+			Schedule:              poller.CredentialsProvider.Schedule,
+			insecureTLS:           insecureTLS,
+			CaCertPath:            poller.CaCertPath,
+		}, nil
+	}
 	if poller.CredentialsFile != "" {
 		err := conf.ReadCredentialFile(poller.CredentialsFile, poller)
 		if err != nil {
@@ -396,6 +483,33 @@ func handCertificateAuth(c *Credentials, poller *conf.Poller, insecureTLS bool)
 		}, nil
 	}
 
+	if poller.CredentialsProvider.Type != "" {
+		secret, err := c.secret(poller)
+		if err != nil {
+			return PollerAuth{}, err
+		}
+		if secret.Cert == "" {
+			return PollerAuth{}, errs.New(errs.ErrMissingParam, "credentials_provider certificate")
+		}
+		cert, key := []byte(secret.Cert), []byte(secret.Key)
+		if secret.Key == "" {
+			// the certificate and key are in one PEM blob
+			cert, key, err = extractCertAndKey(secret.Cert)
+			if err != nil {
+				return PollerAuth{}, err
+			}
+		}
+		return PollerAuth{
+			IsCert:                true,
+			HasCredentialProvider: true,
+			Refreshable:           true,
+			PemCert:               cert,
+			PemKey:                key,
+			insecureTLS:           insecureTLS,
+			CaCertPath:            poller.CaCertPath,
+		}, nil
+	}
+
 	var pathPrefix string
 	certPath := poller.SslCert
 	keyPath := poller.SslKey
@@ -441,17 +555,112 @@ func extractCertAndKey(blob string) ([]byte, []byte, error) {
 	return nil, nil, fmt.Errorf("unexpected PEM block1Type=%s block2Type=%s", block1.Type, block2.Type)
 }
 
+// Transport returns a round tripper for the poller.
+// Clients created with the same Credentials share one pooled http.Transport, so collectors and plugins reuse
+// connections and TLS sessions to the cluster. The transport is rebuilt when the poller's TLS settings or
+// client certificate change.
 func (c *Credentials) Transport(request *http.Request, poller *conf.Poller) (http.RoundTripper, error) {
-	var (
-		cert      tls.Certificate
-		transport *http.Transport
-	)
-
 	pollerAuth, err := c.GetPollerAuth()
 	if err != nil {
 		return nil, err
 	}
 
+	if !pollerAuth.IsCert {
+		if !pollerAuth.Refreshable {
+			if pollerAuth.Username == "" {
+				return nil, errs.New(errs.ErrMissingParam, "username")
+			} else if pollerAuth.Password == "" {
+				return nil, errs.New(errs.ErrMissingParam, "password")
+			}
+		}
+
+		if request != nil {
+			request.SetBasicAuth(pollerAuth.Username, pollerAuth.Password)
+		}
+	}
+
+	return c.roundTripper(poller, pollerAuth)
+}
+
+// TLSTransport returns a round tripper for endpoints that do not authenticate requests.
+// It uses the same TLS settings as Transport: ca_cert, tls_min_version, use_insecure_tls, and the client
+// certificate of certificate_auth, but does not read the poller's credentials.
+func (c *Credentials) TLSTransport(poller *conf.Poller) (http.RoundTripper, error) {
+	pollerAuth := PollerAuth{
+		insecureTLS: poller.UseInsecureTLS != nil && *poller.UseInsecureTLS,
+		CaCertPath:  poller.CaCertPath,
+	}
+	if poller.AuthStyle == conf.CertificateAuth {
+		var err error
+		if pollerAuth, err = c.GetPollerAuth(); err != nil {
+			return nil, err
+		}
+	}
+	return c.roundTripper(poller, pollerAuth)
+}
+
+func (c *Credentials) roundTripper(poller *conf.Poller, pollerAuth PollerAuth) (http.RoundTripper, error) {
+	transport, err := c.sharedTransport(poller, pollerAuth)
+	if err != nil {
+		return nil, err
+	}
+
+	if !poller.IsRecording() {
+		return transport, nil
+	}
+
+	switch poller.Recorder.Mode {
+	case "record":
+		return recording(poller, transport), nil
+	case "replay":
+		return replaying(poller), nil
+	default:
+		return nil, errs.New(errs.ErrInvalidParam, "recorder mode")
+	}
+}
+
+// sharedTransport returns the cached transport when its key matches, otherwise it builds a new transport and
+// closes the idle connections of the previous one
+func (c *Credentials) sharedTransport(poller *conf.Poller, pollerAuth PollerAuth) (*http.Transport, error) {
+	key := strings.Join([]string{
+		poller.Name,
+		poller.Addr,
+		poller.TLSMinVersion,
+		pollerAuth.CaCertPath,
+		strconv.FormatBool(pollerAuth.insecureTLS),
+		strconv.FormatBool(pollerAuth.IsCert),
+		pollerAuth.CertPath,
+		pollerAuth.KeyPath,
+		fmt.Sprintf("%x", sha256.Sum256(append(pollerAuth.PemCert, pollerAuth.PemKey...))),
+	}, "|")
+
+	c.transportMu.Lock()
+	defer c.transportMu.Unlock()
+
+	if c.transport != nil && c.transportKey == key {
+		return c.transport, nil
+	}
+
+	transport, err := c.newTransport(poller, pollerAuth)
+	if err != nil {
+		return nil, err
+	}
+	if c.transport != nil {
+		c.transport.CloseIdleConnections()
+	}
+	c.transport = transport
+	c.transportKey = key
+
+	return transport, nil
+}
+
+func (c *Credentials) newTransport(poller *conf.Poller, pollerAuth PollerAuth) (*http.Transport, error) {
+	var (
+		cert      tls.Certificate
+		transport *http.Transport
+		err       error
+	)
+
 	if pollerAuth.IsCert {
 		cert, err = pollerAuth.Certificate()
 		if err != nil {
@@ -467,18 +676,6 @@ func (c *Credentials) Transport(request *http.Request, poller *conf.Poller) (htt
 			},
 		}
 	} else {
-		if !pollerAuth.HasCredentialScript {
-			if pollerAuth.Username == "" {
-				return nil, errs.New(errs.ErrMissingParam, "username")
-			} else if pollerAuth.Password == "" {
-				return nil, errs.New(errs.ErrMissingParam, "password")
-			}
-		}
-
-		if request != nil {
-			request.SetBasicAuth(pollerAuth.Username, pollerAuth.Password)
-		}
-
 		transport = &http.Transport{
 			Proxy: http.ProxyFromEnvironment,
 			TLSClientConfig: &tls.Config{
@@ -489,6 +686,7 @@ func (c *Credentials) Transport(request *http.Request, poller *conf.Poller) (htt
 	}
 
 	transport.DialContext = (&net.Dialer{Timeout: DefaultDialerTimeout}).DialContext
+	transport.MaxIdleConnsPerHost = DefaultMaxIdleConnsPerHost
 
 	if poller.TLSMinVersion != "" {
 		tlsVersion := tlsVersion(poller.TLSMinVersion, c.logger)
@@ -513,16 +711,5 @@ func (c *Credentials) Transport(request *http.Request, poller *conf.Poller) (htt
 		}
 	}
 
-	if !poller.IsRecording() {
-		return transport, nil
-	}
-
-	switch poller.Recorder.Mode {
-	case "record":
-		return recording(poller, transport), nil
-	case "replay":
-		return replaying(poller), nil
-	default:
-		return nil, errs.New(errs.ErrInvalidParam, "recorder mode")
-	}
+	return transport, nil
 }
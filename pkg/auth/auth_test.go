@@ -3,6 +3,7 @@ package auth
 import (
 	"github.com/netapp/harvest/v2/assert"
 	"log/slog"
+	"net/http"
 	"os"
 	"testing"
 
@@ -166,6 +167,7 @@ Pollers:
 				Password:            "script-data-username-a.b.c",
 				IsCert:              false,
 				HasCredentialScript: true,
+				Refreshable:         true,
 			},
 			defaultDefined: true,
 			yaml: `
@@ -182,7 +184,7 @@ Pollers:
 		{
 			name:           "credentials_script with default username",
 			pollerName:     "test",
-			want:           PollerAuth{Username: "me", Password: "script-data-me-a.b.c", HasCredentialScript: true},
+			want:           PollerAuth{Username: "me", Password: "script-data-me-a.b.c", HasCredentialScript: true, Refreshable: true},
 			defaultDefined: true,
 			yaml: `
 Defaults:
@@ -197,7 +199,7 @@ Pollers:
 		{
 			name:       "no default",
 			pollerName: "test",
-			want:       PollerAuth{Username: "username", Password: "script-data-username-a.b.c", HasCredentialScript: true},
+			want:       PollerAuth{Username: "username", Password: "script-data-username-a.b.c", HasCredentialScript: true, Refreshable: true},
 			yaml: `
 Pollers:
   test:
@@ -248,7 +250,7 @@ Pollers:
 			name:       "poller and default credentials_script",
 			pollerName: "test",
 			// #nosec G101
-			want: PollerAuth{Username: "bat", Password: "script-data-bat-a.b.c", HasCredentialScript: true},
+			want: PollerAuth{Username: "bat", Password: "script-data-bat-a.b.c", HasCredentialScript: true, Refreshable: true},
 			yaml: `
 Defaults:
   use_insecure_tls: true
@@ -268,7 +270,7 @@ Pollers:
 			name:       "poller schedule",
 			pollerName: "test",
 			// #nosec G101
-			want:         PollerAuth{Username: "flo", Password: "script-data-flo-a.b.c", HasCredentialScript: true},
+			want:         PollerAuth{Username: "flo", Password: "script-data-flo-a.b.c", HasCredentialScript: true, Refreshable: true},
 			wantSchedule: "15m",
 			yaml: `
 Defaults:
@@ -291,7 +293,7 @@ Pollers:
 			name:       "defaults schedule",
 			pollerName: "test",
 			// #nosec G101
-			want:         PollerAuth{Username: "flo", Password: "script-data-flo-a.b.c", HasCredentialScript: true},
+			want:         PollerAuth{Username: "flo", Password: "script-data-flo-a.b.c", HasCredentialScript: true, Refreshable: true},
 			wantSchedule: "42m",
 			yaml: `
 Defaults:
@@ -450,6 +452,7 @@ Pollers:
 				Username:            "script-username",
 				Password:            "script-password",
 				HasCredentialScript: true,
+				Refreshable:         true,
 			},
 			yaml: `
 Pollers:
@@ -467,6 +470,7 @@ Pollers:
 				Username:            "username", // Fallback to the username provided in the poller configuration
 				Password:            "plain-text-password",
 				HasCredentialScript: true,
+				Refreshable:         true,
 			},
 			yaml: `
 Pollers:
@@ -484,6 +488,7 @@ Pollers:
 				Username:            "username", // Fallback to the username provided in the poller configuration
 				Password:            "password #\"`!@#$%^&*()-=[]|:'<>/ password",
 				HasCredentialScript: true,
+				Refreshable:         true,
 			},
 			yaml: `
 Pollers:
@@ -501,6 +506,7 @@ Pollers:
 				Username:            "script-username",
 				Password:            "script-password",
 				HasCredentialScript: true,
+				Refreshable:         true,
 			},
 			yaml: `
 Pollers:
@@ -518,6 +524,7 @@ Pollers:
 				Username:            "", // No username provided, so it should be empty
 				Password:            "plain-text-password",
 				HasCredentialScript: true,
+				Refreshable:         true,
 			},
 			yaml: `
 Pollers:
@@ -535,6 +542,7 @@ Pollers:
 				Username:            "myuser",
 				Password:            "my # password",
 				HasCredentialScript: true,
+				Refreshable:         true,
 			},
 			yaml: `
 Pollers:
@@ -552,6 +560,7 @@ Pollers:
 			want: PollerAuth{
 				AuthToken:           "abcd",
 				HasCredentialScript: true,
+				Refreshable:         true,
 			},
 			yaml: `
 Pollers:
@@ -568,6 +577,7 @@ Pollers:
 			want: PollerAuth{
 				AuthToken:           "abcd",
 				HasCredentialScript: true,
+				Refreshable:         true,
 				Password:            "script-password",
 			},
 			yaml: `
@@ -608,6 +618,7 @@ Pollers:
 			assert.Equal(t, got.AuthToken, tt.want.AuthToken)
 			assert.Equal(t, got.IsCert, tt.want.IsCert)
 			assert.Equal(t, got.HasCredentialScript, tt.want.HasCredentialScript)
+			assert.Equal(t, got.Refreshable, tt.want.Refreshable)
 
 			diff1 := cmp.Diff(tt.want.PemCert, got.PemCert)
 			if diff1 != "" {
@@ -627,3 +638,21 @@ Pollers:
 		})
 	}
 }
+
+func TestTransportShared(t *testing.T) {
+	poller := &conf.Poller{Name: "test", Addr: "a.b.c", Username: "user", Password: "pass"}
+	c := NewCredentials(poller, slog.Default())
+
+	t1, err := c.Transport(nil, poller)
+	assert.Nil(t, err)
+	t2, err := c.Transport(nil, poller)
+	assert.Nil(t, err)
+	assert.True(t, t1 == t2)
+	assert.Equal(t, t1.(*http.Transport).MaxIdleConnsPerHost, DefaultMaxIdleConnsPerHost)
+
+	// a TLS change builds a new transport
+	poller.TLSMinVersion = "tls13"
+	t3, err := c.Transport(nil, poller)
+	assert.Nil(t, err)
+	assert.False(t, t1 == t3)
+}
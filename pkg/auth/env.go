@@ -0,0 +1,25 @@
+package auth
+
+import (
+	"os"
+
+	"github.com/netapp/harvest/v2/pkg/errs"
+)
+
+// Env reads credentials from environment variables, by default HARVEST_<POLLER>_USERNAME, HARVEST_<POLLER>_PASSWORD,
+// HARVEST_<POLLER>_AUTH_TOKEN, HARVEST_<POLLER>_CERT, and HARVEST_<POLLER>_KEY
+type Env struct {
+	keys secretKeys
+}
+
+func (e *Env) Fetch() (Secret, error) {
+	secret := e.keys.secret(os.Getenv)
+	if secret.isEmpty() {
+		return Secret{}, errs.New(errs.ErrMissingParam, "environment variable "+e.keys.password+", "+e.keys.authToken+", or "+e.keys.cert)
+	}
+	return secret, nil
+}
+
+func (e *Env) Changed() bool {
+	return false
+}
@@ -0,0 +1,91 @@
+package auth
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
+)
+
+// watchInterval limits how often Kubernetes checks the mounted files for changes
+const watchInterval = 10 * time.Second
+
+// Kubernetes reads credentials from a mounted Kubernetes secret, one file per key.
+// Kubernetes updates mounted secrets in place, so the files are watched and a change triggers a new Fetch.
+type Kubernetes struct {
+	dir  string
+	keys secretKeys
+
+	mu        sync.Mutex
+	modTimes  map[string]time.Time
+	lastCheck time.Time
+}
+
+func newKubernetes(p *conf.Poller, keys secretKeys) (*Kubernetes, error) {
+	dir := p.CredentialsProvider.Dir
+	if dir == "" {
+		return nil, errs.New(errs.ErrMissingParam, "credentials_provider.dir")
+	}
+	return &Kubernetes{
+		dir:      expandName(dir, p),
+		keys:     keys,
+		modTimes: make(map[string]time.Time),
+	}, nil
+}
+
+func (k *Kubernetes) Fetch() (Secret, error) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	modTimes := make(map[string]time.Time)
+	secret := k.keys.secret(func(name string) string {
+		path := filepath.Join(k.dir, name)
+		info, err := os.Stat(path)
+		if err != nil {
+			return ""
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return ""
+		}
+		modTimes[name] = info.ModTime()
+		return strings.TrimSpace(string(data))
+	})
+	k.modTimes = modTimes
+	k.lastCheck = time.Now()
+
+	if secret.isEmpty() {
+		return Secret{}, errs.New(errs.ErrMissingParam, "secret file "+k.keys.password+", "+k.keys.authToken+", or "+k.keys.cert+" in "+k.dir)
+	}
+	return secret, nil
+}
+
+// Changed returns true when a secret file was added, removed, or modified since the last Fetch
+func (k *Kubernetes) Changed() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	if time.Since(k.lastCheck) < watchInterval {
+		return false
+	}
+	k.lastCheck = time.Now()
+
+	for _, name := range []string{k.keys.username, k.keys.password, k.keys.authToken, k.keys.cert, k.keys.key} {
+		info, err := os.Stat(filepath.Join(k.dir, name))
+		modTime, seen := k.modTimes[name]
+		if err != nil {
+			if seen {
+				return true
+			}
+			continue
+		}
+		if !seen || !info.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
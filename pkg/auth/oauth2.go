@@ -0,0 +1,149 @@
+package auth
+
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/requests"
+)
+
+const (
+	ClientAuthBasic = "basic"
+	ClientAuthPost  = "post"
+
+	defaultOAuth2Timeout = 10 * time.Second
+	// tokenRefreshMargin is how long before expiry a token is replaced, so requests never carry an expired token
+	tokenRefreshMargin = time.Minute
+	// defaultTokenLifetime is used when the issuer does not return expires_in
+	defaultTokenLifetime = 5 * time.Minute
+)
+
+// oauth2Token returns a cached access token, requesting a new one with the client credentials flow when the
+// cached token is missing or about to expire
+func (c *Credentials) oauth2Token(poller *conf.Poller) (string, error) {
+	c.authMu.Lock()
+	defer c.authMu.Unlock()
+
+	if c.accessToken != "" && time.Now().Before(c.tokenRefresh) {
+		return c.accessToken, nil
+	}
+
+	token, lifetime, err := c.requestToken(poller)
+	if err != nil {
+		return "", err
+	}
+	c.accessToken = token
+	c.tokenRefresh = time.Now().Add(lifetime - min(tokenRefreshMargin, lifetime/2))
+
+	return token, nil
+}
+
+func (c *Credentials) requestToken(poller *conf.Poller) (string, time.Duration, error) {
+	o := poller.OAuth2
+	if o.TokenURL == "" {
+		return "", 0, errs.New(errs.ErrMissingParam, "oauth2.token_url")
+	}
+	if o.ClientID == "" {
+		return "", 0, errs.New(errs.ErrMissingParam, "oauth2.client_id")
+	}
+	secret, err := fileOr(o.ClientSecret, o.ClientSecretFile)
+	if err != nil {
+		return "", 0, fmt.Errorf("failed to read oauth2.client_secret_file: %w", err)
+	}
+	if secret == "" {
+		return "", 0, errs.New(errs.ErrMissingParam, "oauth2.client_secret")
+	}
+
+	if c.tokenClient == nil {
+		c.tokenClient, err = newHTTPClient("oauth2", o.Timeout, defaultOAuth2Timeout, o.CaCertPath, o.UseInsecureTLS)
+		if err != nil {
+			return "", 0, err
+		}
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	if o.Scope != "" {
+		form.Set("scope", o.Scope)
+	}
+	if o.Audience != "" {
+		form.Set("audience", o.Audience)
+	}
+
+	clientAuth := strings.ToLower(cmp.Or(o.ClientAuth, ClientAuthBasic))
+	switch clientAuth {
+	case ClientAuthBasic:
+	case ClientAuthPost:
+		form.Set("client_id", o.ClientID)
+		form.Set("client_secret", secret)
+	default:
+		return "", 0, errs.New(errs.ErrInvalidParam, "oauth2.client_auth ("+o.ClientAuth+")")
+	}
+
+	req, err := requests.New(http.MethodPost, o.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if clientAuth == ClientAuthBasic {
+		req.SetBasicAuth(url.QueryEscape(o.ClientID), url.QueryEscape(secret))
+	}
+
+	resp, err := c.tokenClient.Do(req)
+	if err != nil {
+		return "", 0, errs.New(errs.ErrPermissionDenied, "oauth2 token request to "+o.TokenURL+" failed: "+err.Error())
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", 0, errs.New(errs.ErrPermissionDenied, "oauth2 token response from "+o.TokenURL+" failed: "+err.Error())
+	}
+
+	// RFC 6749 section 5
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		TokenType        string `json:"token_type"`
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	jsonErr := json.Unmarshal(body, &token)
+
+	if resp.StatusCode != http.StatusOK {
+		msg := fmt.Sprintf("oauth2 token request to %s rejected status=%d", o.TokenURL, resp.StatusCode)
+		if token.Error != "" {
+			msg += " error=" + token.Error
+		}
+		if token.ErrorDescription != "" {
+			msg += " description=" + token.ErrorDescription
+		}
+		return "", 0, errs.New(errs.ErrPermissionDenied, msg, errs.WithStatus(resp.StatusCode))
+	}
+	if jsonErr != nil {
+		return "", 0, errs.New(errs.ErrPermissionDenied, "oauth2 token response from "+o.TokenURL+" is not JSON: "+jsonErr.Error())
+	}
+	if token.AccessToken == "" {
+		return "", 0, errs.New(errs.ErrPermissionDenied, "oauth2 token response from "+o.TokenURL+" has no access_token")
+	}
+	if token.TokenType != "" && !strings.EqualFold(token.TokenType, "bearer") {
+		return "", 0, errs.New(errs.ErrPermissionDenied, "oauth2 token_type "+token.TokenType+" is not supported, only bearer")
+	}
+
+	lifetime := defaultTokenLifetime
+	if token.ExpiresIn > 0 {
+		lifetime = time.Duration(token.ExpiresIn) * time.Second
+	}
+
+	return token.AccessToken, lifetime, nil
+}
@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/netapp/harvest/v2/assert"
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
+)
+
+// newIssuer returns a mock OAuth 2.0 authorization server that issues tokens for client harvest with secret s3cret.
+// Each issued token is named token-N, where N counts the token requests.
+func newIssuer(t *testing.T, requests *int) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		assert.Nil(t, r.ParseForm())
+		w.Header().Set("Content-Type", "application/json")
+		if r.PostForm.Get("grant_type") != "client_credentials" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"error":"unsupported_grant_type"}`))
+			return
+		}
+		id, secret, ok := r.BasicAuth()
+		if !ok {
+			id, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+		}
+		if id != "harvest" || secret != "s3cret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			_, _ = w.Write([]byte(`{"error":"invalid_client","error_description":"Client authentication failed"}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"access_token":"token-` + strconv.Itoa(*requests) + `","token_type":"Bearer","expires_in":3600,"scope":"` + r.PostForm.Get("scope") + `"}`))
+	}))
+}
+
+func TestOAuth2(t *testing.T) {
+	requests := 0
+	issuer := newIssuer(t, &requests)
+	defer issuer.Close()
+
+	conf.Config.Defaults = nil
+	poller := &conf.Poller{
+		Name:      "test",
+		Addr:      "a.b.c",
+		AuthStyle: conf.OAuth2Auth,
+		OAuth2: conf.OAuth2{
+			TokenURL:     issuer.URL + "/token",
+			ClientID:     "harvest",
+			ClientSecret: "s3cret",
+			Scope:        "ontap",
+		},
+	}
+	c := NewCredentials(poller, slog.Default())
+
+	got, err := c.GetPollerAuth()
+	assert.Nil(t, err)
+	assert.Equal(t, got.AuthToken, "token-1")
+	assert.True(t, got.IsOAuth2)
+	assert.False(t, got.HasCredentialScript)
+	assert.True(t, got.Refreshable)
+
+	// the token is cached until it is about to expire
+	got, err = c.GetPollerAuth()
+	assert.Nil(t, err)
+	assert.Equal(t, got.AuthToken, "token-1")
+	assert.Equal(t, requests, 1)
+	assert.True(t, c.tokenRefresh.After(time.Now().Add(58*time.Minute)))
+
+	c.tokenRefresh = time.Now().Add(-time.Second)
+	got, err = c.GetPollerAuth()
+	assert.Nil(t, err)
+	assert.Equal(t, got.AuthToken, "token-2")
+
+	// a rejected token is replaced
+	c.Expire()
+	got, err = c.GetPollerAuth()
+	assert.Nil(t, err)
+	assert.Equal(t, got.AuthToken, "token-3")
+
+	// client credentials sent in the body
+	poller.OAuth2.ClientAuth = ClientAuthPost
+	c = NewCredentials(poller, slog.Default())
+	got, err = c.GetPollerAuth()
+	assert.Nil(t, err)
+	assert.Equal(t, got.AuthToken, "token-4")
+}
+
+func TestOAuth2Errors(t *testing.T) {
+	requests := 0
+	issuer := newIssuer(t, &requests)
+	defer issuer.Close()
+
+	tests := []struct {
+		name    string
+		oauth2  conf.OAuth2
+		wantErr error
+		wantMsg string
+	}{
+		{
+			name:    "invalid client",
+			oauth2:  conf.OAuth2{TokenURL: issuer.URL, ClientID: "harvest", ClientSecret: "wrong"},
+			wantErr: errs.ErrPermissionDenied,
+			wantMsg: "status=401 error=invalid_client description=Client authentication failed",
+		},
+		{
+			name:    "issuer down",
+			oauth2:  conf.OAuth2{TokenURL: "http://127.0.0.1:1/token", ClientID: "harvest", ClientSecret: "s3cret"},
+			wantErr: errs.ErrPermissionDenied,
+			wantMsg: "oauth2 token request to http://127.0.0.1:1/token failed",
+		},
+		{
+			name:    "missing token url",
+			oauth2:  conf.OAuth2{ClientID: "harvest", ClientSecret: "s3cret"},
+			wantErr: errs.ErrMissingParam,
+			wantMsg: "oauth2.token_url",
+		},
+		{
+			name:    "missing secret",
+			oauth2:  conf.OAuth2{TokenURL: issuer.URL, ClientID: "harvest"},
+			wantErr: errs.ErrMissingParam,
+			wantMsg: "oauth2.client_secret",
+		},
+		{
+			name:    "bad client auth",
+			oauth2:  conf.OAuth2{TokenURL: issuer.URL, ClientID: "harvest", ClientSecret: "s3cret", ClientAuth: "jwt"},
+			wantErr: errs.ErrInvalidParam,
+			wantMsg: "oauth2.client_auth",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			poller := &conf.Poller{Name: "test", Addr: "a.b.c", AuthStyle: conf.OAuth2Auth, OAuth2: tt.oauth2}
+			c := NewCredentials(poller, slog.Default())
+			_, err := c.GetPollerAuth()
+			assert.True(t, errors.Is(err, tt.wantErr))
+			assert.True(t, strings.Contains(err.Error(), tt.wantMsg))
+		})
+	}
+}
@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"cmp"
+	"strings"
+	"unicode"
+
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
+)
+
+const (
+	ProviderVault      = "vault"
+	ProviderKubernetes = "kubernetes"
+	ProviderEnv        = "env"
+
+	defaultUsernameKey  = "username"
+	defaultPasswordKey  = "password"
+	defaultAuthTokenKey = "authToken"
+	defaultCertKey      = "tls.crt"
+	defaultKeyKey       = "tls.key"
+)
+
+// Secret holds the credentials read from a secret store
+type Secret struct {
+	Username  string
+	Password  string
+	AuthToken string
+	Cert      string // PEM encoded client certificate, may include the key
+	Key       string // PEM encoded client key
+}
+
+func (s Secret) isEmpty() bool {
+	return s.Password == "" && s.AuthToken == "" && s.Cert == ""
+}
+
+// Provider reads credentials from a secret store.
+// Credentials caches the secret and calls Fetch again when the provider's schedule expires, when Expire is called,
+// or when Changed returns true.
+type Provider interface {
+	Fetch() (Secret, error)
+	// Changed reports if the secret changed since the last Fetch, e.g. a mounted secret file was updated
+	Changed() bool
+}
+
+func newProvider(p *conf.Poller) (Provider, error) {
+	cp := p.CredentialsProvider
+	defaults := secretKeys{
+		username:  defaultUsernameKey,
+		password:  defaultPasswordKey,
+		authToken: defaultAuthTokenKey,
+		cert:      defaultCertKey,
+		key:       defaultKeyKey,
+	}
+	kind := strings.ToLower(cp.Type)
+	if kind == ProviderEnv {
+		defaults = secretKeys{
+			username:  "HARVEST_{POLLER}_USERNAME",
+			password:  "HARVEST_{POLLER}_PASSWORD",
+			authToken: "HARVEST_{POLLER}_AUTH_TOKEN",
+			cert:      "HARVEST_{POLLER}_CERT",
+			key:       "HARVEST_{POLLER}_KEY",
+		}
+	}
+	keys := secretKeys{
+		username:  expandName(cmp.Or(cp.UsernameKey, defaults.username), p),
+		password:  expandName(cmp.Or(cp.PasswordKey, defaults.password), p),
+		authToken: expandName(cmp.Or(cp.AuthTokenKey, defaults.authToken), p),
+		cert:      expandName(cmp.Or(cp.CertKey, defaults.cert), p),
+		key:       expandName(cmp.Or(cp.KeyKey, defaults.key), p),
+	}
+
+	switch kind {
+	case ProviderVault:
+		return newVault(p, keys)
+	case ProviderKubernetes:
+		return newKubernetes(p, keys)
+	case ProviderEnv:
+		return &Env{keys: keys}, nil
+	case "":
+		return nil, errs.New(errs.ErrMissingParam, "credentials_provider.type")
+	default:
+		return nil, errs.New(errs.ErrInvalidParam, "credentials_provider.type ("+cp.Type+")")
+	}
+}
+
+// secretKeys are the names of the secret's fields
+type secretKeys struct {
+	username  string
+	password  string
+	authToken string
+	cert      string
+	key       string
+}
+
+// secret builds a Secret using lookup to read each field
+func (k secretKeys) secret(lookup func(name string) string) Secret {
+	return Secret{
+		Username:  lookup(k.username),
+		Password:  lookup(k.password),
+		AuthToken: lookup(k.authToken),
+		Cert:      lookup(k.cert),
+		Key:       lookup(k.key),
+	}
+}
+
+// expandName replaces {poller}, {POLLER}, and {addr} with the poller's name, its name in environment variable form,
+// and its address
+func expandName(name string, p *conf.Poller) string {
+	return strings.NewReplacer(
+		"{poller}", p.Name,
+		"{POLLER}", envName(p.Name),
+		"{addr}", p.Addr,
+	).Replace(name)
+}
+
+// envName uppercases name and replaces characters that are not allowed in environment variable names with _
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || (!unicode.IsLetter(r) && !unicode.IsDigit(r)) {
+			return '_'
+		}
+		return unicode.ToUpper(r)
+	}, name)
+}
@@ -5,6 +5,7 @@ import (
 	"bytes"
 	"crypto/md5" //nolint:gosec
 	"crypto/tls"
+	"crypto/x509"
 	"encoding/base64"
 	"fmt"
 	"github.com/netapp/harvest/v2/pkg/conf"
@@ -13,11 +14,13 @@ import (
 	"io"
 	"io/fs"
 	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httputil"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 type RoundTripFunc func(req *http.Request) (res *http.Response, err error)
@@ -230,3 +233,38 @@ func valueOrDefault(value, def string) string {
 	}
 	return def
 }
+
+// newHTTPClient returns a client for a service other than the cluster, e.g. a secret store or token issuer.
+// section is the harvest.yml section of the parameters and is used in error messages.
+func newHTTPClient(section string, timeout string, defaultTimeout time.Duration, caCertPath string, insecureTLS bool) (*http.Client, error) {
+	duration := defaultTimeout
+	if timeout != "" {
+		d, err := time.ParseDuration(timeout)
+		if err != nil {
+			return nil, errs.New(errs.ErrInvalidParam, section+".timeout ("+timeout+")")
+		}
+		duration = d
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: insecureTLS} //nolint:gosec
+	if caCertPath != "" {
+		pem, err := os.ReadFile(caCertPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s.ca_cert: %w", section, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errs.New(errs.ErrInvalidParam, section+".ca_cert contains no certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return &http.Client{
+		Timeout: duration,
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+			DialContext:     (&net.Dialer{Timeout: DefaultDialerTimeout}).DialContext,
+		},
+	}, nil
+}
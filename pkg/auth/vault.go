@@ -0,0 +1,263 @@
+package auth
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/requests"
+)
+
+const (
+	VaultAuthToken   = "token"
+	VaultAuthAppRole = "approle"
+	VaultAuthJWT     = "jwt"
+
+	defaultVaultMount   = "secret"
+	defaultVaultTimeout = 10 * time.Second
+	defaultJWTFile      = "/var/run/secrets/kubernetes.io/serviceaccount/token" // #nosec G101
+)
+
+// Vault reads credentials from a HashiCorp Vault KV version 2 secret.
+// Vault logs in with a token, AppRole, or JWT. The client token of an AppRole or JWT login is reused until its
+// lease is about to expire, or until Vault rejects it, so each Fetch does not create a new token.
+type Vault struct {
+	addr       string
+	namespace  string
+	mount      string
+	path       string
+	authMethod string
+	authMount  string
+	cp         conf.CredentialsProvider
+	keys       secretKeys
+	client     *http.Client
+	token      string    // client token of the last AppRole or JWT login
+	tokenRenew time.Time // time when the client token is replaced by a new login
+}
+
+func newVault(p *conf.Poller, keys secretKeys) (*Vault, error) {
+	cp := p.CredentialsProvider
+	v := &Vault{
+		addr:       strings.TrimSuffix(cp.Addr, "/"),
+		namespace:  cp.Namespace,
+		mount:      strings.Trim(cp.Mount, "/"),
+		path:       strings.Trim(expandName(cp.Path, p), "/"),
+		authMethod: strings.ToLower(cp.AuthMethod),
+		authMount:  strings.Trim(cp.AuthMount, "/"),
+		cp:         cp,
+		keys:       keys,
+	}
+
+	if v.addr == "" {
+		v.addr = strings.TrimSuffix(os.Getenv("VAULT_ADDR"), "/")
+	}
+	if v.addr == "" {
+		return nil, errs.New(errs.ErrMissingParam, "credentials_provider.addr")
+	}
+	if v.path == "" {
+		return nil, errs.New(errs.ErrMissingParam, "credentials_provider.path")
+	}
+	if v.mount == "" {
+		v.mount = defaultVaultMount
+	}
+
+	switch v.authMethod {
+	case "":
+		v.authMethod = VaultAuthToken
+	case VaultAuthToken:
+	case VaultAuthAppRole:
+		if cp.RoleID == "" {
+			return nil, errs.New(errs.ErrMissingParam, "credentials_provider.role_id")
+		}
+	case VaultAuthJWT:
+		if cp.Role == "" {
+			return nil, errs.New(errs.ErrMissingParam, "credentials_provider.role")
+		}
+	default:
+		return nil, errs.New(errs.ErrInvalidParam, "credentials_provider.auth_method ("+cp.AuthMethod+")")
+	}
+	if v.authMount == "" && v.authMethod != VaultAuthToken {
+		v.authMount = v.authMethod
+	}
+
+	client, err := newHTTPClient("credentials_provider", cp.Timeout, defaultVaultTimeout, cp.CaCertPath, cp.UseInsecureTLS)
+	if err != nil {
+		return nil, err
+	}
+	v.client = client
+
+	return v, nil
+}
+
+func (v *Vault) Fetch() (Secret, error) {
+	var response struct {
+		Data struct {
+			Data map[string]any `json:"data"`
+		} `json:"data"`
+	}
+
+	cached := v.token != "" && time.Now().Before(v.tokenRenew)
+	token, err := v.login()
+	if err != nil {
+		return Secret{}, err
+	}
+	err = v.do(http.MethodGet, v.mount+"/data/"+v.path, token, nil, &response)
+	if cached && errors.Is(err, errs.ErrAuthFailed) {
+		// the cached token was revoked, log in again
+		v.token = ""
+		if token, err = v.login(); err != nil {
+			return Secret{}, err
+		}
+		err = v.do(http.MethodGet, v.mount+"/data/"+v.path, token, nil, &response)
+	}
+	if err != nil {
+		return Secret{}, err
+	}
+
+	secret := v.keys.secret(func(name string) string {
+		value, ok := response.Data.Data[name]
+		if !ok || value == nil {
+			return ""
+		}
+		if s, ok := value.(string); ok {
+			return s
+		}
+		return fmt.Sprint(value)
+	})
+	if secret.isEmpty() {
+		return Secret{}, errs.New(errs.ErrMissingParam, "vault secret "+v.mount+"/"+v.path+" field "+v.keys.password+", "+v.keys.authToken+", or "+v.keys.cert)
+	}
+	return secret, nil
+}
+
+func (v *Vault) Changed() bool {
+	return false
+}
+
+// login returns a Vault token. AppRole and JWT client tokens are cached until two-thirds of their lease has passed.
+func (v *Vault) login() (string, error) {
+	var body map[string]string
+
+	if v.token != "" && time.Now().Before(v.tokenRenew) {
+		return v.token, nil
+	}
+
+	switch v.authMethod {
+	case VaultAuthAppRole:
+		secretID, err := fileOr(v.cp.SecretID, v.cp.SecretIDFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read credentials_provider.secret_id_file: %w", err)
+		}
+		body = map[string]string{"role_id": v.cp.RoleID, "secret_id": secretID}
+	case VaultAuthJWT:
+		jwt, err := fileOr("", cmp.Or(v.cp.JWTFile, defaultJWTFile))
+		if err != nil {
+			return "", fmt.Errorf("failed to read credentials_provider.jwt_file: %w", err)
+		}
+		body = map[string]string{"role": v.cp.Role, "jwt": jwt}
+	default:
+		token, err := fileOr(v.cp.Token, v.cp.TokenFile)
+		if err != nil {
+			return "", fmt.Errorf("failed to read credentials_provider.token_file: %w", err)
+		}
+		if token == "" {
+			token = os.Getenv("VAULT_TOKEN")
+		}
+		if token == "" {
+			return "", errs.New(errs.ErrMissingParam, "credentials_provider.token")
+		}
+		return token, nil
+	}
+
+	var response struct {
+		Auth struct {
+			ClientToken   string `json:"client_token"`
+			LeaseDuration int64  `json:"lease_duration"`
+		} `json:"auth"`
+	}
+	if err := v.do(http.MethodPost, "auth/"+v.authMount+"/login", "", body, &response); err != nil {
+		return "", err
+	}
+	if response.Auth.ClientToken == "" {
+		return "", errs.New(errs.ErrAuthFailed, "vault "+v.authMethod+" login returned no token")
+	}
+
+	// A lease duration of zero is a token that does not expire
+	lease := time.Duration(response.Auth.LeaseDuration) * time.Second
+	if lease == 0 {
+		lease = 24 * time.Hour
+	}
+	v.token = response.Auth.ClientToken
+	v.tokenRenew = time.Now().Add(lease * 2 / 3)
+	return v.token, nil
+}
+
+func (v *Vault) do(method string, path string, token string, body any, result any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := requests.New(method, v.addr+"/v1/"+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("X-Vault-Token", token)
+	}
+	if v.namespace != "" {
+		req.Header.Set("X-Vault-Namespace", v.namespace)
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("vault request failed: %w", err)
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("vault response read failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		// Vault errors do not include secret values, but keep the message short
+		var vaultErr struct {
+			Errors []string `json:"errors"`
+		}
+		_ = json.Unmarshal(data, &vaultErr)
+		msg := fmt.Sprintf("vault %s %s status=%d %s", method, path, resp.StatusCode, strings.Join(vaultErr.Errors, ", "))
+		if resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+			return errs.New(errs.ErrAuthFailed, msg)
+		}
+		return errs.New(errs.ErrAPIResponse, msg)
+	}
+
+	return json.Unmarshal(data, result)
+}
+
+// fileOr returns value, or the trimmed contents of path when value is empty
+func fileOr(value string, path string) (string, error) {
+	if value != "" || path == "" {
+		return value, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
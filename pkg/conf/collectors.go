@@ -10,21 +10,25 @@ func GetCollectorSlice() []string {
 }
 
 var IsCollector = map[string]struct{}{
-	"AristaRest":  {},
-	"CiscoRest":   {},
-	"CmPerf":      {},
-	"Ems":         {},
-	"Eseries":     {},
-	"EseriesPerf": {},
-	"KeyPerf":     {},
-	"Rest":        {},
-	"RestPerf":    {},
-	"Simple":      {},
-	"StatPerf":    {},
-	"StorageGrid": {},
-	"Unix":        {},
-	"Zapi":        {},
-	"ZapiPerf":    {},
+	"AristaRest":    {},
+	"CiscoRest":     {},
+	"CmPerf":        {},
+	"Ems":           {},
+	"Exec":          {},
+	"Eseries":       {},
+	"EseriesEvents": {},
+	"EseriesPerf":   {},
+	"HTTPJSON":      {},
+	"HttpJSON":      {}, // alias of HTTPJSON, both load the httpjson collector and templates
+	"KeyPerf":       {},
+	"Rest":          {},
+	"RestPerf":      {},
+	"Simple":        {},
+	"StatPerf":      {},
+	"StorageGrid":   {},
+	"Unix":          {},
+	"Zapi":          {},
+	"ZapiPerf":      {},
 }
 
 var IsONTAPCollector = map[string]struct{}{
@@ -39,21 +43,25 @@ var IsONTAPCollector = map[string]struct{}{
 }
 
 var IsESeriesCollector = map[string]struct{}{
-	"Eseries":     {},
-	"EseriesPerf": {},
+	"Eseries":       {},
+	"EseriesEvents": {},
+	"EseriesPerf":   {},
 }
 
 var IsNonONTAPCollector = map[string]struct{}{
-	"AristaRest":  {},
-	"CiscoRest":   {},
-	"StorageGrid": {},
-	"Eseries":     {},
-	"EseriesPerf": {},
+	"AristaRest":    {},
+	"CiscoRest":     {},
+	"StorageGrid":   {},
+	"Eseries":       {},
+	"EseriesEvents": {},
+	"EseriesPerf":   {},
 }
 
+// IsPingableCollector returns false for collectors that do not connect to the poller's addr, like Exec, which runs
+// local commands, and HTTPJSON, which requests the URLs of its templates
 func IsPingableCollector(collector string) bool {
 	switch collector {
-	case "Simple", "Unix":
+	case "Simple", "Unix", "Exec", "HTTPJSON", "HttpJSON":
 		return false
 	}
 	return true
@@ -18,6 +18,7 @@ import (
 	"github.com/netapp/harvest/v2/third_party/mergo"
 	"log"
 	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -43,6 +44,7 @@ const (
 	HarvestYML        = "harvest.yml"
 	BasicAuth         = "basic_auth"
 	CertificateAuth   = "certificate_auth"
+	OAuth2Auth        = "oauth2"
 	HomeEnvVar        = "HARVEST_CONF"
 )
 
@@ -589,6 +591,57 @@ type CertificateScript struct {
 	Timeout string `yaml:"timeout,omitempty"`
 }
 
+// OAuth2 configures the OAuth 2.0 client credentials flow used when auth_style is oauth2.
+// The access token is sent as a bearer token with each ONTAP REST request.
+type OAuth2 struct {
+	TokenURL         string `yaml:"token_url,omitempty"`
+	ClientID         string `yaml:"client_id,omitempty"`
+	ClientSecret     string `yaml:"client_secret,omitempty"`
+	ClientSecretFile string `yaml:"client_secret_file,omitempty"`
+	ClientAuth       string `yaml:"client_auth,omitempty"` // basic or post
+	Scope            string `yaml:"scope,omitempty"`
+	Audience         string `yaml:"audience,omitempty"`
+	Timeout          string `yaml:"timeout,omitempty"`
+	CaCertPath       string `yaml:"ca_cert,omitempty"`
+	UseInsecureTLS   bool   `yaml:"use_insecure_tls,omitempty"`
+}
+
+// CredentialsProvider reads poller credentials from a secret store: vault, kubernetes, or env.
+// The *_key names are secret fields, file names, or environment variable names depending on the type.
+// {poller}, {POLLER}, and {addr} in names and paths are replaced with the poller's name, its name in
+// environment variable form, and its address.
+type CredentialsProvider struct {
+	Type     string `yaml:"type,omitempty"`
+	Schedule string `yaml:"schedule,omitempty"`
+	Timeout  string `yaml:"timeout,omitempty"`
+
+	UsernameKey  string `yaml:"username_key,omitempty"`
+	PasswordKey  string `yaml:"password_key,omitempty"`
+	AuthTokenKey string `yaml:"auth_token_key,omitempty"`
+	CertKey      string `yaml:"cert_key,omitempty"`
+	KeyKey       string `yaml:"key_key,omitempty"`
+
+	// vault
+	Addr           string `yaml:"addr,omitempty"`
+	Namespace      string `yaml:"namespace,omitempty"`
+	Mount          string `yaml:"mount,omitempty"`
+	Path           string `yaml:"path,omitempty"`
+	AuthMethod     string `yaml:"auth_method,omitempty"`
+	AuthMount      string `yaml:"auth_mount,omitempty"`
+	Token          string `yaml:"token,omitempty"`
+	TokenFile      string `yaml:"token_file,omitempty"`
+	RoleID         string `yaml:"role_id,omitempty"`
+	SecretID       string `yaml:"secret_id,omitempty"`
+	SecretIDFile   string `yaml:"secret_id_file,omitempty"`
+	Role           string `yaml:"role,omitempty"`
+	JWTFile        string `yaml:"jwt_file,omitempty"`
+	CaCertPath     string `yaml:"ca_cert,omitempty"`
+	UseInsecureTLS bool   `yaml:"use_insecure_tls,omitempty"`
+
+	// kubernetes
+	Dir string `yaml:"dir,omitempty"`
+}
+
 type ExporterDef struct {
 	Name string
 	Exporter
@@ -608,6 +661,63 @@ func (p Pool) IsEnabled() bool {
 	return p.Limit > 0
 }
 
+// RateLimit limits the rate of ONTAP REST and ZAPI requests a poller sends to its cluster.
+// The allowed rate starts at Rate and is halved when the cluster responds with 429 or 503, or slower than
+// SlowResponse. It grows back towards Rate while responses are healthy.
+type RateLimit struct {
+	Rate         float64 `yaml:"rate,omitempty"`          // maximum requests per second, 0 disables the limiter
+	Burst        int     `yaml:"burst,omitempty"`         // requests that can be sent at once
+	MinRate      float64 `yaml:"min_rate,omitempty"`      // the allowed rate never drops below this
+	SlowResponse string  `yaml:"slow_response,omitempty"` // responses slower than this reduce the allowed rate
+	MaxWait      string  `yaml:"max_wait,omitempty"`      // requests that would wait longer than this fail instead
+}
+
+func (r RateLimit) IsEnabled() bool {
+	return r.Rate > 0
+}
+
+// Cardinality limits the number of instances a poller exports per object. Instances over a limit are not exported.
+// Limits in Objects override the poller-wide limits for that object. A limit of zero means no limit.
+type Cardinality struct {
+	CardinalityLimits `yaml:",inline"`
+	Objects           map[string]CardinalityLimits `yaml:"objects,omitempty"` // keyed by object, e.g. volume or cifs_session
+}
+
+type CardinalityLimits struct {
+	MaxInstances   int            `yaml:"max_instances,omitempty"`    // maximum number of instances per object
+	MaxLabelValues int            `yaml:"max_label_values,omitempty"` // maximum number of distinct values of any instance label
+	Labels         map[string]int `yaml:"labels,omitempty"`           // maximum number of distinct values of the named labels
+}
+
+// For returns the limits of object. Fields the object does not set are inherited from the poller-wide limits.
+func (c Cardinality) For(object string) CardinalityLimits {
+	limits := CardinalityLimits{
+		MaxInstances:   c.MaxInstances,
+		MaxLabelValues: c.MaxLabelValues,
+		Labels:         maps.Clone(c.Labels),
+	}
+	o, ok := c.Objects[object]
+	if !ok {
+		return limits
+	}
+	if o.MaxInstances > 0 {
+		limits.MaxInstances = o.MaxInstances
+	}
+	if o.MaxLabelValues > 0 {
+		limits.MaxLabelValues = o.MaxLabelValues
+	}
+	if len(o.Labels) > 0 && limits.Labels == nil {
+		limits.Labels = make(map[string]int, len(o.Labels))
+	}
+	maps.Copy(limits.Labels, o.Labels)
+	return limits
+}
+
+// IsEnabled returns true when any limit is set
+func (l CardinalityLimits) IsEnabled() bool {
+	return l.MaxInstances > 0 || l.MaxLabelValues > 0 || len(l.Labels) > 0
+}
+
 func (e *ExporterDef) UnmarshalYAML(n ast.Node) error {
 	if n.Type() == ast.MappingType {
 		var aExporter Exporter
@@ -623,42 +733,47 @@ func (e *ExporterDef) UnmarshalYAML(n ast.Node) error {
 }
 
 type Poller struct {
-	APIVersion        string               `yaml:"api_version,omitempty"`
-	APIVfiler         string               `yaml:"api_vfiler,omitempty"`
-	Addr              string               `yaml:"addr,omitempty"`
-	AuthStyle         string               `yaml:"auth_style,omitempty"`
-	CaCertPath        string               `yaml:"ca_cert,omitempty"`
-	CertificateScript CertificateScript    `yaml:"certificate_script,omitempty"`
-	CmPerfManifest    string               `yaml:"cm_perf_manifest,omitzero"`
-	ClientTimeout     string               `yaml:"client_timeout,omitempty"`
-	Collectors        []Collector          `yaml:"collectors,omitempty"`
-	ConfPath          string               `yaml:"conf_path,omitempty"`
-	CredentialsFile   string               `yaml:"credentials_file,omitempty"`
-	CredentialsScript CredentialsScript    `yaml:"credentials_script,omitempty"`
-	Datacenter        string               `yaml:"datacenter,omitempty"`
-	IsDisabled        bool                 `yaml:"disabled,omitempty"`
-	ExporterDefs      []ExporterDef        `yaml:"exporters,omitempty"`
-	Exporters         []string             `yaml:"-"`
-	GCNVOntapMode     bool                 `yaml:"gcnv_ontap_mode,omitempty"`
-	IsKfs             bool                 `yaml:"is_kfs,omitempty"`
-	Labels            *[]map[string]string `yaml:"labels,omitempty"`
-	LogMaxBytes       int64                `yaml:"log_max_bytes,omitempty"`
-	LogMaxFiles       int                  `yaml:"log_max_files,omitempty"`
-	LogSet            *[]string            `yaml:"log,omitempty"`
-	Password          string               `yaml:"password,omitempty"`
-	PollerLogSchedule string               `yaml:"poller_log_schedule,omitempty"`
-	PollerSchedule    string               `yaml:"poller_schedule,omitempty"`
-	Pool              Pool                 `yaml:"pool,omitempty"`
-	PreferZAPI        bool                 `yaml:"prefer_zapi,omitempty"`
-	PromPort          int                  `yaml:"prom_port,omitempty"`
-	Recorder          Recorder             `yaml:"recorder,omitempty"`
-	SslCert           string               `yaml:"ssl_cert,omitempty"`
-	SslKey            string               `yaml:"ssl_key,omitempty"`
-	TLSMinVersion     string               `yaml:"tls_min_version,omitempty"`
-	UseInsecureTLS    *bool                `yaml:"use_insecure_tls,omitempty"`
-	Username          string               `yaml:"username,omitempty"`
-	promIndex         int
-	Name              string
+	APIVersion          string               `yaml:"api_version,omitempty"`
+	APIVfiler           string               `yaml:"api_vfiler,omitempty"`
+	Addr                string               `yaml:"addr,omitempty"`
+	AuthStyle           string               `yaml:"auth_style,omitempty"`
+	CaCertPath          string               `yaml:"ca_cert,omitempty"`
+	Cardinality         Cardinality          `yaml:"cardinality,omitempty"`
+	CertificateScript   CertificateScript    `yaml:"certificate_script,omitempty"`
+	CmPerfManifest      string               `yaml:"cm_perf_manifest,omitzero"`
+	ClientTimeout       string               `yaml:"client_timeout,omitempty"`
+	Collectors          []Collector          `yaml:"collectors,omitempty"`
+	ConfPath            string               `yaml:"conf_path,omitempty"`
+	CredentialsFile     string               `yaml:"credentials_file,omitempty"`
+	CredentialsProvider CredentialsProvider  `yaml:"credentials_provider,omitempty"`
+	CredentialsScript   CredentialsScript    `yaml:"credentials_script,omitempty"`
+	Datacenter          string               `yaml:"datacenter,omitempty"`
+	IsDisabled          bool                 `yaml:"disabled,omitempty"`
+	ExporterDefs        []ExporterDef        `yaml:"exporters,omitempty"`
+	Exporters           []string             `yaml:"-"`
+	GCNVOntapMode       bool                 `yaml:"gcnv_ontap_mode,omitempty"`
+	IsKfs               bool                 `yaml:"is_kfs,omitempty"`
+	Labels              *[]map[string]string `yaml:"labels,omitempty"`
+	OAuth2              OAuth2               `yaml:"oauth2,omitempty"`
+	LogMaxBytes         int64                `yaml:"log_max_bytes,omitempty"`
+	LogMaxFiles         int                  `yaml:"log_max_files,omitempty"`
+	LogSet              *[]string            `yaml:"log,omitempty"`
+	Password            string               `yaml:"password,omitempty"`
+	PollerLogSchedule   string               `yaml:"poller_log_schedule,omitempty"`
+	PollerSchedule      string               `yaml:"poller_schedule,omitempty"`
+	Pool                Pool                 `yaml:"pool,omitempty"`
+	PreferZAPI          bool                 `yaml:"prefer_zapi,omitempty"`
+	PromPort            int                  `yaml:"prom_port,omitempty"`
+	RateLimit           RateLimit            `yaml:"rate_limit,omitempty"`
+	Recorder            Recorder             `yaml:"recorder,omitempty"`
+	RestCacheTTL        string               `yaml:"rest_cache_ttl,omitempty"`
+	SslCert             string               `yaml:"ssl_cert,omitempty"`
+	SslKey              string               `yaml:"ssl_key,omitempty"`
+	TLSMinVersion       string               `yaml:"tls_min_version,omitempty"`
+	UseInsecureTLS      *bool                `yaml:"use_insecure_tls,omitempty"`
+	Username            string               `yaml:"username,omitempty"`
+	promIndex           int
+	Name                string
 }
 
 // Union merges a poller's config with the defaults.
@@ -686,6 +801,7 @@ func (p *Poller) Union(defaults *Poller) {
 	pAuthStyle := p.AuthStyle
 	pCredentialsFile := p.CredentialsFile
 	pCredentialsScript := p.CredentialsScript.Path
+	pCredentialsProvider := p.CredentialsProvider.Type
 
 	_ = mergo.Merge(p, defaults)
 
@@ -700,6 +816,36 @@ func (p *Poller) Union(defaults *Poller) {
 	p.AuthStyle = pAuthStyle
 	p.CredentialsFile = pCredentialsFile
 	p.CredentialsScript.Path = pCredentialsScript
+	p.CredentialsProvider.Type = pCredentialsProvider
+}
+
+func CredentialsProviderFromNode(n *node.Node) CredentialsProvider {
+	return CredentialsProvider{
+		Type:           n.GetChildContentS("type"),
+		Schedule:       n.GetChildContentS("schedule"),
+		Timeout:        n.GetChildContentS("timeout"),
+		UsernameKey:    n.GetChildContentS("username_key"),
+		PasswordKey:    n.GetChildContentS("password_key"),
+		AuthTokenKey:   n.GetChildContentS("auth_token_key"),
+		CertKey:        n.GetChildContentS("cert_key"),
+		KeyKey:         n.GetChildContentS("key_key"),
+		Addr:           n.GetChildContentS("addr"),
+		Namespace:      n.GetChildContentS("namespace"),
+		Mount:          n.GetChildContentS("mount"),
+		Path:           n.GetChildContentS("path"),
+		AuthMethod:     n.GetChildContentS("auth_method"),
+		AuthMount:      n.GetChildContentS("auth_mount"),
+		Token:          n.GetChildContentS("token"),
+		TokenFile:      n.GetChildContentS("token_file"),
+		RoleID:         n.GetChildContentS("role_id"),
+		SecretID:       n.GetChildContentS("secret_id"),
+		SecretIDFile:   n.GetChildContentS("secret_id_file"),
+		Role:           n.GetChildContentS("role"),
+		JWTFile:        n.GetChildContentS("jwt_file"),
+		CaCertPath:     n.GetChildContentS("ca_cert"),
+		UseInsecureTLS: n.GetChildContentS("use_insecure_tls") == "true",
+		Dir:            n.GetChildContentS("dir"),
+	}
 }
 
 func (p *Poller) IsRecording() bool {
@@ -768,6 +914,23 @@ func ZapiPoller(n *node.Node) *Poller {
 		p.CertificateScript.Path = certificateScriptNode.GetChildContentS("path")
 		p.CertificateScript.Timeout = certificateScriptNode.GetChildContentS("timeout")
 	}
+	if oauth2Node := n.GetChildS("oauth2"); oauth2Node != nil {
+		p.OAuth2 = OAuth2{
+			TokenURL:         oauth2Node.GetChildContentS("token_url"),
+			ClientID:         oauth2Node.GetChildContentS("client_id"),
+			ClientSecret:     oauth2Node.GetChildContentS("client_secret"),
+			ClientSecretFile: oauth2Node.GetChildContentS("client_secret_file"),
+			ClientAuth:       oauth2Node.GetChildContentS("client_auth"),
+			Scope:            oauth2Node.GetChildContentS("scope"),
+			Audience:         oauth2Node.GetChildContentS("audience"),
+			Timeout:          oauth2Node.GetChildContentS("timeout"),
+			CaCertPath:       oauth2Node.GetChildContentS("ca_cert"),
+			UseInsecureTLS:   oauth2Node.GetChildContentS("use_insecure_tls") == "true",
+		}
+	}
+	if providerNode := n.GetChildS("credentials_provider"); providerNode != nil {
+		p.CredentialsProvider = CredentialsProviderFromNode(providerNode)
+	}
 	if recorderNode := n.GetChildS("recorder"); recorderNode != nil {
 		p.Recorder.Path = recorderNode.GetChildContentS("path")
 		p.Recorder.Mode = recorderNode.GetChildContentS("mode")
@@ -780,6 +943,16 @@ func ZapiPoller(n *node.Node) *Poller {
 	if tlsMinVersion := n.GetChildContentS("tls_min_version"); tlsMinVersion != "" {
 		p.TLSMinVersion = tlsMinVersion
 	}
+	if rateLimitNode := n.GetChildS("rate_limit"); rateLimitNode != nil {
+		p.RateLimit.Rate, _ = strconv.ParseFloat(rateLimitNode.GetChildContentS("rate"), 64)
+		p.RateLimit.Burst, _ = strconv.Atoi(rateLimitNode.GetChildContentS("burst"))
+		p.RateLimit.MinRate, _ = strconv.ParseFloat(rateLimitNode.GetChildContentS("min_rate"), 64)
+		p.RateLimit.SlowResponse = rateLimitNode.GetChildContentS("slow_response")
+		p.RateLimit.MaxWait = rateLimitNode.GetChildContentS("max_wait")
+	}
+	if restCacheTTL := n.GetChildContentS("rest_cache_ttl"); restCacheTTL != "" {
+		p.RestCacheTTL = restCacheTTL
+	}
 	if logSet := n.GetChildS("log"); logSet != nil {
 		p.LogSet = new(logSet.GetAllChildNamesS())
 	}
@@ -793,6 +966,17 @@ type DiskCacheConfig struct {
 	Path string `yaml:"path"`
 }
 
+// RelabelConfig is one exporter relabeling rule, modeled on Prometheus' metric_relabel_configs
+type RelabelConfig struct {
+	Action       string   `yaml:"action,omitempty"`
+	SourceLabels []string `yaml:"source_labels,omitempty"`
+	Separator    *string  `yaml:"separator,omitempty"`
+	Regex        *string  `yaml:"regex,omitempty"`
+	Modulus      uint64   `yaml:"modulus,omitempty"`
+	TargetLabel  string   `yaml:"target_label,omitempty"`
+	Replacement  *string  `yaml:"replacement,omitempty"`
+}
+
 type Exporter struct {
 	Port              *int      `yaml:"port,omitempty"`
 	PortRange         *IntRange `yaml:"port_range,omitempty"`
@@ -806,6 +990,8 @@ type Exporter struct {
 	CacheMaxKeep      *string   `yaml:"cache_max_keep,omitempty"`
 	ShouldAddMetaTags *bool     `yaml:"add_meta_tags,omitempty"`
 
+	RelabelConfigs []RelabelConfig `yaml:"relabel_configs,omitempty"`
+
 	// Prometheus specific
 	HeartBeatURL string `yaml:"heart_beat_url,omitempty"`
 	SortLabels   bool   `yaml:"sort_labels,omitempty"`
@@ -8,6 +8,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/goccy/go-yaml"
 	"github.com/google/go-cmp/cmp"
 	"github.com/netapp/harvest/v2/assert"
 	"github.com/netapp/harvest/v2/pkg/tree/node"
@@ -270,6 +271,20 @@ func TestNodeToPoller(t *testing.T) {
 	testArg(t, "pass", poller.Password)
 	testArg(t, "30s", poller.ClientTimeout)
 	testArg(t, "true", strconv.FormatBool(*poller.UseInsecureTLS))
+
+	rateLimit := defaultNode.NewChildS("rate_limit", "")
+	rateLimit.NewChildS("rate", "20")
+	rateLimit.NewChildS("burst", "5")
+	rateLimit.NewChildS("min_rate", "2")
+	rateLimit.NewChildS("slow_response", "3s")
+	rateLimit.NewChildS("max_wait", "30s")
+	poller = ZapiPoller(defaultNode)
+
+	testArg(t, "20", strconv.FormatFloat(poller.RateLimit.Rate, 'f', -1, 64))
+	testArg(t, "5", strconv.Itoa(poller.RateLimit.Burst))
+	testArg(t, "2", strconv.FormatFloat(poller.RateLimit.MinRate, 'f', -1, 64))
+	testArg(t, "3s", poller.RateLimit.SlowResponse)
+	testArg(t, "30s", poller.RateLimit.MaxWait)
 }
 
 func TestEmptyPath(t *testing.T) {
@@ -552,3 +567,43 @@ func TestPromPort(t *testing.T) {
 		t.Errorf("got port=%d, want port=0", port)
 	}
 }
+
+func TestCardinality(t *testing.T) {
+	in := `
+cardinality:
+  max_instances: 1000
+  labels:
+    user: 50
+  objects:
+    cifs_session:
+      max_instances: 200
+      labels:
+        user: 10
+        client_ip: 20
+`
+	var p Poller
+	if err := yaml.Unmarshal([]byte(in), &p); err != nil {
+		t.Fatalf("got err=%v, want no error", err)
+	}
+
+	volume := p.Cardinality.For("volume")
+	if volume.MaxInstances != 1000 || volume.Labels["user"] != 50 {
+		t.Errorf("got %+v, want poller-wide limits", volume)
+	}
+
+	session := p.Cardinality.For("cifs_session")
+	if session.MaxInstances != 200 {
+		t.Errorf("got max_instances=%d, want 200", session.MaxInstances)
+	}
+	if session.Labels["user"] != 10 || session.Labels["client_ip"] != 20 {
+		t.Errorf("got labels=%v, want user=10 client_ip=20", session.Labels)
+	}
+	// the object's limits do not change the poller-wide limits
+	if p.Cardinality.Labels["user"] != 50 {
+		t.Errorf("got user=%d, want 50", p.Cardinality.Labels["user"])
+	}
+
+	if (Cardinality{}).For("volume").IsEnabled() {
+		t.Errorf("got enabled, want no limits")
+	}
+}
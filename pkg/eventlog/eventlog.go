@@ -0,0 +1,100 @@
+// Package eventlog forwards collected events as structured log records.
+// Collectors, like EMS, turn events into low-cardinality metrics and use a Sink to keep the full event,
+// including its message text and parameters, in a log system.
+package eventlog
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/slogx"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+)
+
+const (
+	TypeLoki   = "loki"
+	TypeSyslog = "syslog"
+	TypeFile   = "file"
+)
+
+// Record is a single event
+type Record struct {
+	Time       time.Time         `json:"time"`
+	Severity   string            `json:"severity"`
+	Name       string            `json:"name"`
+	Source     string            `json:"source"`
+	Message    string            `json:"message,omitempty"`
+	Labels     map[string]string `json:"labels,omitempty"`     // low-cardinality labels, used as Loki stream labels
+	Parameters map[string]string `json:"parameters,omitempty"` // event parameters
+}
+
+func (r Record) JSON() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// Sink writes records to a log system
+type Sink interface {
+	Write(records []Record) error
+	Close() error
+}
+
+// Flush writes the records created during a poll to sink and returns records emptied for the next poll.
+// Records are dropped when the sink fails so a down log system does not grow memory. A nil sink writes nothing.
+func Flush(sink Sink, records []Record, logger *slog.Logger) []Record {
+	if sink == nil || len(records) == 0 {
+		return records[:0]
+	}
+	if err := sink.Write(records); err != nil {
+		logger.Warn("Failed to write log records", slogx.Err(err), slog.Int("dropped", len(records)))
+	}
+	return records[:0]
+}
+
+// New creates the sink described by the log_sink section of a template.
+//
+//	log_sink:
+//	  type: loki
+//	  url: http://loki:3100/loki/api/v1/push
+func New(params *node.Node, logger *slog.Logger) (Sink, error) {
+	kind := strings.ToLower(params.GetChildContentS("type"))
+	switch kind {
+	case TypeLoki:
+		return newLoki(params, logger)
+	case TypeSyslog:
+		return newSyslog(params, logger)
+	case TypeFile:
+		return newFile(params)
+	case "":
+		return nil, errs.New(errs.ErrMissingParam, "log_sink.type")
+	default:
+		return nil, errs.New(errs.ErrInvalidParam, "log_sink.type ("+kind+")")
+	}
+}
+
+// Severity maps an event severity to an RFC5424 severity.
+// ONTAP, E-Series and syslog severity names are supported. Unknown severities map to notice.
+func Severity(severity string) int {
+	switch strings.ToLower(severity) {
+	case "emergency", "emerg":
+		return 0
+	case "alert":
+		return 1
+	case "critical", "crit":
+		return 2
+	case "error", "err":
+		return 3
+	case "warning", "warn":
+		return 4
+	case "notice":
+		return 5
+	case "informational", "info":
+		return 6
+	case "debug":
+		return 7
+	default:
+		return 5
+	}
+}
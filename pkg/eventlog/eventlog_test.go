@@ -0,0 +1,234 @@
+package eventlog
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/netapp/harvest/v2/assert"
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+)
+
+var testRecords = []Record{
+	{
+		Time:       time.Date(2024, 5, 9, 21, 42, 13, 0, time.UTC),
+		Severity:   "alert",
+		Name:       "LUN.offline",
+		Source:     "cluster1",
+		Message:    `LUN "/vol/vol1/lun1" went offline.`,
+		Labels:     map[string]string{"cluster": "cluster1", "severity": "alert", "message": "LUN.offline"},
+		Parameters: map[string]string{"lun_path": "/vol/vol1/lun1", "volume_name": "vol1"},
+	},
+	{
+		Time:     time.Date(2024, 5, 9, 21, 43, 13, 0, time.UTC),
+		Severity: "notice",
+		Name:     "LUN.online",
+		Source:   "cluster1",
+		Labels:   map[string]string{"cluster": "cluster1", "severity": "notice", "message": "LUN.online"},
+	},
+}
+
+func sinkParams(kind string, params map[string]string) *node.Node {
+	n := node.NewS("log_sink")
+	n.NewChildS("type", kind)
+	for k, v := range params {
+		n.NewChildS(k, v)
+	}
+	return n
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		kind    string
+		params  map[string]string
+		wantErr bool
+	}{
+		{name: "missing type", wantErr: true},
+		{name: "unknown type", kind: "kafka", wantErr: true},
+		{name: "loki without url", kind: TypeLoki, wantErr: true},
+		{name: "syslog without addr", kind: TypeSyslog, wantErr: true},
+		{name: "syslog invalid facility", kind: TypeSyslog, params: map[string]string{"addr": "localhost:514", "facility": "24"}, wantErr: true},
+		{name: "file without path", kind: TypeFile, wantErr: true},
+		{name: "file invalid max_size", kind: TypeFile, params: map[string]string{"path": "ems.log", "max_size": "-1"}, wantErr: true},
+		{name: "loki", kind: TypeLoki, params: map[string]string{"url": "http://localhost:3100/loki/api/v1/push"}},
+		{name: "syslog", kind: TypeSyslog, params: map[string]string{"addr": "localhost:514"}},
+		{name: "file", kind: TypeFile, params: map[string]string{"path": "ems.log"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sink, err := New(sinkParams(tt.kind, tt.params), slog.Default())
+			if tt.wantErr {
+				assert.NotNil(t, err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Nil(t, sink.Close())
+		})
+	}
+}
+
+func TestLoki(t *testing.T) {
+	var (
+		push   lokiPush
+		tenant string
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant = r.Header.Get("X-Scope-OrgID")
+		body, _ := io.ReadAll(r.Body)
+		if err := json.Unmarshal(body, &push); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	params := sinkParams(TypeLoki, map[string]string{"url": server.URL, "tenant": "storage"})
+	params.NewChildS("labels", "").NewChildS("job", "harvest")
+	sink, err := New(params, slog.Default())
+	assert.Nil(t, err)
+	assert.Nil(t, sink.Write(testRecords))
+
+	assert.Equal(t, tenant, "storage")
+	assert.Equal(t, len(push.Streams), 2)
+	stream := push.Streams[0]
+	assert.Equal(t, stream.Stream["job"], "harvest")
+	assert.Equal(t, stream.Stream["message"], "LUN.offline")
+	assert.Equal(t, stream.Values[0][0], strconv.FormatInt(testRecords[0].Time.UnixNano(), 10))
+
+	var r Record
+	assert.Nil(t, json.Unmarshal([]byte(stream.Values[0][1]), &r))
+	assert.Equal(t, r.Parameters["lun_path"], "/vol/vol1/lun1")
+
+	server.Config.Handler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+	})
+	assert.NotNil(t, sink.Write(testRecords))
+}
+
+func TestSyslog(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	defer listener.Close()
+
+	received := make(chan string, len(testRecords))
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for {
+			length, err := reader.ReadString(' ')
+			if err != nil {
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(length))
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(reader, msg); err != nil {
+				return
+			}
+			received <- string(msg)
+		}
+	}()
+
+	sink, err := New(sinkParams(TypeSyslog, map[string]string{"addr": listener.Addr().String()}), slog.Default())
+	assert.Nil(t, err)
+	defer sink.Close()
+	assert.Nil(t, sink.Write(testRecords))
+
+	msg := <-received
+	// local0 (16) * 8 + alert (1)
+	assert.True(t, strings.HasPrefix(msg, "<129>1 2024-05-09T21:42:13Z cluster1 harvest "))
+	assert.True(t, strings.Contains(msg, " LUN.offline [event@789 "))
+	assert.True(t, strings.Contains(msg, `lun_path="/vol/vol1/lun1"`))
+	assert.True(t, strings.HasSuffix(msg, `] LUN "/vol/vol1/lun1" went offline.`))
+
+	msg = <-received
+	assert.True(t, strings.HasPrefix(msg, "<133>1 "))
+}
+
+func TestSyslogFormat(t *testing.T) {
+	s := &Syslog{facility: defaultSyslogFacility}
+	msg := s.format(Record{
+		Time:       time.Date(2024, 5, 9, 21, 42, 13, 0, time.UTC),
+		Severity:   "error",
+		Name:       "a name with spaces",
+		Parameters: map[string]string{"path": `a"b]c\d`},
+	})
+	assert.True(t, strings.HasPrefix(msg, "<131>1 2024-05-09T21:42:13Z - harvest "))
+	assert.True(t, strings.Contains(msg, " a_name_with_spaces [event@789 "))
+	assert.True(t, strings.Contains(msg, `path="a\"b\]c\\d"`))
+
+	// a label with the name of a parameter does not overwrite it
+	msg = s.format(Record{
+		Time:       time.Date(2024, 5, 9, 21, 42, 13, 0, time.UTC),
+		Severity:   "error",
+		Name:       "LUN.offline",
+		Parameters: map[string]string{"node": "param"},
+		Labels:     map[string]string{"node": "label"},
+	})
+	assert.True(t, strings.Contains(msg, `[event@789 node="param"][labels@789 node="label"]`))
+}
+
+func TestFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ems.log")
+	sink, err := New(sinkParams(TypeFile, map[string]string{"path": path}), slog.Default())
+	assert.Nil(t, err)
+	assert.Nil(t, sink.Write(testRecords))
+	assert.Nil(t, sink.Close())
+
+	data, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	assert.Equal(t, len(lines), 2)
+
+	var r Record
+	assert.Nil(t, json.Unmarshal([]byte(lines[1]), &r))
+	assert.Equal(t, r.Name, "LUN.online")
+	assert.Equal(t, r.Severity, "notice")
+}
+
+// failingSink fails every write
+type failingSink struct{ writes int }
+
+func (s *failingSink) Write([]Record) error {
+	s.writes++
+	return errs.New(errs.ErrConnection, "log system is down")
+}
+
+func (s *failingSink) Close() error { return nil }
+
+func TestFlush(t *testing.T) {
+	// records are dropped when the sink fails
+	sink := &failingSink{}
+	records := Flush(sink, slices.Clone(testRecords), slog.Default())
+	assert.Equal(t, len(records), 0)
+	assert.Equal(t, sink.writes, 1)
+
+	// nothing is written without records or without a sink
+	assert.Equal(t, len(Flush(sink, records, slog.Default())), 0)
+	assert.Equal(t, sink.writes, 1)
+	assert.Equal(t, len(Flush(nil, slices.Clone(testRecords), slog.Default())), 0)
+}
+
+func TestSeverity(t *testing.T) {
+	assert.Equal(t, Severity("EMERGENCY"), 0)
+	assert.Equal(t, Severity("informational"), 6)
+	assert.Equal(t, Severity("critical"), 2)
+	assert.Equal(t, Severity("unknown"), 5)
+}
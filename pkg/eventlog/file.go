@@ -0,0 +1,87 @@
+package eventlog
+
+import (
+	"strconv"
+	"sync"
+
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+	"gopkg.in/natefinch/lumberjack.v2"
+)
+
+const (
+	defaultFileMaxSize    = 10 // megabytes
+	defaultFileMaxBackups = 5  // files
+	defaultFileMaxAge     = 0  // days, 0 keeps all backups
+)
+
+// File writes one JSON record per line to a rotating file
+type File struct {
+	mu     sync.Mutex
+	writer *lumberjack.Logger
+}
+
+func newFile(params *node.Node) (*File, error) {
+	path := params.GetChildContentS("path")
+	if path == "" {
+		return nil, errs.New(errs.ErrMissingParam, "log_sink.path")
+	}
+
+	maxSize, err := intParam(params, "max_size", defaultFileMaxSize)
+	if err != nil {
+		return nil, err
+	}
+	maxBackups, err := intParam(params, "max_backups", defaultFileMaxBackups)
+	if err != nil {
+		return nil, err
+	}
+	maxAge, err := intParam(params, "max_age", defaultFileMaxAge)
+	if err != nil {
+		return nil, err
+	}
+
+	return &File{
+		writer: &lumberjack.Logger{
+			Filename:   path,
+			MaxSize:    maxSize,    // megabytes
+			MaxBackups: maxBackups, // files
+			MaxAge:     maxAge,     // days
+			Compress:   params.GetChildContentS("compress") == "true",
+		},
+	}, nil
+}
+
+func intParam(params *node.Node, name string, defaultValue int) (int, error) {
+	v := params.GetChildContentS(name)
+	if v == "" {
+		return defaultValue, nil
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil || i < 0 {
+		return 0, errs.New(errs.ErrInvalidParam, "log_sink."+name+" ("+v+")")
+	}
+	return i, nil
+}
+
+func (f *File) Write(records []Record) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, r := range records {
+		line, err := r.JSON()
+		if err != nil {
+			return err
+		}
+		line = append(line, '\n')
+		if _, err := f.writer.Write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *File) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.writer.Close()
+}
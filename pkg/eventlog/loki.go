@@ -0,0 +1,146 @@
+package eventlog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/requests"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+)
+
+const defaultLokiTimeout = 10 * time.Second
+
+// Loki pushes records to the Loki push API. Records with the same labels are sent as one stream.
+type Loki struct {
+	url      string
+	tenant   string
+	username string
+	password string
+	labels   map[string]string
+	client   *http.Client
+	logger   *slog.Logger
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+type lokiPush struct {
+	Streams []*lokiStream `json:"streams"`
+}
+
+func newLoki(params *node.Node, logger *slog.Logger) (*Loki, error) {
+	l := &Loki{
+		url:      params.GetChildContentS("url"),
+		tenant:   params.GetChildContentS("tenant"),
+		username: params.GetChildContentS("username"),
+		password: params.GetChildContentS("password"),
+		labels:   make(map[string]string),
+		logger:   logger,
+	}
+	if l.url == "" {
+		return nil, errs.New(errs.ErrMissingParam, "log_sink.url")
+	}
+
+	timeout := defaultLokiTimeout
+	if t := params.GetChildContentS("timeout"); t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil {
+			return nil, errs.New(errs.ErrInvalidParam, "log_sink.timeout ("+t+"): "+err.Error())
+		}
+		timeout = d
+	}
+	l.client = &http.Client{Timeout: timeout}
+
+	if labels := params.GetChildS("labels"); labels != nil {
+		for _, label := range labels.GetChildren() {
+			l.labels[label.GetNameS()] = label.GetContentS()
+		}
+	}
+	return l, nil
+}
+
+func (l *Loki) Write(records []Record) error {
+	if len(records) == 0 {
+		return nil
+	}
+
+	body, err := l.payload(records)
+	if err != nil {
+		return err
+	}
+
+	req, err := requests.New(http.MethodPost, l.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if l.tenant != "" {
+		req.Header.Set("X-Scope-OrgID", l.tenant)
+	}
+	if l.username != "" {
+		req.SetBasicAuth(l.username, l.password)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("loki push failed: %w", err)
+	}
+	//goland:noinspection GoUnhandledErrorResult
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("loki push failed: status=%d %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	l.logger.Debug("loki push", slog.Int("records", len(records)))
+	return nil
+}
+
+func (l *Loki) payload(records []Record) ([]byte, error) {
+	streams := make(map[string]*lokiStream)
+	for _, r := range records {
+		labels := maps.Clone(l.labels)
+		maps.Copy(labels, r.Labels)
+
+		keys := slices.Sorted(maps.Keys(labels))
+		var id strings.Builder
+		for _, k := range keys {
+			id.WriteString(k + "=" + labels[k] + ",")
+		}
+
+		stream, ok := streams[id.String()]
+		if !ok {
+			stream = &lokiStream{Stream: labels}
+			streams[id.String()] = stream
+		}
+
+		line, err := r.JSON()
+		if err != nil {
+			return nil, err
+		}
+		stream.Values = append(stream.Values, [2]string{strconv.FormatInt(r.Time.UnixNano(), 10), string(line)})
+	}
+
+	push := lokiPush{}
+	for _, k := range slices.Sorted(maps.Keys(streams)) {
+		push.Streams = append(push.Streams, streams[k])
+	}
+	return json.Marshal(push)
+}
+
+func (l *Loki) Close() error {
+	l.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,211 @@
+package eventlog
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/netapp/harvest/v2/pkg/errs"
+	"github.com/netapp/harvest/v2/pkg/tree/node"
+)
+
+const (
+	defaultSyslogFacility = 16 // local0
+	defaultSyslogTimeout  = 10 * time.Second
+	syslogAppName         = "harvest"
+	syslogSDID            = "event@789" // 789 is the NetApp private enterprise number
+	syslogLabelsSDID      = "labels@789"
+	nilValue              = "-"
+)
+
+// Syslog writes RFC5424 messages over TCP or TLS using octet-counting framing (RFC6587)
+type Syslog struct {
+	addr      string
+	facility  int
+	timeout   time.Duration
+	tlsConfig *tls.Config
+	logger    *slog.Logger
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func newSyslog(params *node.Node, logger *slog.Logger) (*Syslog, error) {
+	s := &Syslog{
+		addr:     params.GetChildContentS("addr"),
+		facility: defaultSyslogFacility,
+		timeout:  defaultSyslogTimeout,
+		logger:   logger,
+	}
+	if s.addr == "" {
+		return nil, errs.New(errs.ErrMissingParam, "log_sink.addr")
+	}
+
+	if f := params.GetChildContentS("facility"); f != "" {
+		facility, err := strconv.Atoi(f)
+		if err != nil || facility < 0 || facility > 23 {
+			return nil, errs.New(errs.ErrInvalidParam, "log_sink.facility ("+f+")")
+		}
+		s.facility = facility
+	}
+
+	if t := params.GetChildContentS("timeout"); t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil {
+			return nil, errs.New(errs.ErrInvalidParam, "log_sink.timeout ("+t+"): "+err.Error())
+		}
+		s.timeout = d
+	}
+
+	if params.GetChildContentS("tls") == "true" {
+		s.tlsConfig = &tls.Config{
+			InsecureSkipVerify: params.GetChildContentS("use_insecure_tls") == "true", //nolint:gosec
+			MinVersion:         tls.VersionTLS12,
+		}
+		if caFile := params.GetChildContentS("ca_cert"); caFile != "" {
+			pem, err := os.ReadFile(caFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read log_sink.ca_cert: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, errs.New(errs.ErrInvalidParam, "log_sink.ca_cert contains no certificates")
+			}
+			s.tlsConfig.RootCAs = pool
+		}
+	}
+	return s, nil
+}
+
+func (s *Syslog) Write(records []Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, r := range records {
+		msg := s.format(r)
+		frame := strconv.Itoa(len(msg)) + " " + msg
+		if err := s.write([]byte(frame)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// write sends a frame and reconnects once when the connection was closed by the server
+func (s *Syslog) write(frame []byte) error {
+	var err error
+	for range 2 {
+		if s.conn == nil {
+			if err = s.connect(); err != nil {
+				return err
+			}
+		}
+		_ = s.conn.SetWriteDeadline(time.Now().Add(s.timeout))
+		if _, err = s.conn.Write(frame); err == nil {
+			return nil
+		}
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+	return fmt.Errorf("syslog write failed: %w", err)
+}
+
+func (s *Syslog) connect() error {
+	var (
+		conn net.Conn
+		err  error
+	)
+	dialer := &net.Dialer{Timeout: s.timeout}
+	if s.tlsConfig != nil {
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.addr, s.tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", s.addr)
+	}
+	if err != nil {
+		return fmt.Errorf("syslog connect failed: %w", err)
+	}
+	s.logger.Debug("syslog connected", slog.String("addr", s.addr), slog.Bool("tls", s.tlsConfig != nil))
+	s.conn = conn
+	return nil
+}
+
+// format returns an RFC5424 message
+//
+//	<PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ELEMENT] MSG
+func (s *Syslog) format(r Record) string {
+	var b strings.Builder
+
+	pri := s.facility*8 + Severity(r.Severity)
+	b.WriteString("<" + strconv.Itoa(pri) + ">1 ")
+	b.WriteString(r.Time.UTC().Format(time.RFC3339Nano) + " ")
+	b.WriteString(headerField(r.Source, 255) + " ")
+	b.WriteString(syslogAppName + " ")
+	b.WriteString(strconv.Itoa(os.Getpid()) + " ")
+	b.WriteString(headerField(r.Name, 32) + " ")
+
+	// parameters and labels are separate SD-ELEMENTs, so a label does not overwrite a parameter with the same name
+	if len(r.Parameters) == 0 && len(r.Labels) == 0 {
+		b.WriteString(nilValue)
+	} else {
+		writeElement(&b, syslogSDID, r.Parameters)
+		writeElement(&b, syslogLabelsSDID, r.Labels)
+	}
+
+	if r.Message != "" {
+		b.WriteString(" " + r.Message)
+	}
+	return b.String()
+}
+
+// writeElement writes an SD-ELEMENT with the params sorted by name. Empty params are skipped.
+func writeElement(b *strings.Builder, id string, params map[string]string) {
+	if len(params) == 0 {
+		return
+	}
+	b.WriteString("[" + id)
+	for _, k := range slices.Sorted(maps.Keys(params)) {
+		b.WriteString(" " + headerField(k, 32) + "=\"" + escapeParam(params[k]) + "\"")
+	}
+	b.WriteString("]")
+}
+
+// headerField returns a printable US-ASCII value without spaces, as required for RFC5424 header fields
+func headerField(value string, maxLen int) string {
+	if value == "" {
+		return nilValue
+	}
+	field := strings.Map(func(r rune) rune {
+		if r <= 32 || r >= 127 || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, value)
+	if len(field) > maxLen {
+		field = field[:maxLen]
+	}
+	return field
+}
+
+func escapeParam(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
+
+func (s *Syslog) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.conn == nil {
+		return nil
+	}
+	err := s.conn.Close()
+	s.conn = nil
+	return err
+}
@@ -0,0 +1,215 @@
+// Package ratelimit limits the rate of requests a poller sends to its cluster.
+// A Limiter is a token bucket whose rate adapts to the cluster's health: additive increase while responses are
+// healthy, multiplicative decrease when the cluster is busy (AIMD).
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/errs"
+)
+
+const (
+	// DefaultSlowResponse is the latency above which a response counts as slow
+	DefaultSlowResponse = 10 * time.Second
+	// DefaultMaxWait is the longest a request waits for the limiter before it fails
+	DefaultMaxWait = time.Minute
+	// decreaseFactor multiplies the rate when the cluster is busy
+	decreaseFactor = 0.5
+	// adjustInterval limits rate changes to one per interval, so a burst of slow responses from requests sent
+	// at the same time only halves the rate once
+	adjustInterval = time.Second
+)
+
+type Limiter struct {
+	mu           sync.Mutex
+	rate         float64 // current allowed requests per second
+	maxRate      float64
+	minRate      float64
+	increase     float64 // requests per second added each adjustInterval while healthy
+	burst        float64
+	tokens       float64
+	last         time.Time
+	lastAdjust   time.Time
+	slowResponse time.Duration
+	maxWait      time.Duration
+	throttled    atomic.Uint64
+	rejected     atomic.Uint64
+	now          func() time.Time
+	sleep        func(context.Context, time.Duration) error
+}
+
+func New(r conf.RateLimit) *Limiter {
+	l := &Limiter{
+		rate:         r.Rate,
+		maxRate:      r.Rate,
+		minRate:      r.MinRate,
+		burst:        float64(r.Burst),
+		slowResponse: DefaultSlowResponse,
+		maxWait:      DefaultMaxWait,
+		now:          time.Now,
+		sleep:        sleep,
+	}
+	if l.burst < 1 {
+		l.burst = max(1, r.Rate)
+	}
+	if l.minRate <= 0 || l.minRate > l.maxRate {
+		l.minRate = l.maxRate / 10
+	}
+	l.increase = max(l.maxRate/20, l.minRate)
+	if r.SlowResponse != "" {
+		if d, err := time.ParseDuration(r.SlowResponse); err == nil {
+			l.slowResponse = d
+		}
+	}
+	if r.MaxWait != "" {
+		if d, err := time.ParseDuration(r.MaxWait); err == nil && d > 0 {
+			l.maxWait = d
+		}
+	}
+	l.tokens = l.burst
+	l.last = l.now()
+	return l
+}
+
+var (
+	limitersMu sync.Mutex
+	limiters   = make(map[string]*Limiter)
+)
+
+// For returns the limiter shared by the REST and ZAPI clients of the poller, or nil when rate limiting is disabled
+func For(poller *conf.Poller) *Limiter {
+	if !poller.RateLimit.IsEnabled() {
+		return nil
+	}
+	key := poller.Name + "|" + poller.Addr
+	limitersMu.Lock()
+	defer limitersMu.Unlock()
+	l, ok := limiters[key]
+	if !ok {
+		l = New(poller.RateLimit)
+		limiters[key] = l
+	}
+	return l
+}
+
+// Wait blocks until a request can be sent. A nil limiter never blocks.
+// It returns an error without waiting when the request would wait longer than max_wait or past the context's
+// deadline, so the queue of waiting requests is bounded. It also returns an error when the context is done
+// while waiting.
+func (l *Limiter) Wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := l.now()
+	l.tokens = min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+	// reserve a token, a negative balance is the wait of requests queued ahead
+	l.tokens--
+	var wait time.Duration
+	if l.tokens < 0 {
+		wait = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+	limit := l.maxWait
+	if deadline, ok := ctx.Deadline(); ok {
+		limit = min(limit, deadline.Sub(now))
+	}
+	if wait > limit {
+		l.tokens++
+		l.mu.Unlock()
+		l.rejected.Add(1)
+		return errs.New(errs.ErrAPIRequestRejected, "rate limit wait of "+wait.Round(time.Millisecond).String()+
+			" exceeds "+limit.Round(time.Millisecond).String())
+	}
+	l.mu.Unlock()
+
+	if wait <= 0 {
+		return nil
+	}
+	l.throttled.Add(1)
+	if err := l.sleep(ctx, wait); err != nil {
+		l.cancel()
+		return err
+	}
+	return nil
+}
+
+// cancel returns the token reserved by a request that stopped waiting
+func (l *Limiter) cancel() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.tokens = min(l.burst, l.tokens+1)
+}
+
+// sleep waits for d or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Observe adjusts the allowed rate using the status code and latency of a response.
+// Pass a status of 0 when the request failed without a response, and cmReject when ONTAP rejected the request
+// because it is busy, which it reports in the response body rather than the status code.
+func (l *Limiter) Observe(status int, latency time.Duration, cmReject bool) {
+	if l == nil {
+		return
+	}
+
+	busy := cmReject || status == http.StatusTooManyRequests || status == http.StatusServiceUnavailable || latency > l.slowResponse
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	if now.Sub(l.lastAdjust) < adjustInterval {
+		return
+	}
+	switch {
+	case busy:
+		l.rate = max(l.minRate, l.rate*decreaseFactor)
+	case l.rate < l.maxRate:
+		l.rate = min(l.maxRate, l.rate+l.increase)
+	default:
+		return
+	}
+	l.lastAdjust = now
+}
+
+// Rate returns the allowed requests per second
+func (l *Limiter) Rate() float64 {
+	if l == nil {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate
+}
+
+// Throttled returns the number of requests that waited for the limiter
+func (l *Limiter) Throttled() uint64 {
+	if l == nil {
+		return 0
+	}
+	return l.throttled.Load()
+}
+
+// Rejected returns the number of requests that failed because they would wait longer than max_wait
+func (l *Limiter) Rejected() uint64 {
+	if l == nil {
+		return 0
+	}
+	return l.rejected.Load()
+}
@@ -0,0 +1,138 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/netapp/harvest/v2/assert"
+	"github.com/netapp/harvest/v2/pkg/conf"
+)
+
+// newTestLimiter returns a limiter with a fake clock. Sleeping advances the clock.
+func newTestLimiter(r conf.RateLimit) (*Limiter, *time.Time, *time.Duration) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	var slept time.Duration
+	l := New(r)
+	l.now = func() time.Time { return now }
+	l.sleep = func(_ context.Context, d time.Duration) error {
+		slept += d
+		now = now.Add(d)
+		return nil
+	}
+	l.last = now
+	return l, &now, &slept
+}
+
+func TestWait(t *testing.T) {
+	l, now, slept := newTestLimiter(conf.RateLimit{Rate: 10, Burst: 5})
+
+	// the burst is sent without waiting
+	for range 5 {
+		assert.Nil(t, l.Wait(context.Background()))
+	}
+	assert.Equal(t, *slept, time.Duration(0))
+	assert.Equal(t, l.Throttled(), uint64(0))
+
+	// then one request every 100ms
+	assert.Nil(t, l.Wait(context.Background()))
+	assert.Equal(t, *slept, 100*time.Millisecond)
+	assert.Nil(t, l.Wait(context.Background()))
+	assert.Equal(t, *slept, 200*time.Millisecond)
+	assert.Equal(t, l.Throttled(), uint64(2))
+
+	// idle time refills the bucket, up to the burst
+	*now = now.Add(time.Minute)
+	for range 5 {
+		assert.Nil(t, l.Wait(context.Background()))
+	}
+	assert.Equal(t, *slept, 200*time.Millisecond)
+}
+
+func TestWaitLimit(t *testing.T) {
+	l, _, slept := newTestLimiter(conf.RateLimit{Rate: 1, Burst: 1, MaxWait: "2s"})
+	ctx := context.Background()
+
+	// the queue of waiting requests is bounded by max_wait
+	assert.Nil(t, l.Wait(ctx))
+	assert.Nil(t, l.Wait(ctx))
+	assert.Equal(t, *slept, time.Second)
+	assert.Nil(t, l.Wait(ctx))
+	assert.Equal(t, *slept, 2*time.Second)
+
+	// a request that would wait longer does not reserve a token. The last wait refilled one token.
+	l.tokens = -3
+	assert.NotNil(t, l.Wait(ctx))
+	assert.Equal(t, l.tokens, -2.0)
+	assert.Equal(t, l.Rejected(), uint64(1))
+	assert.Equal(t, *slept, 2*time.Second)
+
+	// nor does a request that would wait past its deadline
+	l.tokens = 0
+	deadline, cancel := context.WithDeadline(ctx, l.now().Add(500*time.Millisecond))
+	defer cancel()
+	assert.NotNil(t, l.Wait(deadline))
+	assert.Equal(t, l.tokens, 0.0)
+	assert.Equal(t, l.Rejected(), uint64(2))
+
+	// a request whose context is canceled while waiting returns its token
+	l.sleep = sleep
+	canceled, cancel2 := context.WithCancel(ctx)
+	cancel2()
+	assert.NotNil(t, l.Wait(canceled))
+	assert.Equal(t, l.tokens, 0.0)
+}
+
+func TestAIMD(t *testing.T) {
+	l, now, _ := newTestLimiter(conf.RateLimit{Rate: 20, MinRate: 4, SlowResponse: "2s"})
+	assert.Equal(t, l.Rate(), 20.0)
+
+	l.Observe(http.StatusServiceUnavailable, time.Millisecond, false)
+	assert.Equal(t, l.Rate(), 10.0)
+
+	// concurrent busy responses within adjustInterval only decrease once
+	l.Observe(http.StatusTooManyRequests, time.Millisecond, false)
+	assert.Equal(t, l.Rate(), 10.0)
+
+	*now = now.Add(adjustInterval)
+	l.Observe(http.StatusOK, 3*time.Second, false)
+	assert.Equal(t, l.Rate(), 5.0)
+
+	*now = now.Add(adjustInterval)
+	l.Observe(0, 30*time.Second, false)
+	assert.Equal(t, l.Rate(), 4.0)
+
+	// a CM reject is busy whatever its status and latency
+	*now = now.Add(adjustInterval)
+	l.Observe(http.StatusOK, 100*time.Millisecond, false)
+	rate := l.Rate()
+	*now = now.Add(adjustInterval)
+	l.Observe(http.StatusInternalServerError, time.Millisecond, true)
+	assert.True(t, l.Rate() < rate)
+
+	// healthy responses add increase per interval, up to the configured rate
+	for range 10 {
+		*now = now.Add(adjustInterval)
+		l.Observe(http.StatusOK, 100*time.Millisecond, false)
+	}
+	assert.Equal(t, l.Rate(), 20.0)
+}
+
+func TestFor(t *testing.T) {
+	assert.Nil(t, For(&conf.Poller{Name: "off", Addr: "a"}))
+
+	p := &conf.Poller{Name: "on", Addr: "a", RateLimit: conf.RateLimit{Rate: 5}}
+	l := For(p)
+	assert.NotNil(t, l)
+	assert.True(t, l == For(p))
+	assert.Equal(t, l.burst, 5.0)
+	assert.Equal(t, l.minRate, 0.5)
+	assert.Equal(t, l.slowResponse, DefaultSlowResponse)
+
+	// a nil limiter never blocks
+	var nilLimiter *Limiter
+	assert.Nil(t, nilLimiter.Wait(context.Background()))
+	nilLimiter.Observe(http.StatusTooManyRequests, 0, true)
+	assert.Equal(t, nilLimiter.Rate(), 0.0)
+}
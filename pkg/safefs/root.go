@@ -4,6 +4,7 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"strconv"
 )
 
 func WithRoot(dir string, fn func(root *os.Root) error) error {
@@ -31,6 +32,28 @@ func WriteFile(name string, data []byte, perm os.FileMode) error {
 	})
 }
 
+// WriteFileAtomic writes data to a temporary file next to name and renames it to name,
+// so readers never see a partially written file. The directory is created if needed.
+func WriteFileAtomic(name string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(name)
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return err
+	}
+	return WithRoot(dir, func(root *os.Root) error {
+		base := filepath.Base(name)
+		tmp := base + "." + strconv.Itoa(os.Getpid()) + ".tmp"
+		if err := root.WriteFile(tmp, data, perm); err != nil {
+			_ = root.Remove(tmp)
+			return err
+		}
+		if err := root.Rename(tmp, base); err != nil {
+			_ = root.Remove(tmp)
+			return err
+		}
+		return nil
+	})
+}
+
 func WalkDir(dir string, fn func(root *os.Root, path string, d fs.DirEntry) error) error {
 	return WithRoot(dir, func(root *os.Root) error {
 		return fs.WalkDir(root.FS(), ".", func(path string, d fs.DirEntry, err error) error {
@@ -0,0 +1,44 @@
+// Package statefile keeps the state of plugins and collectors across poller restarts in JSON files.
+package statefile
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/netapp/harvest/v2/pkg/conf"
+	"github.com/netapp/harvest/v2/pkg/safefs"
+)
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// Path returns the path of a state file in dir. The file is named after parts, like poller_parent_object.json,
+// with characters that are unsafe in file names replaced. A relative dir is relative to the Harvest home.
+func Path(dir string, parts ...string) string {
+	name := unsafeFileChars.ReplaceAllString(strings.Join(parts, "_"), "_")
+	return filepath.Join(conf.Path(dir), name+".json")
+}
+
+// Load reads the state file at path into v. A missing file is not an error and leaves v unchanged.
+func Load(path string, v any) error {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
+
+// Save writes v to the state file at path. The file is replaced atomically, so a crash never leaves a partial file.
+func Save(path string, v any) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return safefs.WriteFileAtomic(path, b, 0o600)
+}
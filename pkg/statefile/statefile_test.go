@@ -0,0 +1,29 @@
+package statefile
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/netapp/harvest/v2/assert"
+)
+
+func TestPath(t *testing.T) {
+	dir := t.TempDir()
+	assert.Equal(t, Path(dir, "cluster 1", "Rest", "volume/qtree"), filepath.Join(dir, "cluster_1_Rest_volume_qtree.json"))
+}
+
+func TestLoadSave(t *testing.T) {
+	type state struct {
+		Last int64 `json:"last"`
+	}
+	path := Path(filepath.Join(t.TempDir(), "state"), "p1", "EseriesEvents", "MEL")
+
+	// a missing file leaves the state unchanged
+	s := state{Last: -1}
+	assert.Nil(t, Load(path, &s))
+	assert.Equal(t, s.Last, int64(-1))
+
+	assert.Nil(t, Save(path, state{Last: 40215}))
+	assert.Nil(t, Load(path, &s))
+	assert.Equal(t, s.Last, int64(40215))
+}
@@ -0,0 +1,71 @@
+// Package subprocess runs commands in their own process group, so a command and the processes it starts are
+// killed together, and keeps the end of their stderr for errors.
+package subprocess
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// StderrTail is the number of bytes of stderr kept by a TailBuffer
+const StderrTail = 1024
+
+// Command returns a command that runs in its own process group. Canceling ctx kills the process group.
+func Command(ctx context.Context, command []string, env []string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, command[0], command[1:]...) //nolint:gosec
+	cmd.Env = env
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = time.Second
+	return cmd
+}
+
+// Kill kills the process group of a started command and waits for the command to exit.
+// A process group that has already exited is not an error.
+func Kill(cmd *exec.Cmd) error {
+	err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	_ = cmd.Wait()
+	if errors.Is(err, syscall.ESRCH) {
+		return nil
+	}
+	return err
+}
+
+// Error adds the end of the command's stderr to err
+func Error(err error, stderr *TailBuffer) error {
+	msg := strings.TrimSpace(stderr.String())
+	if msg == "" {
+		return err
+	}
+	return fmt.Errorf("%w stderr=%s", err, msg)
+}
+
+// TailBuffer keeps the last StderrTail bytes written to it. It is safe for concurrent use.
+type TailBuffer struct {
+	mu  sync.Mutex
+	buf []byte
+}
+
+func (b *TailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > StderrTail {
+		b.buf = b.buf[len(b.buf)-StderrTail:]
+	}
+	return len(p), nil
+}
+
+func (b *TailBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf)
+}
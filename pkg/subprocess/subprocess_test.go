@@ -0,0 +1,58 @@
+package subprocess
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/netapp/harvest/v2/assert"
+)
+
+func TestTailBuffer(t *testing.T) {
+	b := &TailBuffer{}
+	_, _ = b.Write([]byte(strings.Repeat("a", StderrTail)))
+	_, _ = b.Write([]byte("end"))
+	assert.Equal(t, len(b.String()), StderrTail)
+	assert.True(t, strings.HasSuffix(b.String(), "aend"))
+
+	err := errors.New("exit status 1")
+	assert.Equal(t, Error(err, &TailBuffer{}), err)
+	wrapped := Error(err, b)
+	assert.True(t, errors.Is(wrapped, err))
+	assert.True(t, strings.HasSuffix(wrapped.Error(), " stderr="+b.String()))
+}
+
+func TestKillProcessGroup(t *testing.T) {
+	// sleep keeps stdout open, so Wait only returns before WaitDelay when sleep is killed along with the shell
+	command := []string{"sh", "-c", "sleep 60 & echo started; wait"}
+	started := func(stdout *TailBuffer) {
+		for stdout.String() == "" {
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	cmd := Command(context.Background(), command, nil)
+	stdout := &TailBuffer{}
+	cmd.Stdout = stdout
+	assert.Nil(t, cmd.Start())
+	started(stdout)
+
+	start := time.Now()
+	assert.Nil(t, Kill(cmd))
+	assert.True(t, time.Since(start) < cmd.WaitDelay/2)
+
+	// canceling the context kills the group too
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd = Command(ctx, command, nil)
+	stdout = &TailBuffer{}
+	cmd.Stdout = stdout
+	assert.Nil(t, cmd.Start())
+	started(stdout)
+
+	start = time.Now()
+	cancel()
+	assert.NotNil(t, cmd.Wait())
+	assert.True(t, time.Since(start) < cmd.WaitDelay/2)
+}
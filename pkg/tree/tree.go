@@ -63,7 +63,7 @@ func consume(r *node.Node, key string, y ast.Node, makeNewChild bool) {
 		for _, child := range sn.Values {
 			makeNewChild := false
 			if child.Type() == ast.MappingType {
-				makeNewChild = key == "endpoints" || key == "events" || key == "matches"
+				makeNewChild = key == "endpoints" || key == "events" || key == "matches" || key == "rules"
 			}
 			consume(s, "", child, makeNewChild)
 		}